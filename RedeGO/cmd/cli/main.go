@@ -54,7 +54,7 @@ func main() {
 
 	// Inicia interface TUI
 	fmt.Println("\n⏳ Carregando interface interativa...")
-	p := tea.NewProgram(initialModel(redeService, cnpj), tea.WithAltScreen())
+	p := tea.NewProgram(initialModel(cfg, redeService, cnpj), tea.WithAltScreen())
 	
 	if _, err := p.Run(); err != nil {
 		log.Fatalf("Erro ao executar TUI: %v", err)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,7 +9,11 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/analytics"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/casos"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/config"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/export"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/forensics"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/services"
 )
@@ -29,6 +34,10 @@ const (
 	modeCadeiaControle
 	modeTimeline
 	modeEmpresaDetalhes
+	modeForensicsEmpresa
+	modeSalvarCaso
+	modeCasos
+	modeClustersEndereco
 )
 
 type nodeItem struct {
@@ -39,6 +48,7 @@ type nodeItem struct {
 }
 
 type model struct {
+	cfg         *config.Config
 	redeService *services.RedeService
 	rootCNPJ    string
 	items       []nodeItem
@@ -49,8 +59,10 @@ type model struct {
 	mode        viewMode
 	message     string
 	stats       *analytics.GraphStats
+	algCentralidade int // índice em analytics.AlgoritmosCentralidade
+	algComunidades  int // índice em analytics.AlgoritmosComunidades
 	currentGraph *models.Graph
-	exportMenu   int // 0=excel, 1=csv_nodes, 2=csv_edges, 3=csv_stats
+	exportMenu   int // 0=excel, 1=csv_nodes, 2=csv_edges, 3=csv_stats, 4=i2, 5=graphml, 6=gexf, 7=cytoscape
 	crossMenu    int // menu de cruzamentos
 	crossInput   string // input para cruzamentos
 	crossResults []map[string]interface{} // resultados
@@ -59,10 +71,20 @@ type model struct {
 	viewData     string // dados para visualização
 	selectedEmpresaCursor int // cursor para seleção de empresa
 	selectedEmpresaCNPJ   string // CNPJ da empresa selecionada
+	casos        *casos.Store // nil sem base_local
+	casoAtual    *casos.Caso  // caso aberto ou salvo por último
+	casosLista   []casos.Caso
+	casoCursor   int
+	casoInput    string // nome do caso a salvar
+	clustersEndereco     []forensics.CompanyCluster
+	clusterCursor        int
+	clusterDetalhe       bool   // exibe as empresas do cluster selecionado
+	clusterGranularidade string // endereco.GranularidadePredio ou GranularidadeSala
 }
 
-func initialModel(redeService *services.RedeService, cnpj string) model {
-	return model{
+func initialModel(cfg *config.Config, redeService *services.RedeService, cnpj string) model {
+	m := model{
+		cfg:         cfg,
 		redeService: redeService,
 		rootCNPJ:    cnpj,
 		items:       []nodeItem{},
@@ -70,15 +92,27 @@ func initialModel(redeService *services.RedeService, cnpj string) model {
 		mode:        modeTree,
 		exportMenu:  0,
 	}
+	if db := database.GetDBLocal(); db != nil {
+		m.casos = casos.NewStore(db)
+	}
+	return m
 }
 
 func (m model) Init() tea.Cmd {
 	return m.loadRoot()
 }
 
+// contextoConsulta cria um contexto limitado por TempoMaximoConsulta
+func (m model) contextoConsulta() (context.Context, context.CancelFunc) {
+	return services.ContextoConsulta(context.Background(), m.cfg)
+}
+
 func (m model) loadRoot() tea.Cmd {
 	return func() tea.Msg {
-		graph, err := m.redeService.CamadasRede(1, []string{m.rootCNPJ}, "", "")
+		ctx, cancel := m.contextoConsulta()
+		defer cancel()
+
+		graph, err := m.redeService.CamadasRede(ctx, 1, []string{m.rootCNPJ}, "", "")
 		if err != nil {
 			return errMsg{err}
 		}
@@ -88,7 +122,10 @@ func (m model) loadRoot() tea.Cmd {
 
 func (m model) expandNode(nodeID string) tea.Cmd {
 	return func() tea.Msg {
-		graph, err := m.redeService.CamadasRede(1, []string{nodeID}, "", "")
+		ctx, cancel := m.contextoConsulta()
+		defer cancel()
+
+		graph, err := m.redeService.CamadasRede(ctx, 1, []string{nodeID}, "", "")
 		if err != nil {
 			return errMsg{err}
 		}
@@ -161,6 +198,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateTimeline(msg)
 		case modeEmpresaDetalhes:
 			return m.updateEmpresaDetalhes(msg)
+		case modeForensicsEmpresa:
+			return m.updateForensicsEmpresa(msg)
+		case modeSalvarCaso:
+			return m.updateSalvarCaso(msg)
+		case modeCasos:
+			return m.updateCasos(msg)
+		case modeClustersEndereco:
+			return m.updateClustersEndereco(msg)
 		}
 
 	case graphMsg:
@@ -256,6 +301,8 @@ func (m model) updateTree(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.mode = modeAnalytics
 			analyzer := analytics.NewAnalyzer()
 			m.stats = analyzer.AnalyzeGraph(m.currentGraph)
+			m.calcularCentralidade()
+			m.calcularComunidades()
 			m.message = "✓ Estatísticas calculadas"
 		} else {
 			m.message = "✗ Carregue um grafo primeiro"
@@ -268,6 +315,10 @@ func (m model) updateTree(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		} else {
 			m.message = "✗ Carregue um grafo primeiro"
 		}
+	case "s":
+		return m.iniciarSalvarCaso()
+	case "o":
+		return m.abrirListaCasos()
 	case "c":
 		m.mode = modeCrossData
 		m.crossMenu = 0
@@ -286,10 +337,48 @@ func (m model) updateAnalytics(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "q", "backspace":
 		m.mode = modeTree
 		m.message = "Voltou ao modo árvore"
+	case "c":
+		m.algCentralidade = (m.algCentralidade + 1) % len(analytics.AlgoritmosCentralidade)
+		m.calcularCentralidade()
+	case "m":
+		m.algComunidades = (m.algComunidades + 1) % len(analytics.AlgoritmosComunidades)
+		m.calcularComunidades()
 	}
 	return m, nil
 }
 
+// calcularCentralidade preenche m.stats.Centralidade com o algoritmo
+// selecionado
+func (m *model) calcularCentralidade() {
+	if m.stats == nil || m.currentGraph == nil {
+		return
+	}
+	algoritmo := analytics.AlgoritmosCentralidade[m.algCentralidade]
+	resultado, err := analytics.NewAnalyzer().Centralidade(m.currentGraph, algoritmo)
+	if err != nil {
+		m.message = fmt.Sprintf("✗ Erro na centralidade: %v", err)
+		return
+	}
+	m.stats.Centralidade = resultado
+	m.message = fmt.Sprintf("✓ Centralidade: %s", algoritmo)
+}
+
+// calcularComunidades preenche m.stats.Comunidades com o algoritmo
+// selecionado
+func (m *model) calcularComunidades() {
+	if m.stats == nil || m.currentGraph == nil {
+		return
+	}
+	algoritmo := analytics.AlgoritmosComunidades[m.algComunidades]
+	resultado, err := analytics.NewAnalyzer().Comunidades(m.currentGraph, algoritmo)
+	if err != nil {
+		m.message = fmt.Sprintf("✗ Erro nas comunidades: %v", err)
+		return
+	}
+	m.stats.Comunidades = resultado
+	m.message = fmt.Sprintf("✓ Comunidades: %s", algoritmo)
+}
+
 func (m model) updateHelp(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "q", "backspace", "F1", "?":
@@ -306,7 +395,7 @@ func (m model) updateExport(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.exportMenu--
 		}
 	case "down", "j":
-		if m.exportMenu < 3 {
+		if m.exportMenu < 7 {
 			m.exportMenu++
 		}
 	case "enter", " ":
@@ -407,6 +496,58 @@ func (m model) doExport() string {
 			return ""
 		}
 		return filename
+
+	case 4: // i2 Analyst's Notebook
+		exporter := export.NewI2Exporter()
+		data, err := exporter.ExportGraph(m.currentGraph)
+		if err != nil {
+			return ""
+		}
+
+		filename := filepath.Join(outputDir, "rede-cnpj.anx")
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			return ""
+		}
+		return filename
+
+	case 5: // GraphML
+		exporter := export.NewGraphMLExporter()
+		data, err := exporter.ExportGraph(m.currentGraph)
+		if err != nil {
+			return ""
+		}
+
+		filename := filepath.Join(outputDir, "rede-cnpj.graphml")
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			return ""
+		}
+		return filename
+
+	case 6: // GEXF (Gephi)
+		exporter := export.NewGEXFExporter()
+		data, err := exporter.ExportGraph(m.currentGraph)
+		if err != nil {
+			return ""
+		}
+
+		filename := filepath.Join(outputDir, "rede-cnpj.gexf")
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			return ""
+		}
+		return filename
+
+	case 7: // Cytoscape.js
+		exporter := export.NewCytoscapeExporter()
+		data, err := exporter.ExportGraph(m.currentGraph)
+		if err != nil {
+			return ""
+		}
+
+		filename := filepath.Join(outputDir, "rede-cnpj.cyjs")
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			return ""
+		}
+		return filename
 	}
 
 	return ""
@@ -492,6 +633,14 @@ func (m model) View() string {
 		return m.viewTimeline(m.viewData)
 	case modeEmpresaDetalhes:
 		return m.viewEmpresaDetalhes(m.selectedEmpresaCNPJ)
+	case modeForensicsEmpresa:
+		return m.viewForensicsEmpresa(m.viewData)
+	case modeSalvarCaso:
+		return m.viewSalvarCaso()
+	case modeCasos:
+		return m.viewCasos()
+	case modeClustersEndereco:
+		return m.viewClustersEndereco()
 	}
 
 	return ""
@@ -573,7 +722,8 @@ func (m model) viewTree() string {
 	s += "┌──────────────────────────────────────────────────────────────────────┐\n"
 	s += "│ NAVEGAÇÃO: ↑↓ mover | → expandir | ← colapsar                       │\n"
 	s += "│ AÇÕES: [A]nalytics | [B]uscar CPF/CNPJ | [C]ruzamentos             │\n"
-	s += "│        [E]xportar | [F1/?] Ajuda | [ESC] Voltar | [F10] Sair       │\n"
+	s += "│        [E]xportar | [S]alvar caso | [O] Abrir caso                 │\n"
+	s += "│        [F1/?] Ajuda | [ESC] Voltar | [F10] Sair                    │\n"
 	s += "└──────────────────────────────────────────────────────────────────────┘\n"
 
 	return s
@@ -629,10 +779,59 @@ func (m model) viewAnalytics() string {
 			count++
 		}
 		s += "└────────────────────────────────────────────────────────────────────┘\n"
+
+		if c := m.stats.Centralidade; c != nil {
+			s += "\n"
+			s += fmt.Sprintf("┌─ CENTRALIDADE (%s) %s┐\n", c.Algoritmo, strings.Repeat("─", max(0, 50-len(c.Algoritmo))))
+			for i, node := range c.Top(10) {
+				label := node.Label
+				if label == "" {
+					label = node.ID
+				}
+				if len(label) > 40 {
+					label = label[:37] + "..."
+				}
+				s += fmt.Sprintf("│ %2d. %-40s %10.4f [%3d conexões] │\n", i+1, label, node.Score, node.Degree)
+			}
+			s += "└────────────────────────────────────────────────────────────────────┘\n"
+		}
+
+		if c := m.stats.Comunidades; c != nil {
+			s += "\n"
+			s += fmt.Sprintf("┌─ COMUNIDADES (%s) %s┐\n", c.Algoritmo, strings.Repeat("─", max(0, 51-len(c.Algoritmo))))
+			s += fmt.Sprintf("│ Comunidades: %6d     Modularidade: %.4f                       │\n", len(c.Comunidades), c.Modularidade)
+			labels := make(map[string]string, len(c.Nos))
+			for _, n := range c.Nos {
+				labels[n.ID] = n.Label
+			}
+			for i, comunidade := range c.Comunidades {
+				if i >= 10 {
+					break
+				}
+				// Exemplos de membros da comunidade
+				var exemplos []string
+				for _, id := range comunidade.Nos {
+					if len(exemplos) == 2 {
+						break
+					}
+					label := labels[id]
+					if label == "" {
+						label = id
+					}
+					if len(label) > 20 {
+						label = label[:17] + "..."
+					}
+					exemplos = append(exemplos, label)
+				}
+				s += fmt.Sprintf("│ #%-3d %5d nós  %-45s │\n", comunidade.ID+1, comunidade.Tamanho, strings.Join(exemplos, ", "))
+			}
+			s += "└────────────────────────────────────────────────────────────────────┘\n"
+		}
 	}
 
 	s += "\n"
 	s += "┌──────────────────────────────────────────────────────────────────────┐\n"
+	s += "│ [C] Alterna centralidade   [M] Alterna comunidades                  │\n"
 	s += "│ Pressione [Q] ou [BACKSPACE] para voltar                            │\n"
 	s += "└──────────────────────────────────────────────────────────────────────┘\n"
 
@@ -652,6 +851,10 @@ func (m model) viewExport() string {
 		"📄 CSV - Nós (lista de entidades)",
 		"📄 CSV - Arestas (lista de relacionamentos)",
 		"📄 CSV - Estatísticas (resumo do grafo)",
+		"🕵️ i2 Analyst's Notebook (ANX) - Gráfico com ícones e posições",
+		"🔗 GraphML - Gephi, yEd, networkx, igraph",
+		"🔗 GEXF - Gephi (tipo, situação e camada como atributos)",
+		"🔗 Cytoscape.js (JSON) - Cytoscape e navegador",
 	}
 
 	for i, opt := range options {
@@ -685,6 +888,8 @@ func (m model) viewHelp() string {
 	s += "│  ← / h          - Colapsar nó selecionado                           │\n"
 	s += "│  a              - Ver Analytics (estatísticas do grafo)             │\n"
 	s += "│  e              - Exportar dados (Excel ou CSV)                     │\n"
+	s += "│  s              - Salvar o grafo como caso (versionado)             │\n"
+	s += "│  o              - Abrir um caso salvo                               │\n"
 	s += "│  F1 / ?         - Mostrar esta ajuda                                │\n"
 	s += "│  ESC            - Voltar ao menu principal                          │\n"
 	s += "│  F10            - Sair do programa                                  │\n"
@@ -699,7 +904,11 @@ func (m model) viewHelp() string {
 	s += "│  - Densidade e grau médio da rede                                   │\n"
 	s += "│  - Nós mais conectados (top 10)                                     │\n"
 	s += "│  - Tipos de relacionamento                                          │\n"
+	s += "│  - Centralidade (betweenness, PageRank, closeness, eigenvector)     │\n"
+	s += "│  - Comunidades (Louvain, Leiden, componentes conexos)               │\n"
 	s += "│                                                                      │\n"
+	s += "│  C              - Alternar algoritmo de centralidade                │\n"
+	s += "│  M              - Alternar algoritmo de comunidades                 │\n"
 	s += "│  Q / BACKSPACE  - Voltar ao modo árvore                             │\n"
 	s += "│                                                                      │\n"
 	s += "└──────────────────────────────────────────────────────────────────────┘\n\n"
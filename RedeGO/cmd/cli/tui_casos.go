@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/casos"
+)
+
+// iniciarSalvarCaso abre o campo com o nome do caso a salvar
+func (m model) iniciarSalvarCaso() (tea.Model, tea.Cmd) {
+	switch {
+	case m.casos == nil:
+		m.message = "✗ Base local não configurada (base_local em rede.ini)"
+	case m.currentGraph == nil || len(m.currentGraph.Nodes) == 0:
+		m.message = "✗ Carregue um grafo primeiro"
+	default:
+		m.mode = modeSalvarCaso
+		if m.casoAtual != nil {
+			m.casoInput = m.casoAtual.Nome
+		} else {
+			m.casoInput = "Caso " + m.rootCNPJ
+		}
+		m.message = "Digite o nome do caso"
+	}
+	return m, nil
+}
+
+// abrirListaCasos carrega a lista de casos salvos
+func (m model) abrirListaCasos() (tea.Model, tea.Cmd) {
+	if m.casos == nil {
+		m.message = "✗ Base local não configurada (base_local em rede.ini)"
+		return m, nil
+	}
+	lista, err := m.casos.Listar(context.Background())
+	if err != nil {
+		m.message = fmt.Sprintf("✗ Erro ao listar casos: %v", err)
+		return m, nil
+	}
+	if len(lista) == 0 {
+		m.message = "Nenhum caso salvo. Use [S] para salvar o grafo atual"
+		return m, nil
+	}
+	m.casosLista = lista
+	m.casoCursor = 0
+	m.mode = modeCasos
+	m.message = fmt.Sprintf("%d casos salvos", len(lista))
+	return m, nil
+}
+
+// salvarCaso grava o grafo atual. Um nome já existente recebe nova versão.
+func (m *model) salvarCaso() error {
+	ctx := context.Background()
+	nome := m.casoInput
+
+	if m.casoAtual != nil && m.casoAtual.Nome == nome {
+		caso, err := m.casos.Atualizar(ctx, m.casoAtual.ID, casos.AtualizacaoCaso{Grafo: m.currentGraph, Comentario: "TUI"})
+		if err != nil {
+			return err
+		}
+		m.casoAtual = caso
+		return nil
+	}
+
+	caso, err := m.casos.Criar(ctx, nome, "", m.currentGraph)
+	if errors.Is(err, casos.ErrNomeDuplicado) {
+		lista, errLista := m.casos.Listar(ctx)
+		if errLista != nil {
+			return errLista
+		}
+		for _, c := range lista {
+			if c.Nome == nome {
+				caso, err = m.casos.Atualizar(ctx, c.ID, casos.AtualizacaoCaso{Grafo: m.currentGraph, Comentario: "TUI"})
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	m.casoAtual = caso
+	return nil
+}
+
+func (m model) updateSalvarCaso(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		if err := m.salvarCaso(); err != nil {
+			m.message = fmt.Sprintf("✗ Erro ao salvar caso: %v", err)
+			return m, nil
+		}
+		m.mode = modeTree
+		m.message = fmt.Sprintf("✓ Caso \"%s\" salvo (versão %d)", m.casoAtual.Nome, m.casoAtual.Versao)
+	case tea.KeyBackspace:
+		if len(m.casoInput) > 0 {
+			_, tamanho := utf8.DecodeLastRuneInString(m.casoInput)
+			m.casoInput = m.casoInput[:len(m.casoInput)-tamanho]
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		if utf8.RuneCountInString(m.casoInput) < 60 {
+			m.casoInput += string(msg.Runes)
+		}
+	}
+	return m, nil
+}
+
+func (m model) updateCasos(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.casoCursor > 0 {
+			m.casoCursor--
+		}
+	case "down", "j":
+		if m.casoCursor < len(m.casosLista)-1 {
+			m.casoCursor++
+		}
+	case "enter":
+		caso, err := m.casos.Carregar(context.Background(), m.casosLista[m.casoCursor].ID, 0)
+		if err != nil {
+			m.message = fmt.Sprintf("✗ Erro ao abrir caso: %v", err)
+			return m, nil
+		}
+		m.casoAtual = caso
+		m.mode = modeTree
+		m.cursor = 0
+		grafo := caso.Grafo
+		return m, func() tea.Msg { return graphMsg{grafo} }
+	case "q", "backspace":
+		m.mode = modeTree
+		m.message = "Voltou ao modo árvore"
+	}
+	return m, nil
+}
+
+func (m model) viewSalvarCaso() string {
+	s := "\n"
+	s += "╔══════════════════════════════════════════════════════════════════════╗\n"
+	s += "║         💾 RedeCNPJ - Salvar Caso                                    ║\n"
+	s += "╚══════════════════════════════════════════════════════════════════════╝\n\n"
+
+	s += fmt.Sprintf("Grafo atual: %d nós, %d ligações\n\n", len(m.currentGraph.Nodes), len(m.currentGraph.Edges))
+	s += "Nome do caso (um nome existente recebe uma nova versão):\n\n"
+	s += "┌──────────────────────────────────────────────────────────────────────┐\n"
+	s += fmt.Sprintf("│ > %-67s│\n", m.casoInput)
+	s += "└──────────────────────────────────────────────────────────────────────┘\n\n"
+
+	s += "┌──────────────────────────────────────────────────────────────────────┐\n"
+	s += "│ [ENTER] Salvar | [ESC] Cancelar                                      │\n"
+	s += "└──────────────────────────────────────────────────────────────────────┘\n"
+
+	if m.message != "" {
+		s += fmt.Sprintf("\n💬 %s\n", m.message)
+	}
+	return s
+}
+
+func (m model) viewCasos() string {
+	s := "\n"
+	s += "╔══════════════════════════════════════════════════════════════════════╗\n"
+	s += "║         📂 RedeCNPJ - Casos Salvos                                   ║\n"
+	s += "╚══════════════════════════════════════════════════════════════════════╝\n\n"
+
+	for i, caso := range m.casosLista {
+		cursor := "  "
+		if i == m.casoCursor {
+			cursor = "→ "
+		}
+		nome := caso.Nome
+		if utf8.RuneCountInString(nome) > 36 {
+			nome = string([]rune(nome)[:33]) + "..."
+		}
+		s += fmt.Sprintf("%s%-36s v%-3d %5d nós  %s\n", cursor, nome, caso.Versao, caso.TotalNos,
+			caso.AtualizadoEm.Local().Format("02/01/2006 15:04"))
+	}
+
+	s += "\n"
+	s += "┌──────────────────────────────────────────────────────────────────────┐\n"
+	s += "│ ↑↓ Selecionar | [ENTER] Abrir | [Q] Voltar                           │\n"
+	s += "└──────────────────────────────────────────────────────────────────────┘\n"
+
+	if m.message != "" {
+		s += fmt.Sprintf("\n💬 %s\n", m.message)
+	}
+	return s
+}
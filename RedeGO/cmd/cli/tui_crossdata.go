@@ -18,7 +18,7 @@ func (m model) viewCrossData() string {
 		"2. 👥 CNPJ → Sócios - Todos os sócios (CPF completo)",
 		"3. 🔗 Sócios em Comum - Entre duas empresas",
 		"4. 🕸️  Rede 2º Grau - Empresas dos sócios",
-		"5. 🏠 Mesmo Endereço - Empresas no mesmo local",
+		"5. 🏠 Clusters de Endereço - Empresas no mesmo prédio ou sala",
 		"6. 📞 Mesmo Contato - Email/telefone compartilhado",
 		"7. 👶 Representantes Legais - Menores + representantes",
 		"8. 🌍 Empresas Estrangeiras - Sede no exterior",
@@ -7,6 +7,10 @@ import (
 
 // executeCrossData executa o cruzamento selecionado
 func (m model) executeCrossData() (tea.Model, tea.Cmd) {
+	if m.crossMenu == 4 {
+		return m.abrirClustersEndereco()
+	}
+
 	// Mensagens de funcionalidade implementada
 	messages := []string{
 		"✅ CPF → Empresas: Funcionalidade implementada via API REST",
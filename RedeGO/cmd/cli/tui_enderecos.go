@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/forensics"
+)
+
+// abrirClustersEndereco carrega os clusters de endereço na granularidade
+// atual (prédio por padrão)
+func (m model) abrirClustersEndereco() (tea.Model, tea.Cmd) {
+	if m.clusterGranularidade == "" {
+		m.clusterGranularidade = endereco.GranularidadePredio
+	}
+
+	modelo, err := forensics.CarregarModeloRisco(m.cfg.ModeloRisco)
+	if err != nil {
+		m.message = fmt.Sprintf("✗ Erro no modelo de risco: %v", err)
+		return m, nil
+	}
+	inv := forensics.NewInvestigator(database.GetDBReceita(), database.NewDialect()).
+		ComModelo(modelo).
+		ComEnderecos(database.GetDBEndereco())
+
+	ctx, cancel := m.contextoConsulta()
+	defer cancel()
+
+	clusters, err := inv.DetectShellCompanies(ctx, endereco.ConsultaClusters{
+		Granularidade: m.clusterGranularidade,
+		Limite:        30,
+	})
+	if err != nil {
+		m.mode = modeTree
+		m.message = fmt.Sprintf("✗ Erro nos clusters de endereço: %v", err)
+		return m, nil
+	}
+
+	m.clustersEndereco = clusters
+	m.clusterCursor = 0
+	m.clusterDetalhe = false
+	m.mode = modeClustersEndereco
+	m.message = fmt.Sprintf("%d clusters por %s", len(clusters), m.clusterGranularidade)
+	return m, nil
+}
+
+func (m model) updateClustersEndereco(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.clusterCursor > 0 {
+			m.clusterCursor--
+		}
+	case "down", "j":
+		if m.clusterCursor < len(m.clustersEndereco)-1 {
+			m.clusterCursor++
+		}
+	case "enter", " ":
+		m.clusterDetalhe = !m.clusterDetalhe
+	case "g":
+		if m.clusterGranularidade == endereco.GranularidadeSala {
+			m.clusterGranularidade = endereco.GranularidadePredio
+		} else {
+			m.clusterGranularidade = endereco.GranularidadeSala
+		}
+		return m.abrirClustersEndereco()
+	case "q", "backspace":
+		m.mode = modeTree
+		m.message = "Voltou ao modo árvore"
+	}
+	return m, nil
+}
+
+func (m model) viewClustersEndereco() string {
+	s := "\n"
+	s += "╔══════════════════════════════════════════════════════════════════════╗\n"
+	s += "║         🏠 RedeCNPJ - Clusters de Endereço                           ║\n"
+	s += "╚══════════════════════════════════════════════════════════════════════╝\n\n"
+
+	s += fmt.Sprintf("Agrupamento por %s | ranking por densidade, baixadas e aberturas recentes\n\n", m.clusterGranularidade)
+	s += "   Score  Empr.  Baix.%  Rec.  Endereço\n"
+
+	for i, c := range m.clustersEndereco {
+		cursor := "  "
+		if i == m.clusterCursor {
+			cursor = "→ "
+		}
+		e := c.Endereco
+		s += fmt.Sprintf("%s %5.2f  %5d  %5.1f  %4d  %s\n", cursor, e.Score, e.TotalEmpresas,
+			e.PercentualBaixadas, e.AberturasRecentes, truncate(e.Endereco, 44))
+	}
+
+	if m.clusterDetalhe && m.clusterCursor < len(m.clustersEndereco) {
+		c := m.clustersEndereco[m.clusterCursor]
+		s += "\n┌─ EMPRESAS DO CLUSTER ──────────────────────────────────────────────┐\n"
+		s += fmt.Sprintf("│ %-66s │\n", truncate(c.Endereco.Endereco, 66))
+		s += fmt.Sprintf("│ %d estabelecimentos, %d ativos, %d baixados, %d sócios, risco %3d    │\n",
+			c.Endereco.Estabelecimentos, c.Endereco.Ativas, c.Endereco.Baixadas, c.TotalSocios, c.Score)
+		for i, emp := range c.Empresas {
+			if i >= 10 {
+				s += fmt.Sprintf("│ ... e mais %d estabelecimentos                                    │\n", c.Endereco.Estabelecimentos-10)
+				break
+			}
+			sitIcon := "✅"
+			if situacao := getString(emp, "situacao"); situacao == "08" {
+				sitIcon = "❌"
+			} else if situacao != "02" {
+				sitIcon = "⚠️"
+			}
+			s += fmt.Sprintf("│ %s %s %-10s - %-30s │\n", sitIcon, getString(emp, "cnpj"),
+				getString(emp, "data_abertura"), truncate(getString(emp, "razao_social"), 30))
+		}
+		for _, flag := range c.Flags {
+			s += fmt.Sprintf("│ ⚠️  %-66s │\n", truncate(flag, 66))
+		}
+		s += "└────────────────────────────────────────────────────────────────────┘\n"
+	}
+
+	s += "\n"
+	s += "┌──────────────────────────────────────────────────────────────────────┐\n"
+	s += "│ ↑↓ Selecionar | [ENTER] Empresas | [G] Prédio/Sala | [Q] Voltar      │\n"
+	s += "└──────────────────────────────────────────────────────────────────────┘\n"
+
+	if m.message != "" {
+		s += fmt.Sprintf("\n💬 %s\n", m.message)
+	}
+	return s
+}
@@ -5,13 +5,23 @@ import (
 	"strings"
 
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/crossdata"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/forensics"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/identidade"
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/cpfcnpj"
 )
 
 // viewForensicsInvestigate exibe perfil completo de investigação
 func (m model) viewForensicsInvestigate(cpf string) string {
-	inv := forensics.NewInvestigator("bases/cnpj.db", "bases/rede.db")
-	profile, err := inv.InvestigatePerson(cpf)
+	ctx, cancel := m.contextoConsulta()
+	defer cancel()
+
+	modelo, err := forensics.CarregarModeloRisco(m.cfg.ModeloRisco)
+	if err != nil {
+		return fmt.Sprintf("\n❌ ERRO: %v\n", err)
+	}
+	inv := forensics.NewInvestigator(database.GetDBReceita(), database.NewDialect()).ComModelo(modelo)
+	profile, err := inv.InvestigatePerson(ctx, cpf)
 	
 	if err != nil {
 		return fmt.Sprintf("\n❌ ERRO: %v\n", err)
@@ -52,6 +62,9 @@ func (m model) viewForensicsInvestigate(cpf string) string {
 	s += fmt.Sprintf("│ Rede Bancária:          %6d empresas conectadas                 │\n", profile.RedeBancaria)
 	s += "└────────────────────────────────────────────────────────────────────┘\n\n"
 
+	// Composição do score
+	s += viewComposicaoScore(profile.Pontuacao)
+
 	// Flags
 	if len(profile.Flags) > 0 {
 		s += "┌─ ALERTAS ──────────────────────────────────────────────────────────┐\n"
@@ -85,15 +98,139 @@ func (m model) viewForensicsInvestigate(cpf string) string {
 		s += "└────────────────────────────────────────────────────────────────────┘\n"
 	}
 
+	// Resolução de identidade (CPF mascarado)
+	if id := profile.Identidades; id != nil && (len(id.Sugestoes) > 0 || id.Homonimos) {
+		s += "\n┌─ POSSÍVEL MESMA PESSOA ────────────────────────────────────────────┐\n"
+		if id.Homonimos {
+			s += fmt.Sprintf("│ ⚠️  Faixas etárias %-47s │\n", truncate(strings.Join(id.FaixasEtarias, ", ")+": possíveis homônimos", 47))
+		}
+		for _, sug := range id.Sugestoes {
+			icone := "🟡"
+			if sug.Classe == identidade.ClasseMesmaPessoa {
+				icone = "🟢"
+			}
+			s += fmt.Sprintf("│ %s %.2f %s %-47s │\n", icone, sug.Score, sug.CPF, truncate(sug.Nome, 47))
+		}
+		s += "└────────────────────────────────────────────────────────────────────┘\n"
+	}
+
 	s += "\n[Q] Voltar | [D] Ver Detalhes de Empresa | [T] Timeline\n"
 
 	return s
 }
 
+// viewForensicsEmpresa exibe o perfil de risco de uma empresa
+func (m model) viewForensicsEmpresa(cnpj string) string {
+	ctx, cancel := m.contextoConsulta()
+	defer cancel()
+
+	modelo, err := forensics.CarregarModeloRisco(m.cfg.ModeloRisco)
+	if err != nil {
+		return fmt.Sprintf("\n❌ ERRO: %v\n", err)
+	}
+	inv := forensics.NewInvestigator(database.GetDBReceita(), database.NewDialect()).
+		ComModelo(modelo).
+		ComEnderecos(database.GetDBEndereco())
+	profile, err := inv.InvestigateCompany(ctx, cnpj)
+
+	if err != nil {
+		return fmt.Sprintf("\n❌ ERRO: %v\n", err)
+	}
+
+	s := "\n"
+	s += "╔══════════════════════════════════════════════════════════════════════╗\n"
+	s += "║         🔍 INVESTIGAÇÃO FORENSE - PERFIL DA EMPRESA                 ║\n"
+	s += "╚══════════════════════════════════════════════════════════════════════╝\n\n"
+
+	// Identificação
+	s += "┌─ IDENTIFICAÇÃO ────────────────────────────────────────────────────┐\n"
+	s += fmt.Sprintf("│ CNPJ: %-60s │\n", profile.CNPJ)
+	s += fmt.Sprintf("│ Razão Social: %-52s │\n", truncate(profile.RazaoSocial, 52))
+	s += fmt.Sprintf("│ Situação: %-10s Abertura: %-10s Situação desde: %-10s│\n",
+		profile.SituacaoCadastral, profile.DataInicioAtividades, profile.DataSituacaoCadastral)
+	s += "└────────────────────────────────────────────────────────────────────┘\n\n"
+
+	// Score de risco
+	scoreBar := getScoreBar(profile.Score)
+	scoreLevel := getScoreLevel(profile.Score)
+	s += "┌─ SCORE DE RISCO ───────────────────────────────────────────────────┐\n"
+	s += fmt.Sprintf("│ %s %3d/100 - %s%s │\n", scoreBar, profile.Score, scoreLevel, strings.Repeat(" ", 35-len(scoreLevel)))
+	s += "└────────────────────────────────────────────────────────────────────┘\n\n"
+
+	// Indicadores
+	saidos := "sem histórico"
+	if profile.SociosSaidos != nil {
+		saidos = fmt.Sprintf("%d", *profile.SociosSaidos)
+	}
+	mediana := "amostra insuficiente"
+	if profile.CapitalMedianoCNAE > 0 {
+		mediana = fmt.Sprintf("R$ %.2f (CNAE %s)", profile.CapitalMedianoCNAE, profile.CNAEFiscal)
+	}
+	s += "┌─ INDICADORES DE RISCO ─────────────────────────────────────────────┐\n"
+	s += fmt.Sprintf("│ Sócios:                 %-42s │\n", fmt.Sprintf("%d (%d entraram nos últimos 12 meses)", profile.TotalSocios, profile.SociosEntradaRecente))
+	s += fmt.Sprintf("│ Sócios que saíram:      %-42s │\n", saidos)
+	s += fmt.Sprintf("│ Até 20 / acima de 70:   %6d / %-33d │\n", profile.SociosMenores, profile.SociosIdosos)
+	s += fmt.Sprintf("│ Sócios em fachadas:     %6d                                     │\n", profile.SociosEmCluster)
+	s += fmt.Sprintf("│ Baixadas dos sócios:    %6d                                     │\n", profile.BaixadasRelacionadas)
+	s += fmt.Sprintf("│ Mesmo endereço:         %6d empresas ativas                     │\n", profile.EmpresasMesmoEndereco)
+	s += fmt.Sprintf("│ Capital social:         R$ %-39.2f │\n", profile.CapitalSocial)
+	s += fmt.Sprintf("│ Mediana do CNAE:        %-42s │\n", mediana)
+	if profile.DataExclusaoSimples != "" || profile.DataExclusaoMEI != "" {
+		s += fmt.Sprintf("│ Exclusão Simples/MEI:   %-10s / %-29s │\n", profile.DataExclusaoSimples, profile.DataExclusaoMEI)
+	}
+	if profile.DataReativacao != "" {
+		s += fmt.Sprintf("│ Reativada em:           %-42s │\n", profile.DataReativacao)
+	}
+	s += "└────────────────────────────────────────────────────────────────────┘\n\n"
+
+	// Composição do score
+	s += viewComposicaoScore(profile.Pontuacao)
+
+	// Flags
+	if len(profile.Flags) > 0 {
+		s += "┌─ ALERTAS ──────────────────────────────────────────────────────────┐\n"
+		for _, flag := range profile.Flags {
+			s += fmt.Sprintf("│ ⚠️  %-66s │\n", truncate(flag, 66))
+		}
+		s += "└────────────────────────────────────────────────────────────────────┘\n\n"
+	}
+
+	// Sócios (primeiros 10)
+	if len(profile.Socios) > 0 {
+		s += "┌─ QUADRO SOCIETÁRIO (Top 10) ───────────────────────────────────────┐\n"
+		for i, socio := range profile.Socios {
+			if i >= 10 {
+				break
+			}
+			icon := "👤"
+			if socio.Cluster != "" {
+				icon = "🏚️"
+			} else if socio.EmpresasBaixadas > 0 {
+				icon = "⚠️"
+			}
+			s += fmt.Sprintf("│ %s %-40s %10s %3d baixadas │\n", icon, truncate(socio.Nome, 40), socio.DataEntrada, socio.EmpresasBaixadas)
+			if socio.Cluster != "" {
+				s += fmt.Sprintf("│    Fachada: %-54s │\n", truncate(fmt.Sprintf("%s (%d empresas)", socio.Cluster, socio.EmpresasCluster), 54))
+			}
+		}
+		if len(profile.Socios) > 10 {
+			s += fmt.Sprintf("│ ... e mais %d sócios                                              │\n", len(profile.Socios)-10)
+		}
+		s += "└────────────────────────────────────────────────────────────────────┘\n"
+	}
+
+	s += "\n[Q] Voltar | [S] Ver Sócios | [C] Cadeia de Controle\n"
+
+	return s
+}
+
 // viewCPFDetails exibe todos os dados de um CPF
 func (m model) viewCPFDetails(cpf string) string {
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	empresas, err := engine.EmpresasPorCPF(cpf)
+	ctx, cancel := m.contextoConsulta()
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	empresas, err := engine.EmpresasPorCPF(ctx, cpf)
 	
 	if err != nil {
 		return fmt.Sprintf("\n❌ ERRO: %v\n", err)
@@ -176,8 +313,11 @@ func (m model) viewCPFDetails(cpf string) string {
 
 // viewCNPJDetails exibe todos os dados de um CNPJ
 func (m model) viewCNPJDetails(cnpj string) string {
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	empresa, err := engine.DadosCompletosEmpresa(cnpj)
+	ctx, cancel := m.contextoConsulta()
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	empresa, err := engine.DadosCompletosEmpresa(ctx, cnpj)
 	
 	if err != nil {
 		return fmt.Sprintf("\n❌ ERRO: %v\n", err)
@@ -253,12 +393,44 @@ func (m model) viewCNPJDetails(cnpj string) string {
 	}
 	s += "└────────────────────────────────────────────────────────────────────┘\n"
 
-	s += "\n[Q] Voltar | [S] Ver Sócios | [C] Cadeia de Controle\n"
+	s += "\n[Q] Voltar | [S] Ver Sócios | [C] Cadeia de Controle | [I] Investigar (Forense)\n"
 
 	return s
 }
 
 // Funções auxiliares
+// viewComposicaoScore lista as regras acionadas: valor observado, limite e pontos
+func viewComposicaoScore(p *forensics.Pontuacao) string {
+	if p == nil {
+		return ""
+	}
+	s := fmt.Sprintf("┌─ COMPOSIÇÃO DO SCORE (modelo %s v%s) ", p.Modelo, p.Versao)
+	s += strings.Repeat("─", max(0, 70-len([]rune(s)))) + "┐\n"
+	acionadas := 0
+	for _, r := range p.Regras {
+		if !r.Acionada {
+			continue
+		}
+		acionadas++
+		linha := fmt.Sprintf("%-28s %12s %s %-10s %+4d pts", truncate(r.Regra, 28), formatarMetrica(r.Valor), r.Operador, formatarMetrica(r.Limite), r.Pontos)
+		s += fmt.Sprintf("│ %-66s │\n", linha)
+	}
+	if acionadas == 0 {
+		s += fmt.Sprintf("│ %-66s │\n", "Nenhuma regra acionada")
+	}
+	s += fmt.Sprintf("│ %-66s │\n", fmt.Sprintf("Total: %d pontos (teto %d) → score %d", p.PontosBrutos, p.ScoreMaximo, p.Score))
+	s += "└────────────────────────────────────────────────────────────────────┘\n\n"
+	return s
+}
+
+// formatarMetrica mostra inteiros sem casas decimais
+func formatarMetrica(v float64) string {
+	if v == float64(int64(v)) {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
 func getScoreBar(score int) string {
 	filled := score / 10
 	empty := 10 - filled
@@ -296,8 +468,11 @@ func getString(m map[string]interface{}, key string) string {
 
 // viewSociosList exibe lista completa de sócios de um CNPJ
 func (m model) viewSociosList(cnpj string) string {
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	socios, err := engine.SociosPorCNPJ(cnpj)
+	ctx, cancel := m.contextoConsulta()
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	socios, err := engine.SociosPorCNPJ(ctx, cnpj, "")
 	
 	if err != nil {
 		return fmt.Sprintf("\n❌ ERRO: %v\n", err)
@@ -330,7 +505,7 @@ func (m model) viewSociosList(cnpj string) string {
 		
 		// Identifica tipo
 		tipoIcon := "👤"
-		if len(cpfCnpj) == 14 {
+		if cpfcnpj.PareceCNPJ(cpfCnpj) {
 			tipoIcon = "🏢"
 		}
 
@@ -365,8 +540,11 @@ func (m model) viewCadeiaControle(cnpj string) string {
 	s += fmt.Sprintf("CNPJ: %s\n\n", cnpj)
 
 	// Busca sócios para construir cadeia
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	socios, err := engine.SociosPorCNPJ(cnpj)
+	ctx, cancel := m.contextoConsulta()
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	socios, err := engine.SociosPorCNPJ(ctx, cnpj, "")
 	
 	if err != nil {
 		return fmt.Sprintf("\n❌ ERRO: %v\n", err)
@@ -443,8 +621,11 @@ func (m model) viewCadeiaControle(cnpj string) string {
 
 // viewTimeline exibe timeline de atividades de uma pessoa
 func (m model) viewTimeline(cpf string) string {
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	timeline, err := engine.TimelinePessoa(cpf)
+	ctx, cancel := m.contextoConsulta()
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	timeline, err := engine.TimelinePessoa(ctx, cpf)
 	
 	if err != nil {
 		return fmt.Sprintf("\n❌ ERRO: %v\n", err)
@@ -505,8 +686,11 @@ func (m model) viewEmpresaDetalhes(cnpj string) string {
 		return "\n❌ Nenhuma empresa selecionada\n\n[Q] Voltar\n"
 	}
 
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	empresa, err := engine.DadosCompletosEmpresa(cnpj)
+	ctx, cancel := m.contextoConsulta()
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	empresa, err := engine.DadosCompletosEmpresa(ctx, cnpj)
 	
 	if err != nil {
 		return fmt.Sprintf("\n❌ ERRO: %v\n", err)
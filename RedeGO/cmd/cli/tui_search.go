@@ -2,9 +2,10 @@ package main
 
 import (
 	"fmt"
-	"regexp"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/cpfcnpj"
 )
 
 func (m model) viewSearch() string {
@@ -13,21 +14,22 @@ func (m model) viewSearch() string {
 	s += "║         🔍 Buscar CPF ou CNPJ                                        ║\n"
 	s += "╚══════════════════════════════════════════════════════════════════════╝\n\n"
 
-	s += "Digite o CPF (11 dígitos) ou CNPJ (14 dígitos) sem pontuação:\n\n"
+	s += "Digite o CPF (11 dígitos) ou CNPJ (14 caracteres, numérico ou alfanumérico):\n\n"
 	s += fmt.Sprintf("┌──────────────────────────────────────────────────────────────────────┐\n")
 	s += fmt.Sprintf("│ > %-67s│\n", m.searchInput)
 	s += fmt.Sprintf("└──────────────────────────────────────────────────────────────────────┘\n\n")
 
 	s += "Exemplos:\n"
 	s += "  CPF:  12345678900\n"
-	s += "  CNPJ: 01234567000100\n\n"
+	s += "  CNPJ: 01234567000100\n"
+	s += "  CNPJ: 12ABC34501DE35\n\n"
 
 	s += "┌──────────────────────────────────────────────────────────────────────┐\n"
-	s += "│ [ENTER] Buscar | [Q] Cancelar | [ESC] Voltar                        │\n"
+	s += "│ [ENTER] Buscar | [Q] Cancelar (campo vazio) | [ESC] Voltar          │\n"
 	s += "│                                                                      │\n"
 	s += "│ Após buscar:                                                         │\n"
 	s += "│ • CPF:  Ver todas empresas + Investigação forense                   │\n"
-	s += "│ • CNPJ: Ver dados completos + Sócios                                │\n"
+	s += "│ • CNPJ: Ver dados completos + Sócios + Investigação forense         │\n"
 	s += "└──────────────────────────────────────────────────────────────────────┘\n"
 
 	if m.message != "" {
@@ -44,18 +46,18 @@ func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Valida e busca
 		cleaned := cleanInput(m.searchInput)
 		
-		if len(cleaned) == 11 {
+		if len(cleaned) == 11 && !cpfcnpj.CNPJAlfanumerico(cleaned) {
 			// CPF
 			m.viewData = cleaned
 			m.mode = modeViewCPF
 			m.message = "Exibindo dados do CPF"
-		} else if len(cleaned) == 14 {
+		} else if cpfcnpj.PareceCNPJ(cleaned) {
 			// CNPJ
 			m.viewData = cleaned
 			m.mode = modeViewCNPJ
 			m.message = "Exibindo dados do CNPJ"
 		} else {
-			m.message = "❌ Formato inválido! Use 11 dígitos (CPF) ou 14 (CNPJ)"
+			m.message = "❌ Formato inválido! Use 11 dígitos (CPF) ou 14 caracteres (CNPJ)"
 		}
 		
 	case "backspace":
@@ -64,15 +66,23 @@ func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		
 	case "q":
+		// Com texto digitado, Q é letra de CNPJ alfanumérico
+		if m.searchInput != "" {
+			if len(m.searchInput) < 14 {
+				m.searchInput += "Q"
+			}
+			break
+		}
 		m.mode = modeTree
 		m.searchInput = ""
 		m.message = "Busca cancelada"
 		
 	default:
-		// Aceita apenas números
-		if len(msg.String()) == 1 && msg.String() >= "0" && msg.String() <= "9" {
+		// Aceita números e letras (CNPJ alfanumérico)
+		tecla := strings.ToUpper(msg.String())
+		if len(tecla) == 1 && (tecla >= "0" && tecla <= "9" || tecla >= "A" && tecla <= "Z") {
 			if len(m.searchInput) < 14 {
-				m.searchInput += msg.String()
+				m.searchInput += tecla
 			}
 		}
 	}
@@ -81,9 +91,8 @@ func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func cleanInput(s string) string {
-	// Remove tudo que não é número
-	re := regexp.MustCompile(`[^0-9]`)
-	return re.ReplaceAllString(s, "")
+	// Remove pontuação e coloca letras em maiúsculas
+	return cpfcnpj.LimparCNPJ(s)
 }
 
 // updateViewCPF atualiza visualização de CPF
@@ -118,6 +127,10 @@ func (m model) updateViewCNPJ(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Cadeia de controle
 		m.mode = modeCadeiaControle
 		m.message = "Carregando cadeia de controle..."
+	case "i":
+		// Investigação forense da empresa
+		m.mode = modeForensicsEmpresa
+		m.message = "Análise forense da empresa iniciada"
 	}
 	return m, nil
 }
@@ -141,6 +154,22 @@ func (m model) updateForensics(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updateForensicsEmpresa atualiza visualização forense da empresa
+func (m model) updateForensicsEmpresa(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "backspace":
+		m.mode = modeViewCNPJ
+		m.message = "Voltou para dados do CNPJ"
+	case "s":
+		m.mode = modeViewSocios
+		m.message = "Carregando lista de sócios..."
+	case "c":
+		m.mode = modeCadeiaControle
+		m.message = "Carregando cadeia de controle..."
+	}
+	return m, nil
+}
+
 // updateViewSocios atualiza visualização de sócios
 func (m model) updateViewSocios(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/config"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/export"
+)
+
+func main() {
+	// Flags (rede.ini é lido por config.LoadConfig via -conf_file)
+	formato := flag.String("formato", "neo4j", "Formato de saída: neo4j (CSVs do neo4j-admin import) ou edgelist (TSV origem/destino)")
+	saida := flag.String("saida", "output/grafo", "Pasta de saída")
+	compactar := flag.Bool("gzip", false, "Compacta os arquivos gerados (.gz)")
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Erro ao carregar configuração: %v", err)
+	}
+
+	if *formato != "neo4j" && *formato != "edgelist" {
+		log.Fatalf("Formato desconhecido: %s (use neo4j ou edgelist)", *formato)
+	}
+
+	if err := database.InitDatabases(cfg); err != nil {
+		log.Fatalf("Erro ao inicializar bancos de dados: %v", err)
+	}
+	defer database.Close()
+
+	if database.GetDBRede() == nil || database.GetDBReceita() == nil {
+		log.Fatalf("Bases da Receita e de rede precisam estar configuradas (base_receita e base_rede no rede.ini)")
+	}
+
+	printHeader()
+
+	// Ctrl+C interrompe a consulta em andamento e remove o arquivo incompleto
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	exporter := export.NewBulkExporter(database.GetDBReceita(), database.GetDBRede(), database.NewDialect(), *saida).
+		ComGzip(*compactar).
+		ComProgresso(func(arquivo string, linhas int64) {
+			fmt.Printf("  ⏳ %s: %d linhas\n", filepath.Base(arquivo), linhas)
+		})
+
+	inicio := time.Now()
+	var arquivos []export.ArquivoExportado
+	if *formato == "neo4j" {
+		arquivos, err = exporter.ExportNeo4j(ctx)
+	} else {
+		arquivos, err = exporter.ExportEdgeList(ctx)
+	}
+	for _, arquivo := range arquivos {
+		fmt.Printf("  ✅ %s: %d linhas\n", arquivo.Caminho, arquivo.Linhas)
+	}
+	if err != nil {
+		log.Fatalf("Erro na exportação: %v", err)
+	}
+
+	fmt.Printf("\n✅ Exportação concluída em %v\n", time.Since(inicio).Round(time.Second))
+
+	if *formato == "neo4j" {
+		fmt.Println("\n📥 Para importar no Neo4j (banco parado):")
+		fmt.Println("  " + comandoNeo4jAdmin(arquivos))
+	}
+}
+
+// comandoNeo4jAdmin monta o comando de importação com os arquivos gerados.
+// --skip-bad-relationships ignora ligações para empresas ausentes da base
+// (sócios PJ cujo CNPJ não consta em estabelecimento).
+func comandoNeo4jAdmin(arquivos []export.ArquivoExportado) string {
+	partes := []string{"neo4j-admin database import full"}
+	for _, arquivo := range arquivos {
+		opcao := "--nodes"
+		if strings.HasPrefix(filepath.Base(arquivo.Caminho), "relacionamentos") {
+			opcao = "--relationships"
+		}
+		partes = append(partes, fmt.Sprintf("%s=%s", opcao, arquivo.Caminho))
+	}
+	partes = append(partes, "--skip-bad-relationships", "neo4j")
+	return strings.Join(partes, " ")
+}
+
+func printHeader() {
+	fmt.Println("")
+	fmt.Println("╔════════════════════════════════════════════════════════════════╗")
+	fmt.Println("║                                                                ║")
+	fmt.Println("║         🕸️  RedeCNPJ - Exportação da Rede Completa             ║")
+	fmt.Println("║                                                                ║")
+	fmt.Println("╚════════════════════════════════════════════════════════════════╝")
+	fmt.Println("")
+}
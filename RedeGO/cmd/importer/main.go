@@ -16,8 +16,14 @@ func main() {
 	processOnly := flag.Bool("process", false, "Apenas processa arquivos já baixados")
 	createLinks := flag.Bool("links", false, "Cria tabelas de ligação (rede.db)")
 	createSearch := flag.Bool("search", false, "Cria índices de busca (rede_search.db)")
-	all := flag.Bool("all", false, "Executa todo o processo (download + process + links + search)")
+	createAddresses := flag.Bool("enderecos", false, "Cria a tabela de endereços normalizados (endereco_normalizado.db)")
+	ceps := flag.String("ceps", "", "Importa um CSV de CEPs geocodificados (cep, latitude, longitude) para o mapa")
+	incremental := flag.Bool("incremental", false, "Aplica a nova referência sobre a base existente, registrando alterações em change_log")
+	all := flag.Bool("all", false, "Executa todo o processo (download + process + links + search + enderecos)")
 	confFile := flag.String("config", "rede.ini", "Arquivo de configuração (opcional)")
+	ref := flag.String("ref", "", "Referência mensal a baixar/importar (AAAA-MM); padrão: a mais recente")
+	fonte := flag.String("fonte", "", "Origem dos arquivos: URL da Receita, espelho HTTP ou diretório local")
+	workers := flag.Int("workers", 0, "Leitores de CSV em paralelo; padrão: importacao_workers do rede.ini ou o número de CPUs")
 	
 	flag.Parse()
 
@@ -59,6 +65,15 @@ func main() {
 
 	// Cria importador
 	imp := importer.NewImporter(cfg)
+	if *ref != "" {
+		if err := imp.SetReferencia(*ref); err != nil {
+			log.Fatalf("Erro: %v", err)
+		}
+	}
+	if *fonte != "" {
+		imp.SetFonte(*fonte)
+	}
+	imp.SetWorkers(*workers)
 
 	printHeader()
 
@@ -68,7 +83,11 @@ func main() {
 		*processOnly = false
 		*createLinks = true
 		*createSearch = true
-		runAll(imp)
+		runAll(imp, *incremental)
+	} else if *incremental {
+		if err := imp.ProcessIncremental(); err != nil {
+			log.Fatalf("Erro na importação incremental: %v", err)
+		}
 	} else if *downloadOnly {
 		if err := imp.DownloadFiles(); err != nil {
 			log.Fatalf("Erro no download: %v", err)
@@ -85,6 +104,17 @@ func main() {
 		if err := imp.CreateSearchIndexes(); err != nil {
 			log.Fatalf("Erro ao criar índices de busca: %v", err)
 		}
+	} else if *createAddresses || *ceps != "" {
+		if *createAddresses {
+			if err := imp.CreateAddressTable(); err != nil {
+				log.Fatalf("Erro ao normalizar endereços: %v", err)
+			}
+		}
+		if *ceps != "" {
+			if err := imp.ImportCEPs(*ceps); err != nil {
+				log.Fatalf("Erro ao importar CEPs: %v", err)
+			}
+		}
 	} else {
 		flag.Usage()
 		os.Exit(1)
@@ -93,15 +123,21 @@ func main() {
 	fmt.Println("\n✅ Processo concluído com sucesso!")
 }
 
-func runAll(imp *importer.Importer) {
+func runAll(imp *importer.Importer, incremental bool) {
+	process := imp.ProcessFiles
+	if incremental {
+		process = imp.ProcessIncremental
+	}
+
 	steps := []struct {
 		name string
 		fn   func() error
 	}{
 		{"Download dos arquivos", imp.DownloadFiles},
-		{"Processamento dos arquivos", imp.ProcessFiles},
+		{"Processamento dos arquivos", process},
 		{"Criação de tabelas de ligação", imp.CreateLinkTables},
 		{"Criação de índices de busca", imp.CreateSearchIndexes},
+		{"Normalização de endereços", imp.CreateAddressTable},
 	}
 
 	for i, step := range steps {
@@ -2,16 +2,15 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"strings"
 	"time"
-	"unicode/utf8"
 
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
-	"golang.org/x/text/encoding/charmap"
-	"golang.org/x/text/transform"
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/normalizer"
 )
 
 const (
@@ -20,6 +19,9 @@ const (
 	batchSize  = 10000
 )
 
+// schema define a normalização dos campos migrados
+var schema *normalizer.Schema
+
 type MigrationStats struct {
 	TableName    string
 	TotalRows    int64
@@ -29,29 +31,6 @@ type MigrationStats struct {
 	Duration     time.Duration
 }
 
-// sanitizeString corrige problemas de encoding em strings
-func sanitizeString(s string) string {
-	// Se já é UTF-8 válido, retorna como está
-	if utf8.ValidString(s) {
-		return s
-	}
-
-	// Tenta converter de ISO-8859-1 (Latin1) para UTF-8
-	decoder := charmap.ISO8859_1.NewDecoder()
-	result, _, err := transform.String(decoder, s)
-	if err == nil && utf8.ValidString(result) {
-		return result
-	}
-
-	// Se ainda não funcionou, remove caracteres inválidos
-	return strings.Map(func(r rune) rune {
-		if r == utf8.RuneError {
-			return -1 // Remove o caractere
-		}
-		return r
-	}, s)
-}
-
 // sanitizeNumericString normaliza campos numéricos
 // Converte strings vazias ou inválidas em NULL
 func sanitizeNumericString(ns sql.NullString) sql.NullString {
@@ -142,8 +121,17 @@ func sanitizeDateString(ns sql.NullString) sql.NullString {
 }
 
 func main() {
+	schemaPath := flag.String("schema", "", "Schema YAML/JSON que estende as regras de normalização")
+	flag.Parse()
+
 	printHeader()
 
+	var err error
+	schema, err = normalizer.CarregarSchema(*schemaPath)
+	if err != nil {
+		log.Fatalf("❌ Erro ao carregar schema de normalização: %v", err)
+	}
+
 	// Conectar SQLite
 	log.Println("📂 Conectando ao SQLite...")
 	srcDB, err := sql.Open("sqlite3", sqliteDB)
@@ -212,7 +200,7 @@ func migrateEmpresas(src, dst *sql.DB) MigrationStats {
 	log.Println("📊 Migrando tabela: empresas")
 
 	// Criar normalizador específico para empresas
-	normalizer := GetEmpresasNormalizer()
+	norm := schema.Normalizador("empresas")
 
 	// Contar registros
 	err := src.QueryRow("SELECT COUNT(*) FROM empresas").Scan(&stat.TotalRows)
@@ -276,13 +264,13 @@ func migrateEmpresas(src, dst *sql.DB) MigrationStats {
 		}
 
 		// Normaliza campos usando metadados específicos da tabela
-		cnpjNorm := normalizer.NormalizeNullString("cnpj_basico", cnpj)
-		razaoNorm := normalizer.NormalizeNullString("razao_social", razao)
-		naturezaNorm := normalizer.NormalizeNullString("natureza_juridica", natureza)
-		qualifNorm := normalizer.NormalizeNullString("qualificacao_responsavel", qualif)
-		porteNorm := normalizer.NormalizeNullString("porte_empresa", porte)
-		enteNorm := normalizer.NormalizeNullString("ente_federativo_responsavel", ente)
-		capitalNorm := normalizer.NormalizeFloat64("capital_social", capital)
+		cnpjNorm := norm.NormalizeNullString("cnpj_basico", cnpj)
+		razaoNorm := norm.NormalizeNullString("razao_social", razao)
+		naturezaNorm := norm.NormalizeNullString("natureza_juridica", natureza)
+		qualifNorm := norm.NormalizeNullString("qualificacao_responsavel", qualif)
+		porteNorm := norm.NormalizeNullString("porte_empresa", porte)
+		enteNorm := norm.NormalizeNullString("ente_federativo_responsavel", ente)
+		capitalNorm := norm.NormalizeFloat64("capital_social", capital)
 
 		_, err = stmt.Exec(cnpjNorm, razaoNorm, naturezaNorm, qualifNorm, capitalNorm, porteNorm, enteNorm)
 		if err != nil {
@@ -321,7 +309,7 @@ func migrateEstabelecimentos(src, dst *sql.DB) MigrationStats {
 	log.Println("📊 Migrando tabela: estabelecimento")
 
 	// Criar normalizador específico para estabelecimento
-	normalizer := GetEstabelecimentoNormalizer()
+	norm := schema.Normalizador("estabelecimento")
 
 	// Contar registros
 	err := src.QueryRow("SELECT COUNT(*) FROM estabelecimento").Scan(&stat.TotalRows)
@@ -411,37 +399,37 @@ func migrateEstabelecimentos(src, dst *sql.DB) MigrationStats {
 		}
 
 		// Normaliza campos usando metadados específicos da tabela
-		cnpjNorm := normalizer.NormalizeString("cnpj", cnpj)
-		cnpjBasicoNorm := normalizer.NormalizeString("cnpj_basico", cnpjBasico)
-		cnpjOrdemNorm := normalizer.NormalizeString("cnpj_ordem", cnpjOrdem)
-		cnpjDvNorm := normalizer.NormalizeString("cnpj_dv", cnpjDv)
-		matrizFilialNorm := normalizer.NormalizeString("matriz_filial", matrizFilial)
-		nomeFantasiaNorm := normalizer.NormalizeString("nome_fantasia", nomeFantasia)
-		situacaoCadastralNorm := normalizer.NormalizeString("situacao_cadastral", situacaoCadastral)
-		motivoSituacaoNorm := normalizer.NormalizeString("motivo_situacao_cadastral", motivoSituacao)
-		nomeCidadeExteriorNorm := normalizer.NormalizeString("nome_cidade_exterior", nomeCidadeExterior)
-		paisNorm := normalizer.NormalizeString("pais", pais)
-		cnaeNorm := normalizer.NormalizeString("cnae_fiscal", cnae)
-		cnaeSecundariaNorm := normalizer.NormalizeString("cnae_fiscal_secundaria", cnaeSecundaria)
-		tipoLogradouroNorm := normalizer.NormalizeString("tipo_logradouro", tipoLogradouro)
-		logradouroNorm := normalizer.NormalizeString("logradouro", logradouro)
-		numeroNorm := normalizer.NormalizeString("numero", numero)
-		complementoNorm := normalizer.NormalizeString("complemento", complemento)
-		bairroNorm := normalizer.NormalizeString("bairro", bairro)
-		cepNorm := normalizer.NormalizeString("cep", cep)
-		ufNorm := normalizer.NormalizeString("uf", uf)
-		municipioNorm := normalizer.NormalizeString("municipio", municipio)
-		ddd1Norm := normalizer.NormalizeString("ddd1", ddd1)
-		tel1Norm := normalizer.NormalizeString("telefone1", tel1)
-		ddd2Norm := normalizer.NormalizeString("ddd2", ddd2)
-		tel2Norm := normalizer.NormalizeString("telefone2", tel2)
-		dddFaxNorm := normalizer.NormalizeString("ddd_fax", dddFax)
-		faxNorm := normalizer.NormalizeString("fax", fax)
-		emailNorm := normalizer.NormalizeString("correio_eletronico", email)
-		situacaoEspecialNorm := normalizer.NormalizeString("situacao_especial", situacaoEspecial)
-		dataSituacaoNorm := normalizer.NormalizeNullString("data_situacao_cadastral", dataSituacao)
-		dataInicioNorm := normalizer.NormalizeNullString("data_inicio_atividades", dataInicio)
-		dataEspecialNorm := normalizer.NormalizeNullString("data_situacao_especial", dataEspecial)
+		cnpjNorm := norm.NormalizeString("cnpj", cnpj)
+		cnpjBasicoNorm := norm.NormalizeString("cnpj_basico", cnpjBasico)
+		cnpjOrdemNorm := norm.NormalizeString("cnpj_ordem", cnpjOrdem)
+		cnpjDvNorm := norm.NormalizeString("cnpj_dv", cnpjDv)
+		matrizFilialNorm := norm.NormalizeString("matriz_filial", matrizFilial)
+		nomeFantasiaNorm := norm.NormalizeString("nome_fantasia", nomeFantasia)
+		situacaoCadastralNorm := norm.NormalizeString("situacao_cadastral", situacaoCadastral)
+		motivoSituacaoNorm := norm.NormalizeString("motivo_situacao_cadastral", motivoSituacao)
+		nomeCidadeExteriorNorm := norm.NormalizeString("nome_cidade_exterior", nomeCidadeExterior)
+		paisNorm := norm.NormalizeString("pais", pais)
+		cnaeNorm := norm.NormalizeString("cnae_fiscal", cnae)
+		cnaeSecundariaNorm := norm.NormalizeString("cnae_fiscal_secundaria", cnaeSecundaria)
+		tipoLogradouroNorm := norm.NormalizeString("tipo_logradouro", tipoLogradouro)
+		logradouroNorm := norm.NormalizeString("logradouro", logradouro)
+		numeroNorm := norm.NormalizeString("numero", numero)
+		complementoNorm := norm.NormalizeString("complemento", complemento)
+		bairroNorm := norm.NormalizeString("bairro", bairro)
+		cepNorm := norm.NormalizeString("cep", cep)
+		ufNorm := norm.NormalizeString("uf", uf)
+		municipioNorm := norm.NormalizeString("municipio", municipio)
+		ddd1Norm := norm.NormalizeString("ddd1", ddd1)
+		tel1Norm := norm.NormalizeString("telefone1", tel1)
+		ddd2Norm := norm.NormalizeString("ddd2", ddd2)
+		tel2Norm := norm.NormalizeString("telefone2", tel2)
+		dddFaxNorm := norm.NormalizeString("ddd_fax", dddFax)
+		faxNorm := norm.NormalizeString("fax", fax)
+		emailNorm := norm.NormalizeString("correio_eletronico", email)
+		situacaoEspecialNorm := norm.NormalizeString("situacao_especial", situacaoEspecial)
+		dataSituacaoNorm := norm.NormalizeNullString("data_situacao_cadastral", dataSituacao)
+		dataInicioNorm := norm.NormalizeNullString("data_inicio_atividades", dataInicio)
+		dataEspecialNorm := norm.NormalizeNullString("data_situacao_especial", dataEspecial)
 
 		// Validar campos obrigatórios antes de inserir
 		if !cnpjNorm.Valid || cnpjNorm.String == "" {
@@ -517,7 +505,7 @@ func migrateSocios(src, dst *sql.DB) MigrationStats {
 	log.Println("📊 Migrando tabela: socios")
 
 	// Criar normalizador específico para socios
-	normalizer := GetSociosNormalizer()
+	norm := schema.Normalizador("socios")
 
 	// Contar registros
 	err := src.QueryRow("SELECT COUNT(*) FROM socios").Scan(&stat.TotalRows)
@@ -583,18 +571,18 @@ func migrateSocios(src, dst *sql.DB) MigrationStats {
 		}
 
 		// Normaliza campos usando metadados específicos da tabela
-		cnpjNorm := normalizer.NormalizeString("cnpj", cnpj)
-		cnpjBasicoNorm := normalizer.NormalizeString("cnpj_basico", cnpjBasico)
-		identificadorNorm := normalizer.NormalizeString("identificador_de_socio", identificador)
-		nomeNorm := normalizer.NormalizeString("nome_socio", nome)
-		cpfCnpjNorm := normalizer.NormalizeString("cnpj_cpf_socio", cpfCnpj)
-		qualifNorm := normalizer.NormalizeString("qualificacao_socio", qualif)
-		paisNorm := normalizer.NormalizeString("pais", pais)
-		repLegalNorm := normalizer.NormalizeString("representante_legal", repLegal)
-		nomeRepNorm := normalizer.NormalizeString("nome_representante", nomeRep)
-		qualifRepNorm := normalizer.NormalizeString("qualificacao_representante_legal", qualifRep)
-		faixaEtariaNorm := normalizer.NormalizeString("faixa_etaria", faixaEtaria)
-		dataEntradaNorm := normalizer.NormalizeNullString("data_entrada_sociedade", dataEntrada)
+		cnpjNorm := norm.NormalizeString("cnpj", cnpj)
+		cnpjBasicoNorm := norm.NormalizeString("cnpj_basico", cnpjBasico)
+		identificadorNorm := norm.NormalizeString("identificador_de_socio", identificador)
+		nomeNorm := norm.NormalizeString("nome_socio", nome)
+		cpfCnpjNorm := norm.NormalizeString("cnpj_cpf_socio", cpfCnpj)
+		qualifNorm := norm.NormalizeString("qualificacao_socio", qualif)
+		paisNorm := norm.NormalizeString("pais", pais)
+		repLegalNorm := norm.NormalizeString("representante_legal", repLegal)
+		nomeRepNorm := norm.NormalizeString("nome_representante", nomeRep)
+		qualifRepNorm := norm.NormalizeString("qualificacao_representante_legal", qualifRep)
+		faixaEtariaNorm := norm.NormalizeString("faixa_etaria", faixaEtaria)
+		dataEntradaNorm := norm.NormalizeNullString("data_entrada_sociedade", dataEntrada)
 
 		_, err = stmt.Exec(
 			cnpjNorm, cnpjBasicoNorm, identificadorNorm,
@@ -639,7 +627,7 @@ func migrateSimples(src, dst *sql.DB) MigrationStats {
 	log.Println("📊 Migrando tabela: simples")
 
 	// Criar normalizador específico para simples
-	normalizer := GetSimplesNormalizer()
+	norm := schema.Normalizador("simples")
 
 	// Contar registros
 	err := src.QueryRow("SELECT COUNT(*) FROM simples").Scan(&stat.TotalRows)
@@ -699,13 +687,13 @@ func migrateSimples(src, dst *sql.DB) MigrationStats {
 		}
 
 		// Normaliza campos usando metadados específicos da tabela
-		cnpjBasicoNorm := normalizer.NormalizeString("cnpj_basico", cnpjBasico)
-		opcaoSimplesNorm := normalizer.NormalizeString("opcao_simples", opcaoSimples)
-		dataOpcaoSimplesNorm := normalizer.NormalizeNullString("data_opcao_simples", dataOpcaoSimples)
-		dataExclusaoSimplesNorm := normalizer.NormalizeNullString("data_exclusao_simples", dataExclusaoSimples)
-		opcaoMeiNorm := normalizer.NormalizeString("opcao_mei", opcaoMei)
-		dataOpcaoMeiNorm := normalizer.NormalizeNullString("data_opcao_mei", dataOpcaoMei)
-		dataExclusaoMeiNorm := normalizer.NormalizeNullString("data_exclusao_mei", dataExclusaoMei)
+		cnpjBasicoNorm := norm.NormalizeString("cnpj_basico", cnpjBasico)
+		opcaoSimplesNorm := norm.NormalizeString("opcao_simples", opcaoSimples)
+		dataOpcaoSimplesNorm := norm.NormalizeNullString("data_opcao_simples", dataOpcaoSimples)
+		dataExclusaoSimplesNorm := norm.NormalizeNullString("data_exclusao_simples", dataExclusaoSimples)
+		opcaoMeiNorm := norm.NormalizeString("opcao_mei", opcaoMei)
+		dataOpcaoMeiNorm := norm.NormalizeNullString("data_opcao_mei", dataOpcaoMei)
+		dataExclusaoMeiNorm := norm.NormalizeNullString("data_exclusao_mei", dataExclusaoMei)
 
 		_, err = stmt.Exec(
 			cnpjBasicoNorm, opcaoSimplesNorm, dataOpcaoSimplesNorm,
@@ -772,8 +760,8 @@ func migrateLookupTables(src, dst *sql.DB) {
 			}
 			
 			// Sanitiza strings antes de inserir
-			codigo = sanitizeString(codigo)
-			descricao = sanitizeString(descricao)
+			codigo = normalizer.SanitizeString(codigo)
+			descricao = normalizer.SanitizeString(descricao)
 			
 			_, err = stmt.Exec(codigo, descricao)
 			if err != nil {
@@ -840,10 +828,10 @@ func migrateRede(src, dst *sql.DB) MigrationStats {
 		}
 		
 		// Sanitiza strings antes de inserir
-		id1 = sanitizeString(id1)
-		id2 = sanitizeString(id2)
-		descricao = sanitizeString(descricao)
-		cnpj = sanitizeString(cnpj)
+		id1 = normalizer.SanitizeString(id1)
+		id2 = normalizer.SanitizeString(id2)
+		descricao = normalizer.SanitizeString(descricao)
+		cnpj = normalizer.SanitizeString(cnpj)
 		
 		_, err = stmt.Exec(id1, id2, descricao, cnpj, peso)
 		if err != nil {
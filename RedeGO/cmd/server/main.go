@@ -11,6 +11,7 @@ import (
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/config"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/handlers"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/middleware"
 )
 
 func main() {
@@ -37,75 +38,98 @@ func main() {
 	}
 
 	router := gin.Default()
+	// Sem proxies confiáveis: ClientIP (logs) não aceita X-Forwarded-For
+	router.SetTrustedProxies(nil)
 
-	// Cria handlers
-	h := handlers.NewHandler(cfg)
+	// Autenticação por chave de API e limites de requisição por grupo de
+	// rotas; o usuário local dispensa chave e limites
+	uso := middleware.NewUso()
+	h := handlers.NewHandler(cfg).ComUso(uso)
+
+	rede := router.Group("/rede", middleware.Autenticacao(cfg.APIKeys))
+	padrao := rede.Group("", limitar("padrao", cfg.LimiterPadrao, uso))
+	dados := rede.Group("", limitar("dados", cfg.LimiterDados, uso))
+	arquivos := rede.Group("", limitar("arquivos", cfg.LimiterArquivos, uso))
+	forensics := rede.Group("", limitar("forensics", cfg.LimiterForensics, uso))
 
 	// ============================================
 	// APENAS APIs REST
 	// ============================================
 
 	// API de dados
-	router.POST("/rede/grafojson/:tipo/:camada/:cpfcnpj", h.ServeRedeJSONCNPJ)
-	router.GET("/rede/dadosjson/:cpfcnpj", h.ServeDadosDetalhes)
-	router.POST("/rede/dadosjson/:cpfcnpj", h.ServeDadosDetalhes)
+	dados.POST("/grafojson/:tipo/:camada/:cpfcnpj", h.ServeRedeJSONCNPJ)
+	dados.GET("/dadosjson/:cpfcnpj", h.ServeDadosDetalhes)
+	dados.POST("/dadosjson/:cpfcnpj", h.ServeDadosDetalhes)
 	
 	// API de busca avançada
-	router.POST("/rede/busca", h.ServeBuscaAvancada)
+	dados.POST("/busca", h.ServeBuscaAvancada)
 	
 	// API de exportação
-	router.POST("/rede/export/excel", h.ServeExportExcel)
-	router.POST("/rede/export/csv", h.ServeExportCSV)
+	arquivos.POST("/export/excel", h.ServeExportExcel)
+	arquivos.POST("/export/csv", h.ServeExportCSV)
 	
 	// API de grafos avançados
-	router.POST("/rede/caminhos", h.ServeCaminhos)
-	router.POST("/rede/entidades_comuns", h.ServeEntidadesComuns)
-	router.POST("/rede/filtrar_grafo", h.ServeFiltrarGrafo)
+	dados.POST("/caminhos", h.ServeCaminhos)
+	dados.POST("/entidades_comuns", h.ServeEntidadesComuns)
+	dados.POST("/filtrar_grafo", h.ServeFiltrarGrafo)
 	
 	// API de analytics
-	router.POST("/rede/analytics", h.ServeAnalytics)
-	router.POST("/rede/nos_centrais", h.ServeNosCentrais)
-	router.POST("/rede/comunidades", h.ServeComunidades)
-	router.POST("/rede/caminho_mais_curto", h.ServeCaminhoMaisCurto)
+	dados.POST("/analytics", h.ServeAnalytics)
+	dados.POST("/nos_centrais", h.ServeNosCentrais)
+	dados.POST("/comunidades", h.ServeComunidades)
+	dados.POST("/caminho_mais_curto", h.ServeCaminhoMaisCurto)
 	
 	// API de cruzamento de dados (SEM CENSURA)
-	router.GET("/rede/cross/empresas_por_cpf/:cpf", h.ServeCrossDataEmpresasPorCPF)
-	router.GET("/rede/cross/socios_por_cnpj/:cnpj", h.ServeCrossDataSociosPorCNPJ)
-	router.POST("/rede/cross/socios_em_comum", h.ServeCrossDataSociosEmComum)
-	router.GET("/rede/cross/rede_empresas_pessoa/:cpf", h.ServeCrossDataRedeEmpresasPessoa)
-	router.POST("/rede/cross/empresas_mesmo_endereco", h.ServeCrossDataEmpresasMesmoEndereco)
-	router.POST("/rede/cross/empresas_mesmo_contato", h.ServeCrossDataEmpresasMesmoContato)
-	router.GET("/rede/cross/representantes_legais", h.ServeCrossDataRepresentantesLegais)
-	router.GET("/rede/cross/empresas_estrangeiras", h.ServeCrossDataEmpresasEstrangeiras)
-	router.GET("/rede/cross/socios_estrangeiros", h.ServeCrossDataSociosEstrangeiros)
-	router.GET("/rede/cross/timeline_pessoa/:cpf", h.ServeCrossDataTimelinePessoa)
-	router.GET("/rede/cross/socios_empresas_baixadas", h.ServeCrossDataSociosEmpresasBaixadas)
-	router.GET("/rede/cross/dados_completos/:cnpj", h.ServeCrossDataDadosCompletos)
+	dados.GET("/cross/empresas_por_cpf/:cpf", h.ServeCrossDataEmpresasPorCPF)
+	dados.GET("/cross/socios_por_cnpj/:cnpj", h.ServeCrossDataSociosPorCNPJ)
+	dados.POST("/cross/socios_em_comum", h.ServeCrossDataSociosEmComum)
+	dados.GET("/cross/rede_empresas_pessoa/:cpf", h.ServeCrossDataRedeEmpresasPessoa)
+	dados.POST("/cross/empresas_mesmo_endereco", h.ServeCrossDataEmpresasMesmoEndereco)
+	dados.POST("/cross/empresas_mesmo_contato", h.ServeCrossDataEmpresasMesmoContato)
+	dados.GET("/cross/representantes_legais", h.ServeCrossDataRepresentantesLegais)
+	dados.GET("/cross/empresas_estrangeiras", h.ServeCrossDataEmpresasEstrangeiras)
+	dados.GET("/cross/socios_estrangeiros", h.ServeCrossDataSociosEstrangeiros)
+	dados.GET("/cross/timeline_pessoa/:cpf", h.ServeCrossDataTimelinePessoa)
+	dados.GET("/cross/socios_empresas_baixadas", h.ServeCrossDataSociosEmpresasBaixadas)
+	dados.GET("/cross/dados_completos/:cnpj", h.ServeCrossDataDadosCompletos)
 	
 	// API de Ferramentas Forenses
-	router.GET("/rede/forensics/investigate/:cpf", h.ServeForensicsInvestigatePerson)
-	router.GET("/rede/forensics/shell_companies", h.ServeForensicsShellCompanies)
-	router.POST("/rede/forensics/frontmen", h.ServeForensicsFrontmen)
-	router.GET("/rede/forensics/mass_registration/:cpf", h.ServeForensicsMassRegistration)
-	router.GET("/rede/forensics/ownership_chain/:cnpj", h.ServeForensicsOwnershipChain)
-	router.GET("/rede/forensics/suspicious_patterns", h.ServeForensicsSuspiciousPatterns)
+	forensics.GET("/forensics/investigate/:cpf", h.ServeForensicsInvestigatePerson)
+	forensics.GET("/forensics/investigate_company/:cnpj", h.ServeForensicsInvestigateCompany)
+	forensics.GET("/forensics/shell_companies", h.ServeForensicsShellCompanies)
+	forensics.POST("/forensics/frontmen", h.ServeForensicsFrontmen)
+	forensics.GET("/forensics/mass_registration/:cpf", h.ServeForensicsMassRegistration)
+	forensics.GET("/forensics/ownership_chain/:cnpj", h.ServeForensicsOwnershipChain)
+	forensics.GET("/forensics/suspicious_patterns", h.ServeForensicsSuspiciousPatterns)
+	forensics.GET("/forensics/modelo_risco", h.ServeForensicsModeloRisco)
 
 	// Busca
-	router.GET("/rede/busca", h.ServeBuscaPorNome)
+	dados.GET("/busca", h.ServeBuscaPorNome)
+
+	// Casos (investigações salvas na base local)
+	padrao.GET("/casos", h.ServeCasosListar)
+	padrao.POST("/casos", h.ServeCasosCriar)
+	padrao.GET("/casos/:id", h.ServeCasosCarregar)
+	padrao.PUT("/casos/:id", h.ServeCasosAtualizar)
+	padrao.DELETE("/casos/:id", h.ServeCasosExcluir)
+	padrao.GET("/casos/:id/versoes", h.ServeCasosVersoes)
+	padrao.GET("/casos/:id/diff", h.ServeCasosDiff)
 
 	// Arquivos
-	router.GET("/rede/arquivos_json/:arquivopath", h.ServeArquivosJSON)
-	router.POST("/rede/arquivos_json/:arquivopath", h.ServeArquivosJSON)
-	router.DELETE("/rede/arquivos_json/:arquivopath", h.ServeArquivosJSON)
-	router.POST("/rede/arquivos_json_upload/:nomeArquivo", h.ServeArquivosJSONUpload)
+	arquivos.GET("/arquivos_json", h.ServeArquivosListar)
+	arquivos.GET("/arquivos_json/:arquivopath", h.ServeArquivosJSON)
+	arquivos.POST("/arquivos_json/:arquivopath", h.ServeArquivosJSON)
+	arquivos.DELETE("/arquivos_json/:arquivopath", h.ServeArquivosExcluir)
+	arquivos.POST("/arquivos_json_upload/:nomeArquivo", h.ServeArquivosJSONUpload)
 
 	// Exportação
-	router.POST("/rede/dadosemarquivo/:formato", h.ServeDadosEmArquivo)
-	router.POST("/rede/mapa", h.ServeMapa)
+	arquivos.POST("/dadosemarquivo/:formato", h.ServeDadosEmArquivo)
+	dados.POST("/mapa", h.ServeMapa)
 
 	// Informações
-	router.GET("/rede/informacao/dados_publicos_cnpj_disponivel", h.ServeDadosPublicosDisponivel)
-	router.GET("/rede/api/status", h.ServeAPIStatus)
+	padrao.GET("/informacao/dados_publicos_cnpj_disponivel", h.ServeDadosPublicosDisponivel)
+	padrao.GET("/api/status", h.ServeAPIStatus)
+	padrao.GET("/api/uso", h.ServeAPIUso)
 
 	// Configuração de shutdown gracioso
 	quit := make(chan os.Signal, 1)
@@ -125,6 +149,11 @@ func main() {
 	log.Println("╚════════════════════════════════════════════════════════════════╝")
 	log.Printf("Servidor API iniciado em http://127.0.0.1%s/rede/api/", addr)
 	log.Printf("Referência BD: %s", cfg.ReferenciaBD)
+	if len(cfg.APIKeys) == 0 {
+		log.Println("AVISO: api_keys vazio, API aberta sem chave (limites por IP)")
+	} else {
+		log.Printf("Autenticação: %d chaves de API", len(cfg.APIKeys))
+	}
 	log.Println("")
 	log.Println("NOTA: Este servidor fornece apenas APIs REST.")
 	log.Println("      Para interface gráfica, execute: ./rede-cnpj-gui")
@@ -134,3 +163,13 @@ func main() {
 		log.Fatalf("Erro ao iniciar servidor: %v", err)
 	}
 }
+
+// limitar cria o middleware de limite de requisições de um grupo de rotas a
+// partir da configuração ("20/minute", "100 per hour")
+func limitar(grupo, limite string, uso *middleware.Uso) gin.HandlerFunc {
+	limites, err := middleware.ParseLimites(limite)
+	if err != nil {
+		log.Fatalf("Erro no limite de requisições %s: %v", grupo, err)
+	}
+	return middleware.Limitar(middleware.NewLimitador(grupo, limites), uso)
+}
@@ -0,0 +1,342 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// Algoritmos de centralidade
+const (
+	CentralidadeGrau        = "grau"
+	CentralidadeBetweenness = "betweenness"
+	CentralidadeCloseness   = "closeness"
+	CentralidadePageRank    = "pagerank"
+	CentralidadeEigenvector = "eigenvector"
+)
+
+// AlgoritmosCentralidade lista os algoritmos aceitos por Centralidade
+var AlgoritmosCentralidade = []string{
+	CentralidadeBetweenness,
+	CentralidadePageRank,
+	CentralidadeCloseness,
+	CentralidadeEigenvector,
+	CentralidadeGrau,
+}
+
+// Parâmetros das iterações de PageRank e eigenvector
+const (
+	amortecimentoPageRank = 0.85
+	toleranciaIteracao    = 1e-9
+	maxIteracoes          = 200
+)
+
+// Limites do grafo em betweenness e closeness, que fazem uma busca em
+// largura a partir de cada nó (custo proporcional a nós × ligações)
+const (
+	MaxNosCaminhosMinimos      = 5000
+	MaxLigacoesCaminhosMinimos = 20000
+)
+
+// ErrGrafoGrande indica um grafo acima dos limites de betweenness e closeness
+var ErrGrafoGrande = errors.New("grafo grande demais para o algoritmo")
+
+// NodeScore pontuação de um nó em um algoritmo de centralidade
+type NodeScore struct {
+	ID     string  `json:"id"`
+	Label  string  `json:"label"`
+	Score  float64 `json:"score"`
+	Degree int     `json:"degree"`
+}
+
+// ResultadoCentralidade pontuação de todos os nós, da maior para a menor
+type ResultadoCentralidade struct {
+	Algoritmo string      `json:"algoritmo"`
+	Nos       []NodeScore `json:"nos"`
+}
+
+// Top retorna os n nós de maior pontuação
+func (r *ResultadoCentralidade) Top(n int) []NodeScore {
+	if n > 0 && len(r.Nos) > n {
+		return r.Nos[:n]
+	}
+	return r.Nos
+}
+
+// grafoIndexado é a versão não direcionada e sem ligações repetidas do
+// grafo, com os nós numerados na ordem de graph.Nodes. Nós citados apenas
+// nas ligações entram no final.
+type grafoIndexado struct {
+	ids    []string
+	labels []string
+	indice map[string]int
+	adj    [][]int
+}
+
+func indexarGrafo(graph *models.Graph) *grafoIndexado {
+	g := &grafoIndexado{indice: make(map[string]int)}
+	adicionar := func(id, label string) int {
+		if i, ok := g.indice[id]; ok {
+			return i
+		}
+		g.indice[id] = len(g.ids)
+		g.ids = append(g.ids, id)
+		g.labels = append(g.labels, label)
+		g.adj = append(g.adj, nil)
+		return len(g.ids) - 1
+	}
+
+	for _, node := range graph.Nodes {
+		adicionar(node.ID, node.Label)
+	}
+
+	pares := make(map[[2]int]bool)
+	for _, edge := range graph.Edges {
+		u, v := adicionar(edge.From, ""), adicionar(edge.To, "")
+		if u == v {
+			continue
+		}
+		if u > v {
+			u, v = v, u
+		}
+		if pares[[2]int{u, v}] {
+			continue
+		}
+		pares[[2]int{u, v}] = true
+		g.adj[u] = append(g.adj[u], v)
+		g.adj[v] = append(g.adj[v], u)
+	}
+	return g
+}
+
+// Centralidade calcula a centralidade de todos os nós pelo algoritmo
+// informado. Os valores de betweenness e closeness são normalizados para o
+// intervalo [0, 1]; PageRank soma 1; eigenvector tem norma 1. Betweenness e
+// closeness recusam com ErrGrafoGrande os grafos acima dos seus limites.
+func (a *Analyzer) Centralidade(graph *models.Graph, algoritmo string) (*ResultadoCentralidade, error) {
+	g := indexarGrafo(graph)
+
+	var scores []float64
+	switch algoritmo {
+	case CentralidadeGrau:
+		scores = g.grau()
+	case CentralidadeBetweenness, CentralidadeCloseness:
+		if err := g.limitarCaminhosMinimos(algoritmo); err != nil {
+			return nil, err
+		}
+		if algoritmo == CentralidadeBetweenness {
+			scores = g.betweenness()
+		} else {
+			scores = g.closeness()
+		}
+	case CentralidadePageRank:
+		scores = g.pageRank()
+	case CentralidadeEigenvector:
+		scores = g.eigenvector()
+	default:
+		return nil, fmt.Errorf("algoritmo de centralidade não suportado: %q", algoritmo)
+	}
+
+	resultado := &ResultadoCentralidade{
+		Algoritmo: algoritmo,
+		Nos:       make([]NodeScore, len(g.ids)),
+	}
+	for i, id := range g.ids {
+		resultado.Nos[i] = NodeScore{ID: id, Label: g.labels[i], Score: scores[i], Degree: len(g.adj[i])}
+	}
+	sort.SliceStable(resultado.Nos, func(i, j int) bool {
+		return resultado.Nos[i].Score > resultado.Nos[j].Score
+	})
+	return resultado, nil
+}
+
+// limitarCaminhosMinimos retorna ErrGrafoGrande se o grafo passar de
+// MaxNosCaminhosMinimos nós ou MaxLigacoesCaminhosMinimos ligações
+func (g *grafoIndexado) limitarCaminhosMinimos(algoritmo string) error {
+	ligacoes := 0
+	for _, vizinhos := range g.adj {
+		ligacoes += len(vizinhos)
+	}
+	ligacoes /= 2
+	if len(g.ids) > MaxNosCaminhosMinimos || ligacoes > MaxLigacoesCaminhosMinimos {
+		return fmt.Errorf("%w: %s aceita até %d nós e %d ligações (recebidos %d e %d)", ErrGrafoGrande,
+			algoritmo, MaxNosCaminhosMinimos, MaxLigacoesCaminhosMinimos, len(g.ids), ligacoes)
+	}
+	return nil
+}
+
+func (g *grafoIndexado) grau() []float64 {
+	scores := make([]float64, len(g.ids))
+	for i := range g.adj {
+		scores[i] = float64(len(g.adj[i]))
+	}
+	return scores
+}
+
+// betweenness pelo algoritmo de Brandes: uma busca em largura por nó,
+// acumulando as dependências na ordem inversa de descoberta
+func (g *grafoIndexado) betweenness() []float64 {
+	n := len(g.ids)
+	cb := make([]float64, n)
+	sigma := make([]float64, n)
+	dist := make([]int, n)
+	delta := make([]float64, n)
+	pred := make([][]int, n)
+
+	for s := 0; s < n; s++ {
+		for i := 0; i < n; i++ {
+			sigma[i], dist[i], delta[i], pred[i] = 0, -1, 0, pred[i][:0]
+		}
+		sigma[s], dist[s] = 1, 0
+		pilha := make([]int, 0, n)
+		fila := []int{s}
+		for len(fila) > 0 {
+			v := fila[0]
+			fila = fila[1:]
+			pilha = append(pilha, v)
+			for _, w := range g.adj[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					fila = append(fila, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					pred[w] = append(pred[w], v)
+				}
+			}
+		}
+		for i := len(pilha) - 1; i >= 0; i-- {
+			w := pilha[i]
+			for _, v := range pred[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				cb[w] += delta[w]
+			}
+		}
+	}
+
+	// Cada par é contado nas duas direções; normaliza por (n-1)(n-2)/2 pares
+	if n > 2 {
+		escala := 1 / float64((n-1)*(n-2))
+		for i := range cb {
+			cb[i] *= escala
+		}
+	}
+	return cb
+}
+
+// closeness com a correção de Wasserman e Faust para grafos desconexos:
+// a proximidade dentro do componente é ponderada pela fração de nós
+// alcançáveis
+func (g *grafoIndexado) closeness() []float64 {
+	n := len(g.ids)
+	scores := make([]float64, n)
+	dist := make([]int, n)
+	for s := 0; s < n; s++ {
+		for i := range dist {
+			dist[i] = -1
+		}
+		dist[s] = 0
+		soma, alcancados := 0, 0
+		fila := []int{s}
+		for len(fila) > 0 {
+			v := fila[0]
+			fila = fila[1:]
+			for _, w := range g.adj[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					soma += dist[w]
+					alcancados++
+					fila = append(fila, w)
+				}
+			}
+		}
+		if soma > 0 && n > 1 {
+			r := float64(alcancados)
+			scores[s] = r / float64(soma) * r / float64(n-1)
+		}
+	}
+	return scores
+}
+
+// pageRank sobre o grafo não direcionado; nós isolados distribuem sua
+// pontuação entre todos
+func (g *grafoIndexado) pageRank() []float64 {
+	n := len(g.ids)
+	if n == 0 {
+		return nil
+	}
+	pr := make([]float64, n)
+	for i := range pr {
+		pr[i] = 1 / float64(n)
+	}
+	novo := make([]float64, n)
+	for iter := 0; iter < maxIteracoes; iter++ {
+		isolados := 0.0
+		for i := range novo {
+			novo[i] = 0
+		}
+		for v := 0; v < n; v++ {
+			if len(g.adj[v]) == 0 {
+				isolados += pr[v]
+				continue
+			}
+			parte := pr[v] / float64(len(g.adj[v]))
+			for _, w := range g.adj[v] {
+				novo[w] += parte
+			}
+		}
+		base := (1-amortecimentoPageRank)/float64(n) + amortecimentoPageRank*isolados/float64(n)
+		variacao := 0.0
+		for i := range novo {
+			novo[i] = base + amortecimentoPageRank*novo[i]
+			variacao += math.Abs(novo[i] - pr[i])
+		}
+		pr, novo = novo, pr
+		if variacao < toleranciaIteracao*float64(n) {
+			break
+		}
+	}
+	return pr
+}
+
+// eigenvector por iteração de potência sobre A + I. O deslocamento não muda
+// o autovetor principal, mas evita a oscilação em grafos bipartidos, como o
+// de sócios e empresas.
+func (g *grafoIndexado) eigenvector() []float64 {
+	n := len(g.ids)
+	if n == 0 {
+		return nil
+	}
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = 1 / math.Sqrt(float64(n))
+	}
+	novo := make([]float64, n)
+	for iter := 0; iter < maxIteracoes; iter++ {
+		norma := 0.0
+		for v := 0; v < n; v++ {
+			soma := x[v]
+			for _, w := range g.adj[v] {
+				soma += x[w]
+			}
+			novo[v] = soma
+			norma += soma * soma
+		}
+		norma = math.Sqrt(norma)
+		variacao := 0.0
+		for i := range novo {
+			novo[i] /= norma
+			variacao += math.Abs(novo[i] - x[i])
+		}
+		x, novo = novo, x
+		if variacao < toleranciaIteracao*float64(n) {
+			break
+		}
+	}
+	return x
+}
@@ -0,0 +1,117 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// grafoTeste cria um grafo com os nós e ligações informados como pares
+func grafoTeste(ids []string, pares ...[2]string) *models.Graph {
+	graph := &models.Graph{}
+	for _, id := range ids {
+		graph.Nodes = append(graph.Nodes, models.Node{ID: id, Label: "Nó " + id})
+	}
+	for _, p := range pares {
+		graph.Edges = append(graph.Edges, models.Edge{From: p[0], To: p[1]})
+	}
+	return graph
+}
+
+// doisTriangulos: A-B-C e D-E-F ligados pela ponte C-D
+func doisTriangulos() *models.Graph {
+	return grafoTeste([]string{"A", "B", "C", "D", "E", "F"},
+		[2]string{"A", "B"}, [2]string{"B", "C"}, [2]string{"C", "A"},
+		[2]string{"D", "E"}, [2]string{"E", "F"}, [2]string{"F", "D"},
+		[2]string{"C", "D"})
+}
+
+func pontuacoes(r *ResultadoCentralidade) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, n := range r.Nos {
+		scores[n.ID] = n.Score
+	}
+	return scores
+}
+
+func TestCentralidadeEstrela(t *testing.T) {
+	// Centro com quatro folhas; a ligação repetida não altera o resultado
+	estrela := grafoTeste([]string{"C", "F1", "F2", "F3", "F4"},
+		[2]string{"F1", "C"}, [2]string{"F2", "C"}, [2]string{"F3", "C"}, [2]string{"F4", "C"},
+		[2]string{"C", "F1"})
+
+	tests := []struct {
+		algoritmo     string
+		centro, folha float64
+	}{
+		{CentralidadeGrau, 4, 1},
+		{CentralidadeBetweenness, 1, 0},
+		{CentralidadeCloseness, 1, 4.0 / 7},
+		{CentralidadeEigenvector, 0.7071, 0.3536},
+	}
+	a := NewAnalyzer()
+	for _, tt := range tests {
+		r, err := a.Centralidade(estrela, tt.algoritmo)
+		if err != nil {
+			t.Fatalf("Centralidade(%q) erro: %v", tt.algoritmo, err)
+		}
+		if r.Nos[0].ID != "C" || r.Nos[0].Degree != 4 {
+			t.Errorf("Centralidade(%q): primeiro nó = %+v, esperado C com grau 4", tt.algoritmo, r.Nos[0])
+		}
+		scores := pontuacoes(r)
+		if math.Abs(scores["C"]-tt.centro) > 1e-3 || math.Abs(scores["F1"]-tt.folha) > 1e-3 {
+			t.Errorf("Centralidade(%q) = centro %.4f, folha %.4f, esperado %.4f e %.4f",
+				tt.algoritmo, scores["C"], scores["F1"], tt.centro, tt.folha)
+		}
+	}
+}
+
+func TestCentralidadePonte(t *testing.T) {
+	a := NewAnalyzer()
+	for _, algoritmo := range AlgoritmosCentralidade {
+		r, err := a.Centralidade(doisTriangulos(), algoritmo)
+		if err != nil {
+			t.Fatalf("Centralidade(%q) erro: %v", algoritmo, err)
+		}
+		topo := map[string]bool{r.Top(2)[0].ID: true, r.Top(2)[1].ID: true}
+		if !topo["C"] || !topo["D"] {
+			t.Errorf("Centralidade(%q): mais centrais = %v, esperado C e D", algoritmo, r.Top(2))
+		}
+		if r.Nos[0].Label != "Nó C" && r.Nos[0].Label != "Nó D" {
+			t.Errorf("Centralidade(%q): label = %q", algoritmo, r.Nos[0].Label)
+		}
+	}
+
+	r, _ := a.Centralidade(doisTriangulos(), CentralidadePageRank)
+	soma := 0.0
+	for _, n := range r.Nos {
+		soma += n.Score
+	}
+	if math.Abs(soma-1) > 1e-6 {
+		t.Errorf("soma do PageRank = %v, esperado 1", soma)
+	}
+
+	if _, err := a.Centralidade(doisTriangulos(), "katz"); err == nil {
+		t.Error("algoritmo desconhecido não retornou erro")
+	}
+}
+
+func TestCentralidadeGrafoGrande(t *testing.T) {
+	ids := make([]string, MaxNosCaminhosMinimos+1)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+	}
+	grande := grafoTeste(ids)
+	a := NewAnalyzer()
+	for _, algoritmo := range []string{CentralidadeBetweenness, CentralidadeCloseness} {
+		if _, err := a.Centralidade(grande, algoritmo); !errors.Is(err, ErrGrafoGrande) {
+			t.Errorf("Centralidade(%s) com %d nós = %v, esperado ErrGrafoGrande", algoritmo, len(ids), err)
+		}
+	}
+	if _, err := a.Centralidade(grande, CentralidadePageRank); err != nil {
+		t.Errorf("Centralidade(pagerank) com %d nós erro: %v", len(ids), err)
+	}
+}
@@ -0,0 +1,427 @@
+package analytics
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// Algoritmos de detecção de comunidades
+const (
+	ComunidadesComponentes = "componentes"
+	ComunidadesLouvain     = "louvain"
+	ComunidadesLeiden      = "leiden"
+)
+
+// AlgoritmosComunidades lista os algoritmos aceitos por Comunidades
+var AlgoritmosComunidades = []string{
+	ComunidadesLouvain,
+	ComunidadesLeiden,
+	ComunidadesComponentes,
+}
+
+// maxNiveis limita as agregações de Louvain/Leiden
+const maxNiveis = 50
+
+// Comunidade grupo de nós, numerado da maior para a menor comunidade
+type Comunidade struct {
+	ID      int      `json:"id"`
+	Tamanho int      `json:"tamanho"`
+	Nos     []string `json:"nos"`
+}
+
+// NodeCommunity comunidade de um nó
+type NodeCommunity struct {
+	ID         string `json:"id"`
+	Label      string `json:"label"`
+	Comunidade int    `json:"comunidade"`
+}
+
+// ResultadoComunidades partição do grafo e a modularidade obtida
+type ResultadoComunidades struct {
+	Algoritmo    string          `json:"algoritmo"`
+	Modularidade float64         `json:"modularidade"`
+	Comunidades  []Comunidade    `json:"comunidades"`
+	Nos          []NodeCommunity `json:"nos"`
+}
+
+// Comunidades particiona o grafo pelo algoritmo informado: componentes
+// conexos, Louvain ou Leiden (Louvain com etapa de refinamento, que garante
+// comunidades conexas). A ordem dos nós é determinística, portanto o mesmo
+// grafo produz sempre a mesma partição.
+func (a *Analyzer) Comunidades(graph *models.Graph, algoritmo string) (*ResultadoComunidades, error) {
+	g := indexarGrafo(graph)
+
+	var particao []int
+	switch algoritmo {
+	case ComunidadesComponentes:
+		particao = g.componentes()
+	case ComunidadesLouvain:
+		particao = g.ponderado().louvain(false)
+	case ComunidadesLeiden:
+		particao = g.ponderado().louvain(true)
+	default:
+		return nil, fmt.Errorf("algoritmo de comunidades não suportado: %q", algoritmo)
+	}
+	particao = renumerarPorTamanho(particao)
+
+	resultado := &ResultadoComunidades{
+		Algoritmo:    algoritmo,
+		Modularidade: g.ponderado().modularidade(particao),
+		Nos:          make([]NodeCommunity, len(g.ids)),
+	}
+	for i, id := range g.ids {
+		c := particao[i]
+		for len(resultado.Comunidades) <= c {
+			resultado.Comunidades = append(resultado.Comunidades, Comunidade{ID: len(resultado.Comunidades)})
+		}
+		resultado.Comunidades[c].Nos = append(resultado.Comunidades[c].Nos, id)
+		resultado.Comunidades[c].Tamanho++
+		resultado.Nos[i] = NodeCommunity{ID: id, Label: g.labels[i], Comunidade: c}
+	}
+	if resultado.Comunidades == nil {
+		resultado.Comunidades = []Comunidade{}
+	}
+	return resultado, nil
+}
+
+// componentes atribui a cada nó o índice do seu componente conexo
+func (g *grafoIndexado) componentes() []int {
+	particao := make([]int, len(g.ids))
+	for i := range particao {
+		particao[i] = -1
+	}
+	c := 0
+	for s := range g.ids {
+		if particao[s] >= 0 {
+			continue
+		}
+		particao[s] = c
+		pilha := []int{s}
+		for len(pilha) > 0 {
+			v := pilha[len(pilha)-1]
+			pilha = pilha[:len(pilha)-1]
+			for _, w := range g.adj[v] {
+				if particao[w] < 0 {
+					particao[w] = c
+					pilha = append(pilha, w)
+				}
+			}
+		}
+		c++
+	}
+	return particao
+}
+
+// renumerarPorTamanho numera as comunidades da maior para a menor; empates
+// seguem a ordem do primeiro nó
+func renumerarPorTamanho(particao []int) []int {
+	tamanho := make(map[int]int)
+	primeiro := make(map[int]int)
+	var rotulos []int
+	for i, c := range particao {
+		if _, ok := tamanho[c]; !ok {
+			primeiro[c] = i
+			rotulos = append(rotulos, c)
+		}
+		tamanho[c]++
+	}
+	sort.SliceStable(rotulos, func(i, j int) bool {
+		if tamanho[rotulos[i]] != tamanho[rotulos[j]] {
+			return tamanho[rotulos[i]] > tamanho[rotulos[j]]
+		}
+		return primeiro[rotulos[i]] < primeiro[rotulos[j]]
+	})
+	novo := make(map[int]int, len(rotulos))
+	for i, c := range rotulos {
+		novo[c] = i
+	}
+	resultado := make([]int, len(particao))
+	for i, c := range particao {
+		resultado[i] = novo[c]
+	}
+	return resultado
+}
+
+// grafoPonderado é o grafo usado por Louvain/Leiden. Nos níveis agregados
+// cada nó é uma comunidade do nível anterior e o laço (peso de v em adj[v])
+// guarda o peso interno, contado nas duas direções.
+type grafoPonderado struct {
+	adj   []map[int]float64
+	grau  []float64 // soma dos pesos de cada nó, incluindo o laço
+	total float64   // 2m: soma de todos os graus
+}
+
+func (g *grafoIndexado) ponderado() *grafoPonderado {
+	gp := &grafoPonderado{
+		adj:  make([]map[int]float64, len(g.ids)),
+		grau: make([]float64, len(g.ids)),
+	}
+	for v, vizinhos := range g.adj {
+		gp.adj[v] = make(map[int]float64, len(vizinhos))
+		for _, w := range vizinhos {
+			gp.adj[v][w] = 1
+		}
+		gp.grau[v] = float64(len(vizinhos))
+		gp.total += gp.grau[v]
+	}
+	return gp
+}
+
+// vizinhosOrdenados evita que a ordem de iteração dos mapas altere o
+// resultado
+func (g *grafoPonderado) vizinhosOrdenados(v int) []int {
+	vizinhos := make([]int, 0, len(g.adj[v]))
+	for w := range g.adj[v] {
+		vizinhos = append(vizinhos, w)
+	}
+	sort.Ints(vizinhos)
+	return vizinhos
+}
+
+// modularidade Q = Σc [in_c/2m - (tot_c/2m)²]
+func (g *grafoPonderado) modularidade(particao []int) float64 {
+	if g.total == 0 {
+		return 0
+	}
+	interno := make(map[int]float64)
+	tot := make(map[int]float64)
+	for v := range g.adj {
+		tot[particao[v]] += g.grau[v]
+		for w, peso := range g.adj[v] {
+			if particao[v] == particao[w] {
+				interno[particao[v]] += peso
+			}
+		}
+	}
+	q := 0.0
+	for c, t := range tot {
+		q += interno[c]/g.total - (t/g.total)*(t/g.total)
+	}
+	return q
+}
+
+// louvain alterna a movimentação local dos nós com a agregação das
+// comunidades até que nenhum nó mude de comunidade. Com refinar (Leiden),
+// cada comunidade é subdividida em subcomunidades bem conectadas antes da
+// agregação, e são estas que viram os nós do nível seguinte.
+func (g *grafoPonderado) louvain(refinar bool) []int {
+	n := len(g.adj)
+	membro := make([]int, n) // nó original -> nó do nível atual
+	for i := range membro {
+		membro[i] = i
+	}
+
+	nivel := g
+	particao := make([]int, n)
+	for i := range particao {
+		particao[i] = i
+	}
+
+	for iter := 0; iter < maxNiveis; iter++ {
+		nivel.moverNos(particao)
+
+		agregacao := particao
+		if refinar {
+			agregacao = nivel.refinar(particao)
+		}
+		agregacao = compactar(agregacao)
+		proximo := nivel.agregar(agregacao)
+		if len(proximo.adj) == len(nivel.adj) {
+			break
+		}
+
+		// Cada nó agregado começa na comunidade (não refinada) de origem
+		inicial := make([]int, len(proximo.adj))
+		for v, a := range agregacao {
+			inicial[a] = particao[v]
+		}
+		for i, m := range membro {
+			membro[i] = agregacao[m]
+		}
+		nivel, particao = proximo, compactar(inicial)
+	}
+
+	resultado := make([]int, n)
+	for i, m := range membro {
+		resultado[i] = particao[m]
+	}
+	return resultado
+}
+
+// moverNos move cada nó para a comunidade vizinha de maior ganho de
+// modularidade, repetindo as passagens enquanto houver melhora
+func (g *grafoPonderado) moverNos(particao []int) bool {
+	if g.total == 0 {
+		return false
+	}
+	tot := make([]float64, len(g.adj))
+	for v, c := range particao {
+		tot[c] += g.grau[v]
+	}
+
+	mudou := false
+	for passagem := 0; passagem < maxIteracoes; passagem++ {
+		movidos := 0
+		for v := range g.adj {
+			atual := particao[v]
+			tot[atual] -= g.grau[v]
+
+			// Peso das ligações de v para cada comunidade vizinha
+			pesos := map[int]float64{atual: 0}
+			ordem := []int{atual}
+			for _, w := range g.vizinhosOrdenados(v) {
+				if w == v {
+					continue
+				}
+				c := particao[w]
+				if _, ok := pesos[c]; !ok {
+					ordem = append(ordem, c)
+				}
+				pesos[c] += g.adj[v][w]
+			}
+
+			melhor, melhorGanho := atual, pesos[atual]-tot[atual]*g.grau[v]/g.total
+			for _, c := range ordem {
+				if ganho := pesos[c] - tot[c]*g.grau[v]/g.total; ganho > melhorGanho+1e-12 {
+					melhor, melhorGanho = c, ganho
+				}
+			}
+
+			tot[melhor] += g.grau[v]
+			if melhor != atual {
+				particao[v] = melhor
+				movidos++
+			}
+		}
+		if movidos == 0 {
+			break
+		}
+		mudou = true
+	}
+	return mudou
+}
+
+// refinar subdivide cada comunidade: os nós começam isolados e só se juntam
+// a uma subcomunidade da mesma comunidade se ambos estiverem bem conectados
+// ao restante dela e o ganho de modularidade não for negativo. Assim as
+// comunidades agregadas nunca são desconexas.
+func (g *grafoPonderado) refinar(particao []int) []int {
+	n := len(g.adj)
+	refinada := make([]int, n)
+	for i := range refinada {
+		refinada[i] = i
+	}
+	if g.total == 0 {
+		return refinada
+	}
+
+	totComunidade := make(map[int]float64)
+	for v, c := range particao {
+		totComunidade[c] += g.grau[v]
+	}
+	// Peso das ligações de cada nó/subcomunidade para o restante da comunidade
+	externo := make([]float64, n)
+	for v := range g.adj {
+		for w, peso := range g.adj[v] {
+			if w != v && particao[w] == particao[v] {
+				externo[v] += peso
+			}
+		}
+	}
+	totSub := append([]float64(nil), g.grau...)
+	tamanho := make([]int, n)
+	for i := range tamanho {
+		tamanho[i] = 1
+	}
+
+	bemConectado := func(peso, tot float64, c int) bool {
+		return peso >= tot*(totComunidade[c]-tot)/g.total
+	}
+
+	for v := range g.adj {
+		c := particao[v]
+		// Só nós ainda isolados e bem conectados à comunidade são movidos
+		if tamanho[refinada[v]] > 1 || !bemConectado(externo[v], g.grau[v], c) {
+			continue
+		}
+
+		pesos := make(map[int]float64)
+		var ordem []int
+		for _, w := range g.vizinhosOrdenados(v) {
+			if w == v || particao[w] != c {
+				continue
+			}
+			s := refinada[w]
+			if _, ok := pesos[s]; !ok {
+				ordem = append(ordem, s)
+			}
+			pesos[s] += g.adj[v][w]
+		}
+
+		melhor, melhorGanho := -1, 0.0
+		for _, s := range ordem {
+			if !bemConectado(externo[s], totSub[s], c) {
+				continue
+			}
+			if ganho := pesos[s] - totSub[s]*g.grau[v]/g.total; ganho >= melhorGanho {
+				melhor, melhorGanho = s, ganho
+			}
+		}
+		if melhor < 0 {
+			continue
+		}
+
+		origem := refinada[v]
+		refinada[v] = melhor
+		tamanho[origem]--
+		tamanho[melhor]++
+		totSub[origem] -= g.grau[v]
+		totSub[melhor] += g.grau[v]
+		// As ligações entre v e a subcomunidade deixam de ser externas
+		externo[melhor] += externo[v] - 2*pesos[melhor]
+		externo[origem] = 0
+	}
+	return refinada
+}
+
+// compactar renumera os rótulos para 0..k-1 na ordem de aparição
+func compactar(particao []int) []int {
+	novo := make(map[int]int)
+	resultado := make([]int, len(particao))
+	for i, c := range particao {
+		r, ok := novo[c]
+		if !ok {
+			r = len(novo)
+			novo[c] = r
+		}
+		resultado[i] = r
+	}
+	return resultado
+}
+
+// agregar cria um nó por comunidade; o peso entre comunidades é a soma das
+// ligações entre seus nós e o peso interno vira laço
+func (g *grafoPonderado) agregar(particao []int) *grafoPonderado {
+	k := 0
+	for _, c := range particao {
+		k = max(k, c+1)
+	}
+	agregado := &grafoPonderado{
+		adj:   make([]map[int]float64, k),
+		grau:  make([]float64, k),
+		total: g.total,
+	}
+	for i := range agregado.adj {
+		agregado.adj[i] = make(map[int]float64)
+	}
+	for v := range g.adj {
+		cv := particao[v]
+		agregado.grau[cv] += g.grau[v]
+		for w, peso := range g.adj[v] {
+			agregado.adj[cv][particao[w]] += peso
+		}
+	}
+	return agregado
+}
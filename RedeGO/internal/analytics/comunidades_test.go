@@ -0,0 +1,73 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComunidades(t *testing.T) {
+	graph := doisTriangulos()
+	// Nó isolado e nó citado apenas em uma ligação
+	graph.Nodes = append(graph.Nodes, grafoTeste([]string{"G"}).Nodes...)
+	graph.Edges = append(graph.Edges, grafoTeste(nil, [2]string{"F", "H"}).Edges...)
+
+	tests := []struct {
+		algoritmo   string
+		comunidades int
+	}{
+		{ComunidadesComponentes, 2},
+		{ComunidadesLouvain, 3},
+		{ComunidadesLeiden, 3},
+	}
+	a := NewAnalyzer()
+	for _, tt := range tests {
+		r, err := a.Comunidades(graph, tt.algoritmo)
+		if err != nil {
+			t.Fatalf("Comunidades(%q) erro: %v", tt.algoritmo, err)
+		}
+		if len(r.Comunidades) != tt.comunidades {
+			t.Fatalf("Comunidades(%q) = %d comunidades %v, esperado %d", tt.algoritmo, len(r.Comunidades), r.Comunidades, tt.comunidades)
+		}
+		if len(r.Nos) != 8 {
+			t.Errorf("Comunidades(%q): %d nós, esperado 8", tt.algoritmo, len(r.Nos))
+		}
+
+		membro := make(map[string]int)
+		for _, n := range r.Nos {
+			membro[n.ID] = n.Comunidade
+		}
+		if membro["G"] != tt.comunidades-1 || r.Comunidades[tt.comunidades-1].Tamanho != 1 {
+			t.Errorf("Comunidades(%q): nó isolado na comunidade %d, esperado a última", tt.algoritmo, membro["G"])
+		}
+		if tt.algoritmo == ComunidadesComponentes {
+			continue
+		}
+		if membro["A"] != membro["B"] || membro["B"] != membro["C"] || membro["D"] != membro["E"] ||
+			membro["E"] != membro["F"] || membro["F"] != membro["H"] || membro["A"] == membro["D"] {
+			t.Errorf("Comunidades(%q) = %v, esperado {A,B,C} e {D,E,F,H}", tt.algoritmo, membro)
+		}
+		// D, E, F e H formam a maior comunidade
+		if membro["D"] != 0 {
+			t.Errorf("Comunidades(%q): maior comunidade = %d, esperado 0", tt.algoritmo, membro["D"])
+		}
+		// m = 8; in = 6 e 8; tot = 7 e 9
+		esperado := 6.0/16 - math.Pow(7.0/16, 2) + 8.0/16 - math.Pow(9.0/16, 2)
+		if math.Abs(r.Modularidade-esperado) > 1e-9 {
+			t.Errorf("Comunidades(%q): modularidade = %.4f, esperado %.4f", tt.algoritmo, r.Modularidade, esperado)
+		}
+	}
+
+	if _, err := a.Comunidades(graph, "girvan-newman"); err == nil {
+		t.Error("algoritmo desconhecido não retornou erro")
+	}
+}
+
+func TestComunidadesGrafoVazio(t *testing.T) {
+	r, err := NewAnalyzer().Comunidades(grafoTeste(nil), ComunidadesLeiden)
+	if err != nil {
+		t.Fatalf("Comunidades() erro: %v", err)
+	}
+	if len(r.Comunidades) != 0 || r.Modularidade != 0 {
+		t.Errorf("grafo vazio = %+v", r)
+	}
+}
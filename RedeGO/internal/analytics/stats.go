@@ -18,6 +18,8 @@ type GraphStats struct {
 	NosMaisConectados []NodeDegree      `json:"nosMaisConectados"`
 	TiposRelacao     map[string]int     `json:"tiposRelacao"`
 	ComponentesConexos int              `json:"componentesConexos"`
+	Centralidade      *ResultadoCentralidade `json:"centralidade,omitempty"`
+	Comunidades       *ResultadoComunidades  `json:"comunidades,omitempty"`
 }
 
 // NodeDegree grau de um nó
@@ -158,7 +160,8 @@ func (a *Analyzer) dfs(nodeID string, visited map[string]bool, adjList map[strin
 	}
 }
 
-// DetectCentralNodes detecta nós centrais (betweenness centrality simplificado)
+// DetectCentralNodes ordena os nós pelo grau. Para betweenness, closeness,
+// PageRank ou eigenvector, use Centralidade.
 func (a *Analyzer) DetectCentralNodes(graph *models.Graph, top int) []NodeDegree {
 	// Por simplicidade, usa grau como proxy para centralidade
 	degrees := a.calculateDegrees(graph)
@@ -173,7 +176,8 @@ func (a *Analyzer) DetectCentralNodes(graph *models.Graph, top int) []NodeDegree
 	return degrees
 }
 
-// DetectCommunities detecta comunidades (algoritmo simples baseado em conexões)
+// DetectCommunities agrupa os nós por componente conexo. Para Louvain ou
+// Leiden, use Comunidades.
 func (a *Analyzer) DetectCommunities(graph *models.Graph) map[int][]string {
 	visited := make(map[string]bool)
 	adjList := make(map[string][]string)
@@ -0,0 +1,297 @@
+// Package arquivos guarda os arquivos do usuário (grafos em JSON, planilhas)
+// na pasta de arquivos, com caminhos canônicos, uma subpasta por chave de API,
+// cota de espaço e extensões permitidas.
+package arquivos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/utils"
+)
+
+// Erros retornados pelo Store
+var (
+	ErrNomeInvalido         = errors.New("nome de arquivo inválido")
+	ErrExtensaoNaoPermitida = errors.New("extensão de arquivo não permitida")
+	ErrArquivoNaoEncontrado = errors.New("arquivo não encontrado")
+	ErrArquivoGrande        = errors.New("o arquivo é muito grande e não foi salvo")
+	ErrCotaExcedida         = errors.New("cota de arquivos excedida")
+)
+
+// ExtensoesPermitidas são as extensões aceitas na pasta de arquivos, as
+// mesmas da versão Python
+var ExtensoesPermitidas = []string{".json", ".csv", ".xlsx", ".pdf"}
+
+const (
+	// TamanhoMaximoPadrao é o tamanho máximo de um arquivo salvo (bytes)
+	TamanhoMaximoPadrao int64 = 100000
+	// CotaPadrao é o espaço máximo de cada pasta de usuário (bytes)
+	CotaPadrao int64 = 50 << 20
+
+	// pastaChaves agrupa as subpastas das chaves de API
+	pastaChaves = "chaves"
+	// pastaAnonimos agrupa as subpastas dos usuários remotos sem chave
+	pastaAnonimos = "anonimos"
+)
+
+// Arquivo descreve um arquivo de uma pasta de usuário
+type Arquivo struct {
+	Nome         string    `json:"nome"`
+	Tamanho      int64     `json:"tamanho"`
+	ModificadoEm time.Time `json:"modificadoEm"`
+}
+
+// Store acessa a pasta de arquivos. Cada chave de API e cada endereço IP
+// remoto sem chave têm sua subpasta, com cota própria; os arquivos do usuário
+// local ficam na raiz, compartilhada com a versão Python.
+type Store struct {
+	raiz          string
+	cota          int64
+	tamanhoMaximo int64
+	extensoes     []string
+	mu            sync.Mutex // serializa verificação de cota e escrita
+}
+
+// NewStore cria o repositório sobre a pasta informada, com a cota, o tamanho
+// máximo e as extensões padrão. A pasta é criada no primeiro salvamento.
+func NewStore(raiz string) *Store {
+	return &Store{
+		raiz:          raiz,
+		cota:          CotaPadrao,
+		tamanhoMaximo: TamanhoMaximoPadrao,
+		extensoes:     ExtensoesPermitidas,
+	}
+}
+
+// ComCota define o espaço máximo de cada pasta de usuário (0 = sem limite)
+func (s *Store) ComCota(bytes int64) *Store {
+	s.cota = bytes
+	return s
+}
+
+// ComTamanhoMaximo define o tamanho máximo de um arquivo (0 = sem limite)
+func (s *Store) ComTamanhoMaximo(bytes int64) *Store {
+	s.tamanhoMaximo = bytes
+	return s
+}
+
+// ComExtensoes define as extensões aceitas (com ponto, ex.: ".json")
+func (s *Store) ComExtensoes(extensoes ...string) *Store {
+	s.extensoes = extensoes
+	return s
+}
+
+// Cota retorna o espaço máximo de cada pasta de usuário
+func (s *Store) Cota() int64 {
+	return s.cota
+}
+
+// TamanhoMaximo retorna o tamanho máximo de um arquivo (0 = sem limite)
+func (s *Store) TamanhoMaximo() int64 {
+	return s.tamanhoMaximo
+}
+
+// PastaChave retorna a subpasta da chave de API: um resumo SHA-256, para que
+// a chave não apareça no disco. Sem chave retorna "" (a raiz).
+func PastaChave(chave string) string {
+	if chave == "" {
+		return ""
+	}
+	soma := sha256.Sum256([]byte(chave))
+	return filepath.Join(pastaChaves, hex.EncodeToString(soma[:8]))
+}
+
+// PastaAnonima retorna a subpasta do usuário remoto sem chave de API, pelo
+// resumo SHA-256 do endereço IP
+func PastaAnonima(ip string) string {
+	soma := sha256.Sum256([]byte(ip))
+	return filepath.Join(pastaAnonimos, hex.EncodeToString(soma[:8]))
+}
+
+// NomeSeguro sanitiza um nome enviado pelo usuário e acrescenta .json quando
+// não houver extensão
+func NomeSeguro(nome string) string {
+	nome = utils.SecureFilename(nome)
+	if nome != "" && filepath.Ext(nome) == "" {
+		nome += ".json"
+	}
+	return nome
+}
+
+// Caminho valida o nome e retorna o caminho absoluto do arquivo dentro da
+// pasta do usuário. O nome precisa já estar sanitizado (ver NomeSeguro) e o
+// caminho canônico, sem links simbólicos, não pode sair da pasta.
+func (s *Store) Caminho(pasta, nome string) (string, error) {
+	if nome == "" || nome != utils.SecureFilename(nome) || strings.HasPrefix(nome, ".") {
+		return "", ErrNomeInvalido
+	}
+	if !utils.IsValidExtension(nome, s.extensoes) {
+		return "", ErrExtensaoNaoPermitida
+	}
+	dir, err := s.dirUsuario(pasta)
+	if err != nil {
+		return "", err
+	}
+	caminho := filepath.Join(dir, nome)
+	if rel, err := filepath.Rel(dir, caminho); err != nil || rel != nome {
+		return "", ErrNomeInvalido
+	}
+	if info, err := os.Lstat(caminho); err == nil && !info.Mode().IsRegular() {
+		return "", ErrNomeInvalido
+	}
+	return caminho, nil
+}
+
+// dirUsuario retorna o caminho absoluto da pasta do usuário, conferindo que
+// ela (inclusive após resolver links simbólicos) fica dentro da raiz
+func (s *Store) dirUsuario(pasta string) (string, error) {
+	raiz, err := filepath.Abs(s.raiz)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(raiz, pasta)
+	if rel, err := filepath.Rel(raiz, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrNomeInvalido
+	}
+	raizReal, errRaiz := filepath.EvalSymlinks(raiz)
+	dirReal, errDir := filepath.EvalSymlinks(dir)
+	if errRaiz == nil && errDir == nil {
+		if rel, err := filepath.Rel(raizReal, dirReal); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", ErrNomeInvalido
+		}
+	}
+	return dir, nil
+}
+
+// Listar retorna os arquivos da pasta do usuário em ordem alfabética. A pasta
+// inexistente equivale a uma pasta vazia.
+func (s *Store) Listar(pasta string) ([]Arquivo, error) {
+	dir, err := s.dirUsuario(pasta)
+	if err != nil {
+		return nil, err
+	}
+	entradas, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Arquivo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lista := []Arquivo{}
+	for _, e := range entradas {
+		if !e.Type().IsRegular() || !utils.IsValidExtension(e.Name(), s.extensoes) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		lista = append(lista, Arquivo{Nome: e.Name(), Tamanho: info.Size(), ModificadoEm: info.ModTime()})
+	}
+	sort.Slice(lista, func(i, j int) bool { return lista[i].Nome < lista[j].Nome })
+	return lista, nil
+}
+
+// Uso soma o tamanho dos arquivos da pasta do usuário
+func (s *Store) Uso(pasta string) (int64, error) {
+	lista, err := s.Listar(pasta)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, a := range lista {
+		total += a.Tamanho
+	}
+	return total, nil
+}
+
+// Ler retorna o conteúdo de um arquivo da pasta do usuário
+func (s *Store) Ler(pasta, nome string) ([]byte, error) {
+	caminho, err := s.Caminho(pasta, nome)
+	if err != nil {
+		return nil, err
+	}
+	dados, err := os.ReadFile(caminho)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrArquivoNaoEncontrado
+	}
+	return dados, err
+}
+
+// Salvar grava o arquivo na pasta do usuário e retorna o nome usado. Sem
+// reescrever, um nome já existente recebe um sufixo numérico; com reescrever,
+// o arquivo precisa existir.
+func (s *Store) Salvar(pasta, nome string, dados []byte, reescrever bool) (string, error) {
+	if s.tamanhoMaximo > 0 && int64(len(dados)) > s.tamanhoMaximo {
+		return "", ErrArquivoGrande
+	}
+	caminho, err := s.Caminho(pasta, nome)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var anterior int64
+	if reescrever {
+		info, err := os.Stat(caminho)
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", ErrArquivoNaoEncontrado
+		}
+		if err != nil {
+			return "", err
+		}
+		anterior = info.Size()
+	} else {
+		caminho = utils.NomeArquivoNovo(caminho)
+		if utils.FileExists(caminho) {
+			return "", fmt.Errorf("não há nome disponível para %s", nome)
+		}
+	}
+
+	if s.cota > 0 {
+		uso, err := s.Uso(pasta)
+		if err != nil {
+			return "", err
+		}
+		if uso-anterior+int64(len(dados)) > s.cota {
+			return "", ErrCotaExcedida
+		}
+	}
+
+	if err := utils.EnsureDir(filepath.Dir(caminho)); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(caminho, dados, 0644); err != nil {
+		return "", err
+	}
+	return filepath.Base(caminho), nil
+}
+
+// Excluir remove um arquivo da pasta do usuário
+func (s *Store) Excluir(pasta, nome string) error {
+	caminho, err := s.Caminho(pasta, nome)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(caminho); errors.Is(err, fs.ErrNotExist) {
+		return ErrArquivoNaoEncontrado
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package arquivos
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNomeSeguro(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado string
+	}{
+		{"grafo", "grafo.json"},
+		{"grafo.json", "grafo.json"},
+		{"../../etc/passwd", "passwd.json"},
+		{"rede cnpj.xlsx", "rede_cnpj.xlsx"},
+		{"pasta/sub/dados.csv", "dados.csv"},
+	}
+	for _, c := range casos {
+		if obtido := NomeSeguro(c.entrada); obtido != c.esperado {
+			t.Errorf("NomeSeguro(%q) = %q, esperado %q", c.entrada, obtido, c.esperado)
+		}
+	}
+}
+
+func TestCaminhoRejeitaEscapes(t *testing.T) {
+	s := NewStore(t.TempDir())
+	casos := []struct {
+		pasta string
+		nome  string
+		erro  error
+	}{
+		{"", "../fora.json", ErrNomeInvalido},
+		{"", "sub/dentro.json", ErrNomeInvalido},
+		{"", ".oculto.json", ErrNomeInvalido},
+		{"", "", ErrNomeInvalido},
+		{"", "script.sh", ErrExtensaoNaoPermitida},
+		{"../outra", "grafo.json", ErrNomeInvalido},
+		{"", "grafo.json", nil},
+		{PastaChave("chave"), "grafo.xlsx", nil},
+	}
+	for _, c := range casos {
+		if _, err := s.Caminho(c.pasta, c.nome); !errors.Is(err, c.erro) {
+			t.Errorf("Caminho(%q, %q) erro = %v, esperado %v", c.pasta, c.nome, err, c.erro)
+		}
+	}
+}
+
+func TestCaminhoRejeitaLinkSimbolico(t *testing.T) {
+	raiz := t.TempDir()
+	fora := filepath.Join(t.TempDir(), "segredo.json")
+	if err := os.WriteFile(fora, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(fora, filepath.Join(raiz, "link.json")); err != nil {
+		t.Skipf("sem suporte a links simbólicos: %v", err)
+	}
+	if err := os.Symlink(filepath.Dir(fora), filepath.Join(raiz, "chaves")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewStore(raiz)
+	if _, err := s.Ler("", "link.json"); !errors.Is(err, ErrNomeInvalido) {
+		t.Errorf("Ler(link simbólico) erro = %v, esperado ErrNomeInvalido", err)
+	}
+	if _, err := s.Ler("chaves", "segredo.json"); !errors.Is(err, ErrNomeInvalido) {
+		t.Errorf("Ler(pasta que aponta para fora) erro = %v, esperado ErrNomeInvalido", err)
+	}
+}
+
+func TestStoreCiclo(t *testing.T) {
+	s := NewStore(t.TempDir()).ComCota(100).ComTamanhoMaximo(60)
+	pasta := PastaChave("minha-chave")
+	if pasta == "" || pasta == PastaChave("outra-chave") {
+		t.Fatalf("PastaChave() = %q, esperado uma pasta por chave", pasta)
+	}
+	if anonima := PastaAnonima("203.0.113.7"); anonima == pasta || anonima == PastaAnonima("203.0.113.8") {
+		t.Fatalf("PastaAnonima() = %q, esperado uma pasta por IP, separada das chaves", anonima)
+	}
+
+	nome, err := s.Salvar(pasta, "grafo.json", []byte(`{"no":[]}`), false)
+	if err != nil || nome != "grafo.json" {
+		t.Fatalf("Salvar() = %q, %v", nome, err)
+	}
+	nome, err = s.Salvar(pasta, "grafo.json", []byte(`{"no":[1]}`), false)
+	if err != nil || nome != "grafo0001.json" {
+		t.Errorf("Salvar() de nome repetido = %q, %v, esperado grafo0001.json", nome, err)
+	}
+	if _, err := s.Salvar(pasta, "novo.json", []byte(`{}`), true); !errors.Is(err, ErrArquivoNaoEncontrado) {
+		t.Errorf("Salvar(reescrever) de arquivo inexistente = %v, esperado ErrArquivoNaoEncontrado", err)
+	}
+	if _, err := s.Salvar(pasta, "grande.json", make([]byte, 61), false); !errors.Is(err, ErrArquivoGrande) {
+		t.Errorf("Salvar() acima do tamanho máximo = %v, esperado ErrArquivoGrande", err)
+	}
+
+	// 9 + 10 bytes usados: mais 60 cabem, mais 90 não
+	if _, err := s.Salvar(pasta, "cheio.json", make([]byte, 60), false); err != nil {
+		t.Errorf("Salvar() dentro da cota erro: %v", err)
+	}
+	if _, err := s.Salvar(pasta, "extra.json", make([]byte, 30), false); !errors.Is(err, ErrCotaExcedida) {
+		t.Errorf("Salvar() acima da cota = %v, esperado ErrCotaExcedida", err)
+	}
+	// Reescrever desconta o tamanho anterior
+	if _, err := s.Salvar(pasta, "cheio.json", make([]byte, 50), true); err != nil {
+		t.Errorf("Salvar(reescrever) menor erro: %v", err)
+	}
+
+	lista, err := s.Listar(pasta)
+	if err != nil {
+		t.Fatalf("Listar() erro: %v", err)
+	}
+	if len(lista) != 3 || lista[0].Nome != "cheio.json" || lista[1].Nome != "grafo.json" {
+		t.Errorf("Listar() = %+v", lista)
+	}
+	if raiz, _ := s.Listar(""); len(raiz) != 0 {
+		t.Errorf("Listar(raiz) = %+v, esperado vazio (arquivos ficam na pasta da chave)", raiz)
+	}
+
+	dados, err := s.Ler(pasta, "grafo0001.json")
+	if err != nil || string(dados) != `{"no":[1]}` {
+		t.Errorf("Ler() = %q, %v", dados, err)
+	}
+	if _, err := s.Ler("", "grafo.json"); !errors.Is(err, ErrArquivoNaoEncontrado) {
+		t.Errorf("Ler() em outra pasta = %v, esperado ErrArquivoNaoEncontrado", err)
+	}
+
+	if err := s.Excluir(pasta, "grafo.json"); err != nil {
+		t.Fatalf("Excluir() erro: %v", err)
+	}
+	if err := s.Excluir(pasta, "grafo.json"); !errors.Is(err, ErrArquivoNaoEncontrado) {
+		t.Errorf("Excluir() repetido = %v, esperado ErrArquivoNaoEncontrado", err)
+	}
+	if uso, _ := s.Uso(pasta); uso != 60 {
+		t.Errorf("Uso() = %d, esperado 60", uso)
+	}
+}
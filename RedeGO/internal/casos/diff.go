@@ -0,0 +1,118 @@
+package casos
+
+import (
+	"reflect"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// DiffCaso lista as diferenças entre duas versões do grafo de um caso
+type DiffCaso struct {
+	De                  int           `json:"de"`
+	Para                int           `json:"para"`
+	NosAdicionados      []models.Node `json:"nosAdicionados"`
+	NosRemovidos        []models.Node `json:"nosRemovidos"`
+	NosAlterados        []AlteracaoNo `json:"nosAlterados"`
+	LigacoesAdicionadas []models.Edge `json:"ligacoesAdicionadas"`
+	LigacoesRemovidas   []models.Edge `json:"ligacoesRemovidas"`
+}
+
+// AlteracaoNo indica os campos (nomes do JSON) que mudaram em um nó
+type AlteracaoNo struct {
+	ID     string   `json:"id"`
+	Campos []string `json:"campos"`
+}
+
+// Vazio indica que as versões têm o mesmo conteúdo
+func (d *DiffCaso) Vazio() bool {
+	return len(d.NosAdicionados) == 0 && len(d.NosRemovidos) == 0 && len(d.NosAlterados) == 0 &&
+		len(d.LigacoesAdicionadas) == 0 && len(d.LigacoesRemovidas) == 0
+}
+
+// DiffGrafos compara dois grafos. Nós são identificados pelo ID e ligações
+// por origem, destino, rótulo e tipo; a ordem segue a do grafo de origem
+// de cada item.
+func DiffGrafos(antes, depois *models.Graph) *DiffCaso {
+	antes, depois = grafoOuVazio(antes), grafoOuVazio(depois)
+	diff := &DiffCaso{
+		NosAdicionados:      []models.Node{},
+		NosRemovidos:        []models.Node{},
+		NosAlterados:        []AlteracaoNo{},
+		LigacoesAdicionadas: []models.Edge{},
+		LigacoesRemovidas:   []models.Edge{},
+	}
+
+	nosAntes := make(map[string]models.Node, len(antes.Nodes))
+	for _, n := range antes.Nodes {
+		nosAntes[n.ID] = n
+	}
+	nosDepois := make(map[string]bool, len(depois.Nodes))
+	for _, n := range depois.Nodes {
+		nosDepois[n.ID] = true
+		anterior, ok := nosAntes[n.ID]
+		if !ok {
+			diff.NosAdicionados = append(diff.NosAdicionados, n)
+			continue
+		}
+		if campos := camposAlterados(anterior, n); len(campos) > 0 {
+			diff.NosAlterados = append(diff.NosAlterados, AlteracaoNo{ID: n.ID, Campos: campos})
+		}
+	}
+	for _, n := range antes.Nodes {
+		if !nosDepois[n.ID] {
+			diff.NosRemovidos = append(diff.NosRemovidos, n)
+		}
+	}
+
+	ligacoesAntes := make(map[string]bool, len(antes.Edges))
+	for _, e := range antes.Edges {
+		ligacoesAntes[chaveLigacao(e)] = true
+	}
+	ligacoesDepois := make(map[string]bool, len(depois.Edges))
+	for _, e := range depois.Edges {
+		chave := chaveLigacao(e)
+		ligacoesDepois[chave] = true
+		if !ligacoesAntes[chave] {
+			diff.LigacoesAdicionadas = append(diff.LigacoesAdicionadas, e)
+		}
+	}
+	for _, e := range antes.Edges {
+		if !ligacoesDepois[chaveLigacao(e)] {
+			diff.LigacoesRemovidas = append(diff.LigacoesRemovidas, e)
+		}
+	}
+
+	return diff
+}
+
+func chaveLigacao(e models.Edge) string {
+	return e.From + "\x00" + e.To + "\x00" + e.Label + "\x00" + e.Type
+}
+
+// camposAlterados compara os campos editáveis de um nó
+func camposAlterados(a, b models.Node) []string {
+	var campos []string
+	comparar := func(nome string, igual bool) {
+		if !igual {
+			campos = append(campos, nome)
+		}
+	}
+	comparar("label", a.Label == b.Label)
+	comparar("tipo", a.Type == b.Type)
+	comparar("icone", a.Icon == b.Icon)
+	comparar("cor", a.Color == b.Color)
+	comparar("fixed", a.Fixed == b.Fixed)
+	comparar("nota", a.Note == b.Note)
+	comparar("flags", reflect.DeepEqual(vazioSeNil(a.Flags), vazioSeNil(b.Flags)))
+	comparar("camada", a.Camada == b.Camada)
+	comparar("posicao", a.X == b.X && a.Y == b.Y)
+	comparar("data", len(a.Data) == 0 && len(b.Data) == 0 || reflect.DeepEqual(a.Data, b.Data))
+	return campos
+}
+
+func vazioSeNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,453 @@
+// Package casos guarda investigações (casos) na base local: cada caso tem um
+// grafo com nós, ligações, posições, notas e marcações, e cada alteração do
+// grafo gera uma nova versão.
+package casos
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// Erros retornados pelo Store
+var (
+	ErrCasoNaoEncontrado   = errors.New("caso não encontrado")
+	ErrVersaoNaoEncontrada = errors.New("versão não encontrada")
+	ErrNomeDuplicado       = errors.New("já existe um caso com este nome")
+	ErrNomeVazio           = errors.New("nome do caso é obrigatório")
+	ErrConflito            = errors.New("o caso foi alterado ao mesmo tempo por outra requisição; tente novamente")
+)
+
+// schema das tabelas de casos na base local (SQLite)
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS casos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		nome TEXT NOT NULL UNIQUE,
+		descricao TEXT NOT NULL DEFAULT '',
+		versao_atual INTEGER NOT NULL DEFAULT 1,
+		criado_em TEXT NOT NULL,
+		atualizado_em TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS casos_versoes (
+		caso_id INTEGER NOT NULL REFERENCES casos(id) ON DELETE CASCADE,
+		versao INTEGER NOT NULL,
+		comentario TEXT NOT NULL DEFAULT '',
+		grafo TEXT NOT NULL,
+		total_nos INTEGER NOT NULL,
+		total_ligacoes INTEGER NOT NULL,
+		criado_em TEXT NOT NULL,
+		PRIMARY KEY (caso_id, versao)
+	)`,
+}
+
+// Caso é uma investigação salva. Grafo só é preenchido por Carregar.
+type Caso struct {
+	ID            int64         `json:"id"`
+	Nome          string        `json:"nome"`
+	Descricao     string        `json:"descricao"`
+	Versao        int           `json:"versao"`
+	TotalNos      int           `json:"totalNos"`
+	TotalLigacoes int           `json:"totalLigacoes"`
+	CriadoEm      time.Time     `json:"criadoEm"`
+	AtualizadoEm  time.Time     `json:"atualizadoEm"`
+	Grafo         *models.Graph `json:"grafo,omitempty"`
+}
+
+// VersaoCaso resume uma versão do grafo de um caso
+type VersaoCaso struct {
+	Versao        int       `json:"versao"`
+	Comentario    string    `json:"comentario"`
+	TotalNos      int       `json:"totalNos"`
+	TotalLigacoes int       `json:"totalLigacoes"`
+	CriadoEm      time.Time `json:"criadoEm"`
+}
+
+// AtualizacaoCaso contém os campos a alterar; campos nil são mantidos. Um
+// novo Grafo só gera versão se for diferente do atual.
+type AtualizacaoCaso struct {
+	Nome       *string       `json:"nome,omitempty"`
+	Descricao  *string       `json:"descricao,omitempty"`
+	Grafo      *models.Graph `json:"grafo,omitempty"`
+	Comentario string        `json:"comentario,omitempty"`
+}
+
+// Store acessa os casos na base local
+type Store struct {
+	db     *sql.DB
+	mu     sync.Mutex
+	pronto bool
+}
+
+// NewStore cria o repositório de casos sobre a base local. As tabelas são
+// criadas no primeiro uso.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// preparar cria as tabelas na primeira chamada bem-sucedida
+func (s *Store) preparar(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pronto {
+		return nil
+	}
+	for _, stmt := range schema {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("erro ao criar tabelas de casos: %w", err)
+		}
+	}
+	s.pronto = true
+	return nil
+}
+
+// Criar salva um novo caso com o grafo como versão 1
+func (s *Store) Criar(ctx context.Context, nome, descricao string, grafo *models.Graph) (*Caso, error) {
+	nome = strings.TrimSpace(nome)
+	if nome == "" {
+		return nil, ErrNomeVazio
+	}
+	if err := s.preparar(ctx); err != nil {
+		return nil, err
+	}
+	grafo = grafoOuVazio(grafo)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := nomeDisponivel(ctx, tx, nome, 0); err != nil {
+		return nil, err
+	}
+
+	agora := agoraTexto()
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO casos (nome, descricao, versao_atual, criado_em, atualizado_em) VALUES (?, ?, 1, ?, ?)`,
+		nome, descricao, agora, agora)
+	if err != nil {
+		return nil, erroConcorrencia(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	if err := inserirVersao(ctx, tx, id, 1, "", grafo, agora); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, erroConcorrencia(err)
+	}
+	return s.Carregar(ctx, id, 0)
+}
+
+// Listar retorna os casos, do atualizado mais recentemente ao mais antigo,
+// sem os grafos
+func (s *Store) Listar(ctx context.Context) ([]Caso, error) {
+	if err := s.preparar(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.id, c.nome, c.descricao, c.versao_atual, v.total_nos, v.total_ligacoes, c.criado_em, c.atualizado_em
+		FROM casos c
+		JOIN casos_versoes v ON v.caso_id = c.id AND v.versao = c.versao_atual
+		ORDER BY c.atualizado_em DESC, c.id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lista := []Caso{}
+	for rows.Next() {
+		caso, err := lerCaso(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		lista = append(lista, *caso)
+	}
+	return lista, rows.Err()
+}
+
+// Carregar retorna o caso com o grafo da versão informada (0 = atual)
+func (s *Store) Carregar(ctx context.Context, id int64, versao int) (*Caso, error) {
+	if err := s.preparar(ctx); err != nil {
+		return nil, err
+	}
+
+	caso, err := carregar(ctx, s.db, id, versao)
+	if errors.Is(err, sql.ErrNoRows) {
+		if versao > 0 && s.existe(ctx, id) {
+			return nil, ErrVersaoNaoEncontrada
+		}
+		return nil, ErrCasoNaoEncontrado
+	}
+	return caso, err
+}
+
+// consultor é a conexão ou a transação em que o caso é lido
+type consultor interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// carregar lê o caso com o grafo da versão (0 = atual); retorna
+// sql.ErrNoRows se o caso ou a versão não existirem
+func carregar(ctx context.Context, q consultor, id int64, versao int) (*Caso, error) {
+	var grafoJSON string
+	caso, err := lerCaso(func(dest ...interface{}) error {
+		return q.QueryRowContext(ctx, `
+			SELECT c.id, c.nome, c.descricao, v.versao, v.total_nos, v.total_ligacoes, c.criado_em, c.atualizado_em, v.grafo
+			FROM casos c
+			JOIN casos_versoes v ON v.caso_id = c.id
+			WHERE c.id = ? AND v.versao = CASE WHEN ? > 0 THEN ? ELSE c.versao_atual END
+		`, id, versao, versao).Scan(append(dest, &grafoJSON)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	caso.Grafo = &models.Graph{}
+	if err := json.Unmarshal([]byte(grafoJSON), caso.Grafo); err != nil {
+		return nil, fmt.Errorf("grafo do caso %d inválido: %w", id, err)
+	}
+	return caso, nil
+}
+
+// Atualizar altera nome e descrição e, se o grafo mudou, grava uma nova
+// versão. A versão atual é lida na mesma transação da gravação; se outro
+// processo alterar o caso ao mesmo tempo, retorna ErrConflito.
+func (s *Store) Atualizar(ctx context.Context, id int64, alteracao AtualizacaoCaso) (*Caso, error) {
+	if err := s.preparar(ctx); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	atual, err := carregar(ctx, tx, id, 0)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCasoNaoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nome, descricao, versao := atual.Nome, atual.Descricao, atual.Versao
+	if alteracao.Nome != nil {
+		nome = strings.TrimSpace(*alteracao.Nome)
+		if nome == "" {
+			return nil, ErrNomeVazio
+		}
+		if err := nomeDisponivel(ctx, tx, nome, id); err != nil {
+			return nil, err
+		}
+	}
+	if alteracao.Descricao != nil {
+		descricao = *alteracao.Descricao
+	}
+
+	agora := agoraTexto()
+	if alteracao.Grafo != nil {
+		mudou, err := grafosDiferentes(atual.Grafo, alteracao.Grafo)
+		if err != nil {
+			return nil, err
+		}
+		if mudou {
+			versao++
+			if err := inserirVersao(ctx, tx, id, versao, alteracao.Comentario, alteracao.Grafo, agora); err != nil {
+				return nil, erroConcorrencia(err)
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE casos SET nome = ?, descricao = ?, versao_atual = ?, atualizado_em = ? WHERE id = ?`,
+		nome, descricao, versao, agora, id); err != nil {
+		return nil, erroConcorrencia(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, erroConcorrencia(err)
+	}
+	return s.Carregar(ctx, id, 0)
+}
+
+// erroConcorrencia converte em ErrConflito os erros do SQLite de duas
+// gravações simultâneas do mesmo caso: a versão já gravada pela outra ou a
+// base bloqueada por ela. O nome gravado por outra requisição depois de
+// nomeDisponivel vira ErrNomeDuplicado.
+func erroConcorrencia(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "UNIQUE constraint failed: casos.nome") {
+		return fmt.Errorf("%w (%v)", ErrNomeDuplicado, err)
+	}
+	if strings.Contains(msg, "UNIQUE constraint failed: casos_versoes") || strings.Contains(msg, "database is locked") {
+		return fmt.Errorf("%w (%v)", ErrConflito, err)
+	}
+	return err
+}
+
+// Excluir remove o caso e todas as suas versões
+func (s *Store) Excluir(ctx context.Context, id int64) error {
+	if err := s.preparar(ctx); err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// As versões são removidas explicitamente: o SQLite só aplica ON DELETE
+	// CASCADE com PRAGMA foreign_keys ativo
+	if _, err := tx.ExecContext(ctx, `DELETE FROM casos_versoes WHERE caso_id = ?`, id); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM casos WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCasoNaoEncontrado
+	}
+	return tx.Commit()
+}
+
+// Versoes lista o histórico do caso, da versão mais recente à mais antiga
+func (s *Store) Versoes(ctx context.Context, id int64) ([]VersaoCaso, error) {
+	if err := s.preparar(ctx); err != nil {
+		return nil, err
+	}
+	if !s.existe(ctx, id) {
+		return nil, ErrCasoNaoEncontrado
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT versao, comentario, total_nos, total_ligacoes, criado_em
+		FROM casos_versoes WHERE caso_id = ? ORDER BY versao DESC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versoes := []VersaoCaso{}
+	for rows.Next() {
+		var v VersaoCaso
+		var criado string
+		if err := rows.Scan(&v.Versao, &v.Comentario, &v.TotalNos, &v.TotalLigacoes, &criado); err != nil {
+			return nil, err
+		}
+		v.CriadoEm = lerTempo(criado)
+		versoes = append(versoes, v)
+	}
+	return versoes, rows.Err()
+}
+
+// Diff compara duas versões do caso (0 = atual; de = 0 é a anterior a
+// para). A versão 1 não tem anterior: sem de, retorna ErrVersaoNaoEncontrada.
+func (s *Store) Diff(ctx context.Context, id int64, de, para int) (*DiffCaso, error) {
+	depois, err := s.Carregar(ctx, id, para)
+	if err != nil {
+		return nil, err
+	}
+	if de <= 0 {
+		if depois.Versao <= 1 {
+			return nil, fmt.Errorf("%w: a versão 1 não tem versão anterior para comparar", ErrVersaoNaoEncontrada)
+		}
+		de = depois.Versao - 1
+	}
+	antes, err := s.Carregar(ctx, id, de)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffGrafos(antes.Grafo, depois.Grafo)
+	diff.De, diff.Para = antes.Versao, depois.Versao
+	return diff, nil
+}
+
+func (s *Store) existe(ctx context.Context, id int64) bool {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT 1 FROM casos WHERE id = ?`, id).Scan(&n)
+	return err == nil
+}
+
+// nomeDisponivel verifica se o nome não está em uso por outro caso
+func nomeDisponivel(ctx context.Context, tx *sql.Tx, nome string, id int64) error {
+	var outro int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM casos WHERE nome = ? AND id <> ?`, nome, id).Scan(&outro)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	}
+	return ErrNomeDuplicado
+}
+
+func inserirVersao(ctx context.Context, tx *sql.Tx, id int64, versao int, comentario string, grafo *models.Graph, agora string) error {
+	dados, err := json.Marshal(grafo)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar grafo: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO casos_versoes (caso_id, versao, comentario, grafo, total_nos, total_ligacoes, criado_em)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, id, versao, comentario, string(dados), len(grafo.Nodes), len(grafo.Edges), agora)
+	return err
+}
+
+// lerCaso lê as colunas comuns de casos e da versão
+func lerCaso(scan func(dest ...interface{}) error) (*Caso, error) {
+	var caso Caso
+	var criado, atualizado string
+	if err := scan(&caso.ID, &caso.Nome, &caso.Descricao, &caso.Versao, &caso.TotalNos, &caso.TotalLigacoes, &criado, &atualizado); err != nil {
+		return nil, err
+	}
+	caso.CriadoEm = lerTempo(criado)
+	caso.AtualizadoEm = lerTempo(atualizado)
+	return &caso, nil
+}
+
+func grafoOuVazio(grafo *models.Graph) *models.Graph {
+	if grafo == nil {
+		return &models.Graph{Nodes: []models.Node{}, Edges: []models.Edge{}}
+	}
+	return grafo
+}
+
+func grafosDiferentes(a, b *models.Graph) (bool, error) {
+	ja, err := json.Marshal(grafoOuVazio(a))
+	if err != nil {
+		return false, err
+	}
+	jb, err := json.Marshal(grafoOuVazio(b))
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(ja, jb), nil
+}
+
+// Datas são gravadas em UTC com nanossegundos, para que a ordenação
+// textual acompanhe a cronológica
+const formatoTempo = "2006-01-02T15:04:05.000000000Z"
+
+func agoraTexto() string {
+	return time.Now().UTC().Format(formatoTempo)
+}
+
+func lerTempo(texto string) time.Time {
+	t, _ := time.Parse(formatoTempo, texto)
+	return t
+}
@@ -0,0 +1,209 @@
+package casos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+func storeTeste(t *testing.T) *Store {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "local.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewStore(db)
+}
+
+func grafoCaso() *models.Graph {
+	return &models.Graph{
+		Nodes: []models.Node{
+			{ID: "PJ_11111111000191", Label: "EMPRESA A", Type: "PJ", X: 10, Y: 20},
+			{ID: "PF_***111111**-FULANO", Label: "FULANO", Type: "PF", Note: "sócio oculto?", Flags: []string{"suspeito"}},
+		},
+		Edges: []models.Edge{
+			{From: "PF_***111111**-FULANO", To: "PJ_11111111000191", Label: "Sócio-Administrador", Type: "socio"},
+		},
+	}
+}
+
+func TestStoreCiclo(t *testing.T) {
+	ctx := context.Background()
+	s := storeTeste(t)
+
+	caso, err := s.Criar(ctx, "  Operação Teste ", "primeira análise", grafoCaso())
+	if err != nil {
+		t.Fatalf("Criar() erro: %v", err)
+	}
+	if caso.Nome != "Operação Teste" || caso.Versao != 1 || caso.TotalNos != 2 || caso.TotalLigacoes != 1 {
+		t.Errorf("caso criado = %+v", caso)
+	}
+	if !reflect.DeepEqual(caso.Grafo, grafoCaso()) {
+		t.Errorf("grafo salvo = %+v, esperado %+v", caso.Grafo, grafoCaso())
+	}
+
+	if _, err := s.Criar(ctx, "Operação Teste", "", nil); !errors.Is(err, ErrNomeDuplicado) {
+		t.Errorf("Criar() com nome repetido = %v, esperado ErrNomeDuplicado", err)
+	}
+	if _, err := s.Criar(ctx, " ", "", nil); !errors.Is(err, ErrNomeVazio) {
+		t.Errorf("Criar() sem nome = %v, esperado ErrNomeVazio", err)
+	}
+	if _, err := s.Diff(ctx, caso.ID, 0, 0); !errors.Is(err, ErrVersaoNaoEncontrada) {
+		t.Errorf("Diff() só com a versão 1 = %v, esperado ErrVersaoNaoEncontrada", err)
+	}
+
+	// Alterar só a descrição não gera versão
+	descricao := "revisada"
+	caso, err = s.Atualizar(ctx, caso.ID, AtualizacaoCaso{Descricao: &descricao, Grafo: grafoCaso()})
+	if err != nil {
+		t.Fatalf("Atualizar() erro: %v", err)
+	}
+	if caso.Versao != 1 || caso.Descricao != "revisada" {
+		t.Errorf("após alterar descrição: versão %d, descrição %q", caso.Versao, caso.Descricao)
+	}
+
+	// Move um nó, anota outro, remove a ligação e adiciona uma empresa
+	grafo := grafoCaso()
+	grafo.Nodes[0].X = 50
+	grafo.Nodes[1].Note = "laranja"
+	grafo.Nodes = append(grafo.Nodes, models.Node{ID: "PJ_22222222000191", Label: "EMPRESA B"})
+	grafo.Edges = []models.Edge{{From: "PF_***111111**-FULANO", To: "PJ_22222222000191", Label: "Sócio"}}
+	caso, err = s.Atualizar(ctx, caso.ID, AtualizacaoCaso{Grafo: grafo, Comentario: "nova empresa"})
+	if err != nil {
+		t.Fatalf("Atualizar() erro: %v", err)
+	}
+	if caso.Versao != 2 || caso.TotalNos != 3 {
+		t.Errorf("após alterar grafo: versão %d com %d nós, esperado 2 com 3", caso.Versao, caso.TotalNos)
+	}
+
+	versoes, err := s.Versoes(ctx, caso.ID)
+	if err != nil {
+		t.Fatalf("Versoes() erro: %v", err)
+	}
+	if len(versoes) != 2 || versoes[0].Versao != 2 || versoes[0].Comentario != "nova empresa" {
+		t.Errorf("versões = %+v", versoes)
+	}
+
+	v1, err := s.Carregar(ctx, caso.ID, 1)
+	if err != nil {
+		t.Fatalf("Carregar(versão 1) erro: %v", err)
+	}
+	if len(v1.Grafo.Nodes) != 2 || v1.Versao != 1 {
+		t.Errorf("versão 1 = %d nós, versão %d", len(v1.Grafo.Nodes), v1.Versao)
+	}
+	if _, err := s.Carregar(ctx, caso.ID, 9); !errors.Is(err, ErrVersaoNaoEncontrada) {
+		t.Errorf("Carregar(versão 9) = %v, esperado ErrVersaoNaoEncontrada", err)
+	}
+
+	diff, err := s.Diff(ctx, caso.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("Diff() erro: %v", err)
+	}
+	if diff.De != 1 || diff.Para != 2 {
+		t.Errorf("Diff() entre %d e %d, esperado 1 e 2", diff.De, diff.Para)
+	}
+	if len(diff.NosAdicionados) != 1 || diff.NosAdicionados[0].ID != "PJ_22222222000191" || len(diff.NosRemovidos) != 0 {
+		t.Errorf("nós adicionados/removidos = %v / %v", diff.NosAdicionados, diff.NosRemovidos)
+	}
+	alterados := map[string][]string{}
+	for _, a := range diff.NosAlterados {
+		alterados[a.ID] = a.Campos
+	}
+	esperado := map[string][]string{"PJ_11111111000191": {"posicao"}, "PF_***111111**-FULANO": {"nota"}}
+	if !reflect.DeepEqual(alterados, esperado) {
+		t.Errorf("nós alterados = %v, esperado %v", alterados, esperado)
+	}
+	if len(diff.LigacoesAdicionadas) != 1 || len(diff.LigacoesRemovidas) != 1 {
+		t.Errorf("ligações adicionadas/removidas = %v / %v", diff.LigacoesAdicionadas, diff.LigacoesRemovidas)
+	}
+
+	lista, err := s.Listar(ctx)
+	if err != nil {
+		t.Fatalf("Listar() erro: %v", err)
+	}
+	if len(lista) != 1 || lista[0].Grafo != nil || lista[0].Versao != 2 {
+		t.Errorf("Listar() = %+v", lista)
+	}
+
+	if err := s.Excluir(ctx, caso.ID); err != nil {
+		t.Fatalf("Excluir() erro: %v", err)
+	}
+	if _, err := s.Carregar(ctx, caso.ID, 0); !errors.Is(err, ErrCasoNaoEncontrado) {
+		t.Errorf("Carregar() após excluir = %v, esperado ErrCasoNaoEncontrado", err)
+	}
+	if err := s.Excluir(ctx, caso.ID); !errors.Is(err, ErrCasoNaoEncontrado) {
+		t.Errorf("Excluir() repetido = %v, esperado ErrCasoNaoEncontrado", err)
+	}
+}
+
+func TestStoreAtualizarConcorrente(t *testing.T) {
+	ctx := context.Background()
+	s := storeTeste(t)
+	// Uma conexão, como a base local do servidor
+	s.db.SetMaxOpenConns(1)
+	caso, err := s.Criar(ctx, "Operação Paralela", "", grafoCaso())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const alteracoes = 10
+	erros := make(chan error, alteracoes)
+	var wg sync.WaitGroup
+	for i := 0; i < alteracoes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			grafo := grafoCaso()
+			grafo.Nodes[0].X = float64(100 + i)
+			_, err := s.Atualizar(ctx, caso.ID, AtualizacaoCaso{Grafo: grafo})
+			erros <- err
+		}(i)
+	}
+	wg.Wait()
+	close(erros)
+	for err := range erros {
+		if err != nil {
+			t.Errorf("Atualizar() concorrente erro: %v", err)
+		}
+	}
+
+	versoes, err := s.Versoes(ctx, caso.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versoes) != alteracoes+1 || versoes[0].Versao != alteracoes+1 {
+		t.Errorf("versões = %d (última %d), esperado %d", len(versoes), versoes[0].Versao, alteracoes+1)
+	}
+}
+
+func TestErroConcorrencia(t *testing.T) {
+	pk := errors.New("UNIQUE constraint failed: casos_versoes.caso_id, casos_versoes.versao")
+	if err := erroConcorrencia(pk); !errors.Is(err, ErrConflito) {
+		t.Errorf("erroConcorrencia(PK duplicada) = %v, esperado ErrConflito", err)
+	}
+	nome := errors.New("UNIQUE constraint failed: casos.nome")
+	if err := erroConcorrencia(nome); !errors.Is(err, ErrNomeDuplicado) {
+		t.Errorf("erroConcorrencia(nome duplicado) = %v, esperado ErrNomeDuplicado", err)
+	}
+	outro := errors.New("disk I/O error")
+	if err := erroConcorrencia(outro); err != outro {
+		t.Errorf("erroConcorrencia(outro erro) = %v, esperado o próprio erro", err)
+	}
+}
+
+func TestDiffGrafosIguais(t *testing.T) {
+	if diff := DiffGrafos(grafoCaso(), grafoCaso()); !diff.Vazio() {
+		t.Errorf("DiffGrafos() de grafos iguais = %+v", diff)
+	}
+	if diff := DiffGrafos(nil, grafoCaso()); len(diff.NosAdicionados) != 2 || len(diff.LigacoesAdicionadas) != 1 {
+		t.Errorf("DiffGrafos(nil, grafo) = %+v", diff)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"unicode"
 
 	"github.com/spf13/viper"
 )
@@ -20,15 +22,17 @@ type Config struct {
 	BaseLocal               string
 	PastaArquivos           string
 	ReferenciaBD            string
+	FonteDadosPublicos      string // URL da Receita, espelho HTTP ou diretório local
 
 	// Servidor
 	PortaFlask int
 
 	// Limites
-	LimiterPadrao   string
-	LimiterDados    string
-	LimiterGoogle   string
-	LimiterArquivos string
+	LimiterPadrao    string
+	LimiterDados     string
+	LimiterGoogle    string
+	LimiterArquivos  string
+	LimiterForensics string
 
 	// Flags
 	BuscaGoogle         bool
@@ -42,6 +46,12 @@ type Config struct {
 	LimiteRegistrosCamada int
 	TempoMaximoConsulta   float64
 	GeocodeMax            int
+	ImportacaoWorkers     int    // leitores de CSV do importador; 0 usa o número de CPUs
+	SchemaNormalizacao    string // arquivo YAML/JSON que estende o schema de normalização
+	ModeloRisco           string // modelo de risco forense; vazio usa o embutido
+	PastaModelosRisco     string // modelos de risco por caso (?modelo=<nome>)
+	CotaArquivosMB        int64  // espaço de cada pasta de usuário em PastaArquivos
+	IdentidadesCamada     int    // nós PF do grafo com sugestões de mesma pessoa; 0 (padrão) desliga
 
 	// API
 	APICnpj     bool
@@ -106,15 +116,17 @@ func LoadConfig() (*Config, error) {
 		BaseLinks:               viper.GetString("BASE.base_links"),
 		BaseLocal:               viper.GetString("BASE.base_local"),
 		ReferenciaBD:            viper.GetString("BASE.referencia_bd"),
+		FonteDadosPublicos:      viper.GetString("BASE.fonte_dados_publicos"),
 
 		// Servidor
 		PortaFlask: *portaFlask,
 
 		// Limites
-		LimiterPadrao:   viper.GetString("ETC.limiter_padrao"),
-		LimiterDados:    viper.GetString("ETC.limiter_dados"),
-		LimiterGoogle:   viper.GetString("ETC.limiter_google"),
-		LimiterArquivos: viper.GetString("ETC.limiter_arquivos"),
+		LimiterPadrao:    viper.GetString("ETC.limiter_padrao"),
+		LimiterDados:     viper.GetString("ETC.limiter_dados"),
+		LimiterGoogle:    viper.GetString("ETC.limiter_google"),
+		LimiterArquivos:  viper.GetString("ETC.limiter_arquivos"),
+		LimiterForensics: viper.GetString("ETC.limiter_forensics"),
 
 		// Flags
 		BuscaGoogle:          viper.GetBool("ETC.busca_google"),
@@ -122,17 +134,23 @@ func LoadConfig() (*Config, error) {
 		LigacaoSocioFilial:   viper.GetBool("ETC.ligacao_socio_filial"),
 		ExibeMensagemInicial: *mensagem,
 		ExibeMenuInserir:     *menuInserir,
-		ArquivosDownload:     *download,
+		ArquivosDownload:     *download || viper.GetBool("ETC.arquivos_download"),
 
 		// Limites de processamento
 		LimiteRegistrosCamada: viper.GetInt("ETC.limite_registros_camada"),
 		TempoMaximoConsulta:   viper.GetFloat64("ETC.tempo_maximo_consulta"),
 		GeocodeMax:            viper.GetInt("ETC.geocode_max"),
+		ImportacaoWorkers:     viper.GetInt("ETC.importacao_workers"),
+		SchemaNormalizacao:    viper.GetString("ETC.schema_normalizacao"),
+		ModeloRisco:           viper.GetString("ETC.modelo_risco"),
+		PastaModelosRisco:     viper.GetString("ETC.pasta_modelos_risco"),
+		CotaArquivosMB:        viper.GetInt64("ETC.cota_arquivos_mb"),
+		IdentidadesCamada:     viper.GetInt("ETC.identidades_camada"),
 
 		// API
 		APICnpj:     viper.GetBool("API.api_cnpj"),
 		APICaminhos: viper.GetBool("API.api_caminhos"),
-		APIKeys:     viper.GetStringSlice("API.api_keys"),
+		APIKeys:     listaChaves(viper.GetString("API.api_keys")),
 
 		// Parâmetros de linha de comando
 		CPFCNPJInicial:     *cpfcnpjInicial,
@@ -160,15 +178,21 @@ func LoadConfig() (*Config, error) {
 	if cfg.LimiterArquivos == "" {
 		cfg.LimiterArquivos = "2/minute"
 	}
-	if cfg.LimiteRegistrosCamada == 0 {
+	if cfg.LimiterForensics == "" {
+		cfg.LimiterForensics = cfg.LimiterDados
+	}
+	if cfg.LimiteRegistrosCamada <= 0 {
 		cfg.LimiteRegistrosCamada = 1000
 	}
-	if cfg.TempoMaximoConsulta == 0 {
+	if cfg.TempoMaximoConsulta <= 0 {
 		cfg.TempoMaximoConsulta = 10.0
 	}
-	if cfg.GeocodeMax == 0 {
+	if cfg.GeocodeMax <= 0 {
 		cfg.GeocodeMax = 15
 	}
+	if cfg.CotaArquivosMB <= 0 {
+		cfg.CotaArquivosMB = 50
+	}
 	if cfg.PastaArquivos == "" {
 		cfg.PastaArquivos = "arquivos"
 	}
@@ -178,6 +202,7 @@ func LoadConfig() (*Config, error) {
 		"base_receita":    cfg.BaseReceita,
 		"base_rede":       cfg.BaseRede,
 		"base_rede_search": cfg.BaseRedeSearch,
+		"base_endereco_normalizado": cfg.BaseEnderecoNormalizado,
 	}
 
 	for name, path := range databases {
@@ -199,3 +224,10 @@ func GetConfig() *Config {
 	}
 	return AppConfig
 }
+
+// listaChaves separa as chaves de API por vírgula ou espaço
+func listaChaves(texto string) []string {
+	return strings.FieldsFunc(texto, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+}
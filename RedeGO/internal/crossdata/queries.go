@@ -1,25 +1,49 @@
 package crossdata
 
 import (
+	"context"
 	"database/sql"
-
-	_ "github.com/mattn/go-sqlite3"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
 )
 
 // CrossDataEngine motor de cruzamento de dados
 type CrossDataEngine struct {
-	cnpjDB string
-	redeDB string
+	db        *sql.DB
+	dialeto   database.Dialect
+	enderecos *sql.DB // Base de endereços normalizados (opcional)
 }
 
-// NewCrossDataEngine cria novo motor
-func NewCrossDataEngine(cnpjDB, redeDB string) *CrossDataEngine {
+// NewCrossDataEngine cria novo motor sobre a conexão compartilhada
+// da base da Receita (SQLite ou PostgreSQL)
+func NewCrossDataEngine(db *sql.DB, dialeto database.Dialect) *CrossDataEngine {
 	return &CrossDataEngine{
-		cnpjDB: cnpjDB,
-		redeDB: redeDB,
+		db:      db,
+		dialeto: dialeto,
 	}
 }
 
+// ComEnderecos define a base de endereços normalizados, usada por
+// EmpresasMesmoEndereco para buscar pela chave indexada (database.GetDBEndereco)
+func (c *CrossDataEngine) ComEnderecos(db *sql.DB) *CrossDataEngine {
+	c.enderecos = db
+	return c
+}
+
+// conexao retorna a conexão com a base ou erro se não estiver disponível
+func (c *CrossDataEngine) conexao() (*sql.DB, error) {
+	if c.db == nil {
+		return nil, fmt.Errorf("banco de dados da receita não disponível")
+	}
+	return c.db, nil
+}
+
 // Empresa dados completos de empresa SEM CENSURA
 type Empresa struct {
 	CNPJ                    string  `json:"cnpj"`
@@ -75,12 +99,11 @@ type Socio struct {
 }
 
 // 1. CPF → Empresas
-func (c *CrossDataEngine) EmpresasPorCPF(cpf string) ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+func (c *CrossDataEngine) EmpresasPorCPF(ctx context.Context, cpf string) ([]map[string]interface{}, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	query := `
 		SELECT DISTINCT 
@@ -101,14 +124,14 @@ func (c *CrossDataEngine) EmpresasPorCPF(cpf string) ([]map[string]interface{},
 			est.bairro,
 			est.cep,
 			est.uf
-		FROM socios s
-		JOIN estabelecimento est ON s.cnpj = est.cnpj
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
+		FROM {socios} s
+		JOIN {estabelecimento} est ON s.cnpj = est.cnpj
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
 		WHERE s.cnpj_cpf_socio = ?
 		ORDER BY s.data_entrada_sociedade DESC
 	`
 
-	rows, err := db.Query(query, cpf)
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query), cpf)
 	if err != nil {
 		return nil, err
 	}
@@ -117,34 +140,41 @@ func (c *CrossDataEngine) EmpresasPorCPF(cpf string) ([]map[string]interface{},
 	return scanToMaps(rows)
 }
 
-// 2. CNPJ → Sócios (TODOS OS DADOS SEM CENSURA)
-func (c *CrossDataEngine) SociosPorCNPJ(cnpj string) ([]Socio, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+// 2. CNPJ → Sócios (TODOS OS DADOS SEM CENSURA). Com asOf (AAAA-MM),
+// retorna o quadro societário vigente naquela referência.
+func (c *CrossDataEngine) SociosPorCNPJ(ctx context.Context, cnpj string, asOf string) ([]Socio, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
-	query := `
+	tabela, filtro := "{socios}", ""
+	args := []interface{}{cnpj}
+	if asOf != "" {
+		tabela, filtro = "{socios_historico}", " AND "+c.dialeto.Vigente("s")
+		args = append(args, asOf, asOf)
+	}
+
+	query := fmt.Sprintf(`
 		SELECT 
-			s.cnpj,
+			COALESCE(s.cnpj, ''),
 			s.cnpj_basico,
-			s.identificador_de_socio,
-			s.nome_socio,
-			s.cnpj_cpf_socio,
-			s.qualificacao_socio,
-			s.data_entrada_sociedade,
-			s.pais,
-			s.representante_legal,
-			s.nome_representante,
-			s.qualificacao_representante_legal,
-			s.faixa_etaria
-		FROM socios s
-		WHERE s.cnpj = ?
+			COALESCE(s.identificador_de_socio, ''),
+			COALESCE(s.nome_socio, ''),
+			COALESCE(s.cnpj_cpf_socio, ''),
+			COALESCE(s.qualificacao_socio, ''),
+			COALESCE(%s, ''),
+			COALESCE(s.pais, ''),
+			COALESCE(s.representante_legal, ''),
+			COALESCE(s.nome_representante, ''),
+			COALESCE(s.qualificacao_representante_legal, ''),
+			COALESCE(s.faixa_etaria, '')
+		FROM %s s
+		WHERE s.cnpj = ?%s
 		ORDER BY s.qualificacao_socio, s.nome_socio
-	`
+	`, c.dialeto.DateText("s.data_entrada_sociedade"), tabela, filtro)
 
-	rows, err := db.Query(query, cnpj)
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -166,16 +196,19 @@ func (c *CrossDataEngine) SociosPorCNPJ(cnpj string) ([]Socio, error) {
 		socios = append(socios, s)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return socios, nil
 }
 
 // 3. Sócios em Comum entre duas empresas
-func (c *CrossDataEngine) SociosEmComum(cnpj1, cnpj2 string) ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+func (c *CrossDataEngine) SociosEmComum(ctx context.Context, cnpj1, cnpj2 string) ([]map[string]interface{}, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	query := `
 		SELECT DISTINCT
@@ -187,12 +220,12 @@ func (c *CrossDataEngine) SociosEmComum(cnpj1, cnpj2 string) ([]map[string]inter
 			s2.qualificacao_socio as qualif_empresa2,
 			s1.data_entrada_sociedade as data_entrada1,
 			s2.data_entrada_sociedade as data_entrada2
-		FROM socios s1
-		JOIN socios s2 ON s1.cnpj_cpf_socio = s2.cnpj_cpf_socio
+		FROM {socios} s1
+		JOIN {socios} s2 ON s1.cnpj_cpf_socio = s2.cnpj_cpf_socio
 		WHERE s1.cnpj = ? AND s2.cnpj = ? AND s1.cnpj != s2.cnpj
 	`
 
-	rows, err := db.Query(query, cnpj1, cnpj2)
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query), cnpj1, cnpj2)
 	if err != nil {
 		return nil, err
 	}
@@ -202,17 +235,16 @@ func (c *CrossDataEngine) SociosEmComum(cnpj1, cnpj2 string) ([]map[string]inter
 }
 
 // 4. Rede de Empresas de uma Pessoa (2º grau)
-func (c *CrossDataEngine) RedeEmpresasPessoa(cpf string) ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+func (c *CrossDataEngine) RedeEmpresasPessoa(ctx context.Context, cpf string) ([]map[string]interface{}, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	query := `
 		WITH empresas_pessoa AS (
 			SELECT DISTINCT cnpj, qualificacao_socio
-			FROM socios
+			FROM {socios}
 			WHERE cnpj_cpf_socio = ?
 		)
 		SELECT 
@@ -227,13 +259,13 @@ func (c *CrossDataEngine) RedeEmpresasPessoa(cpf string) ([]map[string]interface
 			est.correio_eletronico,
 			est.telefone1
 		FROM empresas_pessoa ep
-		JOIN estabelecimento est ON ep.cnpj = est.cnpj
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
-		LEFT JOIN socios s2 ON ep.cnpj = s2.cnpj AND s2.cnpj_cpf_socio != ?
+		JOIN {estabelecimento} est ON ep.cnpj = est.cnpj
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
+		LEFT JOIN {socios} s2 ON ep.cnpj = s2.cnpj AND s2.cnpj_cpf_socio != ?
 		ORDER BY ep.cnpj, s2.nome_socio
 	`
 
-	rows, err := db.Query(query, cpf, cpf)
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query), cpf, cpf)
 	if err != nil {
 		return nil, err
 	}
@@ -242,53 +274,150 @@ func (c *CrossDataEngine) RedeEmpresasPessoa(cpf string) ([]map[string]interface
 	return scanToMaps(rows)
 }
 
+// ErrEnderecoIncompleto indica endereço sem CEP válido ou sem logradouro;
+// sem número, o endereço é tratado como S/N
+var ErrEnderecoIncompleto = errors.New("informe CEP válido e logradouro")
+
+const (
+	// maxEmpresasEndereco limita as empresas de EmpresasMesmoEndereco
+	maxEmpresasEndereco = 1000
+	// maxCandidatosCEP limita os estabelecimentos do CEP comparados sem a
+	// base de endereços
+	maxCandidatosCEP = 5000
+	// loteCNPJs é o tamanho das listas IN nas consultas por CNPJ
+	loteCNPJs = 500
+)
+
 // 5. Empresas no Mesmo Endereço
-func (c *CrossDataEngine) EmpresasMesmoEndereco(cep, logradouro, numero string) ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+// Compara os endereços normalizados (ver endereco.Normalizar), de modo que
+// "R." e "RUA", "S/N" e "SN" ou "SL 1201" e "SALA 1201" coincidam. Com
+// granularidade endereco.GranularidadeSala, o complemento também precisa
+// coincidir. Com a base de endereços (ComEnderecos), os estabelecimentos vêm
+// da chave indexada; sem ela, dos primeiros maxCandidatosCEP do mesmo CEP.
+func (c *CrossDataEngine) EmpresasMesmoEndereco(ctx context.Context, end endereco.Endereco, granularidade string) ([]map[string]interface{}, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
+	granularidade, err = endereco.ValidarGranularidade(granularidade)
+	if err != nil {
+		return nil, err
+	}
+	// A chave de referência é sempre a do CEP, como nas linhas de
+	// endereco_normalizado com CEP válido
+	end.UF, end.Municipio = "", ""
+	chave := endereco.Normalizar(end).ChavePor(granularidade)
+	if chave == "" {
+		return nil, ErrEnderecoIncompleto
+	}
 
-	query := `
-		SELECT 
-			est.cnpj,
-			e.razao_social,
-			est.nome_fantasia,
-			est.situacao_cadastral,
-			est.correio_eletronico,
-			est.telefone1,
-			est.ddd1,
-			est.logradouro,
-			est.numero,
-			est.complemento,
-			est.bairro,
-			est.cep,
-			est.uf
-		FROM estabelecimento est
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
-		WHERE est.cep = ? 
-		  AND est.logradouro = ?
-		  AND est.numero = ?
-		ORDER BY e.razao_social
-	`
+	var candidatos []map[string]interface{}
+	estabelecimentos, err := endereco.NewAgrupador(c.enderecos, c.dialeto).
+		Estabelecimentos(ctx, granularidade, chave, maxEmpresasEndereco)
+	switch {
+	case err == nil:
+		cnpjs := make([]string, len(estabelecimentos))
+		for i, e := range estabelecimentos {
+			cnpjs[i] = e.CNPJ
+		}
+		candidatos, err = c.estabelecimentosPorCNPJ(ctx, db, cnpjs)
+	case errors.Is(err, endereco.ErrBaseAusente):
+		candidatos, err = c.estabelecimentosPorCEP(ctx, db, endereco.LimparCEP(end.CEP))
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := db.Query(query, cep, logradouro, numero)
+	campo := func(row map[string]interface{}, coluna string) string {
+		v, _ := row[coluna].(string)
+		return v
+	}
+	var results []map[string]interface{}
+	for _, row := range candidatos {
+		n := endereco.Normalizar(endereco.Endereco{
+			TipoLogradouro: campo(row, "tipo_logradouro"),
+			Logradouro:     campo(row, "logradouro"),
+			Numero:         campo(row, "numero"),
+			Complemento:    campo(row, "complemento"),
+			CEP:            campo(row, "cep"),
+			Municipio:      campo(row, "municipio"),
+			UF:             campo(row, "uf"),
+		})
+		if n.ChavePor(granularidade) == chave && len(results) < maxEmpresasEndereco {
+			row["endereco_normalizado"] = n.Texto()
+			results = append(results, row)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return campo(results[i], "razao_social") < campo(results[j], "razao_social")
+	})
+	return results, nil
+}
+
+// colunasEndereco são as colunas de EmpresasMesmoEndereco
+const colunasEndereco = `
+	SELECT 
+		est.cnpj,
+		e.razao_social,
+		est.nome_fantasia,
+		est.situacao_cadastral,
+		est.correio_eletronico,
+		est.telefone1,
+		est.ddd1,
+		est.tipo_logradouro,
+		est.logradouro,
+		est.numero,
+		est.complemento,
+		est.bairro,
+		est.cep,
+		est.municipio,
+		est.uf
+	FROM {estabelecimento} est
+	JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
+`
+
+// estabelecimentosPorCNPJ carrega os estabelecimentos informados, em lotes
+func (c *CrossDataEngine) estabelecimentosPorCNPJ(ctx context.Context, db *sql.DB, cnpjs []string) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	for inicio := 0; inicio < len(cnpjs); inicio += loteCNPJs {
+		lote := cnpjs[inicio:min(inicio+loteCNPJs, len(cnpjs))]
+		args := make([]interface{}, len(lote))
+		for i, cnpj := range lote {
+			args[i] = cnpj
+		}
+		query := colunasEndereco + fmt.Sprintf("WHERE est.cnpj IN (%s)",
+			strings.TrimSuffix(strings.Repeat("?, ", len(lote)), ", "))
+		rows, err := db.QueryContext(ctx, c.dialeto.Query(query), args...)
+		if err != nil {
+			return nil, err
+		}
+		linhas, err := scanToMaps(rows)
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, linhas...)
+	}
+	return results, nil
+}
+
+// estabelecimentosPorCEP carrega até maxCandidatosCEP estabelecimentos do CEP
+func (c *CrossDataEngine) estabelecimentosPorCEP(ctx context.Context, db *sql.DB, cep string) ([]map[string]interface{}, error) {
+	query := colunasEndereco + "WHERE est.cep = ? ORDER BY est.cnpj LIMIT ?"
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query), cep, maxCandidatosCEP)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
 	return scanToMaps(rows)
 }
 
 // 6. Empresas com Mesmo Email ou Telefone
-func (c *CrossDataEngine) EmpresasMesmoContato(email, telefone string) ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+func (c *CrossDataEngine) EmpresasMesmoContato(ctx context.Context, email, telefone string) ([]map[string]interface{}, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	query := `
 		SELECT 
@@ -306,13 +435,13 @@ func (c *CrossDataEngine) EmpresasMesmoContato(email, telefone string) ([]map[st
 			est.bairro,
 			est.cep,
 			est.uf
-		FROM estabelecimento est
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
+		FROM {estabelecimento} est
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
 		WHERE est.correio_eletronico = ? OR est.telefone1 = ?
 		ORDER BY e.razao_social
 	`
 
-	rows, err := db.Query(query, email, telefone)
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query), email, telefone)
 	if err != nil {
 		return nil, err
 	}
@@ -322,12 +451,11 @@ func (c *CrossDataEngine) EmpresasMesmoContato(email, telefone string) ([]map[st
 }
 
 // 7. Representantes Legais (Menores com Representantes)
-func (c *CrossDataEngine) RepresentantesLegais() ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+func (c *CrossDataEngine) RepresentantesLegais(ctx context.Context) ([]map[string]interface{}, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	query := `
 		SELECT 
@@ -341,14 +469,14 @@ func (c *CrossDataEngine) RepresentantesLegais() ([]map[string]interface{}, erro
 			s.nome_representante,
 			s.qualificacao_representante_legal,
 			est.situacao_cadastral
-		FROM socios s
-		JOIN estabelecimento est ON s.cnpj = est.cnpj
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
+		FROM {socios} s
+		JOIN {estabelecimento} est ON s.cnpj = est.cnpj
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
 		WHERE s.representante_legal IS NOT NULL AND s.representante_legal != ''
 		ORDER BY s.nome_socio
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query))
 	if err != nil {
 		return nil, err
 	}
@@ -358,12 +486,11 @@ func (c *CrossDataEngine) RepresentantesLegais() ([]map[string]interface{}, erro
 }
 
 // 8. Empresas Estrangeiras
-func (c *CrossDataEngine) EmpresasEstrangeiras() ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+func (c *CrossDataEngine) EmpresasEstrangeiras(ctx context.Context) ([]map[string]interface{}, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	query := `
 		SELECT 
@@ -375,14 +502,14 @@ func (c *CrossDataEngine) EmpresasEstrangeiras() ([]map[string]interface{}, erro
 			est.correio_eletronico,
 			est.telefone1,
 			est.situacao_cadastral
-		FROM estabelecimento est
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
-		LEFT JOIN pais p ON est.pais = p.codigo
+		FROM {estabelecimento} est
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
+		LEFT JOIN {pais} p ON est.pais = p.codigo
 		WHERE est.uf = 'EX'
 		ORDER BY p.descricao, e.razao_social
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query))
 	if err != nil {
 		return nil, err
 	}
@@ -392,12 +519,11 @@ func (c *CrossDataEngine) EmpresasEstrangeiras() ([]map[string]interface{}, erro
 }
 
 // 9. Sócios Estrangeiros
-func (c *CrossDataEngine) SociosEstrangeiros() ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+func (c *CrossDataEngine) SociosEstrangeiros(ctx context.Context) ([]map[string]interface{}, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	query := `
 		SELECT 
@@ -410,15 +536,15 @@ func (c *CrossDataEngine) SociosEstrangeiros() ([]map[string]interface{}, error)
 			s.qualificacao_socio,
 			s.data_entrada_sociedade,
 			est.situacao_cadastral
-		FROM socios s
-		JOIN estabelecimento est ON s.cnpj = est.cnpj
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
-		LEFT JOIN pais p ON s.pais = p.codigo
+		FROM {socios} s
+		JOIN {estabelecimento} est ON s.cnpj = est.cnpj
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
+		LEFT JOIN {pais} p ON s.pais = p.codigo
 		WHERE s.identificador_de_socio = '3'
 		ORDER BY p.descricao, s.nome_socio
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query))
 	if err != nil {
 		return nil, err
 	}
@@ -428,12 +554,11 @@ func (c *CrossDataEngine) SociosEstrangeiros() ([]map[string]interface{}, error)
 }
 
 // 10. Timeline de Atividades de uma Pessoa
-func (c *CrossDataEngine) TimelinePessoa(cpf string) ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+func (c *CrossDataEngine) TimelinePessoa(ctx context.Context, cpf string) ([]map[string]interface{}, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	query := `
 		SELECT 
@@ -449,14 +574,14 @@ func (c *CrossDataEngine) TimelinePessoa(cpf string) ([]map[string]interface{},
 			est.data_situacao_cadastral,
 			est.correio_eletronico,
 			est.telefone1
-		FROM socios s
-		JOIN estabelecimento est ON s.cnpj = est.cnpj
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
+		FROM {socios} s
+		JOIN {estabelecimento} est ON s.cnpj = est.cnpj
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
 		WHERE s.cnpj_cpf_socio = ?
 		ORDER BY s.data_entrada_sociedade, est.data_inicio_atividades
 	`
 
-	rows, err := db.Query(query, cpf)
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query), cpf)
 	if err != nil {
 		return nil, err
 	}
@@ -466,12 +591,11 @@ func (c *CrossDataEngine) TimelinePessoa(cpf string) ([]map[string]interface{},
 }
 
 // 11. Empresas Baixadas com Sócios Ativos
-func (c *CrossDataEngine) SociosEmpresasBaixadas() ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+func (c *CrossDataEngine) SociosEmpresasBaixadas(ctx context.Context) ([]map[string]interface{}, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	query := `
 		SELECT 
@@ -480,15 +604,16 @@ func (c *CrossDataEngine) SociosEmpresasBaixadas() ([]map[string]interface{}, er
 			COUNT(DISTINCT CASE WHEN est.situacao_cadastral = '02' THEN s.cnpj END) as empresas_ativas,
 			COUNT(DISTINCT CASE WHEN est.situacao_cadastral = '08' THEN s.cnpj END) as empresas_baixadas,
 			COUNT(DISTINCT s.cnpj) as total_empresas
-		FROM socios s
-		JOIN estabelecimento est ON s.cnpj = est.cnpj
+		FROM {socios} s
+		JOIN {estabelecimento} est ON s.cnpj = est.cnpj
 		GROUP BY s.cnpj_cpf_socio, s.nome_socio
-		HAVING empresas_baixadas > 0 AND empresas_ativas > 0
+		HAVING COUNT(DISTINCT CASE WHEN est.situacao_cadastral = '08' THEN s.cnpj END) > 0
+		   AND COUNT(DISTINCT CASE WHEN est.situacao_cadastral = '02' THEN s.cnpj END) > 0
 		ORDER BY empresas_baixadas DESC, empresas_ativas DESC
 		LIMIT 1000
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, c.dialeto.Query(query))
 	if err != nil {
 		return nil, err
 	}
@@ -498,14 +623,13 @@ func (c *CrossDataEngine) SociosEmpresasBaixadas() ([]map[string]interface{}, er
 }
 
 // 12. Dados Completos de Empresa (SEM CENSURA)
-func (c *CrossDataEngine) DadosCompletosEmpresa(cnpj string) (*Empresa, error) {
-	db, err := sql.Open("sqlite3", c.cnpjDB)
+func (c *CrossDataEngine) DadosCompletosEmpresa(ctx context.Context, cnpj string) (*Empresa, error) {
+	db, err := c.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
-	query := `
+	query := fmt.Sprintf(`
 		SELECT 
 			est.cnpj,
 			est.cnpj_basico,
@@ -513,9 +637,9 @@ func (c *CrossDataEngine) DadosCompletosEmpresa(cnpj string) (*Empresa, error) {
 			est.nome_fantasia,
 			est.matriz_filial,
 			est.situacao_cadastral,
-			est.data_situacao_cadastral,
+			%s,
 			est.motivo_situacao_cadastral,
-			est.data_inicio_atividades,
+			%s,
 			est.cnae_fiscal,
 			est.cnae_fiscal_secundaria,
 			e.natureza_juridica,
@@ -537,14 +661,14 @@ func (c *CrossDataEngine) DadosCompletosEmpresa(cnpj string) (*Empresa, error) {
 			est.fax,
 			est.correio_eletronico,
 			COALESCE(sim.opcao_mei, '') as opcao_mei
-		FROM estabelecimento est
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
-		LEFT JOIN simples sim ON est.cnpj_basico = sim.cnpj_basico
+		FROM {estabelecimento} est
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
+		LEFT JOIN {simples} sim ON est.cnpj_basico = sim.cnpj_basico
 		WHERE est.cnpj = ?
-	`
+	`, c.dialeto.DateText("est.data_situacao_cadastral"), c.dialeto.DateText("est.data_inicio_atividades"))
 
 	var emp Empresa
-	err = db.QueryRow(query, cnpj).Scan(
+	err = db.QueryRowContext(ctx, c.dialeto.Query(query), cnpj).Scan(
 		&emp.CNPJ, &emp.CNPJBasico, &emp.RazaoSocial, &emp.NomeFantasia,
 		&emp.MatrizFilial, &emp.SituacaoCadastral, &emp.DataSituacaoCadastral,
 		&emp.MotivoSituacaoCadastral, &emp.DataInicioAtividades,
@@ -585,10 +709,13 @@ func scanToMaps(rows *sql.Rows) ([]map[string]interface{}, error) {
 
 		row := make(map[string]interface{})
 		for i, col := range cols {
-			val := values[i]
-			if b, ok := val.([]byte); ok {
-				row[col] = string(b)
-			} else {
+			switch val := values[i].(type) {
+			case []byte:
+				row[col] = string(val)
+			case time.Time:
+				// Datas do PostgreSQL no mesmo formato gravado no SQLite
+				row[col] = val.Format("2006-01-02")
+			default:
 				row[col] = val
 			}
 		}
@@ -3,6 +3,8 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"log"
+	"os"
 	"sync"
 
 	_ "github.com/lib/pq"
@@ -15,8 +17,8 @@ var (
 	dbReceita *sql.DB // Legacy SQLite (deprecated)
 	dbRede    *sql.DB // Legacy SQLite (deprecated)
 	dbSearch  *sql.DB // Legacy SQLite (deprecated)
+	dbEndereco *sql.DB // Endereços normalizados e CEPs geocodificados (SQLite)
 	dbLocal   *sql.DB
-	dbMutex   sync.Mutex
 	once      sync.Once
 	usePostgres bool
 )
@@ -43,7 +45,7 @@ func InitDatabases(cfg *config.Config) error {
 		}
 		
 		fmt.Println("✅ Conectado ao PostgreSQL")
-		return nil
+		return abrirBaseLocal(cfg)
 	}
 
 	// Fallback para SQLite (legacy)
@@ -80,16 +82,37 @@ func InitDatabases(cfg *config.Config) error {
 		dbSearch.SetMaxIdleConns(5)
 	}
 
-	// Base Local (se configurada)
-	if cfg.BaseLocal != "" {
-		dbLocal, err = sql.Open("sqlite3", cfg.BaseLocal)
+	// Base de endereços normalizados (opcional). Sem o arquivo a conexão fica
+	// nil, e as consultas de endereço retornam endereco.ErrBaseAusente.
+	if cfg.BaseEnderecoNormalizado != "" {
+		if _, err := os.Stat(cfg.BaseEnderecoNormalizado); err != nil {
+			log.Printf("AVISO: base de endereços %s indisponível (%v); gere com rede-cnpj-importer -enderecos", cfg.BaseEnderecoNormalizado, err)
+			return abrirBaseLocal(cfg)
+		}
+		dbEndereco, err = sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", cfg.BaseEnderecoNormalizado))
 		if err != nil {
-			return fmt.Errorf("erro ao abrir base local: %w", err)
+			return fmt.Errorf("erro ao abrir base de endereços: %w", err)
 		}
-		dbLocal.SetMaxOpenConns(5)
-		dbLocal.SetMaxIdleConns(2)
+		dbEndereco.SetMaxOpenConns(10)
+		dbEndereco.SetMaxIdleConns(5)
 	}
 
+	return abrirBaseLocal(cfg)
+}
+
+// abrirBaseLocal abre a base local (casos salvos), que é sempre SQLite,
+// inclusive quando os dados vêm do PostgreSQL
+func abrirBaseLocal(cfg *config.Config) error {
+	if cfg.BaseLocal == "" {
+		return nil
+	}
+	var err error
+	dbLocal, err = sql.Open("sqlite3", cfg.BaseLocal)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir base local: %w", err)
+	}
+	// Uma conexão de escrita evita "database is locked" entre requisições
+	dbLocal.SetMaxOpenConns(1)
 	return nil
 }
 
@@ -130,21 +153,20 @@ func GetDBSearch() *sql.DB {
 	return dbSearch
 }
 
+// GetDBEndereco retorna a conexão com a base de endereços normalizados
+// (nil no SQLite sem base_endereco_normalizado)
+func GetDBEndereco() *sql.DB {
+	if usePostgres {
+		return db
+	}
+	return dbEndereco
+}
+
 // GetDBLocal retorna a conexão com o banco Local
 func GetDBLocal() *sql.DB {
 	return dbLocal
 }
 
-// Lock adquire o mutex global do banco de dados
-func Lock() {
-	dbMutex.Lock()
-}
-
-// Unlock libera o mutex global do banco de dados
-func Unlock() {
-	dbMutex.Unlock()
-}
-
 // Close fecha todas as conexões de banco de dados
 func Close() {
 	if db != nil {
@@ -159,6 +181,9 @@ func Close() {
 	if dbSearch != nil {
 		dbSearch.Close()
 	}
+	if dbEndereco != nil {
+		dbEndereco.Close()
+	}
 	if dbLocal != nil {
 		dbLocal.Close()
 	}
@@ -166,7 +191,12 @@ func Close() {
 
 // AdaptQuery adapta uma query SQLite para PostgreSQL
 func AdaptQuery(query string) string {
-	if !usePostgres {
+	return adaptQuery(query, usePostgres)
+}
+
+// adaptQuery adapta os placeholders da query para o backend informado
+func adaptQuery(query string, postgres bool) string {
+	if !postgres {
 		return query
 	}
 	
@@ -187,16 +217,23 @@ func AdaptQuery(query string) string {
 
 // TablePrefix retorna o prefixo do schema para PostgreSQL
 func TablePrefix(table string) string {
-	if !usePostgres {
+	return tablePrefix(table, usePostgres)
+}
+
+// tablePrefix qualifica a tabela com o schema do backend informado
+func tablePrefix(table string, postgres bool) string {
+	if !postgres {
 		return table
 	}
 	
 	// Mapear tabelas para schemas PostgreSQL
 	switch table {
 	case "empresas", "estabelecimento", "socios", "simples",
-		"cnae", "motivo", "municipio", "natureza_juridica", "pais", "qualificacao_socio":
+		"cnae", "motivo", "municipio", "natureza_juridica", "pais", "qualificacao_socio",
+		"change_log", "socios_historico", "estabelecimento_historico",
+		"qualidade_importacao":
 		return "receita." + table
-	case "ligacao":
+	case "ligacao", "busca", "endereco_normalizado", "cep_geo":
 		return "rede." + table
 	default:
 		return table
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Dialect encapsula as diferenças de SQL entre SQLite e PostgreSQL.
+// As queries são escritas no dialeto SQLite, com placeholders "?" e nomes
+// de tabela entre chaves ({socios}, {ligacao}); Query as adapta ao backend.
+type Dialect struct {
+	Postgres bool
+}
+
+// NewDialect retorna o dialeto do backend configurado em InitDatabases
+func NewDialect() Dialect {
+	return Dialect{Postgres: usePostgres}
+}
+
+var tabelaDialeto = regexp.MustCompile(`\{(\w+)\}`)
+
+// Query qualifica as tabelas entre chaves com o schema e adapta os placeholders
+func (d Dialect) Query(query string) string {
+	query = tabelaDialeto.ReplaceAllStringFunc(query, func(m string) string {
+		return tablePrefix(m[1:len(m)-1], d.Postgres)
+	})
+	return adaptQuery(query, d.Postgres)
+}
+
+// Table retorna o nome da tabela qualificado com o schema
+func (d Dialect) Table(table string) string {
+	return tablePrefix(table, d.Postgres)
+}
+
+// GroupConcat concatena os valores agrupados com o separador informado
+func (d Dialect) GroupConcat(expr, sep string) string {
+	if d.Postgres {
+		return fmt.Sprintf("STRING_AGG(%s::text, '%s')", expr, sep)
+	}
+	return fmt.Sprintf("GROUP_CONCAT(%s, '%s')", expr, sep)
+}
+
+// DateText retorna a data como texto AAAA-MM-DD, formato gravado pelo
+// importador no SQLite
+func (d Dialect) DateText(expr string) string {
+	if d.Postgres {
+		return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD')", expr)
+	}
+	return expr
+}
+
+// Vigente retorna a condição que seleciona, nas tabelas *_historico, a versão
+// válida em uma referência mensal (AAAA-MM). Consome dois placeholders, ambos
+// com a referência.
+func (d Dialect) Vigente(alias string) string {
+	return fmt.Sprintf("(%[1]s.valido_de <= ? AND (%[1]s.valido_ate IS NULL OR %[1]s.valido_ate > ?))", alias)
+}
+
+var formatoReferencia = regexp.MustCompile(`^\d{4}-(0[1-9]|1[0-2])$`)
+
+// ValidarReferencia verifica se a referência mensal está no formato AAAA-MM
+func ValidarReferencia(referencia string) error {
+	if !formatoReferencia.MatchString(referencia) {
+		return fmt.Errorf("referência inválida %q: use o formato AAAA-MM", referencia)
+	}
+	return nil
+}
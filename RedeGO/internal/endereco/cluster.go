@@ -0,0 +1,419 @@
+package endereco
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+)
+
+// Granularidade do agrupamento de estabelecimentos por endereço
+const (
+	GranularidadePredio = "predio" // Mesmo logradouro e número (Chave)
+	GranularidadeSala   = "sala"   // Mesmo prédio e complemento (ChaveComplemento)
+)
+
+// Pesos do score dos clusters. A densidade satura em densidadeMaxima
+// empresas, para que os maiores endereços comerciais não dominem o ranking.
+const (
+	pesoDensidade   = 0.5
+	pesoBaixadas    = 0.3
+	pesoRecentes    = 0.2
+	densidadeMaxima = 100
+)
+
+// MesesRecentes é a janela das aberturas recentes nos clusters
+const MesesRecentes = 12
+
+const loteChaves = 500 // Chaves por consulta em Empresas
+
+// ValidarGranularidade retorna a granularidade informada, ou a de prédio se
+// vazia
+func ValidarGranularidade(g string) (string, error) {
+	switch g {
+	case "":
+		return GranularidadePredio, nil
+	case GranularidadePredio, GranularidadeSala:
+		return g, nil
+	}
+	return "", fmt.Errorf("granularidade inválida %q: use %s ou %s", g, GranularidadePredio, GranularidadeSala)
+}
+
+// ChavePor retorna a chave do endereço na granularidade informada
+func (n Normalizado) ChavePor(granularidade string) string {
+	if granularidade == GranularidadeSala {
+		return n.ChaveComplemento
+	}
+	return n.Chave
+}
+
+// colunaChave retorna a coluna de endereco_normalizado da granularidade
+func colunaChave(granularidade string) string {
+	if granularidade == GranularidadeSala {
+		return "chave_complemento"
+	}
+	return "chave"
+}
+
+// ConsultaClusters são os critérios da detecção de clusters de endereço
+type ConsultaClusters struct {
+	Granularidade string // GranularidadePredio (padrão) ou GranularidadeSala
+	MinEmpresas   int    // Mínimo de empresas (CNPJ básico) no endereço; padrão 10, mínimo 2
+	UF            string
+	Municipio     string // Código do município na Receita
+	Limite        int    // Clusters retornados; padrão 100, máximo 1000
+}
+
+// Cluster é um endereço compartilhado por várias empresas. O score (0 a 1)
+// combina a densidade de empresas, a parcela de estabelecimentos baixados e
+// a parcela aberta nos MesesRecentes anteriores à abertura mais recente da
+// base.
+type Cluster struct {
+	Chave              string  `json:"chave"`
+	Granularidade      string  `json:"granularidade"`
+	Endereco           string  `json:"endereco"`
+	UF                 string  `json:"uf"`
+	Municipio          string  `json:"municipio"`
+	TotalEmpresas      int     `json:"total_empresas"` // CNPJs básicos distintos
+	Estabelecimentos   int     `json:"estabelecimentos"`
+	Complementos       int     `json:"complementos"` // Salas ou unidades distintas
+	Ativas             int     `json:"ativas"`
+	Baixadas           int     `json:"baixadas"`
+	PercentualBaixadas float64 `json:"percentual_baixadas"`
+	AberturasRecentes  int     `json:"aberturas_recentes"`
+	PercentualRecentes float64 `json:"percentual_recentes"`
+	UltimaAbertura     string  `json:"ultima_abertura,omitempty"`
+	Score              float64 `json:"score"`
+}
+
+// EstabelecimentoCluster é um estabelecimento de um cluster
+type EstabelecimentoCluster struct {
+	CNPJ        string `json:"cnpj"`
+	CNPJBasico  string `json:"cnpj_basico"`
+	Complemento string `json:"complemento,omitempty"`
+	Situacao    string `json:"situacao_cadastral"`
+	Abertura    string `json:"data_inicio_atividades,omitempty"`
+}
+
+// Agrupador detecta clusters de estabelecimentos pelas chaves de
+// endereco_normalizado
+type Agrupador struct {
+	db      *sql.DB
+	dialeto database.Dialect
+}
+
+// NewAgrupador cria um agrupador sobre a base de endereços (SQLite) ou o
+// schema rede (PostgreSQL)
+func NewAgrupador(db *sql.DB, dialeto database.Dialect) *Agrupador {
+	return &Agrupador{db: db, dialeto: dialeto}
+}
+
+// Clusters retorna os endereços com pelo menos MinEmpresas empresas,
+// ordenados pelo score. Os candidatos são os endereços mais densos; o score
+// só reordena entre eles.
+func (a *Agrupador) Clusters(ctx context.Context, c ConsultaClusters) ([]Cluster, error) {
+	if a.db == nil {
+		return nil, ErrBaseAusente
+	}
+	granularidade, err := ValidarGranularidade(c.Granularidade)
+	if err != nil {
+		return nil, err
+	}
+	if c.MinEmpresas <= 0 {
+		c.MinEmpresas = 10
+	}
+	c.MinEmpresas = max(c.MinEmpresas, 2)
+	if c.Limite <= 0 {
+		c.Limite = 100
+	}
+	c.Limite = min(c.Limite, 1000)
+
+	corte, err := a.corteRecentes(ctx)
+	if err != nil {
+		return nil, erroBase(err)
+	}
+
+	coluna := colunaChave(granularidade)
+	filtros := ""
+	args := []interface{}{corte}
+	if c.UF != "" {
+		filtros += " AND uf = ?"
+		args = append(args, strings.ToUpper(c.UF))
+	}
+	if c.Municipio != "" {
+		filtros += " AND municipio = ?"
+		args = append(args, c.Municipio)
+	}
+	args = append(args, c.MinEmpresas, max(c.Limite*10, 500))
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s, COUNT(*), COUNT(DISTINCT cnpj_basico), COUNT(DISTINCT complemento),
+			SUM(CASE WHEN situacao_cadastral = '02' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN situacao_cadastral = '08' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN data_inicio_atividades >= ? THEN 1 ELSE 0 END),
+			COALESCE(MAX(data_inicio_atividades), ''), MIN(cnpj)
+		FROM {endereco_normalizado}
+		WHERE %[1]s <> ''%[2]s
+		GROUP BY %[1]s
+		HAVING COUNT(DISTINCT cnpj_basico) >= ?
+		ORDER BY COUNT(DISTINCT cnpj_basico) DESC
+		LIMIT ?
+	`, coluna, filtros)
+
+	rows, err := a.db.QueryContext(ctx, a.dialeto.Query(query), args...)
+	if err != nil {
+		return nil, erroBase(err)
+	}
+	defer rows.Close()
+
+	var clusters []Cluster
+	var exemplos []string // Um CNPJ de cada cluster, para o texto do endereço
+	for rows.Next() {
+		cl := Cluster{Granularidade: granularidade}
+		var exemplo string
+		if err := rows.Scan(&cl.Chave, &cl.Estabelecimentos, &cl.TotalEmpresas, &cl.Complementos,
+			&cl.Ativas, &cl.Baixadas, &cl.AberturasRecentes, &cl.UltimaAbertura, &exemplo); err != nil {
+			return nil, err
+		}
+		cl.pontuar()
+		clusters = append(clusters, cl)
+		exemplos = append(exemplos, exemplo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ordem := make([]int, len(clusters))
+	for i := range ordem {
+		ordem[i] = i
+	}
+	sort.SliceStable(ordem, func(i, j int) bool {
+		x, y := clusters[ordem[i]], clusters[ordem[j]]
+		if x.Score != y.Score {
+			return x.Score > y.Score
+		}
+		return x.TotalEmpresas > y.TotalEmpresas
+	})
+	if len(ordem) > c.Limite {
+		ordem = ordem[:c.Limite]
+	}
+
+	resultado := make([]Cluster, len(ordem))
+	for i, idx := range ordem {
+		resultado[i] = clusters[idx]
+		if err := a.descrever(ctx, &resultado[i], exemplos[idx]); err != nil {
+			return nil, err
+		}
+	}
+	return resultado, nil
+}
+
+// pontuar calcula os percentuais e o score do cluster
+func (c *Cluster) pontuar() {
+	if c.Estabelecimentos == 0 {
+		return
+	}
+	total := float64(c.Estabelecimentos)
+	c.PercentualBaixadas = math.Round(float64(c.Baixadas)/total*1000) / 10
+	c.PercentualRecentes = math.Round(float64(c.AberturasRecentes)/total*1000) / 10
+
+	densidade := math.Min(1, math.Log(float64(c.TotalEmpresas))/math.Log(densidadeMaxima))
+	score := pesoDensidade*densidade + pesoBaixadas*float64(c.Baixadas)/total + pesoRecentes*float64(c.AberturasRecentes)/total
+	c.Score = math.Round(score*1000) / 1000
+}
+
+// corteRecentes retorna a data (AAAA-MM-DD) a partir da qual uma abertura é
+// recente: MesesRecentes antes da abertura mais recente da base, para que o ranking
+// não dependa da data da consulta
+func (a *Agrupador) corteRecentes(ctx context.Context) (string, error) {
+	var ultima sql.NullString
+	if err := a.db.QueryRowContext(ctx, a.dialeto.Query(
+		"SELECT MAX(data_inicio_atividades) FROM {endereco_normalizado}")).Scan(&ultima); err != nil {
+		return "", err
+	}
+	referencia, err := time.Parse("2006-01-02", ultima.String)
+	if err != nil {
+		referencia = time.Now()
+	}
+	return referencia.AddDate(0, -MesesRecentes, 0).Format("2006-01-02"), nil
+}
+
+// descrever preenche o endereço do cluster a partir de um dos seus
+// estabelecimentos
+func (a *Agrupador) descrever(ctx context.Context, c *Cluster, cnpj string) error {
+	var n Normalizado
+	err := a.db.QueryRowContext(ctx, a.dialeto.Query(`
+		SELECT tipo_logradouro, logradouro, numero, complemento, bairro, cep, municipio, uf
+		FROM {endereco_normalizado} WHERE cnpj = ?
+	`), cnpj).Scan(&n.TipoLogradouro, &n.Logradouro, &n.Numero, &n.Complemento, &n.Bairro, &n.CEP, &n.Municipio, &n.UF)
+	if err != nil {
+		return err
+	}
+	if c.Granularidade == GranularidadePredio {
+		n.Complemento = ""
+	}
+	c.Endereco, c.UF, c.Municipio = n.Texto(), n.UF, n.Municipio
+	return nil
+}
+
+// Estabelecimentos retorna até limite estabelecimentos (0 = todos) com a
+// chave informada, dos abertos mais recentemente aos mais antigos
+func (a *Agrupador) Estabelecimentos(ctx context.Context, granularidade, chave string, limite int) ([]EstabelecimentoCluster, error) {
+	if a.db == nil {
+		return nil, ErrBaseAusente
+	}
+	query := fmt.Sprintf(`
+		SELECT cnpj, cnpj_basico, complemento, situacao_cadastral, data_inicio_atividades
+		FROM {endereco_normalizado}
+		WHERE %s = ?
+		ORDER BY data_inicio_atividades DESC, cnpj
+	`, colunaChave(granularidade))
+	args := []interface{}{chave}
+	if limite > 0 {
+		query += " LIMIT ?"
+		args = append(args, limite)
+	}
+
+	rows, err := a.db.QueryContext(ctx, a.dialeto.Query(query), args...)
+	if err != nil {
+		return nil, erroBase(err)
+	}
+	defer rows.Close()
+
+	var estabelecimentos []EstabelecimentoCluster
+	for rows.Next() {
+		var e EstabelecimentoCluster
+		if err := rows.Scan(&e.CNPJ, &e.CNPJBasico, &e.Complemento, &e.Situacao, &e.Abertura); err != nil {
+			return nil, err
+		}
+		estabelecimentos = append(estabelecimentos, e)
+	}
+	return estabelecimentos, rows.Err()
+}
+
+// Empresas retorna os CNPJs básicos distintos de cada chave, com uma
+// consulta por lote de chaves
+func (a *Agrupador) Empresas(ctx context.Context, granularidade string, chaves []string) (map[string][]string, error) {
+	if a.db == nil {
+		return nil, ErrBaseAusente
+	}
+	coluna := colunaChave(granularidade)
+	empresas := make(map[string][]string, len(chaves))
+	for inicio := 0; inicio < len(chaves); inicio += loteChaves {
+		lote := chaves[inicio:min(inicio+loteChaves, len(chaves))]
+		args := make([]interface{}, len(lote))
+		for i, chave := range lote {
+			args[i] = chave
+		}
+		query := fmt.Sprintf(`
+			SELECT DISTINCT %[1]s, cnpj_basico FROM {endereco_normalizado} WHERE %[1]s IN (%[2]s)
+		`, coluna, strings.TrimSuffix(strings.Repeat("?, ", len(lote)), ", "))
+
+		rows, err := a.db.QueryContext(ctx, a.dialeto.Query(query), args...)
+		if err != nil {
+			return nil, erroBase(err)
+		}
+		for rows.Next() {
+			var chave, basico string
+			if err := rows.Scan(&chave, &basico); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			empresas[chave] = append(empresas[chave], basico)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return empresas, nil
+}
+
+// Enderecos retorna o endereço normalizado de cada CNPJ encontrado na base,
+// com uma consulta por lote de CNPJs
+func (a *Agrupador) Enderecos(ctx context.Context, cnpjs []string) (map[string]Normalizado, error) {
+	if a.db == nil {
+		return nil, ErrBaseAusente
+	}
+	enderecos := make(map[string]Normalizado, len(cnpjs))
+	for inicio := 0; inicio < len(cnpjs); inicio += loteChaves {
+		lote := cnpjs[inicio:min(inicio+loteChaves, len(cnpjs))]
+		args := make([]interface{}, len(lote))
+		for i, cnpj := range lote {
+			args[i] = cnpj
+		}
+		query := fmt.Sprintf(`
+			SELECT cnpj, tipo_logradouro, logradouro, numero, complemento, bairro, cep, municipio, uf,
+				chave, chave_complemento
+			FROM {endereco_normalizado} WHERE cnpj IN (%s)
+		`, strings.TrimSuffix(strings.Repeat("?, ", len(lote)), ", "))
+
+		rows, err := a.db.QueryContext(ctx, a.dialeto.Query(query), args...)
+		if err != nil {
+			return nil, erroBase(err)
+		}
+		for rows.Next() {
+			var cnpj string
+			var n Normalizado
+			if err := rows.Scan(&cnpj, &n.TipoLogradouro, &n.Logradouro, &n.Numero, &n.Complemento, &n.Bairro,
+				&n.CEP, &n.Municipio, &n.UF, &n.Chave, &n.ChaveComplemento); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			enderecos[cnpj] = n
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return enderecos, nil
+}
+
+// Ativas retorna o número de estabelecimentos ativos (situação 02) de cada
+// chave, com uma consulta por lote de chaves. Chaves sem estabelecimento
+// ativo ficam de fora.
+func (a *Agrupador) Ativas(ctx context.Context, granularidade string, chaves []string) (map[string]int, error) {
+	if a.db == nil {
+		return nil, ErrBaseAusente
+	}
+	coluna := colunaChave(granularidade)
+	ativas := make(map[string]int, len(chaves))
+	for inicio := 0; inicio < len(chaves); inicio += loteChaves {
+		lote := chaves[inicio:min(inicio+loteChaves, len(chaves))]
+		args := make([]interface{}, len(lote))
+		for i, chave := range lote {
+			args[i] = chave
+		}
+		query := fmt.Sprintf(`
+			SELECT %[1]s, COUNT(*) FROM {endereco_normalizado}
+			WHERE %[1]s IN (%[2]s) AND situacao_cadastral = '02'
+			GROUP BY %[1]s
+		`, coluna, strings.TrimSuffix(strings.Repeat("?, ", len(lote)), ", "))
+
+		rows, err := a.db.QueryContext(ctx, a.dialeto.Query(query), args...)
+		if err != nil {
+			return nil, erroBase(err)
+		}
+		for rows.Next() {
+			var chave string
+			var total int
+			if err := rows.Scan(&chave, &total); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			ativas[chave] = total
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return ativas, nil
+}
@@ -0,0 +1,153 @@
+package endereco
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+)
+
+// baseEnderecos cria endereco_normalizado com dois prédios: o da Cesário
+// Mota, com grafias variadas, metade das empresas baixadas e aberturas
+// recentes, e o da Paulista, maior porém antigo e todo ativo
+func baseEnderecos(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "endereco_normalizado.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE endereco_normalizado (cnpj TEXT PRIMARY KEY, cnpj_basico TEXT,
+		tipo_logradouro TEXT, logradouro TEXT, numero TEXT, complemento TEXT, bairro TEXT, cep TEXT,
+		cep_valido INTEGER, municipio TEXT, uf TEXT, chave TEXT, chave_complemento TEXT,
+		situacao_cadastral TEXT, data_inicio_atividades TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	inserir := func(cnpj string, e Endereco, situacao, abertura string) {
+		n := Normalizar(e)
+		if _, err := db.Exec(`INSERT INTO endereco_normalizado VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			cnpj, cnpj[:8], n.TipoLogradouro, n.Logradouro, n.Numero, n.Complemento, n.Bairro, n.CEP, n.CEPValido,
+			n.Municipio, n.UF, n.Chave, n.ChaveComplemento, situacao, abertura); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	grafias := []Endereco{
+		{TipoLogradouro: "R", Logradouro: "DR CESARIO MOTA", Numero: "10", Complemento: "SL 1201"},
+		{TipoLogradouro: "RUA", Logradouro: "R. Doutor Cesário Mota", Numero: "0010", Complemento: "SALA 1201"},
+	}
+	for i := 0; i < 12; i++ {
+		e := grafias[i%2]
+		if i >= 8 {
+			e.Complemento = "SALA 1202"
+		}
+		e.CEP, e.Municipio, e.UF = "01221-020", "7107", "SP"
+		situacao, abertura := "02", "2015-03-01"
+		if i%2 == 1 {
+			situacao = "08"
+		}
+		if i < 2 {
+			abertura = "2024-01-15"
+		}
+		inserir(fmt.Sprintf("1%07d000100", i), e, situacao, abertura)
+	}
+
+	for i := 0; i < 15; i++ {
+		e := Endereco{TipoLogradouro: "AV", Logradouro: "PAULISTA", Numero: "S/N", CEP: "01310100", Municipio: "7107", UF: "SP"}
+		inserir(fmt.Sprintf("2%07d000100", i), e, "02", "2005-01-01")
+	}
+	// Filial de uma empresa já contada no prédio e abertura mais recente da base
+	inserir("20000000000200", Endereco{TipoLogradouro: "AVENIDA", Logradouro: "PAULISTA", Numero: "SN",
+		CEP: "01310100", Municipio: "7107", UF: "SP"}, "02", "2024-06-01")
+	return db
+}
+
+func TestClusters(t *testing.T) {
+	a := NewAgrupador(baseEnderecos(t), database.Dialect{})
+	ctx := context.Background()
+
+	clusters, err := a.Clusters(ctx, ConsultaClusters{})
+	if err != nil {
+		t.Fatalf("Clusters() erro: %v", err)
+	}
+	if len(clusters) != 2 {
+		t.Fatalf("Clusters() = %+v, esperado 2 prédios", clusters)
+	}
+
+	// Menos empresas, mas metade baixada e aberturas recentes: primeiro
+	c := clusters[0]
+	if c.Chave != "01221020|DOUTOR CESARIO MOTA|10" || c.TotalEmpresas != 12 || c.Complementos != 2 {
+		t.Errorf("primeiro cluster = %+v", c)
+	}
+	if c.Baixadas != 6 || c.PercentualBaixadas != 50 || c.AberturasRecentes != 2 || c.UltimaAbertura != "2024-01-15" {
+		t.Errorf("indicadores do primeiro cluster = %+v", c)
+	}
+	if c.Endereco != "RUA DOUTOR CESARIO MOTA, 10 - CEP 01221-020 - SP" {
+		t.Errorf("endereço do cluster = %q", c.Endereco)
+	}
+
+	p := clusters[1]
+	if p.TotalEmpresas != 15 || p.Estabelecimentos != 16 || p.AberturasRecentes != 1 || p.Score >= c.Score {
+		t.Errorf("segundo cluster = %+v, esperado score menor que %.3f", p, c.Score)
+	}
+
+	// Por sala, só a 1201 tem empresas suficientes
+	salas, err := a.Clusters(ctx, ConsultaClusters{Granularidade: GranularidadeSala, MinEmpresas: 5, UF: "sp"})
+	if err != nil {
+		t.Fatalf("Clusters(sala) erro: %v", err)
+	}
+	if len(salas) != 2 || salas[0].Chave != "01221020|DOUTOR CESARIO MOTA|10|SALA 1201" || salas[0].TotalEmpresas != 8 {
+		t.Errorf("Clusters(sala) = %+v", salas)
+	}
+	if salas[0].Endereco != "RUA DOUTOR CESARIO MOTA, 10, SALA 1201 - CEP 01221-020 - SP" {
+		t.Errorf("endereço da sala = %q", salas[0].Endereco)
+	}
+
+	estabelecimentos, err := a.Estabelecimentos(ctx, GranularidadeSala, salas[0].Chave, 0)
+	if err != nil {
+		t.Fatalf("Estabelecimentos() erro: %v", err)
+	}
+	if len(estabelecimentos) != 8 || estabelecimentos[0].Abertura != "2024-01-15" {
+		t.Errorf("Estabelecimentos() = %+v", estabelecimentos)
+	}
+	if limitados, err := a.Estabelecimentos(ctx, GranularidadeSala, salas[0].Chave, 3); err != nil || len(limitados) != 3 {
+		t.Errorf("Estabelecimentos(limite 3) = %d, %v, esperado 3", len(limitados), err)
+	}
+
+	empresas, err := a.Empresas(ctx, GranularidadePredio, []string{clusters[0].Chave, clusters[1].Chave, "inexistente"})
+	if err != nil {
+		t.Fatalf("Empresas() erro: %v", err)
+	}
+	if len(empresas) != 2 || len(empresas[clusters[0].Chave]) != 12 || len(empresas[clusters[1].Chave]) != 15 {
+		t.Errorf("Empresas() = %d chaves, %d e %d empresas, esperado 12 e 15",
+			len(empresas), len(empresas[clusters[0].Chave]), len(empresas[clusters[1].Chave]))
+	}
+
+	normalizados, err := a.Enderecos(ctx, []string{"10000001000100", "20000000000200", "99999999000100"})
+	if err != nil {
+		t.Fatalf("Enderecos() erro: %v", err)
+	}
+	if len(normalizados) != 2 || normalizados["10000001000100"].Chave != clusters[0].Chave ||
+		normalizados["20000000000200"].Numero != SemNumero {
+		t.Errorf("Enderecos() = %+v", normalizados)
+	}
+
+	ativas, err := a.Ativas(ctx, GranularidadePredio, []string{clusters[0].Chave, clusters[1].Chave, "inexistente"})
+	if err != nil {
+		t.Fatalf("Ativas() erro: %v", err)
+	}
+	if len(ativas) != 2 || ativas[clusters[0].Chave] != 6 || ativas[clusters[1].Chave] != 16 {
+		t.Errorf("Ativas() = %v, esperado 6 e 16", ativas)
+	}
+
+	if _, err := a.Clusters(ctx, ConsultaClusters{Granularidade: "andar"}); err == nil {
+		t.Error("Clusters() com granularidade inválida deveria falhar")
+	}
+}
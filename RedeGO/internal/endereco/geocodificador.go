@@ -0,0 +1,150 @@
+package endereco
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+)
+
+// Precisão da coordenada de uma localização
+const (
+	PrecisaoCEP   = "cep"   // Coordenada do próprio CEP
+	PrecisaoSetor = "setor" // Média dos CEPs com os mesmos cinco primeiros dígitos
+)
+
+// ErrBaseAusente indica que a base de endereços normalizados não está
+// configurada ou ainda não foi gerada
+var ErrBaseAusente = errors.New("base de endereços ausente ou não configurada (base_endereco_normalizado); gere com rede-cnpj-importer -enderecos e -ceps")
+
+const loteLocalizacao = 500 // CNPJs por consulta
+
+// Localizacao é o endereço normalizado de um estabelecimento e a sua
+// coordenada
+type Localizacao struct {
+	CNPJ      string
+	Endereco  Normalizado
+	Latitude  float64
+	Longitude float64
+	Precisao  string
+}
+
+// Geocodificador localiza estabelecimentos offline, pelo CEP do endereço
+// normalizado e pela tabela cep_geo importada com rede-cnpj-importer -ceps
+type Geocodificador struct {
+	db      *sql.DB
+	dialeto database.Dialect
+}
+
+// NewGeocodificador cria um geocodificador sobre a base de endereços
+// (SQLite) ou o schema rede (PostgreSQL)
+func NewGeocodificador(db *sql.DB, dialeto database.Dialect) *Geocodificador {
+	return &Geocodificador{db: db, dialeto: dialeto}
+}
+
+// Localizar retorna a localização de cada CNPJ com endereço normalizado e
+// coordenada. Sem a coordenada exata do CEP, usa a média do setor (cinco
+// primeiros dígitos). CNPJs sem endereço ou sem coordenada ficam de fora.
+func (g *Geocodificador) Localizar(ctx context.Context, cnpjs []string) (map[string]Localizacao, error) {
+	if g.db == nil {
+		return nil, ErrBaseAusente
+	}
+
+	localizacoes := make(map[string]Localizacao, len(cnpjs))
+	setores := make(map[string]*Localizacao)
+	for inicio := 0; inicio < len(cnpjs); inicio += loteLocalizacao {
+		lote := cnpjs[inicio:min(inicio+loteLocalizacao, len(cnpjs))]
+		if err := g.localizarLote(ctx, lote, localizacoes, setores); err != nil {
+			return nil, erroBase(err)
+		}
+	}
+	return localizacoes, nil
+}
+
+func (g *Geocodificador) localizarLote(ctx context.Context, cnpjs []string, localizacoes map[string]Localizacao, setores map[string]*Localizacao) error {
+	args := make([]interface{}, len(cnpjs))
+	for i, cnpj := range cnpjs {
+		args[i] = cnpj
+	}
+	query := fmt.Sprintf(`
+		SELECT e.cnpj, e.tipo_logradouro, e.logradouro, e.numero, e.complemento, e.bairro,
+			e.cep, e.cep_valido, e.municipio, e.uf, e.chave, e.chave_complemento,
+			g.latitude, g.longitude
+		FROM {endereco_normalizado} e
+		LEFT JOIN {cep_geo} g ON g.cep = e.cep
+		WHERE e.cnpj IN (%s)
+	`, strings.TrimSuffix(strings.Repeat("?, ", len(cnpjs)), ", "))
+
+	rows, err := g.db.QueryContext(ctx, g.dialeto.Query(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var semCoordenada []Localizacao
+	for rows.Next() {
+		var l Localizacao
+		var lat, lon sql.NullFloat64
+		n := &l.Endereco
+		if err := rows.Scan(&l.CNPJ, &n.TipoLogradouro, &n.Logradouro, &n.Numero, &n.Complemento, &n.Bairro,
+			&n.CEP, &n.CEPValido, &n.Municipio, &n.UF, &n.Chave, &n.ChaveComplemento, &lat, &lon); err != nil {
+			return err
+		}
+		if lat.Valid && lon.Valid {
+			l.Latitude, l.Longitude, l.Precisao = lat.Float64, lon.Float64, PrecisaoCEP
+			localizacoes[l.CNPJ] = l
+		} else if n.CEPValido {
+			semCoordenada = append(semCoordenada, l)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, l := range semCoordenada {
+		setor, err := g.setor(ctx, l.Endereco.CEP[:5], setores)
+		if err != nil {
+			return err
+		}
+		if setor != nil {
+			l.Latitude, l.Longitude, l.Precisao = setor.Latitude, setor.Longitude, PrecisaoSetor
+			localizacoes[l.CNPJ] = l
+		}
+	}
+	return nil
+}
+
+// setor retorna a coordenada média dos CEPs com o prefixo informado, ou nil
+// se nenhum estiver na tabela
+func (g *Geocodificador) setor(ctx context.Context, prefixo string, setores map[string]*Localizacao) (*Localizacao, error) {
+	if l, ok := setores[prefixo]; ok {
+		return l, nil
+	}
+	var lat, lon sql.NullFloat64
+	err := g.db.QueryRowContext(ctx, g.dialeto.Query(`
+		SELECT AVG(latitude), AVG(longitude) FROM {cep_geo} WHERE cep BETWEEN ? AND ?
+	`), prefixo+"000", prefixo+"999").Scan(&lat, &lon)
+	if err != nil {
+		return nil, err
+	}
+	var l *Localizacao
+	if lat.Valid && lon.Valid {
+		l = &Localizacao{Latitude: lat.Float64, Longitude: lon.Float64}
+	}
+	setores[prefixo] = l
+	return l, nil
+}
+
+// erroBase converte em ErrBaseAusente o erro de tabela ou arquivo ausente
+func erroBase(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "no such table") || strings.Contains(msg, "does not exist") ||
+		strings.Contains(msg, "unable to open database file") {
+		return fmt.Errorf("%w (%v)", ErrBaseAusente, err)
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package endereco
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+)
+
+func TestLocalizarSemBase(t *testing.T) {
+	if _, err := NewGeocodificador(nil, database.Dialect{}).Localizar(context.Background(), []string{"11111111000191"}); !errors.Is(err, ErrBaseAusente) {
+		t.Errorf("Localizar() sem base = %v, esperado ErrBaseAusente", err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "vazia.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = NewGeocodificador(db, database.Dialect{}).Localizar(context.Background(), []string{"11111111000191"})
+	if !errors.Is(err, ErrBaseAusente) || !strings.Contains(err.Error(), "rede-cnpj-importer -enderecos") {
+		t.Errorf("Localizar() sem tabelas = %v, esperado ErrBaseAusente com instrução do importador", err)
+	}
+
+	// Arquivo inexistente aberto somente leitura, como no servidor
+	ausente, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "ausente.db")+"?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ausente.Close()
+	if _, err := NewGeocodificador(ausente, database.Dialect{}).Localizar(context.Background(), []string{"11111111000191"}); !errors.Is(err, ErrBaseAusente) {
+		t.Errorf("Localizar() com arquivo ausente = %v, esperado ErrBaseAusente", err)
+	}
+}
@@ -0,0 +1,309 @@
+// Package endereco normaliza os endereços dos estabelecimentos e os
+// geocodifica offline pelo CEP. O mesmo endereço aparece na base da Receita
+// com grafias diferentes ("R." e "RUA", "S/N" e "SN", "SL 1201" e
+// "SALA 1201"); a forma normalizada e suas chaves permitem agrupar os
+// estabelecimentos por prédio ou por sala.
+package endereco
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/utils"
+)
+
+// SemNumero é o número dos endereços sem número (S/N, SN, 0 ou vazio)
+const SemNumero = "SN"
+
+// Endereco são os campos de endereço de um estabelecimento como gravados na
+// base da Receita
+type Endereco struct {
+	TipoLogradouro string
+	Logradouro     string
+	Numero         string
+	Complemento    string
+	Bairro         string
+	CEP            string
+	Municipio      string // Código do município na Receita
+	UF             string
+}
+
+// Normalizado é o endereço na forma canônica. Chave identifica o prédio
+// (CEP, logradouro e número) e ChaveComplemento a unidade dentro dele. Com
+// CEP inválido a chave usa UF e município no lugar do CEP; as duas ficam
+// vazias quando não há logradouro ou localidade.
+type Normalizado struct {
+	TipoLogradouro   string `json:"tipo_logradouro"`
+	Logradouro       string `json:"logradouro"`
+	Numero           string `json:"numero"`
+	Complemento      string `json:"complemento"`
+	Bairro           string `json:"bairro"`
+	CEP              string `json:"cep"`
+	CEPValido        bool   `json:"cep_valido"`
+	Municipio        string `json:"municipio"`
+	UF               string `json:"uf"`
+	Chave            string `json:"chave"`
+	ChaveComplemento string `json:"chave_complemento"`
+}
+
+// tiposLogradouro expande as abreviações de tipo de logradouro
+var tiposLogradouro = map[string]string{
+	"R": "RUA", "RUA": "RUA",
+	"AV": "AVENIDA", "AVE": "AVENIDA", "AVN": "AVENIDA", "AVEN": "AVENIDA", "AVENIDA": "AVENIDA",
+	"AL": "ALAMEDA", "ALAMEDA": "ALAMEDA",
+	"TV": "TRAVESSA", "TR": "TRAVESSA", "TRAV": "TRAVESSA", "TRAVESSA": "TRAVESSA",
+	"PC": "PRACA", "PCA": "PRACA", "PRC": "PRACA", "PRACA": "PRACA",
+	"EST": "ESTRADA", "ESTR": "ESTRADA", "ESTRADA": "ESTRADA",
+	"ROD": "RODOVIA", "RODOVIA": "RODOVIA",
+	"LG": "LARGO", "LGO": "LARGO", "LARGO": "LARGO",
+	"VL": "VILA", "VILA": "VILA",
+	"BC": "BECO", "BECO": "BECO",
+	"LD": "LADEIRA", "LAD": "LADEIRA", "LADEIRA": "LADEIRA",
+	"VD": "VIADUTO", "VIADUTO": "VIADUTO",
+	"PQ": "PARQUE", "PRQ": "PARQUE", "PARQUE": "PARQUE",
+	"QD": "QUADRA", "QUADRA": "QUADRA",
+	"CJ": "CONJUNTO", "CONJ": "CONJUNTO", "CONJUNTO": "CONJUNTO",
+	"JD": "JARDIM", "JARDIM": "JARDIM",
+	"SRV": "SERVIDAO", "SERVIDAO": "SERVIDAO",
+	"PSG": "PASSAGEM", "PASSAGEM": "PASSAGEM",
+	"CAM": "CAMINHO", "CAMINHO": "CAMINHO",
+	"AC": "ACESSO", "ACESSO": "ACESSO",
+	"COND": "CONDOMINIO", "CONDOMINIO": "CONDOMINIO",
+	"LOT": "LOTEAMENTO", "LOTEAMENTO": "LOTEAMENTO",
+	"FAZ": "FAZENDA", "FAZENDA": "FAZENDA",
+	"CH": "CHACARA", "CHACARA": "CHACARA",
+	"SIT": "SITIO", "SITIO": "SITIO",
+	"VIA": "VIA", "PRAIA": "PRAIA", "PATIO": "PATIO", "TREVO": "TREVO",
+}
+
+// titulos expande abreviações comuns nos nomes de logradouro
+var titulos = map[string]string{
+	"DR": "DOUTOR", "DRA": "DOUTORA", "PROF": "PROFESSOR", "PROFA": "PROFESSORA",
+	"ENG": "ENGENHEIRO", "GAL": "GENERAL", "GEN": "GENERAL", "CEL": "CORONEL",
+	"CAP": "CAPITAO", "TEN": "TENENTE", "SGT": "SARGENTO", "MAL": "MARECHAL",
+	"BRIG": "BRIGADEIRO", "ALM": "ALMIRANTE", "PRES": "PRESIDENTE",
+	"GOV": "GOVERNADOR", "DEP": "DEPUTADO", "SEN": "SENADOR", "VER": "VEREADOR",
+	"STA": "SANTA", "STO": "SANTO", "PE": "PADRE", "NSA": "NOSSA", "SRA": "SENHORA",
+}
+
+// unidades expande as abreviações do complemento
+var unidades = map[string]string{
+	"SL": "SALA", "SLA": "SALA", "SALA": "SALA", "SLS": "SALA", "SALAS": "SALA",
+	"AP": "APTO", "APT": "APTO", "APTO": "APTO", "APART": "APTO", "APARTAMENTO": "APTO",
+	"AND": "ANDAR", "ANDR": "ANDAR", "ANDAR": "ANDAR", "PAV": "ANDAR", "PAVIMENTO": "ANDAR",
+	"BL": "BLOCO", "BLC": "BLOCO", "BLOCO": "BLOCO",
+	"LJ": "LOJA", "LOJA": "LOJA", "LJS": "LOJA", "LOJAS": "LOJA",
+	"CJ": "CONJ", "CONJ": "CONJ", "CONJUNTO": "CONJ",
+	"CS": "CASA", "CASA": "CASA",
+	"GLP": "GALPAO", "GALPAO": "GALPAO",
+	"QD": "QUADRA", "QUADRA": "QUADRA", "LT": "LOTE", "LOTE": "LOTE",
+	"ED": "EDIFICIO", "EDIF": "EDIFICIO", "EDIFICIO": "EDIFICIO",
+	"TR": "TORRE", "TORRE": "TORRE", "BX": "BOX", "BOX": "BOX",
+	"FDS": "FUNDOS", "FUNDOS": "FUNDOS", "TER": "TERREO", "TERREO": "TERREO",
+}
+
+// particulas são ignoradas nas chaves e no complemento
+var particulas = map[string]bool{
+	"A": true, "O": true, "E": true, "DA": true, "DE": true, "DO": true, "DAS": true, "DOS": true,
+}
+
+var (
+	naoAlfanumerico = regexp.MustCompile(`[^A-Z0-9]+`)
+	letraNumero     = regexp.MustCompile(`([A-Z])([0-9])|([0-9])([A-Z]{2,})`)
+	semNumero       = regexp.MustCompile(`^(S ?N|S ?N ?O|SEM ?N(UMERO|R)?|0+)$`)
+	prefixoNumero   = regexp.MustCompile(`^(N|NO|NR|NUM|NUMERO) `)
+	numeroInicial   = regexp.MustCompile(`^0*([0-9]+)(?: ?([A-Z]))?(?: |$)`)
+	quilometro      = regexp.MustCompile(`^KM\s*([0-9]+)(?:[.,]([0-9]+))?`)
+	milhar          = regexp.MustCompile(`([0-9])\.([0-9]{3})`)
+)
+
+// limpar remove acentos e pontuação e converte para maiúsculas
+func limpar(s string) string {
+	s = utils.RemoveAcentos(strings.ToUpper(s))
+	return strings.TrimSpace(naoAlfanumerico.ReplaceAllString(s, " "))
+}
+
+// Normalizar converte o endereço para a forma canônica
+func Normalizar(e Endereco) Normalizado {
+	n := Normalizado{
+		Bairro:    limpar(e.Bairro),
+		CEP:       LimparCEP(e.CEP),
+		Municipio: strings.TrimSpace(e.Municipio),
+		UF:        strings.ToUpper(strings.TrimSpace(e.UF)),
+	}
+	n.CEPValido = ValidarCEP(n.CEP, n.UF)
+	n.TipoLogradouro, n.Logradouro = normalizarLogradouro(e.TipoLogradouro, e.Logradouro)
+
+	var resto string
+	n.Numero, resto = normalizarNumero(e.Numero)
+	n.Complemento = normalizarComplemento(strings.TrimSpace(resto + " " + e.Complemento))
+
+	local := n.CEP
+	if !n.CEPValido {
+		local = ""
+		if n.Municipio != "" {
+			local = n.UF + n.Municipio
+		}
+	}
+	if nome := chaveNome(n.Logradouro); nome != "" && local != "" {
+		n.Chave = local + "|" + nome + "|" + n.Numero
+		n.ChaveComplemento = n.Chave + "|" + n.Complemento
+	}
+	return n
+}
+
+// Texto formata o endereço para exibição
+func (n Normalizado) Texto() string {
+	partes := []string{strings.TrimSpace(n.TipoLogradouro + " " + n.Logradouro)}
+	if n.Numero == SemNumero {
+		partes = append(partes, "S/N")
+	} else if n.Numero != "" {
+		partes = append(partes, n.Numero)
+	}
+	if n.Complemento != "" {
+		partes = append(partes, n.Complemento)
+	}
+	texto := strings.Join(partes, ", ")
+	if n.Bairro != "" {
+		texto += " - " + n.Bairro
+	}
+	if len(n.CEP) == 8 {
+		texto += " - CEP " + n.CEP[:5] + "-" + n.CEP[5:]
+	}
+	if n.UF != "" {
+		texto += " - " + n.UF
+	}
+	return texto
+}
+
+// normalizarLogradouro expande o tipo de logradouro e as abreviações do
+// nome. O tipo repetido ou abreviado no início do nome ("R. DAS FLORES")
+// é removido do nome e usado como tipo quando este está vazio.
+func normalizarLogradouro(tipo, logradouro string) (string, string) {
+	tipo = limpar(tipo)
+	if expandido, ok := tiposLogradouro[tipo]; ok {
+		tipo = expandido
+	}
+
+	palavras := strings.Fields(limpar(logradouro))
+	if len(palavras) > 1 {
+		if inicial, ok := tiposLogradouro[palavras[0]]; ok && (tipo == "" || inicial == tipo) {
+			tipo = inicial
+			palavras = palavras[1:]
+		}
+	}
+	for i, p := range palavras {
+		if expandido, ok := titulos[p]; ok {
+			palavras[i] = expandido
+		}
+	}
+	return tipo, strings.Join(palavras, " ")
+}
+
+// normalizarNumero extrai o número do endereço (sem zeros à esquerda, com
+// a letra que o acompanha) e retorna o texto excedente, que passa para o
+// complemento. Sem número no início, o endereço é SN e todo o texto vai
+// para o complemento.
+func normalizarNumero(numero string) (string, string) {
+	bruto := strings.TrimSpace(strings.ToUpper(numero))
+	if m := quilometro.FindStringSubmatch(bruto); m != nil {
+		km := "KM " + m[1]
+		if m[2] != "" {
+			km += "." + m[2]
+		}
+		return km, limpar(bruto[len(m[0]):])
+	}
+
+	s := prefixoNumero.ReplaceAllString(limpar(milhar.ReplaceAllString(bruto, "$1$2")), "")
+	if s == "" || semNumero.MatchString(s) {
+		return SemNumero, ""
+	}
+	if m := numeroInicial.FindStringSubmatch(s); m != nil {
+		return m[1] + m[2], strings.TrimSpace(s[len(m[0]):])
+	}
+	return SemNumero, s
+}
+
+// normalizarComplemento expande as abreviações de unidade, separa letras
+// de números ("SL1201") e remove zeros à esquerda e partículas
+func normalizarComplemento(complemento string) string {
+	s := letraNumero.ReplaceAllString(limpar(complemento), "$1$3 $2$4")
+	var palavras []string
+	for _, p := range strings.Fields(s) {
+		if particulas[p] {
+			continue
+		}
+		if expandido, ok := unidades[p]; ok {
+			p = expandido
+		} else if n, err := strconv.Atoi(p); err == nil {
+			p = strconv.Itoa(n)
+		}
+		palavras = append(palavras, p)
+	}
+	return strings.Join(palavras, " ")
+}
+
+// chaveNome é o nome do logradouro usado nas chaves, sem partículas
+func chaveNome(logradouro string) string {
+	var palavras []string
+	for _, p := range strings.Fields(logradouro) {
+		if !particulas[p] {
+			palavras = append(palavras, p)
+		}
+	}
+	return strings.Join(palavras, " ")
+}
+
+// LimparCEP mantém só os dígitos do CEP, completando com zeros à esquerda
+// os CEPs de 7 dígitos que perderam o zero inicial
+func LimparCEP(cep string) string {
+	var b strings.Builder
+	for _, c := range cep {
+		if c >= '0' && c <= '9' {
+			b.WriteRune(c)
+		}
+	}
+	s := b.String()
+	if len(s) == 7 {
+		s = "0" + s
+	}
+	return s
+}
+
+// faixasCEP são as faixas de CEP (cinco primeiros dígitos) de cada UF
+var faixasCEP = map[string][][2]int{
+	"SP": {{1000, 19999}}, "RJ": {{20000, 28999}}, "ES": {{29000, 29999}},
+	"MG": {{30000, 39999}}, "BA": {{40000, 48999}}, "SE": {{49000, 49999}},
+	"PE": {{50000, 56999}}, "AL": {{57000, 57999}}, "PB": {{58000, 58999}},
+	"RN": {{59000, 59999}}, "CE": {{60000, 63999}}, "PI": {{64000, 64999}},
+	"MA": {{65000, 65999}}, "PA": {{66000, 68899}}, "AP": {{68900, 68999}},
+	"AM": {{69000, 69299}, {69400, 69899}}, "RR": {{69300, 69399}}, "AC": {{69900, 69999}},
+	"DF": {{70000, 72799}, {73000, 73699}}, "GO": {{72800, 72999}, {73700, 76799}},
+	"RO": {{76800, 76999}}, "TO": {{77000, 77999}}, "MT": {{78000, 78899}},
+	"MS": {{79000, 79999}}, "PR": {{80000, 87999}}, "SC": {{88000, 89999}},
+	"RS": {{90000, 99999}},
+}
+
+// ValidarCEP verifica se o CEP (só dígitos) tem 8 dígitos, não é um valor
+// de preenchimento (00000000, 11111111...) e pertence às faixas da UF.
+// Com UF vazia ou desconhecida, só o formato é verificado.
+func ValidarCEP(cep, uf string) bool {
+	if len(cep) != 8 || strings.Count(cep, cep[:1]) == 8 {
+		return false
+	}
+	prefixo, err := strconv.Atoi(cep[:5])
+	if err != nil || prefixo < 1000 {
+		return false
+	}
+	faixas, ok := faixasCEP[uf]
+	if !ok {
+		return true
+	}
+	for _, f := range faixas {
+		if prefixo >= f[0] && prefixo <= f[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package endereco
+
+import "testing"
+
+func TestNormalizar(t *testing.T) {
+	casos := []struct {
+		nome     string
+		entrada  Endereco
+		esperado Normalizado
+	}{
+		{
+			"abreviações e sala",
+			Endereco{TipoLogradouro: "R.", Logradouro: "Dr. Cesário Mota", Numero: "0123", Complemento: "SL1201",
+				Bairro: "Vila Buarque", CEP: "01221-020", Municipio: "7107", UF: "sp"},
+			Normalizado{TipoLogradouro: "RUA", Logradouro: "DOUTOR CESARIO MOTA", Numero: "123", Complemento: "SALA 1201",
+				Bairro: "VILA BUARQUE", CEP: "01221020", CEPValido: true, Municipio: "7107", UF: "SP",
+				Chave: "01221020|DOUTOR CESARIO MOTA|123", ChaveComplemento: "01221020|DOUTOR CESARIO MOTA|123|SALA 1201"},
+		},
+		{
+			"tipo repetido no logradouro, sem número e CEP de 7 dígitos",
+			Endereco{TipoLogradouro: "AVENIDA", Logradouro: "AV. PAULISTA", Numero: "S/N", Complemento: "Conj. 12 - 3º andar",
+				CEP: "1310100", UF: "SP"},
+			Normalizado{TipoLogradouro: "AVENIDA", Logradouro: "PAULISTA", Numero: SemNumero, Complemento: "CONJ 12 3 ANDAR",
+				CEP: "01310100", CEPValido: true, UF: "SP",
+				Chave: "01310100|PAULISTA|SN", ChaveComplemento: "01310100|PAULISTA|SN|CONJ 12 3 ANDAR"},
+		},
+		{
+			"tipo vazio, número com letra e texto excedente, CEP de outra UF",
+			Endereco{Logradouro: "TV DAS FLORES", Numero: "N° 1.250-B fundos", CEP: "20000000", Municipio: "7107", UF: "SP"},
+			Normalizado{TipoLogradouro: "TRAVESSA", Logradouro: "DAS FLORES", Numero: "1250B", Complemento: "FUNDOS",
+				CEP: "20000000", Municipio: "7107", UF: "SP",
+				Chave: "SP7107|FLORES|1250B", ChaveComplemento: "SP7107|FLORES|1250B|FUNDOS"},
+		},
+		{
+			"quilômetro de rodovia",
+			Endereco{TipoLogradouro: "ROD", Logradouro: "BR 116", Numero: "KM 12,5", CEP: "00000000", UF: "RJ"},
+			Normalizado{TipoLogradouro: "RODOVIA", Logradouro: "BR 116", Numero: "KM 12.5",
+				CEP: "00000000", UF: "RJ"},
+		},
+	}
+	for _, c := range casos {
+		if obtido := Normalizar(c.entrada); obtido != c.esperado {
+			t.Errorf("%s:\n obtido   %+v\n esperado %+v", c.nome, obtido, c.esperado)
+		}
+	}
+}
+
+func TestChavesEquivalentes(t *testing.T) {
+	// Grafias diferentes do mesmo prédio e da mesma sala
+	a := Normalizar(Endereco{TipoLogradouro: "R", Logradouro: "JOSE DE ALENCAR", Numero: "10", Complemento: "SALA 01", CEP: "80000-000", UF: "PR"})
+	b := Normalizar(Endereco{TipoLogradouro: "RUA", Logradouro: "R. José Alencar", Numero: "10", Complemento: "sl. 1", CEP: "80000000", UF: "PR"})
+	if a.Chave != b.Chave || a.ChaveComplemento != b.ChaveComplemento {
+		t.Errorf("chaves diferentes: %q/%q e %q/%q", a.Chave, a.ChaveComplemento, b.Chave, b.ChaveComplemento)
+	}
+
+	// Mesmo prédio, salas diferentes
+	c := Normalizar(Endereco{TipoLogradouro: "RUA", Logradouro: "JOSE DE ALENCAR", Numero: "10", Complemento: "SALA 2", CEP: "80000000", UF: "PR"})
+	if c.Chave != a.Chave || c.ChaveComplemento == a.ChaveComplemento {
+		t.Errorf("sala 2: chave %q/%q, esperado mesmo prédio e outra sala", c.Chave, c.ChaveComplemento)
+	}
+}
+
+func TestValidarCEP(t *testing.T) {
+	casos := []struct {
+		cep, uf string
+		valido  bool
+	}{
+		{"01310100", "SP", true},
+		{"01310100", "RJ", false},
+		{"69301000", "RR", true},
+		{"69301000", "AM", false},
+		{"73750000", "GO", true},
+		{"01310100", "", true},
+		{"01310100", "EX", true},
+		{"00000000", "", false},
+		{"11111111", "SP", false},
+		{"00999999", "", false},
+		{"1310100", "SP", false},
+	}
+	for _, c := range casos {
+		if obtido := ValidarCEP(c.cep, c.uf); obtido != c.valido {
+			t.Errorf("ValidarCEP(%s, %s) = %v, esperado %v", c.cep, c.uf, obtido, c.valido)
+		}
+	}
+}
+
+func TestTexto(t *testing.T) {
+	n := Normalizar(Endereco{TipoLogradouro: "AV", Logradouro: "PAULISTA", Numero: "", Complemento: "LJ 3", Bairro: "Bela Vista", CEP: "01310100", UF: "SP"})
+	if texto := n.Texto(); texto != "AVENIDA PAULISTA, S/N, LOJA 3 - BELA VISTA - CEP 01310-100 - SP" {
+		t.Errorf("Texto() = %q", texto)
+	}
+}
@@ -0,0 +1,410 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/graph"
+)
+
+// intervaloProgresso é o número de linhas entre duas chamadas de progresso
+const intervaloProgresso = 1000000
+
+// BulkExporter exporta a rede inteira (tabela ligacao e atributos de
+// empresas e pessoas) para arquivos de carga de bancos de grafos. As linhas
+// são lidas do banco e gravadas uma a uma, sem manter a rede em memória; a
+// deduplicação de pessoas fica a cargo do GROUP BY no banco.
+type BulkExporter struct {
+	dbReceita *sql.DB
+	dbRede    *sql.DB
+	dialeto   database.Dialect
+	pasta     string
+	gzip      bool
+	progresso func(arquivo string, linhas int64)
+}
+
+// ArquivoExportado descreve um arquivo gerado pela exportação em lote
+type ArquivoExportado struct {
+	Caminho string `json:"caminho"`
+	Linhas  int64  `json:"linhas"`
+}
+
+// NewBulkExporter cria o exportador em lote. No SQLite, dbReceita é o
+// cnpj.db e dbRede o rede.db; no PostgreSQL ambos são a mesma conexão.
+func NewBulkExporter(dbReceita, dbRede *sql.DB, dialeto database.Dialect, pasta string) *BulkExporter {
+	return &BulkExporter{
+		dbReceita: dbReceita,
+		dbRede:    dbRede,
+		dialeto:   dialeto,
+		pasta:     pasta,
+	}
+}
+
+// ComGzip grava os arquivos compactados (.gz), aceitos diretamente pelo
+// neo4j-admin
+func (b *BulkExporter) ComGzip(gzip bool) *BulkExporter {
+	b.gzip = gzip
+	return b
+}
+
+// ComProgresso registra a função chamada a cada milhão de linhas gravadas
+func (b *BulkExporter) ComProgresso(fn func(arquivo string, linhas int64)) *BulkExporter {
+	b.progresso = fn
+	return b
+}
+
+// Rótulos dos nós no Neo4j, por prefixo do ID
+var rotulosNeo4j = map[string]string{
+	"PJ_": "Empresa",
+	"PF_": "Pessoa",
+	"PE_": "Estrangeiro",
+}
+
+// ExportNeo4j gera os CSVs do neo4j-admin database import: empresas.csv,
+// pessoas.csv, estrangeiros.csv e relacionamentos.csv. Os IDs mantêm os
+// prefixos da tabela ligacao (PJ_, PF_, PE_) em um único espaço de IDs.
+func (b *BulkExporter) ExportNeo4j(ctx context.Context) ([]ArquivoExportado, error) {
+	etapas := []func(context.Context) (ArquivoExportado, error){
+		b.exportarEmpresas,
+		func(ctx context.Context) (ArquivoExportado, error) {
+			return b.exportarPessoas(ctx, "PF_", "pessoas.csv")
+		},
+		func(ctx context.Context) (ArquivoExportado, error) {
+			return b.exportarPessoas(ctx, "PE_", "estrangeiros.csv")
+		},
+		b.exportarRelacionamentos,
+	}
+
+	var arquivos []ArquivoExportado
+	for _, etapa := range etapas {
+		arquivo, err := etapa(ctx)
+		if err != nil {
+			return arquivos, err
+		}
+		arquivos = append(arquivos, arquivo)
+	}
+	return arquivos, nil
+}
+
+// ExportEdgeList gera arestas.tsv, uma lista de arestas sem cabeçalho
+// (origem, destino, tipo, qualificação separados por tabulação), lida
+// diretamente por networkx, igraph e graph-tool
+func (b *BulkExporter) ExportEdgeList(ctx context.Context) ([]ArquivoExportado, error) {
+	saida, err := b.criarArquivo("arestas.tsv")
+	if err != nil {
+		return nil, err
+	}
+	defer saida.descartar()
+
+	rows, err := b.dbRede.QueryContext(ctx, b.dialeto.Query("SELECT id1, id2, descricao FROM {ligacao}"))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler ligacao: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id1, id2, descricao sql.NullString
+		if err := rows.Scan(&id1, &id2, &descricao); err != nil {
+			return nil, err
+		}
+		campos := []string{id1.String, id2.String, graph.TipoLigacao(descricao.String), descricao.String}
+		for i, campo := range campos {
+			campos[i] = textoPlano(campo, "\t")
+		}
+		if err := saida.escreverLinha(strings.Join(campos, "\t")); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao ler ligacao: %w", err)
+	}
+
+	arquivo, err := saida.fechar()
+	if err != nil {
+		return nil, err
+	}
+	return []ArquivoExportado{arquivo}, nil
+}
+
+// exportarEmpresas grava um nó por estabelecimento com os dados cadastrais
+func (b *BulkExporter) exportarEmpresas(ctx context.Context) (ArquivoExportado, error) {
+	saida, err := b.criarArquivo("empresas.csv")
+	if err != nil {
+		return ArquivoExportado{}, err
+	}
+	defer saida.descartar()
+
+	cabecalho := []string{"id:ID", "cnpj", "razao_social", "nome_fantasia", "matriz_filial",
+		"situacao_cadastral", "data_situacao_cadastral", "data_inicio_atividades", "cnae_fiscal",
+		"uf", "municipio", "natureza_juridica", "porte_empresa", "capital_social:float", ":LABEL"}
+	if err := saida.escreverCSV(cabecalho); err != nil {
+		return ArquivoExportado{}, err
+	}
+
+	d := b.dialeto
+	query := d.Query(fmt.Sprintf(`
+		SELECT e.cnpj, emp.razao_social, e.nome_fantasia, e.matriz_filial,
+			e.situacao_cadastral, %s, %s, e.cnae_fiscal,
+			e.uf, e.municipio, emp.natureza_juridica, emp.porte_empresa, emp.capital_social
+		FROM {estabelecimento} e
+		LEFT JOIN {empresas} emp ON emp.cnpj_basico = e.cnpj_basico
+	`, d.DateText("e.data_situacao_cadastral"), d.DateText("e.data_inicio_atividades")))
+
+	rows, err := b.dbReceita.QueryContext(ctx, query)
+	if err != nil {
+		return ArquivoExportado{}, fmt.Errorf("erro ao ler estabelecimentos: %w", err)
+	}
+	defer rows.Close()
+
+	campos := make([]sql.NullString, 12)
+	destinos := make([]interface{}, 0, 13)
+	for i := range campos {
+		destinos = append(destinos, &campos[i])
+	}
+	var capital sql.NullFloat64
+	destinos = append(destinos, &capital)
+
+	registro := make([]string, len(cabecalho))
+	for rows.Next() {
+		if err := rows.Scan(destinos...); err != nil {
+			return ArquivoExportado{}, err
+		}
+		registro[0] = "PJ_" + campos[0].String
+		for i, campo := range campos {
+			registro[i+1] = textoPlano(campo.String, " ")
+		}
+		registro[13] = ""
+		if capital.Valid {
+			registro[13] = formatarNumero(capital.Float64)
+		}
+		registro[14] = rotulosNeo4j["PJ_"]
+		if err := saida.escreverCSV(registro); err != nil {
+			return ArquivoExportado{}, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return ArquivoExportado{}, fmt.Errorf("erro ao ler estabelecimentos: %w", err)
+	}
+
+	return saida.fechar()
+}
+
+// exportarPessoas grava os nós PF_ ou PE_ distintos da tabela ligacao, com
+// o número de ligações de cada um. O CPF (mascarado) e o nome vêm do ID.
+func (b *BulkExporter) exportarPessoas(ctx context.Context, prefixo, nome string) (ArquivoExportado, error) {
+	saida, err := b.criarArquivo(nome)
+	if err != nil {
+		return ArquivoExportado{}, err
+	}
+	defer saida.descartar()
+
+	cabecalho := []string{"id:ID", "nome", "ligacoes:int", ":LABEL"}
+	if prefixo == "PF_" {
+		cabecalho = []string{"id:ID", "cpf", "nome", "ligacoes:int", ":LABEL"}
+	}
+	if err := saida.escreverCSV(cabecalho); err != nil {
+		return ArquivoExportado{}, err
+	}
+
+	query := b.dialeto.Query(`
+		SELECT id, COUNT(*) FROM (
+			SELECT id1 AS id FROM {ligacao} WHERE substr(id1, 1, 3) = ?
+			UNION ALL
+			SELECT id2 AS id FROM {ligacao} WHERE substr(id2, 1, 3) = ?
+		) t
+		GROUP BY id
+	`)
+	rows, err := b.dbRede.QueryContext(ctx, query, prefixo, prefixo)
+	if err != nil {
+		return ArquivoExportado{}, fmt.Errorf("erro ao ler ligacao: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var ligacoes int64
+		if err := rows.Scan(&id, &ligacoes); err != nil {
+			return ArquivoExportado{}, err
+		}
+
+		registro := []string{textoPlano(id, " ")}
+		resto := strings.TrimPrefix(id, prefixo)
+		if prefixo == "PF_" {
+			cpf, nomePessoa, _ := strings.Cut(resto, "-")
+			registro = append(registro, cpf, textoPlano(nomePessoa, " "))
+		} else {
+			registro = append(registro, textoPlano(resto, " "))
+		}
+		registro = append(registro, strconv.FormatInt(ligacoes, 10), rotulosNeo4j[prefixo])
+
+		if err := saida.escreverCSV(registro); err != nil {
+			return ArquivoExportado{}, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return ArquivoExportado{}, fmt.Errorf("erro ao ler ligacao: %w", err)
+	}
+
+	return saida.fechar()
+}
+
+// exportarRelacionamentos grava uma relação por linha da tabela ligacao, com
+// o tipo (SOCIO, FILIAL, REPRESENTANTE) e a qualificação como propriedade
+func (b *BulkExporter) exportarRelacionamentos(ctx context.Context) (ArquivoExportado, error) {
+	saida, err := b.criarArquivo("relacionamentos.csv")
+	if err != nil {
+		return ArquivoExportado{}, err
+	}
+	defer saida.descartar()
+
+	if err := saida.escreverCSV([]string{":START_ID", ":END_ID", ":TYPE", "qualificacao"}); err != nil {
+		return ArquivoExportado{}, err
+	}
+
+	rows, err := b.dbRede.QueryContext(ctx, b.dialeto.Query("SELECT id1, id2, descricao FROM {ligacao}"))
+	if err != nil {
+		return ArquivoExportado{}, fmt.Errorf("erro ao ler ligacao: %w", err)
+	}
+	defer rows.Close()
+
+	registro := make([]string, 4)
+	for rows.Next() {
+		var id1, id2, descricao sql.NullString
+		if err := rows.Scan(&id1, &id2, &descricao); err != nil {
+			return ArquivoExportado{}, err
+		}
+		registro[0] = textoPlano(id1.String, " ")
+		registro[1] = textoPlano(id2.String, " ")
+		registro[2] = strings.ToUpper(graph.TipoLigacao(descricao.String))
+		registro[3] = textoPlano(descricao.String, " ")
+		if err := saida.escreverCSV(registro); err != nil {
+			return ArquivoExportado{}, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return ArquivoExportado{}, fmt.Errorf("erro ao ler ligacao: %w", err)
+	}
+
+	return saida.fechar()
+}
+
+// textoPlano troca quebras de linha (e o separador, quando informado) por
+// espaço, para que cada registro ocupe exatamente uma linha
+func textoPlano(s, separador string) string {
+	if !strings.ContainsAny(s, "\r\n"+separador) {
+		return s
+	}
+	s = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(s)
+	if separador != " " {
+		s = strings.ReplaceAll(s, separador, " ")
+	}
+	return s
+}
+
+// arquivoSaida grava um arquivo de exportação, opcionalmente compactado, e
+// conta as linhas de dados
+type arquivoSaida struct {
+	caminho   string
+	f         *os.File
+	gz        *gzip.Writer
+	buf       *bufio.Writer
+	csv       *csv.Writer
+	linhas    int64
+	cabecalho bool
+	progresso func(string, int64)
+	fechado   bool
+}
+
+func (b *BulkExporter) criarArquivo(nome string) (*arquivoSaida, error) {
+	if err := os.MkdirAll(b.pasta, 0755); err != nil {
+		return nil, err
+	}
+	if b.gzip {
+		nome += ".gz"
+	}
+	caminho := filepath.Join(b.pasta, nome)
+
+	f, err := os.Create(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar %s: %w", caminho, err)
+	}
+
+	saida := &arquivoSaida{caminho: caminho, f: f, progresso: b.progresso}
+	if b.gzip {
+		saida.gz = gzip.NewWriter(f)
+		saida.buf = bufio.NewWriterSize(saida.gz, 1<<20)
+	} else {
+		saida.buf = bufio.NewWriterSize(f, 1<<20)
+	}
+	saida.csv = csv.NewWriter(saida.buf)
+	return saida, nil
+}
+
+// escreverCSV grava um registro; o primeiro é o cabeçalho e não é contado
+func (s *arquivoSaida) escreverCSV(registro []string) error {
+	if err := s.csv.Write(registro); err != nil {
+		return fmt.Errorf("erro ao gravar %s: %w", s.caminho, err)
+	}
+	if !s.cabecalho {
+		s.cabecalho = true
+		return nil
+	}
+	s.contar()
+	return nil
+}
+
+// escreverLinha grava uma linha já formatada (lista de arestas)
+func (s *arquivoSaida) escreverLinha(linha string) error {
+	if _, err := s.buf.WriteString(linha + "\n"); err != nil {
+		return fmt.Errorf("erro ao gravar %s: %w", s.caminho, err)
+	}
+	s.contar()
+	return nil
+}
+
+func (s *arquivoSaida) contar() {
+	s.linhas++
+	if s.progresso != nil && s.linhas%intervaloProgresso == 0 {
+		s.progresso(s.caminho, s.linhas)
+	}
+}
+
+// fechar descarrega os buffers e fecha o arquivo
+func (s *arquivoSaida) fechar() (ArquivoExportado, error) {
+	s.fechado = true
+	s.csv.Flush()
+	err := s.csv.Error()
+	if err == nil {
+		err = s.buf.Flush()
+	}
+	if s.gz != nil {
+		if errGz := s.gz.Close(); err == nil {
+			err = errGz
+		}
+	}
+	if errF := s.f.Close(); err == nil {
+		err = errF
+	}
+	if err != nil {
+		return ArquivoExportado{}, fmt.Errorf("erro ao gravar %s: %w", s.caminho, err)
+	}
+	return ArquivoExportado{Caminho: s.caminho, Linhas: s.linhas}, nil
+}
+
+// descartar fecha e remove o arquivo incompleto quando a exportação falha
+func (s *arquivoSaida) descartar() {
+	if s.fechado {
+		return
+	}
+	s.f.Close()
+	os.Remove(s.caminho)
+}
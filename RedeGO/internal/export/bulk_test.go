@@ -0,0 +1,180 @@
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+)
+
+// basesTeste cria cnpj.db e rede.db como o importador: estabelecimentos e
+// empresas em uma base, a tabela ligacao na outra
+func basesTeste(t *testing.T) (*sql.DB, *sql.DB) {
+	t.Helper()
+	pasta := t.TempDir()
+	abrir := func(nome string, stmts ...string) *sql.DB {
+		db, err := sql.Open("sqlite3", filepath.Join(pasta, nome))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return db
+	}
+
+	receita := abrir("cnpj.db",
+		`CREATE TABLE empresas (cnpj_basico TEXT, razao_social TEXT, natureza_juridica TEXT, capital_social REAL, porte_empresa TEXT)`,
+		`CREATE TABLE estabelecimento (cnpj TEXT, cnpj_basico TEXT, matriz_filial TEXT, nome_fantasia TEXT,
+			situacao_cadastral TEXT, data_situacao_cadastral TEXT, data_inicio_atividades TEXT, cnae_fiscal TEXT,
+			uf TEXT, municipio TEXT)`,
+		`INSERT INTO empresas VALUES ('11111111', 'EMPRESA, "A"', '2062', 1500.5, '01')`,
+		`INSERT INTO estabelecimento VALUES ('11111111000191', '11111111', '1', 'FANTASIA
+A', '02', '2010-01-01', '2009-05-01', '4781400', 'SP', '7107')`,
+		`INSERT INTO estabelecimento VALUES ('11111111000272', '11111111', '2', '', '08', '2020-01-01', '2012-01-01', '4781400', 'RJ', '6001')`,
+	)
+	rede := abrir("rede.db",
+		`CREATE TABLE ligacao (id1 TEXT, id2 TEXT, descricao TEXT, comentario TEXT)`,
+		`INSERT INTO ligacao VALUES ('PF_***111111**-FULANO DE TAL', 'PJ_11111111000191', 'Sócio-Administrador', 'socios')`,
+		`INSERT INTO ligacao VALUES ('PF_***111111**-FULANO DE TAL', 'PE_JOHN DOE', 'rep-sócio-Procurador', 'socios')`,
+		`INSERT INTO ligacao VALUES ('PE_JOHN DOE', 'PJ_11111111000191', 'Sócio Pessoa Jurídica Domiciliado no Exterior', 'socios')`,
+		`INSERT INTO ligacao VALUES ('PJ_11111111000272', 'PJ_11111111000191', 'filial', 'estabelecimento')`,
+	)
+	return receita, rede
+}
+
+func lerCSV(t *testing.T, caminho string) [][]string {
+	t.Helper()
+	f, err := os.Open(caminho)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(caminho, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	registros, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV inválido em %s: %v", caminho, err)
+	}
+	return registros
+}
+
+func TestBulkExportNeo4j(t *testing.T) {
+	receita, rede := basesTeste(t)
+	pasta := t.TempDir()
+
+	arquivos, err := NewBulkExporter(receita, rede, database.Dialect{}, pasta).ComGzip(true).ExportNeo4j(context.Background())
+	if err != nil {
+		t.Fatalf("ExportNeo4j() erro: %v", err)
+	}
+
+	linhas := map[string]int64{}
+	for _, a := range arquivos {
+		linhas[filepath.Base(a.Caminho)] = a.Linhas
+	}
+	esperadas := map[string]int64{"empresas.csv.gz": 2, "pessoas.csv.gz": 1, "estrangeiros.csv.gz": 1, "relacionamentos.csv.gz": 4}
+	for nome, n := range esperadas {
+		if linhas[nome] != n {
+			t.Errorf("%s com %d linhas, esperado %d", nome, linhas[nome], n)
+		}
+	}
+
+	empresas := lerCSV(t, filepath.Join(pasta, "empresas.csv.gz"))
+	if empresas[0][0] != "id:ID" || empresas[0][len(empresas[0])-1] != ":LABEL" {
+		t.Errorf("cabeçalho de empresas = %v", empresas[0])
+	}
+	matriz := empresas[1]
+	if matriz[0] != "PJ_11111111000191" || matriz[2] != `EMPRESA, "A"` || matriz[3] != "FANTASIA A" || matriz[13] != "1500.5" || matriz[14] != "Empresa" {
+		t.Errorf("empresa = %v", matriz)
+	}
+
+	pessoas := lerCSV(t, filepath.Join(pasta, "pessoas.csv.gz"))
+	if got := strings.Join(pessoas[1], "|"); got != "PF_***111111**-FULANO DE TAL|***111111**|FULANO DE TAL|2|Pessoa" {
+		t.Errorf("pessoa = %s", got)
+	}
+	estrangeiros := lerCSV(t, filepath.Join(pasta, "estrangeiros.csv.gz"))
+	if got := strings.Join(estrangeiros[1], "|"); got != "PE_JOHN DOE|JOHN DOE|2|Estrangeiro" {
+		t.Errorf("estrangeiro = %s", got)
+	}
+
+	tipos := map[string]string{}
+	for _, r := range lerCSV(t, filepath.Join(pasta, "relacionamentos.csv.gz"))[1:] {
+		tipos[r[3]] = r[2]
+	}
+	for qualificacao, tipo := range map[string]string{"Sócio-Administrador": "SOCIO", "rep-sócio-Procurador": "REPRESENTANTE", "filial": "FILIAL"} {
+		if tipos[qualificacao] != tipo {
+			t.Errorf("tipo de %q = %q, esperado %q", qualificacao, tipos[qualificacao], tipo)
+		}
+	}
+}
+
+func TestBulkExportEdgeList(t *testing.T) {
+	receita, rede := basesTeste(t)
+	pasta := t.TempDir()
+
+	arquivos, err := NewBulkExporter(receita, rede, database.Dialect{}, pasta).ExportEdgeList(context.Background())
+	if err != nil {
+		t.Fatalf("ExportEdgeList() erro: %v", err)
+	}
+	if len(arquivos) != 1 || arquivos[0].Linhas != 4 {
+		t.Fatalf("arquivos = %+v, esperado arestas.tsv com 4 linhas", arquivos)
+	}
+
+	data, err := os.ReadFile(arquivos[0].Caminho)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primeira := strings.SplitN(string(data), "\n", 2)[0]
+	if primeira != "PF_***111111**-FULANO DE TAL\tPJ_11111111000191\tsocio\tSócio-Administrador" {
+		t.Errorf("primeira linha = %q", primeira)
+	}
+}
+
+func TestBulkExportCancelado(t *testing.T) {
+	receita, rede := basesTeste(t)
+	pasta := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewBulkExporter(receita, rede, database.Dialect{}, pasta).ExportNeo4j(ctx); err == nil {
+		t.Fatal("ExportNeo4j() sem erro com contexto cancelado")
+	}
+	if entradas, _ := os.ReadDir(pasta); len(entradas) != 0 {
+		t.Errorf("arquivos incompletos não removidos: %d", len(entradas))
+	}
+}
+
+func TestTextoPlano(t *testing.T) {
+	tests := []struct {
+		s, separador, esperado string
+	}{
+		{"RUA A\r\nSALA 1", " ", "RUA A SALA 1"},
+		{"A\tB", "\t", "A B"},
+		{"A\tB", " ", "A\tB"},
+		{"SEM QUEBRA", "\t", "SEM QUEBRA"},
+	}
+	for _, tt := range tests {
+		if got := textoPlano(tt.s, tt.separador); got != tt.esperado {
+			t.Errorf("textoPlano(%q, %q) = %q, esperado %q", tt.s, tt.separador, got, tt.esperado)
+		}
+	}
+}
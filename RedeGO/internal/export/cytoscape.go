@@ -0,0 +1,103 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// CytoscapeExporter exporta o grafo para o JSON de elementos do Cytoscape.js,
+// também aceito pelo Cytoscape desktop (.cyjs)
+type CytoscapeExporter struct{}
+
+// NewCytoscapeExporter cria um novo exportador Cytoscape.js
+func NewCytoscapeExporter() *CytoscapeExporter {
+	return &CytoscapeExporter{}
+}
+
+type cytoscapeDoc struct {
+	FormatVersion string            `json:"format_version"`
+	GeneratedBy   string            `json:"generated_by"`
+	Data          map[string]string `json:"data"`
+	Elements      cytoscapeElements `json:"elements"`
+}
+
+type cytoscapeElements struct {
+	Nodes []cytoscapeElement `json:"nodes"`
+	Edges []cytoscapeElement `json:"edges"`
+}
+
+type cytoscapeElement struct {
+	Data     map[string]interface{} `json:"data"`
+	Position *cytoscapePosition     `json:"position,omitempty"`
+}
+
+type cytoscapePosition struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ExportGraph gera o JSON com os campos de Node.Data em data, ao lado de id,
+// label, tipo, situacao e camada, que têm precedência
+func (e *CytoscapeExporter) ExportGraph(graph *models.Graph) ([]byte, error) {
+	doc := cytoscapeDoc{
+		FormatVersion: "1.0",
+		GeneratedBy:   "RedeCNPJ",
+		Data:          map[string]string{"name": "rede-cnpj"},
+		Elements: cytoscapeElements{
+			Nodes: []cytoscapeElement{},
+			Edges: []cytoscapeElement{},
+		},
+	}
+
+	vistos := make(map[string]bool, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		if vistos[node.ID] {
+			continue
+		}
+		vistos[node.ID] = true
+
+		data := make(map[string]interface{}, len(node.Data)+8)
+		for chave, valor := range node.Data {
+			data[chave] = valor
+		}
+		label := node.Label
+		if label == "" {
+			label = node.ID
+		}
+		data["id"] = node.ID
+		data["label"] = label
+		data["tipo"] = node.Type
+		data["situacao"] = situacaoNo(node)
+		data["camada"] = node.Camada
+		data["cor"] = node.Color
+		data["icone"] = node.Icon
+		data["nota"] = node.Note
+
+		el := cytoscapeElement{Data: data}
+		if node.X != 0 || node.Y != 0 {
+			el.Position = &cytoscapePosition{X: node.X, Y: node.Y}
+		}
+		doc.Elements.Nodes = append(doc.Elements.Nodes, el)
+	}
+
+	for i, edge := range arestasValidas(graph) {
+		doc.Elements.Edges = append(doc.Elements.Edges, cytoscapeElement{Data: map[string]interface{}{
+			"id":           fmt.Sprintf("e%d", i),
+			"source":       edge.From,
+			"target":       edge.To,
+			"label":        edge.Label,
+			"tipo":         edge.Type,
+			"interaction":  edge.Type,
+			"qualificacao": edge.Qualificacao,
+			"valor":        edge.Value,
+		}})
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao gerar JSON do Cytoscape: %w", err)
+	}
+	return data, nil
+}
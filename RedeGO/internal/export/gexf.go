@@ -0,0 +1,193 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// GEXFExporter exporta o grafo para GEXF 1.3, o formato nativo do Gephi
+type GEXFExporter struct{}
+
+// NewGEXFExporter cria um novo exportador GEXF
+func NewGEXFExporter() *GEXFExporter {
+	return &GEXFExporter{}
+}
+
+type gexfDoc struct {
+	XMLName  xml.Name  `xml:"gexf"`
+	Xmlns    string    `xml:"xmlns,attr"`
+	XmlnsViz string    `xml:"xmlns:viz,attr"`
+	Version  string    `xml:"version,attr"`
+	Meta     gexfMeta  `xml:"meta"`
+	Graph    gexfGraph `xml:"graph"`
+}
+
+type gexfMeta struct {
+	Creator     string `xml:"creator"`
+	Description string `xml:"description"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Mode            string           `xml:"mode,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue,omitempty"`
+	Color     *gexfColor     `xml:"viz:color,omitempty"`
+	Position  *gexfPosition  `xml:"viz:position,omitempty"`
+}
+
+type gexfEdge struct {
+	ID        string         `xml:"id,attr"`
+	Source    string         `xml:"source,attr"`
+	Target    string         `xml:"target,attr"`
+	Label     string         `xml:"label,attr,omitempty"`
+	Weight    string         `xml:"weight,attr,omitempty"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue,omitempty"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfColor struct {
+	R int `xml:"r,attr"`
+	G int `xml:"g,attr"`
+	B int `xml:"b,attr"`
+}
+
+type gexfPosition struct {
+	X string `xml:"x,attr"`
+	Y string `xml:"y,attr"`
+	Z string `xml:"z,attr"`
+}
+
+// Atributos fixos dos nós e das arestas
+var (
+	gexfAtributosNo = []gexfAttribute{
+		{ID: "tipo", Title: "tipo", Type: "string"},
+		{ID: "situacao", Title: "situacao", Type: "string"},
+		{ID: "camada", Title: "camada", Type: "integer"},
+		{ID: "nota", Title: "nota", Type: "string"},
+	}
+	gexfAtributosAresta = []gexfAttribute{
+		{ID: "tipo", Title: "tipo", Type: "string"},
+		{ID: "qualificacao", Title: "qualificacao", Type: "string"},
+	}
+)
+
+// ExportGraph gera o GEXF com tipo, situação e camada como atributos do nó,
+// os campos de Node.Data como atributos adicionais e cor e posição no
+// módulo viz
+func (e *GEXFExporter) ExportGraph(graph *models.Graph) ([]byte, error) {
+	doc := gexfDoc{
+		Xmlns:    "http://gexf.net/1.3",
+		XmlnsViz: "http://gexf.net/1.3/viz",
+		Version:  "1.3",
+		Meta:     gexfMeta{Creator: "RedeCNPJ", Description: "Rede de relacionamentos de CNPJ"},
+		Graph:    gexfGraph{DefaultEdgeType: "directed", Mode: "static"},
+	}
+
+	atributosNo := append([]gexfAttribute{}, gexfAtributosNo...)
+	fixos := make(map[string]bool, len(gexfAtributosNo))
+	for _, a := range gexfAtributosNo {
+		fixos[a.ID] = true
+	}
+	tipos := tiposCampos(graph.Nodes)
+	tiposGEXF := map[string]string{campoTexto: "string", campoNumero: "double", campoLogico: "boolean"}
+	for _, chave := range ordenarChaves(tipos) {
+		if fixos[chave] {
+			continue
+		}
+		atributosNo = append(atributosNo, gexfAttribute{ID: "d_" + chave, Title: chave, Type: tiposGEXF[tipos[chave]]})
+	}
+	doc.Graph.Attributes = []gexfAttributes{
+		{Class: "node", Attributes: atributosNo},
+		{Class: "edge", Attributes: gexfAtributosAresta},
+	}
+
+	vistos := make(map[string]bool, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		if vistos[node.ID] {
+			continue
+		}
+		vistos[node.ID] = true
+
+		n := gexfNode{ID: node.ID, Label: node.Label}
+		if n.Label == "" {
+			n.Label = node.ID
+		}
+		n.AttValues = adicionarAttValues(n.AttValues,
+			"tipo", node.Type,
+			"situacao", situacaoNo(node),
+			"camada", strconv.Itoa(node.Camada),
+			"nota", node.Note)
+		for _, chave := range ordenarChaves(node.Data) {
+			if fixos[chave] {
+				continue
+			}
+			n.AttValues = adicionarAttValues(n.AttValues, "d_"+chave, textoCampo(node.Data[chave], tipos[chave]))
+		}
+		if r, g, b, ok := corRGB(node.Color); ok {
+			n.Color = &gexfColor{R: r, G: g, B: b}
+		}
+		if node.X != 0 || node.Y != 0 {
+			n.Position = &gexfPosition{X: formatarNumero(node.X), Y: formatarNumero(node.Y), Z: "0"}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+	}
+
+	for i, edge := range arestasValidas(graph) {
+		ed := gexfEdge{ID: strconv.Itoa(i), Source: edge.From, Target: edge.To, Label: edge.Label}
+		if edge.Value != 0 {
+			ed.Weight = formatarNumero(edge.Value)
+		}
+		ed.AttValues = adicionarAttValues(ed.AttValues,
+			"tipo", edge.Type,
+			"qualificacao", edge.Qualificacao)
+		doc.Graph.Edges = append(doc.Graph.Edges, ed)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("erro ao gerar GEXF: %w", err)
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
+
+// adicionarAttValues acrescenta os pares atributo/valor não vazios
+func adicionarAttValues(valores []gexfAttValue, pares ...string) []gexfAttValue {
+	for i := 0; i+1 < len(pares); i += 2 {
+		if pares[i+1] != "" {
+			valores = append(valores, gexfAttValue{For: pares[i], Value: pares[i+1]})
+		}
+	}
+	return valores
+}
@@ -0,0 +1,144 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// Tipos dos campos de Node.Data nos formatos com atributos tipados
+const (
+	campoTexto  = "texto"
+	campoNumero = "numero"
+	campoLogico = "logico"
+)
+
+// tipoCampo classifica um valor de Node.Data
+func tipoCampo(valor interface{}) string {
+	switch valor.(type) {
+	case float64, float32, int, int64, int32:
+		return campoNumero
+	case bool:
+		return campoLogico
+	}
+	return campoTexto
+}
+
+// tiposCampos define o tipo de cada chave de Node.Data: numérico ou lógico
+// quando todos os valores do campo forem desse tipo, texto nos demais casos
+func tiposCampos(nodes []models.Node) map[string]string {
+	tipos := make(map[string]string)
+	for _, node := range nodes {
+		for chave, valor := range node.Data {
+			tipo := tipoCampo(valor)
+			if atual, ok := tipos[chave]; ok && atual != tipo {
+				tipo = campoTexto
+			}
+			tipos[chave] = tipo
+		}
+	}
+	return tipos
+}
+
+// textoCampo converte o valor para texto compatível com o tipo do campo
+func textoCampo(valor interface{}, tipo string) string {
+	switch v := valor.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		if tipo == campoLogico {
+			return strconv.FormatBool(v)
+		}
+		return map[bool]string{true: "Sim", false: "Não"}[v]
+	case float64:
+		return formatarNumero(v)
+	case float32:
+		return formatarNumero(float64(v))
+	case int, int64, int32:
+		return fmt.Sprint(v)
+	}
+	// Mapas e listas viram JSON
+	data, err := json.Marshal(valor)
+	if err != nil {
+		return fmt.Sprint(valor)
+	}
+	return string(data)
+}
+
+// situacaoNo retorna a situação cadastral do nó: o campo situacao de
+// Node.Data, se houver, ou a cor atribuída pelo serviço de rede às empresas
+// (verde = ativa)
+func situacaoNo(node models.Node) string {
+	for _, chave := range []string{"situacao", "situacao_cadastral"} {
+		if s, ok := node.Data[chave].(string); ok && s != "" {
+			return s
+		}
+	}
+	switch node.Color {
+	case "green":
+		return "ATIVA"
+	case "red":
+		return "INATIVA"
+	}
+	return ""
+}
+
+// arestasValidas descarta as arestas cujas pontas não estão no grafo, que
+// Gephi, Cytoscape e i2 recusam
+func arestasValidas(graph *models.Graph) []models.Edge {
+	ids := make(map[string]bool, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		ids[node.ID] = true
+	}
+	edges := make([]models.Edge, 0, len(graph.Edges))
+	for _, edge := range graph.Edges {
+		if ids[edge.From] && ids[edge.To] {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// corRGB converte a cor do nó (nome ou #rrggbb) para RGB
+func corRGB(cor string) (r, g, b int, ok bool) {
+	nomes := map[string]string{
+		"green":  "#008000",
+		"red":    "#ff0000",
+		"blue":   "#0000ff",
+		"yellow": "#ffff00",
+		"orange": "#ffa500",
+		"black":  "#000000",
+		"gray":   "#808080",
+	}
+	if hex, ok := nomes[strings.ToLower(cor)]; ok {
+		cor = hex
+	}
+	if len(cor) != 7 || cor[0] != '#' {
+		return 0, 0, 0, false
+	}
+	rgb, err := strconv.ParseUint(cor[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return int(rgb >> 16 & 0xff), int(rgb >> 8 & 0xff), int(rgb & 0xff), true
+}
+
+func formatarNumero(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// ordenarChaves retorna as chaves do mapa em ordem alfabética
+func ordenarChaves[V any](m map[string]V) []string {
+	chaves := make([]string, 0, len(m))
+	for chave := range m {
+		chaves = append(chaves, chave)
+	}
+	sort.Strings(chaves)
+	return chaves
+}
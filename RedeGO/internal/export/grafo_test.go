@@ -0,0 +1,140 @@
+package export
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// grafoTeste tem uma empresa ativa posicionada, um sócio na camada 1 e uma
+// aresta para um nó fora do grafo, que deve ser descartada
+func grafoTeste() *models.Graph {
+	return &models.Graph{
+		Nodes: []models.Node{
+			{ID: "PJ_11111111000191", Label: "EMPRESA A", Type: "PJ", Color: "green", X: 10.5, Y: -20,
+				Data: map[string]interface{}{"capital_social": 1000.0, "uf": "SP"}},
+			{ID: "PF_***111111**-FULANO", Label: "FULANO", Type: "PF", Camada: 1,
+				Data: map[string]interface{}{"capital_social": 0.0, "situacao": "PEP"}},
+		},
+		Edges: []models.Edge{
+			{From: "PF_***111111**-FULANO", To: "PJ_11111111000191", Type: "socio", Label: "Sócio", Qualificacao: "Sócio-Administrador", Value: 2},
+			{From: "PF_INEXISTENTE", To: "PJ_11111111000191", Type: "socio"},
+		},
+	}
+}
+
+func TestGraphMLExportGraph(t *testing.T) {
+	data, err := NewGraphMLExporter().ExportGraph(grafoTeste())
+	if err != nil {
+		t.Fatalf("ExportGraph() erro: %v", err)
+	}
+
+	var doc graphmlDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("XML inválido: %v", err)
+	}
+	if len(doc.Graph.Nodes) != 2 || len(doc.Graph.Edges) != 1 {
+		t.Fatalf("%d nós e %d arestas, esperado 2 e 1", len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+
+	chaves := map[string]graphmlKey{}
+	for _, k := range doc.Keys {
+		chaves[k.ID] = k
+	}
+	if k := chaves["d_capital_social"]; k.AttrName != "capital_social" || k.AttrType != "double" {
+		t.Errorf("chave de capital_social = %+v, esperado double", k)
+	}
+	if _, ok := chaves["d_situacao"]; ok {
+		t.Error("campo situacao de Node.Data duplicou a chave fixa")
+	}
+
+	dados := func(d []graphmlData) map[string]string {
+		m := map[string]string{}
+		for _, v := range d {
+			m[v.Key] = v.Value
+		}
+		return m
+	}
+	empresa := dados(doc.Graph.Nodes[0].Data)
+	for chave, valor := range map[string]string{"label": "EMPRESA A", "tipo": "PJ", "situacao": "ATIVA", "camada": "0", "x": "10.5", "y": "-20", "d_uf": "SP"} {
+		if empresa[chave] != valor {
+			t.Errorf("empresa[%s] = %q, esperado %q", chave, empresa[chave], valor)
+		}
+	}
+	if socio := dados(doc.Graph.Nodes[1].Data); socio["situacao"] != "PEP" || socio["camada"] != "1" {
+		t.Errorf("sócio = %v, esperado situacao PEP e camada 1", socio)
+	}
+
+	aresta := doc.Graph.Edges[0]
+	if d := dados(aresta.Data); aresta.Source != "PF_***111111**-FULANO" || d["e_qualificacao"] != "Sócio-Administrador" || d["e_valor"] != "2" {
+		t.Errorf("aresta = %+v", aresta)
+	}
+}
+
+func TestGEXFExportGraph(t *testing.T) {
+	data, err := NewGEXFExporter().ExportGraph(grafoTeste())
+	if err != nil {
+		t.Fatalf("ExportGraph() erro: %v", err)
+	}
+
+	var doc gexfDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("XML inválido: %v", err)
+	}
+	if doc.Version != "1.3" || len(doc.Graph.Nodes) != 2 || len(doc.Graph.Edges) != 1 {
+		t.Fatalf("versão %s com %d nós e %d arestas, esperado 1.3, 2 e 1", doc.Version, len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+
+	valores := map[string]string{}
+	for _, v := range doc.Graph.Nodes[0].AttValues {
+		valores[v.For] = v.Value
+	}
+	for chave, valor := range map[string]string{"tipo": "PJ", "situacao": "ATIVA", "camada": "0", "d_capital_social": "1000"} {
+		if valores[chave] != valor {
+			t.Errorf("attvalue %s = %q, esperado %q", chave, valores[chave], valor)
+		}
+	}
+	if aresta := doc.Graph.Edges[0]; aresta.Weight != "2" || aresta.Label != "Sócio" {
+		t.Errorf("aresta = %+v, esperado peso 2 e label Sócio", aresta)
+	}
+
+	for _, trecho := range []string{`<viz:color r="0" g="128" b="0">`, `<viz:position x="10.5" y="-20" z="0">`} {
+		if !strings.Contains(string(data), trecho) {
+			t.Errorf("GEXF sem %s", trecho)
+		}
+	}
+}
+
+func TestCytoscapeExportGraph(t *testing.T) {
+	data, err := NewCytoscapeExporter().ExportGraph(grafoTeste())
+	if err != nil {
+		t.Fatalf("ExportGraph() erro: %v", err)
+	}
+
+	var doc cytoscapeDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("JSON inválido: %v", err)
+	}
+	if len(doc.Elements.Nodes) != 2 || len(doc.Elements.Edges) != 1 {
+		t.Fatalf("%d nós e %d arestas, esperado 2 e 1", len(doc.Elements.Nodes), len(doc.Elements.Edges))
+	}
+
+	empresa := doc.Elements.Nodes[0]
+	if empresa.Data["id"] != "PJ_11111111000191" || empresa.Data["situacao"] != "ATIVA" || empresa.Data["uf"] != "SP" {
+		t.Errorf("empresa = %v", empresa.Data)
+	}
+	if empresa.Position == nil || empresa.Position.X != 10.5 || empresa.Position.Y != -20 {
+		t.Errorf("posição = %+v, esperado (10.5, -20)", empresa.Position)
+	}
+	if socio := doc.Elements.Nodes[1]; socio.Position != nil || socio.Data["camada"] != 1.0 {
+		t.Errorf("sócio = %+v, esperado camada 1 sem posição", socio)
+	}
+
+	aresta := doc.Elements.Edges[0].Data
+	if aresta["source"] != "PF_***111111**-FULANO" || aresta["target"] != "PJ_11111111000191" || aresta["qualificacao"] != "Sócio-Administrador" {
+		t.Errorf("aresta = %v", aresta)
+	}
+}
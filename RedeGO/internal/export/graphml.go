@@ -0,0 +1,172 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// GraphMLExporter exporta o grafo para GraphML (Gephi, Cytoscape, yEd,
+// networkx, igraph)
+type GraphMLExporter struct{}
+
+// NewGraphMLExporter cria um novo exportador GraphML
+func NewGraphMLExporter() *GraphMLExporter {
+	return &GraphMLExporter{}
+}
+
+type graphmlDoc struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   graphmlGraph `xml:"graph"`
+}
+
+type graphmlKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphmlGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Chaves fixas dos nós e das arestas (id, nome e tipo)
+var (
+	graphmlChavesNo = []graphmlKey{
+		{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+		{ID: "tipo", For: "node", AttrName: "tipo", AttrType: "string"},
+		{ID: "situacao", For: "node", AttrName: "situacao", AttrType: "string"},
+		{ID: "camada", For: "node", AttrName: "camada", AttrType: "int"},
+		{ID: "cor", For: "node", AttrName: "cor", AttrType: "string"},
+		{ID: "icone", For: "node", AttrName: "icone", AttrType: "string"},
+		{ID: "nota", For: "node", AttrName: "nota", AttrType: "string"},
+		{ID: "x", For: "node", AttrName: "x", AttrType: "double"},
+		{ID: "y", For: "node", AttrName: "y", AttrType: "double"},
+	}
+	graphmlChavesAresta = []graphmlKey{
+		{ID: "e_label", For: "edge", AttrName: "label", AttrType: "string"},
+		{ID: "e_tipo", For: "edge", AttrName: "tipo", AttrType: "string"},
+		{ID: "e_qualificacao", For: "edge", AttrName: "qualificacao", AttrType: "string"},
+		{ID: "e_valor", For: "edge", AttrName: "valor", AttrType: "double"},
+	}
+)
+
+// ExportGraph gera o GraphML com os atributos do nó (tipo, situação, camada,
+// posição) e os campos de Node.Data como chaves adicionais
+func (e *GraphMLExporter) ExportGraph(graph *models.Graph) ([]byte, error) {
+	doc := graphmlDoc{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graphmlGraph{ID: "rede-cnpj", EdgeDefault: "directed"},
+	}
+	doc.Keys = append(doc.Keys, graphmlChavesNo...)
+
+	fixas := make(map[string]bool, len(graphmlChavesNo))
+	for _, k := range graphmlChavesNo {
+		fixas[k.AttrName] = true
+	}
+	tipos := tiposCampos(graph.Nodes)
+	tiposGraphML := map[string]string{campoTexto: "string", campoNumero: "double", campoLogico: "boolean"}
+	for _, chave := range ordenarChaves(tipos) {
+		if fixas[chave] {
+			continue
+		}
+		doc.Keys = append(doc.Keys, graphmlKey{ID: "d_" + chave, For: "node", AttrName: chave, AttrType: tiposGraphML[tipos[chave]]})
+	}
+	doc.Keys = append(doc.Keys, graphmlChavesAresta...)
+
+	vistos := make(map[string]bool, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		if vistos[node.ID] {
+			continue
+		}
+		vistos[node.ID] = true
+
+		label := node.Label
+		if label == "" {
+			label = node.ID
+		}
+
+		n := graphmlNode{ID: node.ID}
+		n.Data = adicionarDados(n.Data,
+			"label", label,
+			"tipo", node.Type,
+			"situacao", situacaoNo(node),
+			"cor", node.Color,
+			"icone", node.Icon,
+			"camada", strconv.Itoa(node.Camada),
+			"nota", node.Note)
+		if node.X != 0 || node.Y != 0 {
+			n.Data = append(n.Data,
+				graphmlData{Key: "x", Value: formatarNumero(node.X)},
+				graphmlData{Key: "y", Value: formatarNumero(node.Y)})
+		}
+		for _, chave := range ordenarChaves(node.Data) {
+			if fixas[chave] {
+				continue
+			}
+			if valor := textoCampo(node.Data[chave], tipos[chave]); valor != "" {
+				n.Data = append(n.Data, graphmlData{Key: "d_" + chave, Value: valor})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+	}
+
+	for i, edge := range arestasValidas(graph) {
+		ed := graphmlEdge{ID: fmt.Sprintf("e%d", i), Source: edge.From, Target: edge.To}
+		ed.Data = adicionarDados(ed.Data,
+			"e_label", edge.Label,
+			"e_tipo", edge.Type,
+			"e_qualificacao", edge.Qualificacao)
+		if edge.Value != 0 {
+			ed.Data = append(ed.Data, graphmlData{Key: "e_valor", Value: formatarNumero(edge.Value)})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, ed)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("erro ao gerar GraphML: %w", err)
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
+
+// adicionarDados acrescenta os pares chave/valor não vazios
+func adicionarDados(dados []graphmlData, pares ...string) []graphmlData {
+	for i := 0; i+1 < len(pares); i += 2 {
+		if pares[i+1] != "" {
+			dados = append(dados, graphmlData{Key: pares[i], Value: pares[i+1]})
+		}
+	}
+	return dados
+}
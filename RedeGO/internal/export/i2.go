@@ -0,0 +1,355 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// I2Exporter exporta o grafo para o formato ANX (XML) do i2 Analyst's
+// Notebook e do i2 Chart Reader
+type I2Exporter struct{}
+
+// NewI2Exporter cria um novo exportador i2
+func NewI2Exporter() *I2Exporter {
+	return &I2Exporter{}
+}
+
+// Tipos de entidade do i2 (nomes dos ícones da biblioteca padrão)
+const (
+	i2Empresa     = "Office"
+	i2Pessoa      = "Person"
+	i2Estrangeiro = "House"
+	i2Documento   = "Document"
+)
+
+// Tipos de ligação: nome, cor (OLE, 0x00BBGGRR) e estilo da linha
+var i2TiposLigacao = map[string]struct {
+	cor   int
+	traco string
+}{
+	"socio":         {0x000000, "Solid"},
+	"filial":        {0x008000, "Solid"},
+	"representante": {0x800000, "Dashed"},
+}
+
+// raio e espaçamento do layout circular usado quando o nó não tem posição
+const (
+	i2RaioMinimo  = 200
+	i2Espacamento = 80
+)
+
+// Estrutura do XML ANX. A ordem dos elementos segue o schema do i2.
+type anxChart struct {
+	XMLName            xml.Name        `xml:"Chart"`
+	IdReferenceLinking bool            `xml:"IdReferenceLinking,attr"`
+	Strengths          []anxStrength   `xml:"StrengthCollection>Strength"`
+	AttributeClasses   []anxAttrClass  `xml:"AttributeClassCollection>AttributeClass,omitempty"`
+	EntityTypes        []anxEntityType `xml:"EntityTypeCollection>EntityType"`
+	LinkTypes          []anxLinkType   `xml:"LinkTypeCollection>LinkType"`
+	ChartItems         []anxChartItem  `xml:"ChartItemCollection>ChartItem"`
+}
+
+type anxStrength struct {
+	Id       string `xml:"Id,attr"`
+	Name     string `xml:"Name,attr"`
+	DotStyle string `xml:"DotStyle,attr"`
+}
+
+type anxAttrClass struct {
+	Name          string `xml:"Name,attr"`
+	Type          string `xml:"Type,attr"` // AttText, AttNumber, AttFlag
+	ShowValue     bool   `xml:"ShowValue,attr"`
+	ShowClassName bool   `xml:"ShowClassName,attr"`
+	Visible       bool   `xml:"Visible,attr"`
+}
+
+type anxEntityType struct {
+	Name     string `xml:"Name,attr"`
+	IconFile string `xml:"IconFile,attr"`
+}
+
+type anxLinkType struct {
+	Name   string `xml:"Name,attr"`
+	Colour int    `xml:"Colour,attr"`
+}
+
+type anxChartItem struct {
+	Label       string         `xml:"Label,attr"`
+	Description string         `xml:"Description,attr,omitempty"`
+	XPosition   *int           `xml:"XPosition,attr"`
+	End         *anxEnd        `xml:"End,omitempty"`
+	Link        *anxLink       `xml:"Link,omitempty"`
+	Attributes  []anxAttribute `xml:"AttributeCollection>Attribute,omitempty"`
+}
+
+type anxEnd struct {
+	X      int       `xml:"X,attr"`
+	Y      int       `xml:"Y,attr"`
+	Entity anxEntity `xml:"Entity"`
+}
+
+type anxEntity struct {
+	EntityId string  `xml:"EntityId,attr"`
+	Identity string  `xml:"Identity,attr"`
+	Icon     anxIcon `xml:"Icon"`
+}
+
+type anxIcon struct {
+	Style anxIconStyle `xml:"IconStyle"`
+}
+
+type anxIconStyle struct {
+	Type  string         `xml:"Type,attr"`
+	Frame *anxFrameStyle `xml:"FrameStyle,omitempty"`
+}
+
+type anxFrameStyle struct {
+	Colour  int  `xml:"Colour,attr"`
+	Visible bool `xml:"Visible,attr"`
+	Margin  int  `xml:"Margin,attr"`
+}
+
+type anxLink struct {
+	End1Id string       `xml:"End1Id,attr"`
+	End2Id string       `xml:"End2Id,attr"`
+	Style  anxLinkStyle `xml:"LinkStyle"`
+}
+
+type anxLinkStyle struct {
+	Type              string `xml:"Type,attr"`
+	StrengthReference string `xml:"StrengthReference,attr"`
+	ArrowStyle        string `xml:"ArrowStyle,attr"`
+	MlStyle           string `xml:"MlStyle,attr"`
+}
+
+type anxAttribute struct {
+	AttributeClass string `xml:"AttributeClass,attr"`
+	Value          string `xml:"Value,attr"`
+}
+
+// ExportGraph gera o arquivo .anx: um tipo de entidade por ícone, um tipo de
+// ligação por tipo de aresta (rótulo = qualificação) e atributos com o tipo,
+// a camada e os campos de Node.Data
+func (e *I2Exporter) ExportGraph(graph *models.Graph) ([]byte, error) {
+	chart := anxChart{
+		Strengths: []anxStrength{
+			{Id: "Solid", Name: "Solid", DotStyle: "DotStyleSolid"},
+			{Id: "Dashed", Name: "Dashed", DotStyle: "DotStyleDashed"},
+		},
+	}
+
+	classes := classesAtributos(graph.Nodes)
+	tipos := tiposCampos(graph.Nodes)
+	for _, nome := range ordenarChaves(classes) {
+		chart.AttributeClasses = append(chart.AttributeClasses, anxAttrClass{
+			Name:          nome,
+			Type:          classes[nome],
+			ShowValue:     true,
+			ShowClassName: true,
+			Visible:       false,
+		})
+	}
+
+	// Entidades
+	ids := make(map[string]bool, len(graph.Nodes))
+	tiposEntidade := make(map[string]bool)
+	posicoes := layoutCircular(graph.Nodes)
+	for i, node := range graph.Nodes {
+		if ids[node.ID] {
+			continue
+		}
+		ids[node.ID] = true
+
+		tipo := tipoEntidadeI2(node)
+		tiposEntidade[tipo] = true
+
+		x, y := posicoes[i][0], posicoes[i][1]
+		label := node.Label
+		if label == "" {
+			label = node.ID
+		}
+
+		estilo := anxIconStyle{Type: tipo}
+		if cor, ok := corOLE(node.Color); ok {
+			estilo.Frame = &anxFrameStyle{Colour: cor, Visible: true, Margin: 5}
+		}
+
+		chart.ChartItems = append(chart.ChartItems, anxChartItem{
+			Label:       label,
+			Description: node.Note,
+			XPosition:   &x,
+			End: &anxEnd{
+				X: x,
+				Y: y,
+				Entity: anxEntity{
+					EntityId: node.ID,
+					Identity: node.ID,
+					Icon:     anxIcon{Style: estilo},
+				},
+			},
+			Attributes: atributosNo(node, tipos),
+		})
+	}
+
+	for _, tipo := range ordenarChaves(tiposEntidade) {
+		chart.EntityTypes = append(chart.EntityTypes, anxEntityType{Name: tipo, IconFile: tipo})
+	}
+
+	// Ligações; arestas para nós fora do grafo são descartadas, pois o i2
+	// recusa o arquivo inteiro
+	tiposLigacao := make(map[string]bool)
+	for _, edge := range graph.Edges {
+		if !ids[edge.From] || !ids[edge.To] {
+			continue
+		}
+
+		tipo := edge.Type
+		if tipo == "" {
+			tipo = "socio"
+		}
+		tiposLigacao[tipo] = true
+
+		traco := "Solid"
+		if t, ok := i2TiposLigacao[tipo]; ok {
+			traco = t.traco
+		}
+
+		label := edge.Qualificacao
+		if label == "" {
+			label = edge.Label
+		}
+
+		item := anxChartItem{
+			Label: label,
+			Link: &anxLink{
+				End1Id: edge.From,
+				End2Id: edge.To,
+				Style: anxLinkStyle{
+					Type:              tipo,
+					StrengthReference: traco,
+					ArrowStyle:        "ArrowOnHead",
+					MlStyle:           "MultiplicityMultiple",
+				},
+			},
+		}
+		if edge.Value != 0 {
+			item.Attributes = []anxAttribute{{AttributeClass: "Valor", Value: formatarNumero(edge.Value)}}
+		}
+		chart.ChartItems = append(chart.ChartItems, item)
+	}
+
+	for _, tipo := range ordenarChaves(tiposLigacao) {
+		chart.LinkTypes = append(chart.LinkTypes, anxLinkType{Name: tipo, Colour: i2TiposLigacao[tipo].cor})
+	}
+	if temValor(graph.Edges) {
+		if _, ok := classes["Valor"]; !ok {
+			chart.AttributeClasses = append(chart.AttributeClasses, anxAttrClass{
+				Name: "Valor", Type: "AttNumber", ShowValue: true, ShowClassName: true,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(chart); err != nil {
+		return nil, fmt.Errorf("erro ao gerar XML i2: %w", err)
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
+
+// tipoEntidadeI2 escolhe o ícone do i2 pelo tipo do nó
+func tipoEntidadeI2(node models.Node) string {
+	switch {
+	case node.Type == "PJ" || strings.HasPrefix(node.ID, "PJ_"):
+		return i2Empresa
+	case node.Type == "PE" || strings.HasPrefix(node.ID, "PE_"):
+		return i2Estrangeiro
+	case node.Type == "PF" || strings.HasPrefix(node.ID, "PF_") || node.Icon == "pessoa":
+		return i2Pessoa
+	}
+	return i2Documento
+}
+
+// classesAtributos define uma classe de atributo por campo: fixas (Tipo,
+// Camada) e as chaves de Node.Data
+func classesAtributos(nodes []models.Node) map[string]string {
+	classes := map[string]string{"Tipo": "AttText", "Camada": "AttNumber"}
+	tiposI2 := map[string]string{campoTexto: "AttText", campoNumero: "AttNumber", campoLogico: "AttFlag"}
+	for chave, tipo := range tiposCampos(nodes) {
+		if _, fixa := classes[chave]; !fixa {
+			classes[chave] = tiposI2[tipo]
+		}
+	}
+	return classes
+}
+
+// atributosNo monta os atributos do nó na ordem das classes
+func atributosNo(node models.Node, tipos map[string]string) []anxAttribute {
+	attrs := []anxAttribute{{AttributeClass: "Tipo", Value: node.Type}}
+	if node.Camada != 0 {
+		attrs = append(attrs, anxAttribute{AttributeClass: "Camada", Value: strconv.Itoa(node.Camada)})
+	}
+	for _, chave := range ordenarChaves(node.Data) {
+		if chave == "Tipo" || chave == "Camada" {
+			continue
+		}
+		if valor := textoCampo(node.Data[chave], tipos[chave]); valor != "" {
+			attrs = append(attrs, anxAttribute{AttributeClass: chave, Value: valor})
+		}
+	}
+	return attrs
+}
+
+func temValor(edges []models.Edge) bool {
+	for _, edge := range edges {
+		if edge.Value != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// layoutCircular usa Node.X/Y e distribui em círculo os nós sem posição
+// (X e Y zerados), para que o i2 não os empilhe na origem
+func layoutCircular(nodes []models.Node) [][2]int {
+	posicoes := make([][2]int, len(nodes))
+	var semPosicao []int
+	for i, node := range nodes {
+		if node.X == 0 && node.Y == 0 {
+			semPosicao = append(semPosicao, i)
+			continue
+		}
+		posicoes[i] = [2]int{int(math.Round(node.X)), int(math.Round(node.Y))}
+	}
+
+	n := len(semPosicao)
+	if n == 1 {
+		return posicoes
+	}
+	raio := math.Max(i2RaioMinimo, float64(n)*i2Espacamento/(2*math.Pi))
+	for k, i := range semPosicao {
+		angulo := 2 * math.Pi * float64(k) / float64(n)
+		posicoes[i] = [2]int{int(math.Round(raio * math.Cos(angulo))), int(math.Round(raio * math.Sin(angulo)))}
+	}
+	return posicoes
+}
+
+// corOLE converte a cor do nó (nome ou #rrggbb) para o inteiro OLE do i2
+// (0x00BBGGRR)
+func corOLE(cor string) (int, bool) {
+	r, g, b, ok := corRGB(cor)
+	if !ok {
+		return 0, false
+	}
+	return b<<16 | g<<8 | r, true
+}
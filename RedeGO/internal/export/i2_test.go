@@ -0,0 +1,122 @@
+package export
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+func TestI2ExportGraph(t *testing.T) {
+	graph := &models.Graph{
+		Nodes: []models.Node{
+			{ID: "PJ_11111111000191", Label: "EMPRESA A", Type: "PJ", Color: "green", X: 100, Y: -50,
+				Data: map[string]interface{}{"capital_social": 1000.0, "uf": "SP", "matriz": true}},
+			{ID: "PF_***111111**-FULANO", Label: "FULANO", Type: "PF", Camada: 1,
+				Data: map[string]interface{}{"capital_social": "n/d"}},
+			{ID: "PE_JOHN DOE", Type: "PE"},
+		},
+		Edges: []models.Edge{
+			{From: "PF_***111111**-FULANO", To: "PJ_11111111000191", Type: "socio", Qualificacao: "Sócio-Administrador"},
+			{From: "PE_JOHN DOE", To: "PJ_11111111000191", Type: "representante", Label: "Procurador"},
+			{From: "PF_INEXISTENTE", To: "PJ_11111111000191", Type: "socio"},
+		},
+	}
+
+	data, err := NewI2Exporter().ExportGraph(graph)
+	if err != nil {
+		t.Fatalf("ExportGraph() erro: %v", err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Error("arquivo sem cabeçalho XML")
+	}
+
+	var chart anxChart
+	if err := xml.Unmarshal(data, &chart); err != nil {
+		t.Fatalf("XML inválido: %v", err)
+	}
+
+	var entidades, ligacoes []anxChartItem
+	for _, item := range chart.ChartItems {
+		if item.End != nil {
+			entidades = append(entidades, item)
+		} else {
+			ligacoes = append(ligacoes, item)
+		}
+	}
+	if len(entidades) != 3 || len(ligacoes) != 2 {
+		t.Fatalf("%d entidades e %d ligações, esperado 3 e 2", len(entidades), len(ligacoes))
+	}
+
+	empresa := entidades[0]
+	if empresa.End.X != 100 || empresa.End.Y != -50 {
+		t.Errorf("posição = (%d, %d), esperado (100, -50)", empresa.End.X, empresa.End.Y)
+	}
+	if estilo := empresa.End.Entity.Icon.Style; estilo.Type != "Office" || estilo.Frame == nil || estilo.Frame.Colour != 0x008000 {
+		t.Errorf("ícone da empresa = %+v, esperado Office com moldura verde", estilo)
+	}
+	if tipo := entidades[1].End.Entity.Icon.Style.Type; tipo != "Person" {
+		t.Errorf("ícone de PF = %q, esperado Person", tipo)
+	}
+	if tipo := entidades[2].End.Entity.Icon.Style.Type; tipo != "House" {
+		t.Errorf("ícone de PE = %q, esperado House", tipo)
+	}
+	if entidades[2].Label != "PE_JOHN DOE" {
+		t.Errorf("Label sem rótulo = %q, esperado o ID", entidades[2].Label)
+	}
+	// Nós sem posição não ficam empilhados na origem
+	if p1, p2 := entidades[1].End, entidades[2].End; p1.X == p2.X && p1.Y == p2.Y {
+		t.Errorf("nós sem posição sobrepostos em (%d, %d)", p1.X, p1.Y)
+	}
+
+	atributos := map[string]string{}
+	for _, a := range empresa.Attributes {
+		atributos[a.AttributeClass] = a.Value
+	}
+	for classe, valor := range map[string]string{"Tipo": "PJ", "uf": "SP", "capital_social": "1000", "matriz": "true"} {
+		if atributos[classe] != valor {
+			t.Errorf("atributo %s = %q, esperado %q", classe, atributos[classe], valor)
+		}
+	}
+
+	classes := map[string]string{}
+	for _, c := range chart.AttributeClasses {
+		classes[c.Name] = c.Type
+	}
+	// capital_social tem valor texto em um dos nós
+	if classes["capital_social"] != "AttText" || classes["Camada"] != "AttNumber" {
+		t.Errorf("classes = %v, esperado capital_social AttText e Camada AttNumber", classes)
+	}
+
+	socio, representante := ligacoes[0].Link, ligacoes[1].Link
+	if ligacoes[0].Label != "Sócio-Administrador" || socio.Style.Type != "socio" || socio.Style.StrengthReference != "Solid" {
+		t.Errorf("ligação de sócio = %q %+v", ligacoes[0].Label, socio.Style)
+	}
+	if ligacoes[1].Label != "Procurador" || representante.Style.StrengthReference != "Dashed" {
+		t.Errorf("ligação de representante = %q %+v", ligacoes[1].Label, representante.Style)
+	}
+	if len(chart.LinkTypes) != 2 || len(chart.EntityTypes) != 3 {
+		t.Errorf("%d tipos de ligação e %d de entidade, esperado 2 e 3", len(chart.LinkTypes), len(chart.EntityTypes))
+	}
+}
+
+func TestCorOLE(t *testing.T) {
+	tests := []struct {
+		cor string
+		ole int
+		ok  bool
+	}{
+		{"red", 0x0000ff, true},
+		{"#0000ff", 0xff0000, true},
+		{"#123456", 0x563412, true},
+		{"", 0, false},
+		{"#xyz", 0, false},
+	}
+	for _, tt := range tests {
+		ole, ok := corOLE(tt.cor)
+		if ole != tt.ole || ok != tt.ok {
+			t.Errorf("corOLE(%q) = %#x %v, esperado %#x %v", tt.cor, ole, ok, tt.ole, tt.ok)
+		}
+	}
+}
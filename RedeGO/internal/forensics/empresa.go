@@ -0,0 +1,564 @@
+package forensics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/cpfcnpj"
+)
+
+const (
+	// mesesRecentes delimita entradas de sócios e reativações recentes
+	mesesRecentes = 12
+
+	// minEmpresasCluster é o mínimo de empresas ativas no mesmo endereço para
+	// que ele conte como cluster de fachada (mesmo padrão de shell_companies)
+	minEmpresasCluster = 10
+
+	// limiteSociosAnalisados limita os sócios cruzados com o restante da base
+	limiteSociosAnalisados = 50
+
+	// amostraCNAE é o tamanho da amostra usada na mediana de capital do CNAE;
+	// com menos de amostraMinimaCNAE empresas a comparação não é feita
+	amostraCNAE       = 1000
+	amostraMinimaCNAE = 10
+)
+
+// CompanyProfile perfil de risco de uma empresa
+type CompanyProfile struct {
+	CNPJ                  string  `json:"cnpj"`
+	RazaoSocial           string  `json:"razao_social"`
+	NomeFantasia          string  `json:"nome_fantasia"`
+	SituacaoCadastral     string  `json:"situacao_cadastral"`
+	DataSituacaoCadastral string  `json:"data_situacao_cadastral"`
+	DataInicioAtividades  string  `json:"data_inicio_atividades"`
+	CNAEFiscal            string  `json:"cnae_fiscal"`
+	NaturezaJuridica      string  `json:"natureza_juridica"`
+	PorteEmpresa          string  `json:"porte_empresa"`
+	CapitalSocial         float64 `json:"capital_social"`
+	CapitalMedianoCNAE    float64 `json:"capital_mediano_cnae"` // 0 quando a amostra do CNAE é pequena
+	Endereco              string  `json:"endereco"`
+	EmpresasMesmoEndereco int     `json:"empresas_mesmo_endereco"` // Outras empresas ativas no endereço
+
+	OpcaoSimples        string `json:"opcao_simples"`
+	DataExclusaoSimples string `json:"data_exclusao_simples"`
+	OpcaoMEI            string `json:"opcao_mei"`
+	DataExclusaoMEI     string `json:"data_exclusao_mei"`
+	DataReativacao      string `json:"data_reativacao,omitempty"`
+
+	TotalSocios          int            `json:"total_socios"`
+	SociosEntradaRecente int            `json:"socios_entrada_recente"` // Nos últimos 12 meses
+	SociosSaidos         *int           `json:"socios_saidos"`          // nil sem socios_historico
+	SociosMenores        int            `json:"socios_menores"`         // Faixa etária até 20 anos
+	SociosIdosos         int            `json:"socios_idosos"`          // Faixa etária acima de 70 anos
+	SociosEmCluster      int            `json:"socios_em_cluster"`
+	BaixadasRelacionadas int            `json:"baixadas_relacionadas"` // Empresas baixadas dos sócios
+	Socios               []SocioEmpresa `json:"socios"`
+
+	Score     int        `json:"score_risco"` // 0-100
+	Flags     []string   `json:"flags"`
+	Pontuacao *Pontuacao `json:"pontuacao"`
+
+	metricas Metricas
+}
+
+// SocioEmpresa sócio do QSA com os cruzamentos feitos no perfil
+type SocioEmpresa struct {
+	Nome             string `json:"nome"`
+	Documento        string `json:"documento"`
+	Identificador    string `json:"identificador"` // 1 = PJ, 2 = PF, 3 = estrangeiro
+	Qualificacao     string `json:"qualificacao"`
+	DataEntrada      string `json:"data_entrada"`
+	FaixaEtaria      string `json:"faixa_etaria"`
+	EmpresasBaixadas int    `json:"empresas_baixadas"`
+	Cluster          string `json:"cluster,omitempty"` // Endereço de fachada onde o sócio tem empresa
+	EmpresasCluster  int    `json:"empresas_cluster,omitempty"`
+}
+
+// 7. PERFIL DE RISCO DE EMPRESA
+func (inv *Investigator) InvestigateCompany(ctx context.Context, cnpj string) (*CompanyProfile, error) {
+	db, err := inv.conexao()
+	if err != nil {
+		return nil, err
+	}
+	if cnpj = cpfcnpj.LimparCNPJ(cnpj); !cpfcnpj.PareceCNPJ(cnpj) {
+		return nil, fmt.Errorf("CNPJ inválido: %q", cnpj)
+	}
+	cnpjBasico := cnpj[:8]
+	agora := time.Now()
+
+	profile := &CompanyProfile{
+		CNPJ:   cnpj,
+		Flags:  []string{},
+		Socios: []SocioEmpresa{},
+	}
+	metricas := Metricas{}
+
+	// Dados cadastrais
+	query := fmt.Sprintf(`
+		SELECT
+			COALESCE(e.razao_social, ''),
+			COALESCE(est.nome_fantasia, ''),
+			COALESCE(est.situacao_cadastral, ''),
+			COALESCE(%s, ''),
+			COALESCE(%s, ''),
+			COALESCE(est.cnae_fiscal, ''),
+			COALESCE(e.natureza_juridica, ''),
+			COALESCE(e.porte_empresa, ''),
+			COALESCE(e.capital_social, 0),
+			COALESCE(est.cep, ''),
+			COALESCE(est.logradouro, ''),
+			COALESCE(est.numero, ''),
+			COALESCE(est.uf, '')
+		FROM {estabelecimento} est
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
+		WHERE est.cnpj = ?
+	`, inv.dialeto.DateText("est.data_situacao_cadastral"), inv.dialeto.DateText("est.data_inicio_atividades"))
+
+	var cep, logr, num, uf string
+	err = db.QueryRowContext(ctx, inv.dialeto.Query(query), cnpj).Scan(
+		&profile.RazaoSocial,
+		&profile.NomeFantasia,
+		&profile.SituacaoCadastral,
+		&profile.DataSituacaoCadastral,
+		&profile.DataInicioAtividades,
+		&profile.CNAEFiscal,
+		&profile.NaturezaJuridica,
+		&profile.PorteEmpresa,
+		&profile.CapitalSocial,
+		&cep, &logr, &num, &uf,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("CNPJ %s não encontrado", cnpj)
+		}
+		return nil, err
+	}
+	profile.Endereco = fmt.Sprintf("%s, %s - %s - %s", logr, num, cep, uf)
+
+	// Reutilização do endereço
+	agrupador := endereco.NewAgrupador(inv.enderecos, inv.dialeto)
+	normalizados, err := agrupador.Enderecos(ctx, []string{cnpj})
+	switch {
+	case err == nil:
+		if n := normalizados[cnpj]; n.Chave != "" {
+			ativas, err := agrupador.Ativas(ctx, endereco.GranularidadePredio, []string{n.Chave})
+			if err != nil {
+				return nil, err
+			}
+			profile.EmpresasMesmoEndereco = ativas[n.Chave]
+			if profile.SituacaoCadastral == "02" && profile.EmpresasMesmoEndereco > 0 {
+				profile.EmpresasMesmoEndereco-- // A própria empresa
+			}
+			profile.Endereco = n.Texto()
+			metricas["empresas_mesmo_endereco"] = float64(profile.EmpresasMesmoEndereco)
+		}
+	case errors.Is(err, endereco.ErrBaseAusente):
+		// Sem a base de endereços, só as grafias idênticas coincidem
+		if cep != "" && logr != "" {
+			enderecoQuery := `
+				SELECT COUNT(DISTINCT cnpj)
+				FROM {estabelecimento}
+				WHERE cep = ? AND logradouro = ? AND numero = ?
+				  AND situacao_cadastral = '02' AND cnpj != ?
+			`
+			if err := db.QueryRowContext(ctx, inv.dialeto.Query(enderecoQuery), cep, logr, num, cnpj).Scan(&profile.EmpresasMesmoEndereco); err != nil {
+				return nil, err
+			}
+			metricas["empresas_mesmo_endereco"] = float64(profile.EmpresasMesmoEndereco)
+		}
+	default:
+		return nil, err
+	}
+
+	// Capital social comparado à mediana do CNAE
+	if profile.CNAEFiscal != "" {
+		mediana, err := inv.capitalMedianoCNAE(ctx, profile.CNAEFiscal, cnpj)
+		if err != nil {
+			return nil, err
+		}
+		if mediana > 0 {
+			profile.CapitalMedianoCNAE = mediana
+			metricas["capital_relativo_cnae"] = profile.CapitalSocial / mediana * 100
+		}
+	}
+
+	// Exclusões do Simples e do MEI
+	simplesQuery := fmt.Sprintf(`
+		SELECT COALESCE(opcao_simples, ''), COALESCE(%s, ''), COALESCE(opcao_mei, ''), COALESCE(%s, '')
+		FROM {simples}
+		WHERE cnpj_basico = ?
+	`, inv.dialeto.DateText("data_exclusao_simples"), inv.dialeto.DateText("data_exclusao_mei"))
+
+	err = db.QueryRowContext(ctx, inv.dialeto.Query(simplesQuery), cnpjBasico).Scan(
+		&profile.OpcaoSimples, &profile.DataExclusaoSimples, &profile.OpcaoMEI, &profile.DataExclusaoMEI)
+	switch {
+	case err == nil:
+		metricas["exclusao_simples"] = indicador(dataValida(profile.DataExclusaoSimples))
+		metricas["exclusao_mei"] = indicador(dataValida(profile.DataExclusaoMEI))
+	case err != sql.ErrNoRows:
+		return nil, err
+	}
+
+	// Reativação: empresa ativa que o histórico da importação incremental já
+	// registrou em outra situação (suspensa, inapta, baixada)
+	if profile.SituacaoCadastral == "02" {
+		reativada, err := inv.reativada(ctx, cnpj)
+		if err != nil {
+			return nil, err
+		}
+		if situacao, errS := parseData(profile.DataSituacaoCadastral); reativada && errS == nil {
+			profile.DataReativacao = profile.DataSituacaoCadastral
+			metricas["meses_desde_reativacao"] = float64(mesesEntre(situacao, agora))
+		}
+	}
+
+	// Quadro societário
+	if err := inv.carregarSocios(ctx, profile, cnpjBasico, agora); err != nil {
+		return nil, err
+	}
+	metricas["total_socios"] = float64(profile.TotalSocios)
+	metricas["socios_entrada_recente"] = float64(profile.SociosEntradaRecente)
+	metricas["socios_menores"] = float64(profile.SociosMenores)
+	metricas["socios_idosos"] = float64(profile.SociosIdosos)
+
+	// Sócios em endereços de fachada, só com a base de endereços
+	switch err := inv.clustersSocios(ctx, db, profile, cnpjBasico); {
+	case err == nil:
+		metricas["socios_em_cluster"] = float64(profile.SociosEmCluster)
+	case !errors.Is(err, endereco.ErrBaseAusente):
+		return nil, err
+	}
+	metricas["baixadas_relacionadas"] = float64(profile.BaixadasRelacionadas)
+
+	// Saídas do QSA, só disponíveis com o histórico da importação incremental
+	saidos, ok, err := inv.sociosSaidos(ctx, cnpjBasico)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		profile.SociosSaidos = &saidos
+		metricas["socios_saidos"] = float64(saidos)
+	}
+
+	profile.metricas = metricas
+	profile.calculateRiskScore(inv.modelo)
+
+	return profile, nil
+}
+
+// carregarSocios lê o QSA e cruza os primeiros sócios com as empresas
+// baixadas em que também aparecem
+func (inv *Investigator) carregarSocios(ctx context.Context, profile *CompanyProfile, cnpjBasico string, agora time.Time) error {
+	query := fmt.Sprintf(`
+		SELECT
+			COALESCE(nome_socio, ''),
+			COALESCE(cnpj_cpf_socio, ''),
+			COALESCE(identificador_de_socio, ''),
+			COALESCE(qualificacao_socio, ''),
+			COALESCE(%s, ''),
+			COALESCE(faixa_etaria, '')
+		FROM {socios}
+		WHERE cnpj_basico = ?
+		ORDER BY data_entrada_sociedade DESC
+	`, inv.dialeto.DateText("data_entrada_sociedade"))
+
+	rows, err := inv.db.QueryContext(ctx, inv.dialeto.Query(query), cnpjBasico)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	limiteRecente := agora.AddDate(0, -mesesRecentes, 0)
+	for rows.Next() {
+		var s SocioEmpresa
+		if err := rows.Scan(&s.Nome, &s.Documento, &s.Identificador, &s.Qualificacao, &s.DataEntrada, &s.FaixaEtaria); err != nil {
+			return err
+		}
+		if entrada, err := parseData(s.DataEntrada); err == nil && entrada.After(limiteRecente) {
+			profile.SociosEntradaRecente++
+		}
+		switch s.FaixaEtaria {
+		case "1", "2": // 0 a 12 e 13 a 20 anos
+			profile.SociosMenores++
+		case "8", "9": // 71 a 80 e acima de 80 anos
+			profile.SociosIdosos++
+		}
+		profile.Socios = append(profile.Socios, s)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	profile.TotalSocios = len(profile.Socios)
+
+	// Cruzamentos por sócio, depois de liberar a conexão da consulta do QSA
+	baixadasQuery := inv.dialeto.Query(`
+		SELECT COUNT(DISTINCT est.cnpj)
+		FROM {socios} s
+		JOIN {estabelecimento} est ON s.cnpj = est.cnpj
+		WHERE s.cnpj_cpf_socio = ? AND s.nome_socio = ?
+		  AND s.cnpj_basico != ? AND est.situacao_cadastral = '08'
+	`)
+
+	for i := range profile.Socios {
+		if i >= limiteSociosAnalisados {
+			break
+		}
+		s := &profile.Socios[i]
+		if s.Documento == "" {
+			continue
+		}
+
+		if err := inv.db.QueryRowContext(ctx, baixadasQuery, s.Documento, s.Nome, cnpjBasico).Scan(&s.EmpresasBaixadas); err != nil {
+			return err
+		}
+	}
+
+	// Total de empresas baixadas ligadas pelos sócios, sem repetir as que
+	// têm mais de um sócio em comum
+	relacionadasQuery := inv.dialeto.Query(`
+		SELECT DISTINCT est.cnpj
+		FROM {socios} s1
+		JOIN {socios} s2 ON s2.cnpj_cpf_socio = s1.cnpj_cpf_socio AND s2.nome_socio = s1.nome_socio
+		JOIN {estabelecimento} est ON s2.cnpj = est.cnpj
+		WHERE s1.cnpj_basico = ? AND s1.cnpj_cpf_socio != ''
+		  AND s2.cnpj_basico != s1.cnpj_basico AND est.situacao_cadastral = '08'
+	`)
+	relRows, err := inv.db.QueryContext(ctx, relacionadasQuery, cnpjBasico)
+	if err != nil {
+		return err
+	}
+	defer relRows.Close()
+
+	baixadas := make(map[string]bool)
+	for relRows.Next() {
+		var cnpj string
+		if err := relRows.Scan(&cnpj); err != nil {
+			return err
+		}
+		baixadas[cnpj] = true
+	}
+	profile.BaixadasRelacionadas = len(baixadas)
+
+	return relRows.Err()
+}
+
+// clustersSocios marca os primeiros sócios que têm empresa em endereço de
+// fachada: prédio (chave normalizada de endereco_normalizado) com pelo menos
+// minEmpresasCluster estabelecimentos ativos. Sem a base de endereços
+// retorna endereco.ErrBaseAusente.
+func (inv *Investigator) clustersSocios(ctx context.Context, db *sql.DB, profile *CompanyProfile, cnpjBasico string) error {
+	if inv.enderecos == nil {
+		return endereco.ErrBaseAusente
+	}
+
+	empresasQuery := inv.dialeto.Query(`
+		SELECT DISTINCT cnpj FROM {socios}
+		WHERE cnpj_cpf_socio = ? AND nome_socio = ? AND cnpj_basico != ?
+		ORDER BY cnpj
+	`)
+	empresas := make(map[int][]string)
+	var cnpjs []string
+	for i := range profile.Socios {
+		if i >= limiteSociosAnalisados {
+			break
+		}
+		s := &profile.Socios[i]
+		if s.Documento == "" {
+			continue
+		}
+		rows, err := db.QueryContext(ctx, empresasQuery, s.Documento, s.Nome, cnpjBasico)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var cnpj string
+			if err := rows.Scan(&cnpj); err != nil {
+				rows.Close()
+				return err
+			}
+			empresas[i] = append(empresas[i], cnpj)
+			cnpjs = append(cnpjs, cnpj)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	if len(cnpjs) == 0 {
+		return nil
+	}
+
+	agrupador := endereco.NewAgrupador(inv.enderecos, inv.dialeto)
+	normalizados, err := agrupador.Enderecos(ctx, cnpjs)
+	if err != nil {
+		return err
+	}
+	var chaves []string
+	for _, n := range normalizados {
+		if n.Chave != "" {
+			chaves = append(chaves, n.Chave)
+		}
+	}
+	ativas, err := agrupador.Ativas(ctx, endereco.GranularidadePredio, chaves)
+	if err != nil {
+		return err
+	}
+
+	for i, lista := range empresas {
+		s := &profile.Socios[i]
+		var maior endereco.Normalizado
+		for _, cnpj := range lista {
+			n, ok := normalizados[cnpj]
+			if !ok || n.Chave == "" {
+				continue
+			}
+			if total := ativas[n.Chave]; total > s.EmpresasCluster {
+				s.EmpresasCluster, maior = total, n
+			}
+		}
+		if s.EmpresasCluster < minEmpresasCluster {
+			s.EmpresasCluster = 0
+			continue
+		}
+		maior.Complemento = ""
+		s.Cluster = maior.Texto()
+		profile.SociosEmCluster++
+	}
+	return nil
+}
+
+// sociosSaidos conta os sócios que deixaram o QSA segundo socios_historico.
+// Retorna false quando o histórico não existe (base sem importação incremental).
+func (inv *Investigator) sociosSaidos(ctx context.Context, cnpjBasico string) (int, bool, error) {
+	query := `
+		SELECT COUNT(DISTINCT h.cnpj_cpf_socio || '-' || h.nome_socio)
+		FROM {socios_historico} h
+		WHERE h.cnpj_basico = ? AND h.valido_ate IS NOT NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM {socios_historico} a
+			WHERE a.cnpj_basico = h.cnpj_basico
+			  AND a.cnpj_cpf_socio = h.cnpj_cpf_socio
+			  AND a.nome_socio = h.nome_socio
+			  AND a.valido_ate IS NULL
+		  )
+	`
+	var saidos int
+	if err := inv.db.QueryRowContext(ctx, inv.dialeto.Query(query), cnpjBasico).Scan(&saidos); err != nil {
+		if historicoAusente(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return saidos, true, nil
+}
+
+// reativada informa se estabelecimento_historico tem uma versão encerrada do
+// estabelecimento em situação diferente de ativa. Sem o histórico não há como
+// distinguir reativação de outras mudanças de situação e retorna false.
+func (inv *Investigator) reativada(ctx context.Context, cnpj string) (bool, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM {estabelecimento_historico}
+		WHERE cnpj = ? AND valido_ate IS NOT NULL AND situacao_cadastral != '02'
+	`
+	var versoes int
+	if err := inv.db.QueryRowContext(ctx, inv.dialeto.Query(query), cnpj).Scan(&versoes); err != nil {
+		if historicoAusente(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return versoes > 0, nil
+}
+
+// historicoAusente reconhece o erro de uma tabela de histórico que não existe
+func historicoAusente(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "no such table") || strings.Contains(msg, "does not exist")
+}
+
+// capitalMedianoCNAE calcula a mediana do capital social de uma amostra de
+// matrizes ativas com o mesmo CNAE principal
+func (inv *Investigator) capitalMedianoCNAE(ctx context.Context, cnae, cnpj string) (float64, error) {
+	query := `
+		SELECT COALESCE(e.capital_social, 0)
+		FROM {estabelecimento} est
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
+		WHERE est.cnae_fiscal = ? AND est.matriz_filial = '1'
+		  AND est.situacao_cadastral = '02' AND est.cnpj != ?
+		LIMIT ?
+	`
+	rows, err := inv.db.QueryContext(ctx, inv.dialeto.Query(query), cnae, cnpj, amostraCNAE)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var valores []float64
+	for rows.Next() {
+		var v float64
+		if err := rows.Scan(&v); err != nil {
+			return 0, err
+		}
+		valores = append(valores, v)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(valores) < amostraMinimaCNAE {
+		return 0, nil
+	}
+
+	sort.Float64s(valores)
+	meio := len(valores) / 2
+	if len(valores)%2 == 0 {
+		return (valores[meio-1] + valores[meio]) / 2, nil
+	}
+	return valores[meio], nil
+}
+
+// Metricas retorna os indicadores do perfil usados pelas regras do alvo
+// empresa. Indicadores sem dados (histórico, Simples, amostra do CNAE)
+// ficam de fora e as regras correspondentes não são avaliadas.
+func (p *CompanyProfile) Metricas() Metricas {
+	return p.metricas
+}
+
+// calculateRiskScore calcula score de risco com as regras do modelo
+func (p *CompanyProfile) calculateRiskScore(m *ModeloRisco) {
+	p.Pontuacao = m.Avaliar(AlvoEmpresa, p.Metricas())
+	p.Score = p.Pontuacao.Score
+	p.Flags = p.Pontuacao.Flags
+}
+
+// dataValida indica se a data foi preenchida (as bases legadas usam
+// "00000000" ou "0" para datas vazias)
+func dataValida(data string) bool {
+	d, err := parseData(strings.TrimSpace(data))
+	return err == nil && d.Year() > 1900
+}
+
+// indicador converte um booleano na métrica 0/1
+func indicador(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// mesesEntre retorna o número de meses completos de inicio até fim
+func mesesEntre(inicio, fim time.Time) int {
+	meses := (fim.Year()-inicio.Year())*12 + int(fim.Month()) - int(inicio.Month())
+	if fim.Day() < inicio.Day() {
+		meses--
+	}
+	return max(meses, 0)
+}
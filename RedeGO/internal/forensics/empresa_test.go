@@ -0,0 +1,267 @@
+package forensics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
+)
+
+// baseTeste cria uma base SQLite com as colunas usadas pelo perfil de empresa
+func baseTeste(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cnpj.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	stmts := []string{
+		`CREATE TABLE empresas (cnpj_basico TEXT, razao_social TEXT, natureza_juridica TEXT, capital_social REAL, porte_empresa TEXT)`,
+		`CREATE TABLE estabelecimento (cnpj TEXT, cnpj_basico TEXT, matriz_filial TEXT, nome_fantasia TEXT,
+			situacao_cadastral TEXT, data_situacao_cadastral TEXT, data_inicio_atividades TEXT, cnae_fiscal TEXT,
+			logradouro TEXT, numero TEXT, cep TEXT, uf TEXT)`,
+		`CREATE TABLE socios (cnpj TEXT, cnpj_basico TEXT, identificador_de_socio TEXT, nome_socio TEXT,
+			cnpj_cpf_socio TEXT, qualificacao_socio TEXT, data_entrada_sociedade TEXT, faixa_etaria TEXT)`,
+		`CREATE TABLE simples (cnpj_basico TEXT, opcao_simples TEXT, data_exclusao_simples TEXT, opcao_mei TEXT, data_exclusao_mei TEXT)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func empresaTeste(t *testing.T, db *sql.DB, cnpj, situacao, dataSituacao, cnae string, capital float64, logr, num, cep string) {
+	t.Helper()
+	if _, err := db.Exec(`INSERT INTO empresas VALUES (?, ?, '2062', ?, '01')`, cnpj[:8], "EMPRESA "+cnpj, capital); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO estabelecimento VALUES (?, ?, '1', '', ?, ?, '2010-01-01', ?, ?, ?, ?, 'SP')`,
+		cnpj, cnpj[:8], situacao, dataSituacao, cnae, logr, num, cep); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func socioTeste(t *testing.T, db *sql.DB, cnpj, nome, cpf, entrada, faixa string) {
+	t.Helper()
+	if _, err := db.Exec(`INSERT INTO socios VALUES (?, ?, '2', ?, ?, '49', ?, ?)`, cnpj, cnpj[:8], nome, cpf, entrada, faixa); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// enderecosTeste gera endereco_normalizado a partir dos estabelecimentos
+func enderecosTeste(t *testing.T, db *sql.DB) *sql.DB {
+	t.Helper()
+	enderecos, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "endereco_normalizado.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { enderecos.Close() })
+	if _, err := enderecos.Exec(`CREATE TABLE endereco_normalizado (cnpj TEXT PRIMARY KEY, cnpj_basico TEXT,
+		tipo_logradouro TEXT, logradouro TEXT, numero TEXT, complemento TEXT, bairro TEXT, cep TEXT,
+		cep_valido INTEGER, municipio TEXT, uf TEXT, chave TEXT, chave_complemento TEXT,
+		situacao_cadastral TEXT, data_inicio_atividades TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query(`SELECT cnpj, logradouro, numero, cep, uf, situacao_cadastral, data_inicio_atividades FROM estabelecimento`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cnpj, situacao, abertura string
+		var e endereco.Endereco
+		if err := rows.Scan(&cnpj, &e.Logradouro, &e.Numero, &e.CEP, &e.UF, &situacao, &abertura); err != nil {
+			t.Fatal(err)
+		}
+		n := endereco.Normalizar(e)
+		if _, err := enderecos.Exec(`INSERT INTO endereco_normalizado VALUES (?, ?, ?, ?, ?, ?, '', ?, ?, '', ?, ?, ?, ?, ?)`,
+			cnpj, cnpj[:8], n.TipoLogradouro, n.Logradouro, n.Numero, n.Complemento, n.CEP, n.CEPValido, n.UF,
+			n.Chave, n.ChaveComplemento, situacao, abertura); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return enderecos
+}
+
+func TestInvestigateCompany(t *testing.T) {
+	db := baseTeste(t)
+	agora := time.Now()
+	reativacao := agora.AddDate(0, -3, 0).Format("2006-01-02")
+	entradaRecente := agora.AddDate(0, -1, 0).Format("2006-01-02")
+
+	// Empresa investigada: capital baixo para o CNAE, reativada há 3 meses
+	alvo := "11111111000191"
+	empresaTeste(t, db, alvo, "02", reativacao, "4781400", 100, "RUA ALFA", "1", "01000000")
+	empresaTeste(t, db, "22222222000191", "02", "2010-01-01", "9999999", 5000, "R. ALFA", "01", "01000000")
+	socioTeste(t, db, alvo, "FULANO", "***111111**", entradaRecente, "2")
+	socioTeste(t, db, alvo, "BELTRANO", "***222222**", "2015-06-01", "9")
+	if _, err := db.Exec(`INSERT INTO simples VALUES ('11111111', 'N', '2023-05-01', 'N', '00000000')`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Endereço de fachada com 12 empresas do mesmo CNAE, grafado de duas
+	// formas, uma delas do FULANO
+	for i := 0; i < 12; i++ {
+		cnpj := fmt.Sprintf("3%07d000100", i)
+		logr := "RUA B"
+		if i%2 == 1 {
+			logr = "R B"
+		}
+		empresaTeste(t, db, cnpj, "02", "2010-01-01", "4781400", 100000, logr, "2", "02000000")
+		if i == 0 {
+			socioTeste(t, db, cnpj, "FULANO", "***111111**", "2012-01-01", "2")
+		}
+	}
+
+	// Empresa baixada do BELTRANO
+	empresaTeste(t, db, "44444444000191", "08", "2020-01-01", "9999999", 1000, "RUA C", "3", "03000000")
+	socioTeste(t, db, "44444444000191", "BELTRANO", "***222222**", "2011-01-01", "9")
+
+	// Sem a base de endereços só as grafias idênticas coincidem e os
+	// clusters dos sócios não são avaliados
+	p, err := NewInvestigator(db, database.Dialect{}).InvestigateCompany(context.Background(), alvo)
+	if err != nil {
+		t.Fatalf("InvestigateCompany() sem base de endereços erro: %v", err)
+	}
+	if p.EmpresasMesmoEndereco != 0 || p.SociosEmCluster != 0 || p.Socios[0].Cluster != "" {
+		t.Errorf("sem base de endereços: EmpresasMesmoEndereco = %d, SociosEmCluster = %d, Socios[0] = %+v",
+			p.EmpresasMesmoEndereco, p.SociosEmCluster, p.Socios[0])
+	}
+	for _, r := range p.Pontuacao.Regras {
+		if r.Regra == "empresa_socios_em_cluster" && r.Avaliada {
+			t.Error("empresa_socios_em_cluster avaliada sem base de endereços")
+		}
+	}
+
+	inv := NewInvestigator(db, database.Dialect{}).ComEnderecos(enderecosTeste(t, db))
+	p, err = inv.InvestigateCompany(context.Background(), alvo)
+	if err != nil {
+		t.Fatalf("InvestigateCompany() erro: %v", err)
+	}
+
+	inteiros := []struct {
+		nome           string
+		obtido, espera int
+	}{
+		{"TotalSocios", p.TotalSocios, 2},
+		{"SociosEntradaRecente", p.SociosEntradaRecente, 1},
+		{"SociosMenores", p.SociosMenores, 1},
+		{"SociosIdosos", p.SociosIdosos, 1},
+		{"SociosEmCluster", p.SociosEmCluster, 1},
+		{"BaixadasRelacionadas", p.BaixadasRelacionadas, 1},
+		{"EmpresasMesmoEndereco", p.EmpresasMesmoEndereco, 1},
+	}
+	for _, tt := range inteiros {
+		if tt.obtido != tt.espera {
+			t.Errorf("%s = %d, esperado %d", tt.nome, tt.obtido, tt.espera)
+		}
+	}
+
+	if p.CapitalMedianoCNAE != 100000 {
+		t.Errorf("CapitalMedianoCNAE = %.2f, esperado 100000", p.CapitalMedianoCNAE)
+	}
+	if p.DataReativacao != "" {
+		t.Errorf("DataReativacao = %q, esperado vazio sem estabelecimento_historico", p.DataReativacao)
+	}
+	if p.SociosSaidos != nil {
+		t.Errorf("SociosSaidos = %d, esperado nil sem socios_historico", *p.SociosSaidos)
+	}
+	if p.Socios[0].Nome != "FULANO" || p.Socios[0].EmpresasCluster != 12 || p.Socios[0].Cluster != "RUA B, 2 - CEP 02000-000 - SP" {
+		t.Errorf("Socios[0] = %+v, esperado FULANO em cluster de 12 empresas", p.Socios[0])
+	}
+	if p.Endereco != "RUA ALFA, 1 - CEP 01000-000 - SP" {
+		t.Errorf("Endereco = %q", p.Endereco)
+	}
+
+	// 15 (menores) + 10 (idosos) + 20 (cluster) + 15 (capital < 1% da
+	// mediana) + 10 (exclusão do Simples)
+	if p.Score != 70 {
+		t.Errorf("Score = %d, esperado 70 (flags %v)", p.Score, p.Flags)
+	}
+	for _, r := range p.Pontuacao.Regras {
+		if r.Regra == "empresa_saida_socios" && r.Avaliada {
+			t.Error("empresa_saida_socios avaliada sem socios_historico")
+		}
+		if r.Regra == "empresa_exclusao_mei" && r.Acionada {
+			t.Error("empresa_exclusao_mei acionada com data vazia (00000000)")
+		}
+	}
+
+	// Com o histórico da importação incremental: a versão encerrada como
+	// suspensa caracteriza a reativação e o sócio que deixou o QSA é contado
+	stmts := []string{
+		`CREATE TABLE estabelecimento_historico (cnpj TEXT, situacao_cadastral TEXT, valido_de TEXT, valido_ate TEXT)`,
+		`CREATE TABLE socios_historico (cnpj_basico TEXT, cnpj_cpf_socio TEXT, nome_socio TEXT, valido_de TEXT, valido_ate TEXT)`,
+		`INSERT INTO estabelecimento_historico VALUES ('11111111000191', '03', '2024-01', '2024-02'), ('11111111000191', '02', '2024-02', NULL)`,
+		`INSERT INTO socios_historico VALUES ('11111111', '***333333**', 'CICRANO', '2024-01', '2024-02')`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err = inv.InvestigateCompany(context.Background(), alvo)
+	if err != nil {
+		t.Fatalf("InvestigateCompany() com histórico erro: %v", err)
+	}
+	if p.DataReativacao != reativacao {
+		t.Errorf("DataReativacao = %q, esperado %q", p.DataReativacao, reativacao)
+	}
+	if p.SociosSaidos == nil || *p.SociosSaidos != 1 {
+		t.Errorf("SociosSaidos = %v, esperado 1", p.SociosSaidos)
+	}
+	// Empresa sempre ativa no histórico não é reativação
+	if _, err := db.Exec(`UPDATE estabelecimento_historico SET situacao_cadastral = '02'`); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = inv.InvestigateCompany(context.Background(), alvo); err != nil {
+		t.Fatalf("InvestigateCompany() sempre ativa erro: %v", err)
+	}
+	if p.DataReativacao != "" {
+		t.Errorf("DataReativacao = %q, esperado vazio para empresa sempre ativa", p.DataReativacao)
+	}
+}
+
+func TestInvestigateCompanyNaoEncontrada(t *testing.T) {
+	inv := NewInvestigator(baseTeste(t), database.Dialect{})
+	for _, cnpj := range []string{"99999999000199", "123"} {
+		if _, err := inv.InvestigateCompany(context.Background(), cnpj); err == nil {
+			t.Errorf("InvestigateCompany(%q) sem erro", cnpj)
+		}
+	}
+}
+
+func TestMesesEntre(t *testing.T) {
+	data := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	tests := []struct {
+		inicio, fim string
+		meses       int
+	}{
+		{"2024-01-15", "2024-04-15", 3},
+		{"2024-01-15", "2024-04-14", 2},
+		{"2023-12-31", "2024-01-01", 0},
+		{"2024-05-01", "2024-01-01", 0},
+	}
+	for _, tt := range tests {
+		if got := mesesEntre(data(tt.inicio), data(tt.fim)); got != tt.meses {
+			t.Errorf("mesesEntre(%s, %s) = %d, esperado %d", tt.inicio, tt.fim, got, tt.meses)
+		}
+	}
+}
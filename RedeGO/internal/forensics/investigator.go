@@ -1,26 +1,64 @@
 package forensics
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/identidade"
+)
+
+const (
+	// maxEmpresasCluster limita os estabelecimentos detalhados por cluster
+	maxEmpresasCluster = 50
+
+	// loteCNPJs é o tamanho das listas IN nas consultas por CNPJ
+	loteCNPJs = 500
 )
 
 // Investigator motor de investigação forense
 type Investigator struct {
-	cnpjDB string
-	redeDB string
+	db        *sql.DB
+	dialeto   database.Dialect
+	modelo    *ModeloRisco
+	enderecos *sql.DB // Base de endereços normalizados (clusters de endereço)
 }
 
-// NewInvestigator cria novo investigador
-func NewInvestigator(cnpjDB, redeDB string) *Investigator {
+// NewInvestigator cria novo investigador sobre a conexão compartilhada
+// da base da Receita (SQLite ou PostgreSQL), com o modelo de risco padrão
+func NewInvestigator(db *sql.DB, dialeto database.Dialect) *Investigator {
 	return &Investigator{
-		cnpjDB: cnpjDB,
-		redeDB: redeDB,
+		db:      db,
+		dialeto: dialeto,
+		modelo:  ModeloRiscoPadrao(),
+	}
+}
+
+// ComModelo troca o modelo de risco usado nos scores
+func (inv *Investigator) ComModelo(m *ModeloRisco) *Investigator {
+	if m != nil {
+		inv.modelo = m
+	}
+	return inv
+}
+
+// ComEnderecos define a base de endereços normalizados usada na detecção de
+// clusters de endereço (database.GetDBEndereco)
+func (inv *Investigator) ComEnderecos(db *sql.DB) *Investigator {
+	inv.enderecos = db
+	return inv
+}
+
+// conexao retorna a conexão com a base ou erro se não estiver disponível
+func (inv *Investigator) conexao() (*sql.DB, error) {
+	if inv.db == nil {
+		return nil, fmt.Errorf("banco de dados da receita não disponível")
 	}
+	return inv.db, nil
 }
 
 // SuspectProfile perfil de suspeito
@@ -41,7 +79,9 @@ type SuspectProfile struct {
 	RedeBancaria         int                      `json:"rede_bancaria"` // Empresas de outros sócios
 	Score                int                      `json:"score_risco"`   // 0-100
 	Flags                []string                 `json:"flags"`
+	Pontuacao            *Pontuacao               `json:"pontuacao"` // Composição do score por regra
 	Empresas             []map[string]interface{} `json:"empresas"`
+	Identidades          *identidade.Resolucao    `json:"identidades,omitempty"` // Sugestões de mesma pessoa
 }
 
 // CompanyCluster cluster de empresas suspeitas
@@ -55,15 +95,38 @@ type CompanyCluster struct {
 	Socios          []map[string]interface{} `json:"socios"`
 	Score           int                      `json:"score_risco"`
 	Flags           []string                 `json:"flags"`
+	Pontuacao       *Pontuacao               `json:"pontuacao"`
+	Endereco        *endereco.Cluster        `json:"endereco,omitempty"` // Ranking do cluster de endereço
+}
+
+// Metricas retorna as métricas do cluster usadas nas regras do modelo de
+// risco. Nos clusters de endereço, total_empresas conta apenas as ativas.
+func (c *CompanyCluster) Metricas() Metricas {
+	m := Metricas{
+		"total_empresas": float64(c.TotalEmpresas),
+		"total_socios":   float64(c.TotalSocios),
+	}
+	if e := c.Endereco; e != nil {
+		m["total_empresas"] = float64(e.Ativas)
+		m["percentual_baixadas"] = e.PercentualBaixadas
+		m["aberturas_recentes"] = float64(e.AberturasRecentes)
+	}
+	return m
+}
+
+// pontuar aplica ao cluster as regras do alvo
+func (c *CompanyCluster) pontuar(m *ModeloRisco, alvo string) {
+	c.Pontuacao = m.Avaliar(alvo, c.Metricas())
+	c.Score = c.Pontuacao.Score
+	c.Flags = c.Pontuacao.Flags
 }
 
 // 1. PERFIL COMPLETO DE SUSPEITO
-func (inv *Investigator) InvestigatePerson(cpf string) (*SuspectProfile, error) {
-	db, err := sql.Open("sqlite3", inv.cnpjDB)
+func (inv *Investigator) InvestigatePerson(ctx context.Context, cpf string) (*SuspectProfile, error) {
+	db, err := inv.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	profile := &SuspectProfile{
 		CPF:   cpf,
@@ -71,7 +134,7 @@ func (inv *Investigator) InvestigatePerson(cpf string) (*SuspectProfile, error)
 	}
 
 	// Query principal
-	query := `
+	query := fmt.Sprintf(`
 		SELECT 
 			s.nome_socio,
 			COUNT(DISTINCT s.cnpj) as total_empresas,
@@ -82,19 +145,19 @@ func (inv *Investigator) InvestigatePerson(cpf string) (*SuspectProfile, error)
 			COUNT(DISTINCT est.cep || est.logradouro) as enderecos,
 			COUNT(DISTINCT est.telefone1) as telefones,
 			COUNT(DISTINCT est.correio_eletronico) as emails,
-			MIN(s.data_entrada_sociedade) as primeira,
-			MAX(s.data_entrada_sociedade) as ultima
-		FROM socios s
-		JOIN estabelecimento est ON s.cnpj = est.cnpj
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
+			%s as primeira,
+			%s as ultima
+		FROM {socios} s
+		JOIN {estabelecimento} est ON s.cnpj = est.cnpj
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
 		WHERE s.cnpj_cpf_socio = ?
 		GROUP BY s.nome_socio
-	`
+	`, inv.dialeto.DateText("MIN(s.data_entrada_sociedade)"), inv.dialeto.DateText("MAX(s.data_entrada_sociedade)"))
 
 	var capitalTotal sql.NullFloat64
 	var primeira, ultima sql.NullString
 	
-	err = db.QueryRow(query, cpf).Scan(
+	err = db.QueryRowContext(ctx, inv.dialeto.Query(query), cpf).Scan(
 		&profile.Nome,
 		&profile.TotalEmpresas,
 		&profile.EmpresasAtivas,
@@ -121,40 +184,40 @@ func (inv *Investigator) InvestigatePerson(cpf string) (*SuspectProfile, error)
 		profile.UltimaEmpresa = ultima.String
 		
 		// Calcula período
-		if len(primeira.String) == 8 && len(ultima.String) == 8 {
-			p, _ := time.Parse("20060102", primeira.String)
-			u, _ := time.Parse("20060102", ultima.String)
+		p, errP := parseData(primeira.String)
+		u, errU := parseData(ultima.String)
+		if errP == nil && errU == nil {
 			anos := u.Year() - p.Year()
 			profile.PeriodoAtividade = fmt.Sprintf("%d anos", anos)
 		}
 	}
 
 	// Busca empresas detalhadas
-	empresasQuery := `
+	empresasQuery := fmt.Sprintf(`
 		SELECT 
 			s.cnpj,
-			e.razao_social,
-			est.nome_fantasia,
-			s.qualificacao_socio,
-			s.data_entrada_sociedade,
-			est.situacao_cadastral,
-			e.capital_social,
-			est.correio_eletronico,
-			est.telefone1,
-			est.cep,
-			est.logradouro,
-			est.numero,
-			est.uf
-		FROM socios s
-		JOIN estabelecimento est ON s.cnpj = est.cnpj
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
+			COALESCE(e.razao_social, ''),
+			COALESCE(est.nome_fantasia, ''),
+			COALESCE(s.qualificacao_socio, ''),
+			COALESCE(%s, ''),
+			COALESCE(est.situacao_cadastral, ''),
+			COALESCE(e.capital_social, 0),
+			COALESCE(est.correio_eletronico, ''),
+			COALESCE(est.telefone1, ''),
+			COALESCE(est.cep, ''),
+			COALESCE(est.logradouro, ''),
+			COALESCE(est.numero, ''),
+			COALESCE(est.uf, '')
+		FROM {socios} s
+		JOIN {estabelecimento} est ON s.cnpj = est.cnpj
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
 		WHERE s.cnpj_cpf_socio = ?
 		ORDER BY s.data_entrada_sociedade DESC
-	`
+	`, inv.dialeto.DateText("s.data_entrada_sociedade"))
 
-	rows, err := db.Query(empresasQuery, cpf)
+	rows, err := db.QueryContext(ctx, inv.dialeto.Query(empresasQuery), cpf)
 	if err != nil {
-		return profile, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -163,8 +226,10 @@ func (inv *Investigator) InvestigatePerson(cpf string) (*SuspectProfile, error)
 		var cnpj, razao, fantasia, qualif, data, situacao, email, tel, cep, logr, num, uf string
 		var capital float64
 
-		rows.Scan(&cnpj, &razao, &fantasia, &qualif, &data, &situacao, &capital,
-			&email, &tel, &cep, &logr, &num, &uf)
+		if err := rows.Scan(&cnpj, &razao, &fantasia, &qualif, &data, &situacao, &capital,
+			&email, &tel, &cep, &logr, &num, &uf); err != nil {
+			return nil, err
+		}
 
 		emp["cnpj"] = cnpj
 		emp["razao_social"] = razao
@@ -180,176 +245,249 @@ func (inv *Investigator) InvestigatePerson(cpf string) (*SuspectProfile, error)
 		profile.Empresas = append(profile.Empresas, emp)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	// Calcula rede bancária (empresas de outros sócios)
 	redeQuery := `
 		SELECT COUNT(DISTINCT s2.cnpj)
-		FROM socios s1
-		JOIN socios s2 ON s1.cnpj = s2.cnpj
+		FROM {socios} s1
+		JOIN {socios} s2 ON s1.cnpj = s2.cnpj
 		WHERE s1.cnpj_cpf_socio = ? AND s2.cnpj_cpf_socio != ?
 	`
-	db.QueryRow(redeQuery, cpf, cpf).Scan(&profile.RedeBancaria)
+	if err := db.QueryRowContext(ctx, inv.dialeto.Query(redeQuery), cpf, cpf).Scan(&profile.RedeBancaria); err != nil {
+		return nil, err
+	}
+
+	// Outros sócios que provavelmente são a mesma pessoa (CPF mascarado)
+	if res, err := identidade.NewResolvedor(db, inv.dialeto).Resolver(ctx, "PF_"+cpf+"-"+profile.Nome); err == nil {
+		profile.Identidades = res
+	}
 
 	// Calcula score e flags
-	profile.calculateRiskScore()
+	profile.calculateRiskScore(inv.modelo)
 
 	return profile, nil
 }
 
-// calculateRiskScore calcula score de risco
-func (p *SuspectProfile) calculateRiskScore() {
-	score := 0
-	
-	// Muitas empresas baixadas
-	if p.EmpresasBaixadas > 5 {
-		score += 20
-		p.Flags = append(p.Flags, fmt.Sprintf("ALTO: %d empresas baixadas", p.EmpresasBaixadas))
-	} else if p.EmpresasBaixadas > 2 {
-		score += 10
-		p.Flags = append(p.Flags, fmt.Sprintf("MÉDIO: %d empresas baixadas", p.EmpresasBaixadas))
+// parseData interpreta datas gravadas como AAAA-MM-DD (importador Go) ou
+// AAAAMMDD (bases legadas)
+func parseData(data string) (time.Time, error) {
+	if len(data) == 8 {
+		return time.Parse("20060102", data)
 	}
+	return time.Parse("2006-01-02", data)
+}
 
-	// Muitas empresas ativas
-	if p.EmpresasAtivas > 10 {
-		score += 15
-		p.Flags = append(p.Flags, fmt.Sprintf("ALTO: %d empresas ativas simultaneamente", p.EmpresasAtivas))
+// Metricas retorna os indicadores do perfil usados pelas regras do alvo pessoa
+func (p *SuspectProfile) Metricas() Metricas {
+	return Metricas{
+		"total_empresas":       float64(p.TotalEmpresas),
+		"empresas_ativas":      float64(p.EmpresasAtivas),
+		"empresas_baixadas":    float64(p.EmpresasBaixadas),
+		"empresas_suspensas":   float64(p.EmpresasSuspensas),
+		"capital_social_total": p.CapitalSocialTotal,
+		"enderecos_diferentes": float64(p.EnderecosDiferentes),
+		"telefones_diferentes": float64(p.TelefonesDiferentes),
+		"emails_diferentes":    float64(p.EmailsDiferentes),
+		"rede_conectada":       float64(p.RedeBancaria),
 	}
+}
 
-	// Empresas suspensas
-	if p.EmpresasSuspensas > 0 {
-		score += 15
-		p.Flags = append(p.Flags, fmt.Sprintf("CRÍTICO: %d empresas suspensas", p.EmpresasSuspensas))
-	}
+// calculateRiskScore calcula score de risco com as regras do modelo
+func (p *SuspectProfile) calculateRiskScore(m *ModeloRisco) {
+	p.Pontuacao = m.Avaliar(AlvoPessoa, p.Metricas())
+	p.Score = p.Pontuacao.Score
+	p.Flags = p.Pontuacao.Flags
+}
 
-	// Muitos endereços diferentes
-	if p.EnderecosDiferentes > 10 {
-		score += 10
-		p.Flags = append(p.Flags, fmt.Sprintf("MÉDIO: %d endereços diferentes", p.EnderecosDiferentes))
+// 2. DETECTAR EMPRESAS DE FACHADA (MESMO ENDEREÇO)
+// Os clusters vêm das chaves normalizadas de endereco_normalizado (ver
+// endereco.Agrupador), por prédio ou por sala, ordenados pelo score de
+// densidade, baixadas e aberturas recentes. Cada cluster traz até
+// maxEmpresasCluster estabelecimentos, dos mais recentes aos mais antigos.
+// Sócios e dados cadastrais são consultados em lotes para todos os clusters.
+func (inv *Investigator) DetectShellCompanies(ctx context.Context, consulta endereco.ConsultaClusters) ([]CompanyCluster, error) {
+	db, err := inv.conexao()
+	if err != nil {
+		return nil, err
 	}
-
-	// Muitos telefones diferentes
-	if p.TelefonesDiferentes > 5 {
-		score += 5
-		p.Flags = append(p.Flags, fmt.Sprintf("BAIXO: %d telefones diferentes", p.TelefonesDiferentes))
+	granularidade, err := endereco.ValidarGranularidade(consulta.Granularidade)
+	if err != nil {
+		return nil, err
 	}
 
-	// Rede bancária grande
-	if p.RedeBancaria > 50 {
-		score += 20
-		p.Flags = append(p.Flags, fmt.Sprintf("ALTO: Rede de %d empresas conectadas", p.RedeBancaria))
+	agrupador := endereco.NewAgrupador(inv.enderecos, inv.dialeto)
+	enderecos, err := agrupador.Clusters(ctx, consulta)
+	if err != nil {
+		return nil, err
 	}
 
-	// Capital social muito alto
-	if p.CapitalSocialTotal > 10000000 { // 10 milhões
-		score += 10
-		p.Flags = append(p.Flags, fmt.Sprintf("INFO: Capital social total R$ %.2f milhões", p.CapitalSocialTotal/1000000))
+	chaves := make([]string, len(enderecos))
+	for i, end := range enderecos {
+		chaves[i] = end.Chave
 	}
-
-	if score > 100 {
-		score = 100
+	empresasPorChave, err := agrupador.Empresas(ctx, granularidade, chaves)
+	if err != nil {
+		return nil, err
 	}
-
-	p.Score = score
-}
-
-// 2. DETECTAR EMPRESAS DE FACHADA (MESMO ENDEREÇO)
-func (inv *Investigator) DetectShellCompanies(minEmpresas int) ([]CompanyCluster, error) {
-	db, err := sql.Open("sqlite3", inv.cnpjDB)
+	var basicos []string
+	for _, empresas := range empresasPorChave {
+		basicos = append(basicos, empresas...)
+	}
+	socios, err := inv.sociosPorEmpresa(ctx, db, basicos)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
-	query := `
-		SELECT 
-			est.cep,
-			est.logradouro,
-			est.numero,
-			est.uf,
-			COUNT(DISTINCT est.cnpj) as total_empresas,
-			COUNT(DISTINCT s.cnpj_cpf_socio) as total_socios
-		FROM estabelecimento est
-		LEFT JOIN socios s ON est.cnpj = s.cnpj
-		WHERE est.situacao_cadastral = '02'
-		  AND est.cep IS NOT NULL
-		  AND est.logradouro IS NOT NULL
-		GROUP BY est.cep, est.logradouro, est.numero, est.uf
-		HAVING total_empresas >= ?
-		ORDER BY total_empresas DESC
-		LIMIT 100
-	`
-
-	rows, err := db.Query(query, minEmpresas)
+	detalhados := make([][]endereco.EstabelecimentoCluster, len(enderecos))
+	var cnpjs []string
+	for i, end := range enderecos {
+		if detalhados[i], err = agrupador.Estabelecimentos(ctx, granularidade, end.Chave, maxEmpresasCluster); err != nil {
+			return nil, err
+		}
+		for _, e := range detalhados[i] {
+			cnpjs = append(cnpjs, e.CNPJ)
+		}
+	}
+	dados, err := inv.dadosEstabelecimentos(ctx, db, cnpjs)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var clusters []CompanyCluster
-
-	for rows.Next() {
-		var cep, logr, num, uf string
-		var totalEmp, totalSoc int
 
-		rows.Scan(&cep, &logr, &num, &uf, &totalEmp, &totalSoc)
+	criterio := "Empresas no mesmo prédio (endereço normalizado)"
+	if granularidade == endereco.GranularidadeSala {
+		criterio = "Empresas na mesma sala (endereço e complemento normalizados)"
+	}
 
+	clusters := make([]CompanyCluster, 0, len(enderecos))
+	for i := range enderecos {
+		end := &enderecos[i]
 		cluster := CompanyCluster{
 			TipoCluster:   "MESMO_ENDERECO",
-			Criterio:      "Empresas no mesmo endereço físico",
-			ValorComum:    fmt.Sprintf("%s, %s - %s - %s", logr, num, cep, uf),
-			TotalEmpresas: totalEmp,
-			TotalSocios:   totalSoc,
+			Criterio:      criterio,
+			ValorComum:    end.Endereco,
+			TotalEmpresas: end.TotalEmpresas,
+			Endereco:      end,
 			Flags:         []string{},
 		}
 
-		// Score baseado na quantidade
-		if totalEmp > 50 {
-			cluster.Score = 90
-			cluster.Flags = append(cluster.Flags, "CRÍTICO: Mais de 50 empresas no mesmo endereço")
-		} else if totalEmp > 20 {
-			cluster.Score = 70
-			cluster.Flags = append(cluster.Flags, "ALTO: Mais de 20 empresas no mesmo endereço")
-		} else if totalEmp > 10 {
-			cluster.Score = 50
-			cluster.Flags = append(cluster.Flags, "MÉDIO: Mais de 10 empresas no mesmo endereço")
+		distintos := make(map[string]bool)
+		for _, basico := range empresasPorChave[end.Chave] {
+			for _, socio := range socios[basico] {
+				distintos[socio] = true
+			}
 		}
+		cluster.TotalSocios = len(distintos)
+		cluster.Empresas = empresasCluster(detalhados[i], dados)
 
-		// Busca empresas do cluster
-		empQuery := `
-			SELECT cnpj, e.razao_social, est.nome_fantasia, est.correio_eletronico, est.telefone1
-			FROM estabelecimento est
-			JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
-			WHERE est.cep = ? AND est.logradouro = ? AND est.numero = ?
-			  AND est.situacao_cadastral = '02'
-			LIMIT 50
-		`
+		cluster.pontuar(inv.modelo, AlvoEnderecoCompartilhado)
+		clusters = append(clusters, cluster)
+	}
 
-		empRows, _ := db.Query(empQuery, cep, logr, num)
-		for empRows.Next() {
-			var cnpj, razao, fantasia, email, tel string
-			empRows.Scan(&cnpj, &razao, &fantasia, &email, &tel)
-			cluster.Empresas = append(cluster.Empresas, map[string]interface{}{
-				"cnpj":          cnpj,
-				"razao_social":  razao,
-				"nome_fantasia": fantasia,
-				"email":         email,
-				"telefone":      tel,
-			})
+	return clusters, nil
+}
+
+// sociosPorEmpresa retorna os sócios distintos de cada CNPJ básico, em lotes
+func (inv *Investigator) sociosPorEmpresa(ctx context.Context, db *sql.DB, basicos []string) (map[string][]string, error) {
+	socios := make(map[string][]string)
+	for inicio := 0; inicio < len(basicos); inicio += loteCNPJs {
+		lote := basicos[inicio:min(inicio+loteCNPJs, len(basicos))]
+		args := make([]interface{}, len(lote))
+		for i, basico := range lote {
+			args[i] = basico
+		}
+		query := fmt.Sprintf(`
+			SELECT DISTINCT cnpj_basico, cnpj_cpf_socio || '-' || nome_socio FROM {socios} WHERE cnpj_basico IN (%s)
+		`, strings.TrimSuffix(strings.Repeat("?, ", len(lote)), ", "))
+		rows, err := db.QueryContext(ctx, inv.dialeto.Query(query), args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var basico string
+			var socio sql.NullString
+			if err := rows.Scan(&basico, &socio); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if socio.Valid {
+				socios[basico] = append(socios[basico], socio.String)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
 		}
-		empRows.Close()
+	}
+	return socios, nil
+}
 
-		clusters = append(clusters, cluster)
+// dadosEstabelecimentos retorna razão social, nome fantasia, e-mail e
+// telefone de cada CNPJ na base da Receita, em lotes
+func (inv *Investigator) dadosEstabelecimentos(ctx context.Context, db *sql.DB, cnpjs []string) (map[string][4]string, error) {
+	dados := make(map[string][4]string, len(cnpjs))
+	for inicio := 0; inicio < len(cnpjs); inicio += loteCNPJs {
+		lote := cnpjs[inicio:min(inicio+loteCNPJs, len(cnpjs))]
+		args := make([]interface{}, len(lote))
+		for i, cnpj := range lote {
+			args[i] = cnpj
+		}
+		query := fmt.Sprintf(`
+			SELECT est.cnpj, COALESCE(e.razao_social, ''), COALESCE(est.nome_fantasia, ''),
+				COALESCE(est.correio_eletronico, ''), COALESCE(est.telefone1, '')
+			FROM {estabelecimento} est
+			LEFT JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
+			WHERE est.cnpj IN (%s)
+		`, strings.TrimSuffix(strings.Repeat("?, ", len(lote)), ", "))
+		rows, err := db.QueryContext(ctx, inv.dialeto.Query(query), args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var cnpj string
+			var d [4]string
+			if err := rows.Scan(&cnpj, &d[0], &d[1], &d[2], &d[3]); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			dados[cnpj] = d
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
 	}
+	return dados, nil
+}
 
-	return clusters, nil
+// empresasCluster monta o detalhe dos estabelecimentos de um cluster com os
+// dados da base da Receita
+func empresasCluster(estabelecimentos []endereco.EstabelecimentoCluster, dados map[string][4]string) []map[string]interface{} {
+	empresas := make([]map[string]interface{}, 0, len(estabelecimentos))
+	for _, e := range estabelecimentos {
+		d := dados[e.CNPJ]
+		empresas = append(empresas, map[string]interface{}{
+			"cnpj":          e.CNPJ,
+			"razao_social":  d[0],
+			"nome_fantasia": d[1],
+			"email":         d[2],
+			"telefone":      d[3],
+			"complemento":   e.Complemento,
+			"situacao":      e.Situacao,
+			"data_abertura": e.Abertura,
+		})
+	}
+	return empresas
 }
 
 // 3. DETECTAR LARANJAS (MESMO TELEFONE/EMAIL)
-func (inv *Investigator) DetectFrontmen(criterio string, valor string) (*CompanyCluster, error) {
-	db, err := sql.Open("sqlite3", inv.cnpjDB)
+func (inv *Investigator) DetectFrontmen(ctx context.Context, criterio string, valor string) (*CompanyCluster, error) {
+	db, err := inv.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	var query string
 
@@ -357,31 +495,31 @@ func (inv *Investigator) DetectFrontmen(criterio string, valor string) (*Company
 		query = `
 			SELECT 
 				est.cnpj,
-				e.razao_social,
-				est.nome_fantasia,
-				est.correio_eletronico,
-				est.telefone1,
-				est.situacao_cadastral
-			FROM estabelecimento est
-			JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
+				COALESCE(e.razao_social, ''),
+				COALESCE(est.nome_fantasia, ''),
+				COALESCE(est.correio_eletronico, ''),
+				COALESCE(est.telefone1, ''),
+				COALESCE(est.situacao_cadastral, '')
+			FROM {estabelecimento} est
+			JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
 			WHERE est.telefone1 = ?
 		`
 	} else {
 		query = `
 			SELECT 
 				est.cnpj,
-				e.razao_social,
-				est.nome_fantasia,
-				est.correio_eletronico,
-				est.telefone1,
-				est.situacao_cadastral
-			FROM estabelecimento est
-			JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
+				COALESCE(e.razao_social, ''),
+				COALESCE(est.nome_fantasia, ''),
+				COALESCE(est.correio_eletronico, ''),
+				COALESCE(est.telefone1, ''),
+				COALESCE(est.situacao_cadastral, '')
+			FROM {estabelecimento} est
+			JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
 			WHERE est.correio_eletronico = ?
 		`
 	}
 
-	rows, err := db.Query(query, valor)
+	rows, err := db.QueryContext(ctx, inv.dialeto.Query(query), valor)
 	if err != nil {
 		return nil, err
 	}
@@ -396,7 +534,9 @@ func (inv *Investigator) DetectFrontmen(criterio string, valor string) (*Company
 
 	for rows.Next() {
 		var cnpj, razao, fantasia, email, tel, situacao string
-		rows.Scan(&cnpj, &razao, &fantasia, &email, &tel, &situacao)
+		if err := rows.Scan(&cnpj, &razao, &fantasia, &email, &tel, &situacao); err != nil {
+			return nil, err
+		}
 
 		cluster.Empresas = append(cluster.Empresas, map[string]interface{}{
 			"cnpj":          cnpj,
@@ -408,47 +548,43 @@ func (inv *Investigator) DetectFrontmen(criterio string, valor string) (*Company
 		})
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	cluster.TotalEmpresas = len(cluster.Empresas)
 
 	// Score
-	if cluster.TotalEmpresas > 10 {
-		cluster.Score = 90
-		cluster.Flags = append(cluster.Flags, fmt.Sprintf("CRÍTICO: %d empresas com mesmo %s", cluster.TotalEmpresas, criterio))
-	} else if cluster.TotalEmpresas > 5 {
-		cluster.Score = 70
-		cluster.Flags = append(cluster.Flags, fmt.Sprintf("ALTO: %d empresas com mesmo %s", cluster.TotalEmpresas, criterio))
-	} else if cluster.TotalEmpresas > 2 {
-		cluster.Score = 50
-		cluster.Flags = append(cluster.Flags, fmt.Sprintf("MÉDIO: %d empresas com mesmo %s", cluster.TotalEmpresas, criterio))
-	}
+	cluster.pontuar(inv.modelo, AlvoContatoCompartilhado)
 
 	return cluster, nil
 }
 
 // 4. ANÁLISE TEMPORAL (EMPRESAS ABERTAS EM MASSA)
-func (inv *Investigator) DetectMassRegistration(cpf string, diasJanela int) ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", inv.cnpjDB)
+func (inv *Investigator) DetectMassRegistration(ctx context.Context, cpf string, diasJanela int) ([]map[string]interface{}, error) {
+	db, err := inv.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
-	query := `
+	query := fmt.Sprintf(`
 		SELECT 
-			s.data_entrada_sociedade,
+			COALESCE(%s, ''),
 			COUNT(*) as total,
-			GROUP_CONCAT(est.cnpj, ', ') as cnpjs,
-			GROUP_CONCAT(e.razao_social, ' | ') as empresas
-		FROM socios s
-		JOIN estabelecimento est ON s.cnpj = est.cnpj
-		JOIN empresas e ON est.cnpj_basico = e.cnpj_basico
+			COALESCE(%s, '') as cnpjs,
+			COALESCE(%s, '') as empresas
+		FROM {socios} s
+		JOIN {estabelecimento} est ON s.cnpj = est.cnpj
+		JOIN {empresas} e ON est.cnpj_basico = e.cnpj_basico
 		WHERE s.cnpj_cpf_socio = ?
 		GROUP BY s.data_entrada_sociedade
-		HAVING total >= 2
+		HAVING COUNT(*) >= 2
 		ORDER BY total DESC
-	`
+	`, inv.dialeto.DateText("s.data_entrada_sociedade"),
+		inv.dialeto.GroupConcat("est.cnpj", ", "),
+		inv.dialeto.GroupConcat("e.razao_social", " | "))
 
-	rows, err := db.Query(query, cpf)
+	rows, err := db.QueryContext(ctx, inv.dialeto.Query(query), cpf)
 	if err != nil {
 		return nil, err
 	}
@@ -460,7 +596,9 @@ func (inv *Investigator) DetectMassRegistration(cpf string, diasJanela int) ([]m
 		var data, cnpjs, empresas string
 		var total int
 
-		rows.Scan(&data, &total, &cnpjs, &empresas)
+		if err := rows.Scan(&data, &total, &cnpjs, &empresas); err != nil {
+			return nil, err
+		}
 
 		results = append(results, map[string]interface{}{
 			"data":      data,
@@ -471,37 +609,40 @@ func (inv *Investigator) DetectMassRegistration(cpf string, diasJanela int) ([]m
 		})
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return results, nil
 }
 
 // 5. CADEIA DE CONTROLE (EMPRESAS DE EMPRESAS)
-func (inv *Investigator) TraceOwnershipChain(cnpj string, maxNivel int) ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", inv.cnpjDB)
+func (inv *Investigator) TraceOwnershipChain(ctx context.Context, cnpj string, maxNivel int) ([]map[string]interface{}, error) {
+	db, err := inv.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	// Busca sócios PJ
 	query := `
 		WITH RECURSIVE cadeia(cnpj, cnpj_socio, nome_socio, nivel) AS (
 			SELECT s.cnpj, s.cnpj_cpf_socio, s.nome_socio, 1
-			FROM socios s
+			FROM {socios} s
 			WHERE s.cnpj = ? AND s.identificador_de_socio = '2'
 			
 			UNION ALL
 			
 			SELECT s.cnpj, s.cnpj_cpf_socio, s.nome_socio, c.nivel + 1
-			FROM socios s
+			FROM {socios} s
 			JOIN cadeia c ON s.cnpj = c.cnpj_socio
 			WHERE s.identificador_de_socio = '2' AND c.nivel < ?
 		)
-		SELECT DISTINCT cnpj, cnpj_socio, nome_socio, nivel
+		SELECT DISTINCT cnpj, COALESCE(cnpj_socio, ''), COALESCE(nome_socio, ''), nivel
 		FROM cadeia
 		ORDER BY nivel, cnpj
 	`
 
-	rows, err := db.Query(query, cnpj, maxNivel)
+	rows, err := db.QueryContext(ctx, inv.dialeto.Query(query), cnpj, maxNivel)
 	if err != nil {
 		return nil, err
 	}
@@ -513,7 +654,9 @@ func (inv *Investigator) TraceOwnershipChain(cnpj string, maxNivel int) ([]map[s
 		var cnpjEmp, cnpjSocio, nome string
 		var nivel int
 
-		rows.Scan(&cnpjEmp, &cnpjSocio, &nome, &nivel)
+		if err := rows.Scan(&cnpjEmp, &cnpjSocio, &nome, &nivel); err != nil {
+			return nil, err
+		}
 
 		results = append(results, map[string]interface{}{
 			"cnpj_empresa":     cnpjEmp,
@@ -524,36 +667,39 @@ func (inv *Investigator) TraceOwnershipChain(cnpj string, maxNivel int) ([]map[s
 		})
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return results, nil
 }
 
 // 6. PADRÃO DE ATIVIDADE SUSPEITA
-func (inv *Investigator) DetectSuspiciousPatterns() ([]map[string]interface{}, error) {
-	db, err := sql.Open("sqlite3", inv.cnpjDB)
+func (inv *Investigator) DetectSuspiciousPatterns(ctx context.Context) ([]map[string]interface{}, error) {
+	db, err := inv.conexao()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	// Pessoas com muitas empresas baixadas rapidamente
-	query := `
+	query := fmt.Sprintf(`
 		SELECT 
-			s.cnpj_cpf_socio,
-			s.nome_socio,
+			COALESCE(s.cnpj_cpf_socio, ''),
+			COALESCE(s.nome_socio, ''),
 			COUNT(DISTINCT s.cnpj) as total_empresas,
 			COUNT(DISTINCT CASE WHEN est.situacao_cadastral = '08' THEN s.cnpj END) as baixadas,
-			MIN(est.data_situacao_cadastral) as primeira_baixa,
-			MAX(est.data_situacao_cadastral) as ultima_baixa
-		FROM socios s
-		JOIN estabelecimento est ON s.cnpj = est.cnpj
+			COALESCE(%s, '') as primeira_baixa,
+			COALESCE(%s, '') as ultima_baixa
+		FROM {socios} s
+		JOIN {estabelecimento} est ON s.cnpj = est.cnpj
 		WHERE est.situacao_cadastral = '08'
 		GROUP BY s.cnpj_cpf_socio, s.nome_socio
-		HAVING baixadas >= 5
+		HAVING COUNT(DISTINCT CASE WHEN est.situacao_cadastral = '08' THEN s.cnpj END) >= 5
 		ORDER BY baixadas DESC
 		LIMIT 100
-	`
+	`, inv.dialeto.DateText("MIN(est.data_situacao_cadastral)"), inv.dialeto.DateText("MAX(est.data_situacao_cadastral)"))
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, inv.dialeto.Query(query))
 	if err != nil {
 		return nil, err
 	}
@@ -565,17 +711,15 @@ func (inv *Investigator) DetectSuspiciousPatterns() ([]map[string]interface{}, e
 		var cpf, nome, primeira, ultima string
 		var total, baixadas int
 
-		rows.Scan(&cpf, &nome, &total, &baixadas, &primeira, &ultima)
-
-		score := 0
-		if baixadas > 10 {
-			score = 90
-		} else if baixadas > 7 {
-			score = 70
-		} else {
-			score = 50
+		if err := rows.Scan(&cpf, &nome, &total, &baixadas, &primeira, &ultima); err != nil {
+			return nil, err
 		}
 
+		pontuacao := inv.modelo.Avaliar(AlvoBaixasEmSerie, Metricas{
+			"total_empresas":    float64(total),
+			"empresas_baixadas": float64(baixadas),
+		})
+
 		results = append(results, map[string]interface{}{
 			"cpf":            cpf,
 			"nome":           nome,
@@ -583,10 +727,15 @@ func (inv *Investigator) DetectSuspiciousPatterns() ([]map[string]interface{}, e
 			"baixadas":       baixadas,
 			"primeira_baixa": primeira,
 			"ultima_baixa":   ultima,
-			"score":          score,
-			"flag":           fmt.Sprintf("ALTO RISCO: %d empresas baixadas", baixadas),
+			"score":          pontuacao.Score,
+			"flag":           strings.Join(pontuacao.Flags, "; "),
+			"pontuacao":      pontuacao,
 		})
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return results, nil
 }
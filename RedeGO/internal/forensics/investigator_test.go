@@ -0,0 +1,182 @@
+package forensics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
+)
+
+func TestDetectShellCompanies(t *testing.T) {
+	db := baseTeste(t)
+	enderecos, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "endereco_normalizado.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enderecos.Close()
+	if _, err := enderecos.Exec(`CREATE TABLE endereco_normalizado (cnpj TEXT PRIMARY KEY, cnpj_basico TEXT,
+		tipo_logradouro TEXT, logradouro TEXT, numero TEXT, complemento TEXT, bairro TEXT, cep TEXT,
+		cep_valido INTEGER, municipio TEXT, uf TEXT, chave TEXT, chave_complemento TEXT,
+		situacao_cadastral TEXT, data_inicio_atividades TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Três grafias do mesmo prédio e uma empresa em outro endereço
+	empresas := []struct {
+		cnpj, situacao, tipo, logr, num string
+	}{
+		{"11111111000191", "02", "R", "B", "2"},
+		{"22222222000191", "08", "RUA", "R. B", "02"},
+		{"33333333000191", "02", "", "RUA B", "2"},
+		{"44444444000191", "02", "RUA", "C", "3"},
+	}
+	for _, e := range empresas {
+		empresaTeste(t, db, e.cnpj, e.situacao, "2010-01-01", "4781400", 1000, e.tipo+" "+e.logr, e.num, "02000000")
+		n := endereco.Normalizar(endereco.Endereco{TipoLogradouro: e.tipo, Logradouro: e.logr, Numero: e.num, CEP: "02000000", UF: "SP"})
+		if _, err := enderecos.Exec(`INSERT INTO endereco_normalizado VALUES (?, ?, ?, ?, ?, ?, '', ?, 1, '', 'SP', ?, ?, ?, '2010-01-01')`,
+			e.cnpj, e.cnpj[:8], n.TipoLogradouro, n.Logradouro, n.Numero, n.Complemento, n.CEP, n.Chave, n.ChaveComplemento, e.situacao); err != nil {
+			t.Fatal(err)
+		}
+	}
+	socioTeste(t, db, "11111111000191", "FULANO", "***111111**", "2010-01-01", "4")
+	socioTeste(t, db, "22222222000191", "FULANO", "***111111**", "2010-01-01", "4")
+	socioTeste(t, db, "33333333000191", "BELTRANO", "***222222**", "2010-01-01", "4")
+	// Colunas de contato, ausentes na base do perfil de empresa
+	for _, coluna := range []string{"correio_eletronico", "telefone1"} {
+		if _, err := db.Exec("ALTER TABLE estabelecimento ADD COLUMN " + coluna + " TEXT"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inv := NewInvestigator(db, database.Dialect{})
+	if _, err := inv.DetectShellCompanies(context.Background(), endereco.ConsultaClusters{}); !errors.Is(err, endereco.ErrBaseAusente) {
+		t.Errorf("DetectShellCompanies() sem base de endereços = %v, esperado ErrBaseAusente", err)
+	}
+	// Base configurada mas ainda não gerada
+	ausente, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "ausente.db")+"?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ausente.Close()
+	if _, err := NewInvestigator(db, database.Dialect{}).ComEnderecos(ausente).
+		DetectShellCompanies(context.Background(), endereco.ConsultaClusters{}); !errors.Is(err, endereco.ErrBaseAusente) {
+		t.Errorf("DetectShellCompanies() com base ausente = %v, esperado ErrBaseAusente", err)
+	}
+
+	clusters, err := inv.ComEnderecos(enderecos).DetectShellCompanies(context.Background(), endereco.ConsultaClusters{MinEmpresas: 2})
+	if err != nil {
+		t.Fatalf("DetectShellCompanies() erro: %v", err)
+	}
+	if len(clusters) != 1 {
+		t.Fatalf("DetectShellCompanies() = %d clusters, esperado 1", len(clusters))
+	}
+	c := clusters[0]
+	if c.TotalEmpresas != 3 || c.TotalSocios != 2 || len(c.Empresas) != 3 || c.Endereco.Baixadas != 1 {
+		t.Errorf("cluster = %+v", c)
+	}
+	if c.ValorComum != "RUA B, 2 - CEP 02000-000 - SP" {
+		t.Errorf("ValorComum = %q", c.ValorComum)
+	}
+	if razao := c.Empresas[0]["razao_social"]; razao == "" {
+		t.Errorf("empresa sem razão social: %+v", c.Empresas[0])
+	}
+}
+
+func TestInvestigatePerson(t *testing.T) {
+	db := baseTeste(t)
+	empresaTeste(t, db, "11111111000191", "02", "2015-01-01", "4781400", 1000, "RUA A", "1", "01000000")
+	empresaTeste(t, db, "22222222000191", "08", "2018-01-01", "4781400", 1000, "RUA B", "2", "02000000")
+	socioTeste(t, db, "11111111000191", "FULANO", "***111111**", "2015-01-01", "4")
+	socioTeste(t, db, "22222222000191", "FULANO", "***111111**", "2018-01-01", "4")
+	socioTeste(t, db, "22222222000191", "BELTRANO", "***222222**", "2018-01-01", "4")
+	// Colunas de contato, ausentes na base do perfil de empresa
+	for _, coluna := range []string{"correio_eletronico", "telefone1"} {
+		if _, err := db.Exec("ALTER TABLE estabelecimento ADD COLUMN " + coluna + " TEXT"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Campos nulos não interrompem a leitura das empresas
+	if _, err := db.Exec(`UPDATE empresas SET razao_social = NULL, capital_social = NULL WHERE cnpj_basico = '22222222'`); err != nil {
+		t.Fatal(err)
+	}
+
+	inv := NewInvestigator(db, database.Dialect{})
+	p, err := inv.InvestigatePerson(context.Background(), "***111111**")
+	if err != nil {
+		t.Fatalf("InvestigatePerson() erro: %v", err)
+	}
+	if p.TotalEmpresas != 2 || len(p.Empresas) != 2 || p.RedeBancaria != 1 || p.EmpresasBaixadas != 1 {
+		t.Errorf("perfil = %+v", p)
+	}
+
+	// Erros da consulta das empresas não devolvem um perfil parcial
+	if _, err := db.Exec(`ALTER TABLE estabelecimento DROP COLUMN uf`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inv.InvestigatePerson(context.Background(), "***111111**"); err == nil {
+		t.Error("InvestigatePerson() sem a coluna uf não retornou erro")
+	}
+}
+
+func TestDetectoresCamposNulos(t *testing.T) {
+	db := baseTeste(t)
+	// Cinco empresas baixadas do mesmo sócio, abertas na mesma data, com o
+	// mesmo telefone e sem nome fantasia nem e-mail
+	for i := 1; i <= 5; i++ {
+		cnpj := fmt.Sprintf("%08d000191", i)
+		empresaTeste(t, db, cnpj, "08", "2020-01-01", "4781400", 1000, "RUA A", "1", "01000000")
+		socioTeste(t, db, cnpj, "FULANO", "***111111**", "2019-01-01", "4")
+	}
+	// Sócio PJ sem nome na cadeia de controle
+	if _, err := db.Exec(`INSERT INTO socios VALUES ('00000001000191', '00000001', '2', NULL, '00000002000191', '22', '2019-01-01', '0')`); err != nil {
+		t.Fatal(err)
+	}
+	stmts := []string{
+		`ALTER TABLE estabelecimento ADD COLUMN correio_eletronico TEXT`,
+		`ALTER TABLE estabelecimento ADD COLUMN telefone1 TEXT`,
+		`UPDATE estabelecimento SET nome_fantasia = NULL, telefone1 = '1133334444'`,
+		`UPDATE empresas SET razao_social = NULL WHERE cnpj_basico = '00000003'`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inv := NewInvestigator(db, database.Dialect{})
+	ctx := context.Background()
+
+	cluster, err := inv.DetectFrontmen(ctx, "telefone", "1133334444")
+	if err != nil || cluster.TotalEmpresas != 5 {
+		t.Fatalf("DetectFrontmen() = %+v, %v, esperado 5 empresas", cluster, err)
+	}
+	for _, e := range cluster.Empresas {
+		if e["telefone"] != "1133334444" || e["situacao"] != "08" {
+			t.Errorf("DetectFrontmen() empresa incompleta: %v", e)
+		}
+	}
+	massa, err := inv.DetectMassRegistration(ctx, "***111111**", 30)
+	if err != nil || len(massa) != 1 || massa[0]["total"] != 5 {
+		t.Errorf("DetectMassRegistration() = %v, %v, esperado 5 empresas na mesma data", massa, err)
+	}
+	cadeia, err := inv.TraceOwnershipChain(ctx, "00000001000191", 3)
+	if err != nil {
+		t.Fatalf("TraceOwnershipChain() erro: %v", err)
+	}
+	semNome := false
+	for _, elo := range cadeia {
+		semNome = semNome || (elo["cnpj_socio"] == "00000002000191" && elo["nome_socio"] == "" && elo["nivel"] == 1)
+	}
+	if !semNome {
+		t.Errorf("TraceOwnershipChain() = %v, esperado o sócio 00000002000191 sem nome", cadeia)
+	}
+	padroes, err := inv.DetectSuspiciousPatterns(ctx)
+	if err != nil || len(padroes) != 1 || padroes[0]["baixadas"] != 5 {
+		t.Errorf("DetectSuspiciousPatterns() = %v, %v, esperado FULANO com 5 baixadas", padroes, err)
+	}
+}
@@ -0,0 +1,288 @@
+package forensics
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Alvos dos conjuntos de regras do modelo de risco
+const (
+	AlvoPessoa                = "pessoa"
+	AlvoEmpresa               = "empresa"
+	AlvoEnderecoCompartilhado = "endereco_compartilhado"
+	AlvoContatoCompartilhado  = "contato_compartilhado"
+	AlvoBaixasEmSerie         = "baixas_em_serie"
+)
+
+// modeloPadraoYAML reproduz os limites usados antes do motor de regras
+//
+//go:embed risco.yaml
+var modeloPadraoYAML []byte
+
+// nomeModelo restringe os modelos por caso a nomes de arquivo simples
+var nomeModelo = regexp.MustCompile(`^[\w.-]+$`)
+
+// ModeloRisco reúne as regras, pesos e limites usados para pontuar pessoas,
+// empresas e agrupamentos
+type ModeloRisco struct {
+	Nome        string       `yaml:"nome" json:"nome"`
+	Versao      string       `yaml:"versao" json:"versao"`
+	ScoreMaximo int          `yaml:"score_maximo" json:"score_maximo"`
+	Regras      []RegraRisco `yaml:"regras" json:"regras"`
+
+	// Origem é o arquivo de onde o modelo foi lido ("embutido" para o padrão)
+	Origem string `yaml:"-" json:"origem"`
+}
+
+// RegraRisco compara uma métrica do alvo com faixas de limite. As faixas são
+// avaliadas na ordem declarada e vale a primeira atendida, por isso a mais
+// rigorosa deve vir primeiro.
+type RegraRisco struct {
+	ID        string       `yaml:"id" json:"id"`
+	Alvo      string       `yaml:"alvo" json:"alvo"`
+	Metrica   string       `yaml:"metrica" json:"metrica"`
+	Descricao string       `yaml:"descricao" json:"descricao"`
+	Mensagem  string       `yaml:"mensagem" json:"mensagem"` // aceita {valor} e {limite}
+	Operador  string       `yaml:"operador,omitempty" json:"operador"`
+	Peso      *float64     `yaml:"peso,omitempty" json:"peso"`
+	Faixas    []FaixaRisco `yaml:"faixas" json:"faixas"`
+}
+
+// FaixaRisco pontua a regra quando a métrica atende ao limite
+type FaixaRisco struct {
+	Limite float64 `yaml:"limite" json:"limite"`
+	Pontos int     `yaml:"pontos" json:"pontos"`
+	Nivel  string  `yaml:"nivel" json:"nivel"`
+}
+
+// Metricas são os valores observados de um alvo, por nome de métrica
+type Metricas map[string]float64
+
+// ResultadoRegra explica a contribuição de uma regra ao score: valor
+// observado, limite comparado e pontos atribuídos
+type ResultadoRegra struct {
+	Regra     string  `json:"regra"`
+	Descricao string  `json:"descricao"`
+	Metrica   string  `json:"metrica"`
+	Valor     float64 `json:"valor"`
+	Operador  string  `json:"operador"`
+	Limite    float64 `json:"limite"` // da faixa atingida ou, se nenhuma, da mais branda
+	Nivel     string  `json:"nivel,omitempty"`
+	Peso      float64 `json:"peso"`
+	Pontos    int     `json:"pontos"`
+	Acionada  bool    `json:"acionada"`
+	Avaliada  bool    `json:"avaliada"` // false quando a métrica não estava disponível
+}
+
+// Pontuacao é o score de um alvo com a composição regra a regra
+type Pontuacao struct {
+	Modelo       string           `json:"modelo"`
+	Versao       string           `json:"versao"`
+	Alvo         string           `json:"alvo"`
+	Score        int              `json:"score"`
+	PontosBrutos int              `json:"pontos_brutos"` // soma antes do teto score_maximo
+	ScoreMaximo  int              `json:"score_maximo"`
+	Regras       []ResultadoRegra `json:"regras"`
+	Flags        []string         `json:"flags"`
+}
+
+var (
+	modeloPadraoOnce sync.Once
+	modeloPadrao     *ModeloRisco
+)
+
+// ModeloRiscoPadrao retorna o modelo embutido (risco.yaml)
+func ModeloRiscoPadrao() *ModeloRisco {
+	modeloPadraoOnce.Do(func() {
+		m, err := ParseModeloRisco(modeloPadraoYAML)
+		if err != nil {
+			panic(fmt.Sprintf("risco.yaml embutido inválido: %v", err))
+		}
+		m.Origem = "embutido"
+		modeloPadrao = m
+	})
+	return modeloPadrao
+}
+
+// ParseModeloRisco lê e valida um modelo YAML ou JSON
+func ParseModeloRisco(data []byte) (*ModeloRisco, error) {
+	m := &ModeloRisco{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("modelo de risco inválido: %w", err)
+	}
+	if err := m.validar(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CarregarModeloRisco lê o modelo do arquivo; com path vazio retorna o padrão
+func CarregarModeloRisco(path string) (*ModeloRisco, error) {
+	if path == "" {
+		return ModeloRiscoPadrao(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler modelo de risco: %w", err)
+	}
+	m, err := ParseModeloRisco(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m.Origem = path
+	return m, nil
+}
+
+// CarregarModeloCaso lê o modelo <nome>.yaml (ou .yml/.json) da pasta de
+// modelos por caso. O nome não pode conter separadores de caminho.
+func CarregarModeloCaso(pasta, nome string) (*ModeloRisco, error) {
+	if !nomeModelo.MatchString(nome) || strings.HasPrefix(nome, ".") {
+		return nil, fmt.Errorf("nome de modelo inválido: %q", nome)
+	}
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		path := filepath.Join(pasta, nome+ext)
+		if _, err := os.Stat(path); err == nil {
+			return CarregarModeloRisco(path)
+		}
+	}
+	return nil, fmt.Errorf("modelo de risco %q não encontrado em %s", nome, pasta)
+}
+
+// validar confere as regras e preenche operador e peso padrão
+func (m *ModeloRisco) validar() error {
+	if m.ScoreMaximo <= 0 {
+		m.ScoreMaximo = 100
+	}
+	ids := make(map[string]bool)
+	for i := range m.Regras {
+		r := &m.Regras[i]
+		if r.ID == "" {
+			return fmt.Errorf("regra %d sem id", i+1)
+		}
+		if ids[r.ID] {
+			return fmt.Errorf("regra %q duplicada", r.ID)
+		}
+		ids[r.ID] = true
+
+		if r.Alvo == "" || r.Metrica == "" {
+			return fmt.Errorf("regra %q: alvo e metrica são obrigatórios", r.ID)
+		}
+		if r.Operador == "" {
+			r.Operador = ">"
+		}
+		if _, err := compara(r.Operador, 0, 0); err != nil {
+			return fmt.Errorf("regra %q: %w", r.ID, err)
+		}
+		if r.Peso == nil {
+			peso := 1.0
+			r.Peso = &peso
+		} else if *r.Peso < 0 {
+			return fmt.Errorf("regra %q: peso negativo", r.ID)
+		}
+		if len(r.Faixas) == 0 {
+			return fmt.Errorf("regra %q: nenhuma faixa declarada", r.ID)
+		}
+	}
+	return nil
+}
+
+// compara aplica o operador da regra
+func compara(operador string, valor, limite float64) (bool, error) {
+	switch operador {
+	case ">":
+		return valor > limite, nil
+	case ">=":
+		return valor >= limite, nil
+	case "<":
+		return valor < limite, nil
+	case "<=":
+		return valor <= limite, nil
+	case "==":
+		return valor == limite, nil
+	case "!=":
+		return valor != limite, nil
+	}
+	return false, fmt.Errorf("operador desconhecido %q", operador)
+}
+
+// Avaliar pontua as métricas com as regras do alvo. Regras cuja métrica não
+// foi informada entram na composição com avaliada = false e zero pontos.
+func (m *ModeloRisco) Avaliar(alvo string, metricas Metricas) *Pontuacao {
+	p := &Pontuacao{
+		Modelo:      m.Nome,
+		Versao:      m.Versao,
+		Alvo:        alvo,
+		ScoreMaximo: m.ScoreMaximo,
+		Regras:      []ResultadoRegra{},
+		Flags:       []string{},
+	}
+
+	for _, r := range m.Regras {
+		if r.Alvo != alvo {
+			continue
+		}
+		res := ResultadoRegra{
+			Regra:     r.ID,
+			Descricao: r.Descricao,
+			Metrica:   r.Metrica,
+			Operador:  r.Operador,
+			Peso:      *r.Peso,
+			Limite:    r.Faixas[len(r.Faixas)-1].Limite,
+		}
+
+		valor, ok := metricas[r.Metrica]
+		if ok {
+			res.Avaliada = true
+			res.Valor = valor
+			for _, f := range r.Faixas {
+				if atende, _ := compara(r.Operador, valor, f.Limite); atende {
+					res.Acionada = true
+					res.Limite = f.Limite
+					res.Nivel = f.Nivel
+					res.Pontos = int(math.Round(float64(f.Pontos) * *r.Peso))
+					break
+				}
+			}
+		}
+
+		if res.Acionada {
+			p.PontosBrutos += res.Pontos
+			p.Flags = append(p.Flags, r.flag(res))
+		}
+		p.Regras = append(p.Regras, res)
+	}
+
+	p.Score = min(max(p.PontosBrutos, 0), m.ScoreMaximo)
+	return p
+}
+
+// flag formata o alerta da regra acionada: "NÍVEL: mensagem"
+func (r RegraRisco) flag(res ResultadoRegra) string {
+	msg := r.Mensagem
+	if msg == "" {
+		msg = r.Descricao + ": {valor}"
+	}
+	msg = strings.ReplaceAll(msg, "{valor}", formatarValor(res.Valor))
+	msg = strings.ReplaceAll(msg, "{limite}", formatarValor(res.Limite))
+	if res.Nivel == "" {
+		return msg
+	}
+	return res.Nivel + ": " + msg
+}
+
+// formatarValor mostra inteiros sem casas decimais e os demais com duas
+func formatarValor(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
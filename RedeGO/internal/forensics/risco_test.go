@@ -0,0 +1,158 @@
+package forensics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModeloPadraoPessoa(t *testing.T) {
+	p := &SuspectProfile{
+		EmpresasBaixadas:   6,
+		EmpresasAtivas:     3,
+		EmpresasSuspensas:  1,
+		RedeBancaria:       51,
+		CapitalSocialTotal: 500000,
+	}
+	p.calculateRiskScore(ModeloRiscoPadrao())
+
+	// 20 (baixadas > 5) + 15 (suspensas > 0) + 20 (rede > 50)
+	if p.Score != 55 {
+		t.Errorf("Score = %d, esperado 55", p.Score)
+	}
+	esperadas := []string{"ALTO: 6 empresas baixadas", "CRÍTICO: 1 empresas suspensas", "ALTO: Rede de 51 empresas conectadas"}
+	if strings.Join(p.Flags, "|") != strings.Join(esperadas, "|") {
+		t.Errorf("Flags = %v, esperado %v", p.Flags, esperadas)
+	}
+
+	var baixadas *ResultadoRegra
+	for i, r := range p.Pontuacao.Regras {
+		if r.Regra == "pessoa_empresas_baixadas" {
+			baixadas = &p.Pontuacao.Regras[i]
+		}
+	}
+	if baixadas == nil {
+		t.Fatal("regra pessoa_empresas_baixadas ausente da composição")
+	}
+	if !baixadas.Acionada || baixadas.Valor != 6 || baixadas.Limite != 5 || baixadas.Pontos != 20 || baixadas.Nivel != "ALTO" {
+		t.Errorf("composição de pessoa_empresas_baixadas = %+v", *baixadas)
+	}
+	if len(p.Pontuacao.Regras) != 7 {
+		t.Errorf("composição com %d regras, esperado 7 (só as do alvo pessoa)", len(p.Pontuacao.Regras))
+	}
+}
+
+func TestModeloPadraoFaixas(t *testing.T) {
+	m := ModeloRiscoPadrao()
+	tests := []struct {
+		alvo     string
+		metricas Metricas
+		score    int
+		flag     string
+	}{
+		{AlvoEnderecoCompartilhado, Metricas{"total_empresas": 51}, 90, "CRÍTICO: Mais de 50 empresas no mesmo endereço"},
+		{AlvoEnderecoCompartilhado, Metricas{"total_empresas": 21}, 70, "ALTO: Mais de 20 empresas no mesmo endereço"},
+		{AlvoEnderecoCompartilhado, Metricas{"total_empresas": 10}, 0, ""},
+		{AlvoEnderecoCompartilhado, Metricas{"total_empresas": 21, "percentual_baixadas": 60, "aberturas_recentes": 11}, 90,
+			"ALTO: Mais de 20 empresas no mesmo endereço|MÉDIO: 60% dos estabelecimentos do endereço baixados|ALTO: 11 estabelecimentos abertos nos últimos 12 meses"},
+		{AlvoContatoCompartilhado, Metricas{"total_empresas": 3}, 50, "MÉDIO: 3 empresas com o mesmo contato"},
+		{AlvoBaixasEmSerie, Metricas{"empresas_baixadas": 8}, 70, "ALTO RISCO: 8 empresas baixadas"},
+		{AlvoBaixasEmSerie, Metricas{"empresas_baixadas": 5}, 50, "ALTO RISCO: 5 empresas baixadas"},
+	}
+	for _, tt := range tests {
+		p := m.Avaliar(tt.alvo, tt.metricas)
+		flag := strings.Join(p.Flags, "|")
+		if p.Score != tt.score || flag != tt.flag {
+			t.Errorf("Avaliar(%s, %v) = %d %q, esperado %d %q", tt.alvo, tt.metricas, p.Score, flag, tt.score, tt.flag)
+		}
+	}
+}
+
+func TestModeloPersonalizado(t *testing.T) {
+	yaml := `
+nome: caso-123
+versao: "2"
+score_maximo: 50
+regras:
+  - id: baixadas
+    alvo: pessoa
+    metrica: empresas_baixadas
+    mensagem: "{valor} baixadas (limite {limite})"
+    peso: 1.5
+    faixas:
+      - {limite: 3, pontos: 20, nivel: ALTO}
+  - id: capital_baixo
+    alvo: pessoa
+    metrica: capital_social_total
+    operador: "<"
+    faixas:
+      - {limite: 1000, pontos: 40, nivel: MÉDIO}
+  - id: idade
+    alvo: pessoa
+    metrica: idade
+    faixas:
+      - {limite: 70, pontos: 5}
+`
+	m, err := ParseModeloRisco([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ParseModeloRisco() erro: %v", err)
+	}
+
+	p := m.Avaliar(AlvoPessoa, Metricas{"empresas_baixadas": 4, "capital_social_total": 100})
+	// 30 (20 × 1,5) + 40 = 70, limitado a 50
+	if p.PontosBrutos != 70 || p.Score != 50 {
+		t.Errorf("PontosBrutos = %d, Score = %d, esperado 70 e 50", p.PontosBrutos, p.Score)
+	}
+	if p.Flags[0] != "ALTO: 4 baixadas (limite 3)" {
+		t.Errorf("Flags[0] = %q, esperado %q", p.Flags[0], "ALTO: 4 baixadas (limite 3)")
+	}
+	if idade := p.Regras[2]; idade.Avaliada || idade.Pontos != 0 {
+		t.Errorf("regra sem métrica = %+v, esperado não avaliada", idade)
+	}
+	if p.Modelo != "caso-123" || p.Versao != "2" {
+		t.Errorf("Modelo = %s v%s, esperado caso-123 v2", p.Modelo, p.Versao)
+	}
+}
+
+func TestParseModeloRiscoInvalido(t *testing.T) {
+	tests := []struct {
+		yaml   string
+		trecho string
+	}{
+		{"regras: [{alvo: pessoa, metrica: x, faixas: [{limite: 1, pontos: 1}]}]", "sem id"},
+		{"regras: [{id: a, metrica: x, faixas: [{limite: 1, pontos: 1}]}]", "alvo e metrica"},
+		{"regras: [{id: a, alvo: pessoa, metrica: x, operador: '=>', faixas: [{limite: 1, pontos: 1}]}]", "operador desconhecido"},
+		{"regras: [{id: a, alvo: pessoa, metrica: x}]", "nenhuma faixa"},
+		{"regras: [{id: a, alvo: pessoa, metrica: x, peso: -1, faixas: [{limite: 1, pontos: 1}]}]", "peso negativo"},
+		{"regras: [{id: a, alvo: pessoa, metrica: x, faixas: [{limite: 1}]}, {id: a, alvo: pessoa, metrica: y, faixas: [{limite: 1}]}]", "duplicada"},
+	}
+	for _, tt := range tests {
+		_, err := ParseModeloRisco([]byte(tt.yaml))
+		if err == nil || !strings.Contains(err.Error(), tt.trecho) {
+			t.Errorf("ParseModeloRisco(%q) erro = %v, esperado conter %q", tt.yaml, err, tt.trecho)
+		}
+	}
+}
+
+func TestCarregarModeloCaso(t *testing.T) {
+	pasta := t.TempDir()
+	json := `{"nome": "caso", "regras": [{"id": "a", "alvo": "pessoa", "metrica": "total_empresas", "faixas": [{"limite": 0, "pontos": 10}]}]}`
+	if err := os.WriteFile(filepath.Join(pasta, "caso.json"), []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := CarregarModeloCaso(pasta, "caso")
+	if err != nil {
+		t.Fatalf("CarregarModeloCaso() erro: %v", err)
+	}
+	if m.Nome != "caso" || m.Origem != filepath.Join(pasta, "caso.json") {
+		t.Errorf("modelo = %s (%s), esperado caso (%s)", m.Nome, m.Origem, filepath.Join(pasta, "caso.json"))
+	}
+
+	for _, nome := range []string{"../caso", "a/b", "..", ".oculto", "inexistente"} {
+		if _, err := CarregarModeloCaso(pasta, nome); err == nil {
+			t.Errorf("CarregarModeloCaso(%q) sem erro", nome)
+		}
+	}
+}
@@ -0,0 +1,644 @@
+package graph
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// Valores padrão das opções de busca de caminhos
+const (
+	profundidadePadrao  = 5
+	maxCaminhosPadrao   = 10
+	grauMaximoPadrao    = 500
+	maxExploradosPadrao = 20000
+	tamanhoLoteSituacao = 500
+	situacaoBaixada     = "08"
+)
+
+// errLimiteBusca interrompe a busca quando MaxNosExplorados é atingido
+var errLimiteBusca = errors.New("limite de nós explorados atingido")
+
+// OpcoesCaminhos controla a busca de caminhos entre duas entidades
+type OpcoesCaminhos struct {
+	MaxProfundidade  int                `json:"maxDepth"`         // máximo de ligações por caminho
+	MaxCaminhos      int                `json:"maxCaminhos"`      // quantidade de caminhos (k de Yen); 1 = só o menor
+	GrauMaximo       int                `json:"grauMaximo"`       // nós intermediários com mais ligações não são atravessados; < 0 = sem limite
+	ExcluirBaixadas  bool               `json:"excluirBaixadas"`  // não atravessa empresas baixadas
+	Ponderado        bool               `json:"ponderado"`        // custo pela qualificação em vez do número de ligações
+	Pesos            []PesoQualificacao `json:"pesos,omitempty"`  // substitui PesosQualificacaoPadrao
+	MaxNosExplorados int                `json:"maxNosExplorados"` // limite de nós expandidos na busca inteira
+}
+
+// normalizar preenche os campos zerados com os valores padrão
+func (o OpcoesCaminhos) normalizar() OpcoesCaminhos {
+	if o.MaxProfundidade <= 0 {
+		o.MaxProfundidade = profundidadePadrao
+	}
+	if o.MaxCaminhos <= 0 {
+		o.MaxCaminhos = maxCaminhosPadrao
+	}
+	if o.GrauMaximo == 0 {
+		o.GrauMaximo = grauMaximoPadrao
+	}
+	if o.MaxNosExplorados <= 0 {
+		o.MaxNosExplorados = maxExploradosPadrao
+	}
+	if len(o.Pesos) == 0 {
+		o.Pesos = PesosQualificacaoPadrao
+	}
+	return o
+}
+
+// PesoQualificacao associa um trecho da descrição da ligação a um custo.
+// Custos menores representam vínculos mais fortes.
+type PesoQualificacao struct {
+	Trecho string  `json:"trecho"`
+	Peso   float64 `json:"peso"`
+}
+
+// PesosQualificacaoPadrao privilegia caminhos por administradores e filiais
+// sobre sócios quotistas e representantes. A primeira regra que casar vale.
+var PesosQualificacaoPadrao = []PesoQualificacao{
+	{Trecho: "rep-sócio", Peso: 3},
+	{Trecho: "procurador", Peso: 3},
+	{Trecho: "filial", Peso: 0.5},
+	{Trecho: "administrador", Peso: 1},
+	{Trecho: "presidente", Peso: 1},
+	{Trecho: "diretor", Peso: 1},
+	{Trecho: "titular", Peso: 1},
+	{Trecho: "sócio", Peso: 2},
+}
+
+// pesoSemRegra é o custo das ligações que não casam com nenhuma regra
+const pesoSemRegra = 2
+
+// CustoLigacao retorna o custo da ligação pela qualificação
+func CustoLigacao(descricao string, pesos []PesoQualificacao) float64 {
+	descricao = strings.ToLower(descricao)
+	for _, p := range pesos {
+		if strings.Contains(descricao, strings.ToLower(p.Trecho)) {
+			return p.Peso
+		}
+	}
+	return pesoSemRegra
+}
+
+// Caminho é uma sequência de IDs da origem ao destino
+type Caminho struct {
+	Nos   []string `json:"nos"`
+	Custo float64  `json:"custo"`
+}
+
+// ResultadoCaminhos é o grafo com os caminhos encontrados, do menor para o
+// maior custo
+type ResultadoCaminhos struct {
+	*models.Graph
+	Caminhos []Caminho `json:"caminhos"`
+}
+
+// FindPaths encontra até MaxCaminhos caminhos simples entre duas entidades,
+// em ordem de custo (algoritmo de Yen). Sem ponderação, o menor caminho é
+// obtido por busca em largura bidirecional. Se o limite de nós explorados
+// for atingido ou o prazo do contexto expirar, retorna os caminhos já
+// encontrados com Truncado = true; cancelamentos são devolvidos como erro.
+func (p *PathFinder) FindPaths(ctx context.Context, from, to string, opcoes OpcoesCaminhos) (*ResultadoCaminhos, error) {
+	if p.db == nil {
+		return nil, fmt.Errorf("banco de dados de rede não disponível")
+	}
+	opcoes = opcoes.normalizar()
+	if opcoes.ExcluirBaixadas && p.dbReceita == nil {
+		return nil, fmt.Errorf("base da Receita necessária para excluir empresas baixadas")
+	}
+
+	b := p.novaBusca(from, to, opcoes)
+	caminhos, err := b.yen(ctx)
+
+	resultado := &ResultadoCaminhos{Caminhos: caminhos}
+	switch {
+	case errors.Is(err, errLimiteBusca):
+		resultado.Graph = b.paraGrafo(ctx, caminhos)
+		resultado.Truncado = true
+		resultado.Mensagem = fmt.Sprintf("Limite de %d nós explorados atingido", opcoes.MaxNosExplorados)
+	case errors.Is(err, context.DeadlineExceeded):
+		resultado.Graph = b.paraGrafo(context.Background(), caminhos)
+		resultado.Truncado = true
+		resultado.Mensagem = "Tempo máximo de consulta atingido"
+	case err != nil:
+		return nil, err
+	default:
+		resultado.Graph = b.paraGrafo(ctx, caminhos)
+	}
+	if resultado.Caminhos == nil {
+		resultado.Caminhos = []Caminho{}
+	}
+	return resultado, nil
+}
+
+// ShortestPath retorna o menor caminho entre duas entidades, ou nil se não
+// houver caminho dentro de MaxProfundidade
+func (p *PathFinder) ShortestPath(ctx context.Context, from, to string, opcoes OpcoesCaminhos) (*Caminho, error) {
+	opcoes.MaxCaminhos = 1
+	resultado, err := p.FindPaths(ctx, from, to, opcoes)
+	if err != nil || len(resultado.Caminhos) == 0 {
+		return nil, err
+	}
+	return &resultado.Caminhos[0], nil
+}
+
+// ligacaoVizinha é uma ligação vista a partir de um dos nós
+type ligacaoVizinha struct {
+	vizinho   string
+	id1, id2  string
+	descricao string
+}
+
+// buscaCaminhos guarda o estado de uma chamada a FindPaths: vizinhos já
+// consultados, hubs e empresas baixadas
+type buscaCaminhos struct {
+	p          *PathFinder
+	opcoes     OpcoesCaminhos
+	origem     string
+	destino    string
+	vizinhos   map[string][]ligacaoVizinha
+	ligacoes   map[string]ligacaoVizinha // menor custo por par de nós
+	hubs       map[string]bool
+	baixadas   map[string]bool
+	explorados int
+}
+
+func (p *PathFinder) novaBusca(origem, destino string, opcoes OpcoesCaminhos) *buscaCaminhos {
+	return &buscaCaminhos{
+		p:        p,
+		opcoes:   opcoes,
+		origem:   origem,
+		destino:  destino,
+		vizinhos: make(map[string][]ligacaoVizinha),
+		ligacoes: make(map[string]ligacaoVizinha),
+		hubs:     make(map[string]bool),
+		baixadas: make(map[string]bool),
+	}
+}
+
+// chaveAresta identifica o par de nós independente da direção da ligação
+func chaveAresta(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "\x00" + b
+}
+
+// vizinhosDe consulta as ligações do nó. Nós intermediários com mais de
+// GrauMaximo ligações são marcados como hub e não são expandidos; empresas
+// baixadas são descartadas quando ExcluirBaixadas estiver ativo.
+func (b *buscaCaminhos) vizinhosDe(ctx context.Context, id string) ([]ligacaoVizinha, error) {
+	if v, ok := b.vizinhos[id]; ok {
+		return v, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if b.explorados >= b.opcoes.MaxNosExplorados {
+		return nil, errLimiteBusca
+	}
+	b.explorados++
+
+	extremo := id == b.origem || id == b.destino
+	query := `
+		SELECT id1, id2, descricao FROM {ligacao} WHERE id1 = ?
+		UNION ALL
+		SELECT id1, id2, descricao FROM {ligacao} WHERE id2 = ?
+	`
+	args := []interface{}{id, id}
+	if b.opcoes.GrauMaximo > 0 && !extremo {
+		query += " LIMIT ?"
+		args = append(args, b.opcoes.GrauMaximo+1)
+	}
+
+	rows, err := b.p.db.QueryContext(ctx, b.p.dialeto.Query(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lista []ligacaoVizinha
+	for rows.Next() {
+		var l ligacaoVizinha
+		var descricao *string
+		if err := rows.Scan(&l.id1, &l.id2, &descricao); err != nil {
+			return nil, err
+		}
+		if descricao != nil {
+			l.descricao = *descricao
+		}
+		l.vizinho = l.id2
+		if l.id2 == id {
+			l.vizinho = l.id1
+		}
+		if l.vizinho == id {
+			continue
+		}
+		lista = append(lista, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if b.opcoes.GrauMaximo > 0 && !extremo && len(lista) > b.opcoes.GrauMaximo {
+		b.hubs[id] = true
+		b.vizinhos[id] = nil
+		return nil, nil
+	}
+
+	if b.opcoes.ExcluirBaixadas {
+		if err := b.verificarBaixadas(ctx, lista); err != nil {
+			return nil, err
+		}
+		filtrada := lista[:0]
+		for _, l := range lista {
+			if !b.baixadas[l.vizinho] || l.vizinho == b.origem || l.vizinho == b.destino {
+				filtrada = append(filtrada, l)
+			}
+		}
+		lista = filtrada
+	}
+
+	for _, l := range lista {
+		chave := chaveAresta(id, l.vizinho)
+		if atual, ok := b.ligacoes[chave]; !ok || b.custo(l.descricao) < b.custo(atual.descricao) {
+			b.ligacoes[chave] = l
+		}
+	}
+	b.vizinhos[id] = lista
+	return lista, nil
+}
+
+// verificarBaixadas consulta, em lotes, a situação das empresas vizinhas
+// ainda não verificadas
+func (b *buscaCaminhos) verificarBaixadas(ctx context.Context, lista []ligacaoVizinha) error {
+	var pendentes []string
+	vistos := make(map[string]bool)
+	for _, l := range lista {
+		if strings.HasPrefix(l.vizinho, "PJ_") {
+			if _, ok := b.baixadas[l.vizinho]; !ok && !vistos[l.vizinho] {
+				vistos[l.vizinho] = true
+				pendentes = append(pendentes, l.vizinho)
+			}
+		}
+	}
+
+	for inicio := 0; inicio < len(pendentes); inicio += tamanhoLoteSituacao {
+		lote := pendentes[inicio:min(inicio+tamanhoLoteSituacao, len(pendentes))]
+		args := make([]interface{}, 0, len(lote)+1)
+		args = append(args, situacaoBaixada)
+		for _, id := range lote {
+			b.baixadas[id] = false
+			args = append(args, strings.TrimPrefix(id, "PJ_"))
+		}
+
+		query := b.p.dialeto.Query(fmt.Sprintf(
+			"SELECT cnpj FROM {estabelecimento} WHERE situacao_cadastral = ? AND cnpj IN (%s)",
+			strings.TrimSuffix(strings.Repeat("?,", len(lote)), ",")))
+		rows, err := b.p.dbReceita.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var cnpj string
+			if err := rows.Scan(&cnpj); err != nil {
+				rows.Close()
+				return err
+			}
+			b.baixadas["PJ_"+cnpj] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// custo de uma ligação: 1 por ligação ou o peso da qualificação
+func (b *buscaCaminhos) custo(descricao string) float64 {
+	if !b.opcoes.Ponderado {
+		return 1
+	}
+	return CustoLigacao(descricao, b.opcoes.Pesos)
+}
+
+// custoCaminho soma o custo das ligações do caminho
+func (b *buscaCaminhos) custoCaminho(nos []string) float64 {
+	total := 0.0
+	for i := 0; i+1 < len(nos); i++ {
+		total += b.custo(b.ligacoes[chaveAresta(nos[i], nos[i+1])].descricao)
+	}
+	return total
+}
+
+// bloqueios são os nós e ligações removidos do grafo em um desvio de Yen
+type bloqueios struct {
+	nos     map[string]bool
+	arestas map[string]bool
+}
+
+func (bl bloqueios) permite(de, para string) bool {
+	return !bl.nos[para] && !bl.arestas[chaveAresta(de, para)]
+}
+
+// menorCaminho escolhe o algoritmo: BFS bidirecional sem ponderação,
+// Dijkstra com ponderação
+func (b *buscaCaminhos) menorCaminho(ctx context.Context, de, para string, maxArestas int, bl bloqueios) ([]string, error) {
+	if de == para {
+		return []string{de}, nil
+	}
+	if maxArestas <= 0 {
+		return nil, nil
+	}
+	if b.opcoes.Ponderado {
+		return b.dijkstra(ctx, de, para, maxArestas, bl)
+	}
+	return b.bfsBidirecional(ctx, de, para, maxArestas, bl)
+}
+
+// bfsBidirecional expande, a cada passo, a menor das duas fronteiras até
+// que se encontrem, o que reduz drasticamente os nós visitados em redes com
+// alto grau médio
+func (b *buscaCaminhos) bfsBidirecional(ctx context.Context, de, para string, maxArestas int, bl bloqueios) ([]string, error) {
+	paiA := map[string]string{de: ""}
+	paiB := map[string]string{para: ""}
+	distA := map[string]int{de: 0}
+	distB := map[string]int{para: 0}
+	frenteA, frenteB := []string{de}, []string{para}
+	nivelA, nivelB := 0, 0
+
+	for len(frenteA) > 0 && len(frenteB) > 0 && nivelA+nivelB < maxArestas {
+		// Expande o lado com a menor fronteira
+		ladoA := len(frenteA) <= len(frenteB)
+		frente, pai, dist, outroDist := frenteA, paiA, distA, distB
+		if !ladoA {
+			frente, pai, dist, outroDist = frenteB, paiB, distB, distA
+		}
+
+		var proxima []string
+		encontro, melhor := "", -1
+		for _, u := range frente {
+			vizinhos, err := b.vizinhosDe(ctx, u)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range vizinhos {
+				v := l.vizinho
+				if !bl.permite(u, v) {
+					continue
+				}
+				if _, visto := pai[v]; visto {
+					continue
+				}
+				pai[v] = u
+				dist[v] = dist[u] + 1
+				proxima = append(proxima, v)
+				if d, ok := outroDist[v]; ok && (melhor < 0 || dist[v]+d < melhor) {
+					// O nó de encontro não foi expandido por nenhum dos lados;
+					// consulta suas ligações para saber se é hub
+					if v != de && v != para {
+						if _, err := b.vizinhosDe(ctx, v); err != nil {
+							return nil, err
+						}
+						if b.hubs[v] {
+							continue
+						}
+					}
+					encontro, melhor = v, dist[v]+d
+				}
+			}
+		}
+
+		if ladoA {
+			frenteA, nivelA = proxima, nivelA+1
+		} else {
+			frenteB, nivelB = proxima, nivelB+1
+		}
+
+		if encontro != "" && melhor <= maxArestas {
+			return juntarCaminho(paiA, paiB, encontro), nil
+		}
+	}
+	return nil, nil
+}
+
+// juntarCaminho monta o caminho origem -> encontro -> destino a partir dos
+// pais das duas buscas
+func juntarCaminho(paiA, paiB map[string]string, encontro string) []string {
+	var inicio []string
+	for n := encontro; n != ""; n = paiA[n] {
+		inicio = append(inicio, n)
+	}
+	for i, j := 0, len(inicio)-1; i < j; i, j = i+1, j-1 {
+		inicio[i], inicio[j] = inicio[j], inicio[i]
+	}
+	for n := paiB[encontro]; n != ""; n = paiB[n] {
+		inicio = append(inicio, n)
+	}
+	return inicio
+}
+
+// itemFila é um nó na fila de prioridade do Dijkstra
+type itemFila struct {
+	id      string
+	custo   float64
+	arestas int
+}
+
+type filaPrioridade []itemFila
+
+func (f filaPrioridade) Len() int            { return len(f) }
+func (f filaPrioridade) Less(i, j int) bool  { return f[i].custo < f[j].custo }
+func (f filaPrioridade) Swap(i, j int)       { f[i], f[j] = f[j], f[i] }
+func (f *filaPrioridade) Push(x interface{}) { *f = append(*f, x.(itemFila)) }
+func (f *filaPrioridade) Pop() interface{} {
+	old := *f
+	item := old[len(old)-1]
+	*f = old[:len(old)-1]
+	return item
+}
+
+// dijkstra encontra o caminho de menor custo com no máximo maxArestas
+// ligações. O limite de ligações é aplicado sobre o caminho de menor custo
+// até cada nó, o que pode descartar um caminho mais curto e mais caro.
+func (b *buscaCaminhos) dijkstra(ctx context.Context, de, para string, maxArestas int, bl bloqueios) ([]string, error) {
+	custos := map[string]float64{de: 0}
+	pais := map[string]string{de: ""}
+	fechados := make(map[string]bool)
+	fila := &filaPrioridade{{id: de}}
+
+	for fila.Len() > 0 {
+		item := heap.Pop(fila).(itemFila)
+		if fechados[item.id] {
+			continue
+		}
+		fechados[item.id] = true
+
+		if item.id == para {
+			var caminho []string
+			for n := para; n != ""; n = pais[n] {
+				caminho = append([]string{n}, caminho...)
+			}
+			return caminho, nil
+		}
+		if item.arestas >= maxArestas {
+			continue
+		}
+
+		vizinhos, err := b.vizinhosDe(ctx, item.id)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range vizinhos {
+			v := l.vizinho
+			if fechados[v] || !bl.permite(item.id, v) {
+				continue
+			}
+			custo := item.custo + b.custo(l.descricao)
+			if atual, ok := custos[v]; !ok || custo < atual {
+				custos[v] = custo
+				pais[v] = item.id
+				heap.Push(fila, itemFila{id: v, custo: custo, arestas: item.arestas + 1})
+			}
+		}
+	}
+	return nil, nil
+}
+
+// yen lista os k caminhos simples de menor custo: cada novo caminho é um
+// desvio a partir de um nó de um caminho já aceito, com as ligações já
+// usadas por caminhos de mesmo prefixo bloqueadas
+func (b *buscaCaminhos) yen(ctx context.Context) ([]Caminho, error) {
+	primeiro, err := b.menorCaminho(ctx, b.origem, b.destino, b.opcoes.MaxProfundidade, bloqueios{})
+	if err != nil || len(primeiro) < 2 {
+		return nil, err
+	}
+
+	aceitos := [][]string{primeiro}
+	var candidatos []Caminho
+	conhecidos := map[string]bool{strings.Join(primeiro, "\x00"): true}
+
+	for len(aceitos) < b.opcoes.MaxCaminhos {
+		anterior := aceitos[len(aceitos)-1]
+
+		for i := 0; i < len(anterior)-1; i++ {
+			desvio := anterior[i]
+			raiz := anterior[:i+1]
+
+			bl := bloqueios{nos: make(map[string]bool), arestas: make(map[string]bool)}
+			for _, c := range aceitos {
+				if len(c) > i+1 && igual(c[:i+1], raiz) {
+					bl.arestas[chaveAresta(c[i], c[i+1])] = true
+				}
+			}
+			for _, n := range raiz[:i] {
+				bl.nos[n] = true
+			}
+
+			trecho, err := b.menorCaminho(ctx, desvio, b.destino, b.opcoes.MaxProfundidade-i, bl)
+			if err != nil {
+				return caminhosComCusto(b, aceitos), err
+			}
+			if len(trecho) < 2 {
+				continue
+			}
+
+			nos := append(append([]string{}, raiz[:i]...), trecho...)
+			chave := strings.Join(nos, "\x00")
+			if !conhecidos[chave] {
+				conhecidos[chave] = true
+				candidatos = append(candidatos, Caminho{Nos: nos, Custo: b.custoCaminho(nos)})
+			}
+		}
+
+		if len(candidatos) == 0 {
+			break
+		}
+		// Aceita o candidato de menor custo; empate pelo menor número de nós
+		sort.SliceStable(candidatos, func(i, j int) bool {
+			if candidatos[i].Custo != candidatos[j].Custo {
+				return candidatos[i].Custo < candidatos[j].Custo
+			}
+			return len(candidatos[i].Nos) < len(candidatos[j].Nos)
+		})
+		aceitos = append(aceitos, candidatos[0].Nos)
+		candidatos = candidatos[1:]
+	}
+
+	return caminhosComCusto(b, aceitos), nil
+}
+
+func caminhosComCusto(b *buscaCaminhos, aceitos [][]string) []Caminho {
+	caminhos := make([]Caminho, len(aceitos))
+	for i, nos := range aceitos {
+		caminhos[i] = Caminho{Nos: nos, Custo: b.custoCaminho(nos)}
+	}
+	return caminhos
+}
+
+func igual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// paraGrafo converte os caminhos em grafo, com as ligações na direção
+// original (sócio -> empresa) e o custo em Value quando ponderado
+func (b *buscaCaminhos) paraGrafo(ctx context.Context, caminhos []Caminho) *models.Graph {
+	graph := &models.Graph{
+		Nodes: []models.Node{},
+		Edges: []models.Edge{},
+	}
+
+	nodeSet := make(map[string]bool)
+	edgeSet := make(map[string]bool)
+	for _, caminho := range caminhos {
+		for i, id := range caminho.Nos {
+			if !nodeSet[id] {
+				node, _ := b.p.getNodeInfo(ctx, b.p.db, id)
+				graph.Nodes = append(graph.Nodes, node)
+				nodeSet[id] = true
+			}
+			if i == 0 {
+				continue
+			}
+
+			chave := chaveAresta(caminho.Nos[i-1], id)
+			if edgeSet[chave] {
+				continue
+			}
+			edgeSet[chave] = true
+
+			l := b.ligacoes[chave]
+			edge := models.Edge{
+				From:         l.id1,
+				To:           l.id2,
+				Label:        l.descricao,
+				Type:         TipoLigacao(l.descricao),
+				Qualificacao: l.descricao,
+			}
+			if b.opcoes.Ponderado {
+				edge.Value = b.custo(l.descricao)
+			}
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+	return graph
+}
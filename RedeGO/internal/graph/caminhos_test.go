@@ -0,0 +1,195 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+)
+
+// basesCaminhos monta a rede:
+//
+//	PJ_A - PF_QUOTISTA - PJ_Z                 (sócios)
+//	PJ_A - PF_HUB - PJ_Z                      (PF_HUB tem 5 ligações)
+//	PJ_A - PF_ADM - PJ_M - PJ_Z               (administrador, diretor, filial)
+//
+// PJ_M está baixada na base da Receita.
+func basesCaminhos(t *testing.T) *PathFinder {
+	t.Helper()
+	pasta := t.TempDir()
+	abrir := func(nome string, stmts ...string) *sql.DB {
+		db, err := sql.Open("sqlite3", filepath.Join(pasta, nome))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return db
+	}
+
+	receita := abrir("cnpj.db",
+		`CREATE TABLE estabelecimento (cnpj TEXT, situacao_cadastral TEXT)`,
+		`INSERT INTO estabelecimento VALUES ('A', '02'), ('Z', '02'), ('M', '08')`,
+	)
+	rede := abrir("rede.db",
+		`CREATE TABLE ligacao (id1 TEXT, id2 TEXT, descricao TEXT, comentario TEXT)`,
+		`INSERT INTO ligacao (id1, id2, descricao) VALUES
+			('PF_QUOTISTA', 'PJ_A', 'Sócio'),
+			('PF_QUOTISTA', 'PJ_Z', 'Sócio'),
+			('PF_HUB', 'PJ_A', 'Sócio'),
+			('PF_HUB', 'PJ_Z', 'Sócio'),
+			('PF_HUB', 'PJ_X1', 'Sócio'),
+			('PF_HUB', 'PJ_X2', 'Sócio'),
+			('PF_HUB', 'PJ_X3', 'Sócio'),
+			('PF_ADM', 'PJ_A', 'Sócio-Administrador'),
+			('PF_ADM', 'PJ_M', 'Diretor'),
+			('PJ_M', 'PJ_Z', 'filial')`,
+	)
+	return NewPathFinder(rede, database.Dialect{}).ComBaseReceita(receita)
+}
+
+func nosCaminhos(resultado *ResultadoCaminhos) []string {
+	var nos []string
+	for _, c := range resultado.Caminhos {
+		nos = append(nos, strings.Join(c.Nos, " "))
+	}
+	return nos
+}
+
+func TestFindPaths(t *testing.T) {
+	p := basesCaminhos(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		nome     string
+		opcoes   OpcoesCaminhos
+		caminhos []string
+	}{
+		{"k menores caminhos", OpcoesCaminhos{}, []string{
+			"PJ_A PF_QUOTISTA PJ_Z",
+			"PJ_A PF_HUB PJ_Z",
+			"PJ_A PF_ADM PJ_M PJ_Z",
+		}},
+		{"apenas o menor", OpcoesCaminhos{MaxCaminhos: 1}, []string{"PJ_A PF_QUOTISTA PJ_Z"}},
+		{"profundidade máxima", OpcoesCaminhos{MaxProfundidade: 2}, []string{
+			"PJ_A PF_QUOTISTA PJ_Z",
+			"PJ_A PF_HUB PJ_Z",
+		}},
+		{"hub excluído", OpcoesCaminhos{GrauMaximo: 3}, []string{
+			"PJ_A PF_QUOTISTA PJ_Z",
+			"PJ_A PF_ADM PJ_M PJ_Z",
+		}},
+		{"baixadas excluídas", OpcoesCaminhos{ExcluirBaixadas: true}, []string{
+			"PJ_A PF_QUOTISTA PJ_Z",
+			"PJ_A PF_HUB PJ_Z",
+		}},
+		{"ponderado pela qualificação", OpcoesCaminhos{Ponderado: true, MaxCaminhos: 2}, []string{
+			"PJ_A PF_ADM PJ_M PJ_Z",
+			"PJ_A PF_QUOTISTA PJ_Z",
+		}},
+	}
+
+	for _, tt := range tests {
+		resultado, err := p.FindPaths(ctx, "PJ_A", "PJ_Z", tt.opcoes)
+		if err != nil {
+			t.Fatalf("%s: FindPaths() erro: %v", tt.nome, err)
+		}
+		nos := nosCaminhos(resultado)
+		if strings.Join(nos, " | ") != strings.Join(tt.caminhos, " | ") {
+			t.Errorf("%s: caminhos = %q, esperado %q", tt.nome, nos, tt.caminhos)
+		}
+		if resultado.Truncado {
+			t.Errorf("%s: resultado truncado: %s", tt.nome, resultado.Mensagem)
+		}
+		for i := 1; i < len(resultado.Caminhos); i++ {
+			if resultado.Caminhos[i].Custo < resultado.Caminhos[i-1].Custo {
+				t.Errorf("%s: caminhos fora de ordem de custo: %v", tt.nome, resultado.Caminhos)
+			}
+		}
+	}
+}
+
+func TestFindPathsGrafo(t *testing.T) {
+	p := basesCaminhos(t)
+	resultado, err := p.FindPaths(context.Background(), "PJ_A", "PJ_Z", OpcoesCaminhos{Ponderado: true, MaxCaminhos: 1})
+	if err != nil {
+		t.Fatalf("FindPaths() erro: %v", err)
+	}
+	if custo := resultado.Caminhos[0].Custo; custo != 2.5 {
+		t.Errorf("custo = %v, esperado 2.5", custo)
+	}
+	if len(resultado.Nodes) != 4 || len(resultado.Edges) != 3 {
+		t.Fatalf("%d nós e %d ligações, esperado 4 e 3", len(resultado.Nodes), len(resultado.Edges))
+	}
+
+	// A ligação mantém a direção original (sócio -> empresa)
+	edge := resultado.Edges[0]
+	if edge.From != "PF_ADM" || edge.To != "PJ_A" || edge.Qualificacao != "Sócio-Administrador" || edge.Value != 1 {
+		t.Errorf("primeira ligação = %+v", edge)
+	}
+	if tipo := resultado.Edges[2].Type; tipo != "filial" {
+		t.Errorf("tipo da ligação PJ_M -> PJ_Z = %q, esperado filial", tipo)
+	}
+	if tipo := resultado.Nodes[1].Type; tipo != "PF" {
+		t.Errorf("tipo do nó PF_ADM = %q, esperado PF", tipo)
+	}
+}
+
+func TestFindPathsLimites(t *testing.T) {
+	p := basesCaminhos(t)
+
+	resultado, err := p.FindPaths(context.Background(), "PJ_A", "PJ_Z", OpcoesCaminhos{MaxNosExplorados: 1})
+	if err != nil {
+		t.Fatalf("FindPaths() erro: %v", err)
+	}
+	if !resultado.Truncado {
+		t.Error("busca com limite de nós explorados não foi marcada como truncada")
+	}
+
+	resultado, err = p.FindPaths(context.Background(), "PJ_A", "PJ_INEXISTENTE", OpcoesCaminhos{})
+	if err != nil {
+		t.Fatalf("FindPaths() erro: %v", err)
+	}
+	if len(resultado.Caminhos) != 0 || len(resultado.Nodes) != 0 {
+		t.Errorf("caminhos para nó inexistente = %v", resultado.Caminhos)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.FindPaths(ctx, "PJ_A", "PJ_Z", OpcoesCaminhos{}); err == nil {
+		t.Error("busca cancelada não retornou erro")
+	}
+
+	semReceita := NewPathFinder(p.db, database.Dialect{})
+	if _, err := semReceita.FindPaths(context.Background(), "PJ_A", "PJ_Z", OpcoesCaminhos{ExcluirBaixadas: true}); err == nil {
+		t.Error("ExcluirBaixadas sem base da Receita não retornou erro")
+	}
+}
+
+func TestCustoLigacao(t *testing.T) {
+	tests := []struct {
+		descricao string
+		custo     float64
+	}{
+		{"Sócio-Administrador", 1},
+		{"Sócio", 2},
+		{"filial", 0.5},
+		{"rep-sócio-Procurador", 3},
+		{"Diretor", 1},
+		{"Presidente", 1},
+		{"Fundador", 2},
+	}
+	for _, tt := range tests {
+		if custo := CustoLigacao(tt.descricao, PesosQualificacaoPadrao); custo != tt.custo {
+			t.Errorf("CustoLigacao(%q) = %v, esperado %v", tt.descricao, custo, tt.custo)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package graph
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // GraphType tipo de grafo
@@ -17,142 +20,75 @@ const (
 	GraphTypeCaminhosComum  GraphType = "caminhos-comum"  // Entidades em comum
 )
 
-// PathFinder encontra caminhos entre entidades
-type PathFinder struct {
-	dbPath string
-}
-
-// NewPathFinder cria um novo buscador de caminhos
-func NewPathFinder(dbPath string) *PathFinder {
-	return &PathFinder{dbPath: dbPath}
-}
-
-// FindPaths encontra caminhos entre duas entidades
-func (p *PathFinder) FindPaths(from, to string, maxDepth int) (*models.Graph, error) {
-	db, err := sql.Open("sqlite3", p.dbPath)
-	if err != nil {
-		return nil, err
+// TipoLigacao classifica a ligação pela descrição: "filial",
+// "representante" (rep-sócio-*) ou "socio"
+func TipoLigacao(descricao string) string {
+	switch {
+	case descricao == "filial":
+		return "filial"
+	case strings.HasPrefix(descricao, "rep-sócio"):
+		return "representante"
+	default:
+		return "socio"
 	}
-	defer db.Close()
-
-	// Usa BFS para encontrar caminhos
-	visited := make(map[string]bool)
-	paths := [][]string{}
-	
-	// Busca caminhos
-	p.bfs(db, from, to, maxDepth, []string{from}, visited, &paths)
-
-	// Converte caminhos para grafo
-	return p.pathsToGraph(db, paths)
 }
 
-// bfs busca em largura para encontrar caminhos
-func (p *PathFinder) bfs(db *sql.DB, current, target string, depth int, path []string, visited map[string]bool, paths *[][]string) {
-	if depth == 0 {
-		return
-	}
-
-	if current == target && len(path) > 1 {
-		// Encontrou caminho
-		pathCopy := make([]string, len(path))
-		copy(pathCopy, path)
-		*paths = append(*paths, pathCopy)
-		return
-	}
-
-	visited[current] = true
-
-	// Busca vizinhos
-	query := `
-		SELECT id2 FROM ligacao WHERE id1 = ?
-		UNION
-		SELECT id1 FROM ligacao WHERE id2 = ?
-	`
-
-	rows, err := db.Query(query, current, current)
-	if err != nil {
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var neighbor string
-		if err := rows.Scan(&neighbor); err != nil {
-			continue
-		}
-
-		if !visited[neighbor] {
-			newPath := append(path, neighbor)
-			p.bfs(db, neighbor, target, depth-1, newPath, visited, paths)
-		}
-	}
-
-	visited[current] = false
+// PathFinder encontra caminhos entre entidades
+type PathFinder struct {
+	db        *sql.DB
+	dbReceita *sql.DB
+	dialeto   database.Dialect
 }
 
-// pathsToGraph converte caminhos para grafo
-func (p *PathFinder) pathsToGraph(db *sql.DB, paths [][]string) (*models.Graph, error) {
-	graph := &models.Graph{
-		Nodes: []models.Node{},
-		Edges: []models.Edge{},
-	}
-
-	nodeSet := make(map[string]bool)
-	edgeSet := make(map[string]bool)
-
-	for _, path := range paths {
-		for i, nodeID := range path {
-			// Adiciona nó
-			if !nodeSet[nodeID] {
-				node, err := p.getNodeInfo(db, nodeID)
-				if err == nil {
-					graph.Nodes = append(graph.Nodes, node)
-					nodeSet[nodeID] = true
-				}
-			}
-
-			// Adiciona aresta
-			if i < len(path)-1 {
-				nextID := path[i+1]
-				edgeKey := nodeID + "->" + nextID
-				
-				if !edgeSet[edgeKey] {
-					edge, err := p.getEdgeInfo(db, nodeID, nextID)
-					if err == nil {
-						graph.Edges = append(graph.Edges, edge)
-						edgeSet[edgeKey] = true
-					}
-				}
-			}
-		}
-	}
+// NewPathFinder cria um novo buscador de caminhos sobre a conexão
+// compartilhada da base de rede (SQLite ou PostgreSQL)
+func NewPathFinder(db *sql.DB, dialeto database.Dialect) *PathFinder {
+	return &PathFinder{db: db, dialeto: dialeto}
+}
 
-	return graph, nil
+// ComBaseReceita define a base da Receita, usada para consultar a situação
+// cadastral quando OpcoesCaminhos.ExcluirBaixadas estiver ativo
+func (p *PathFinder) ComBaseReceita(db *sql.DB) *PathFinder {
+	p.dbReceita = db
+	return p
 }
 
 // getNodeInfo obtém informações do nó
-func (p *PathFinder) getNodeInfo(db *sql.DB, nodeID string) (models.Node, error) {
+func (p *PathFinder) getNodeInfo(ctx context.Context, db *sql.DB, nodeID string) (models.Node, error) {
 	// Busca label do nó
-	var label string
-	
-	// Tenta buscar em id_search
-	err := db.QueryRow("SELECT id_descricao FROM id_search WHERE id_descricao LIKE ? LIMIT 1", nodeID+"%").Scan(&label)
-	if err != nil {
-		// Se não encontrar, usa o próprio ID
-		label = nodeID
+	label := nodeID
+
+	// Tenta buscar em id_search (índice FTS5, existe apenas no SQLite);
+	// se não encontrar, usa o próprio ID
+	if !p.dialeto.Postgres {
+		var descricao string
+		err := db.QueryRowContext(ctx, "SELECT id_descricao FROM id_search WHERE id_descricao LIKE ? LIMIT 1", nodeID+"%").Scan(&descricao)
+		if err == nil {
+			label = descricao
+		}
 	}
 
-	return models.Node{
+	node := models.Node{
 		ID:    nodeID,
 		Label: label,
-	}, nil
+	}
+	switch {
+	case strings.HasPrefix(nodeID, "PJ_"):
+		node.Type, node.Icon = "PJ", "empresa"
+	case strings.HasPrefix(nodeID, "PF_"):
+		node.Type, node.Icon = "PF", "pessoa"
+	case strings.HasPrefix(nodeID, "PE_"):
+		node.Type, node.Icon = "PE", "pessoa"
+	}
+	return node, nil
 }
 
 // getEdgeInfo obtém informações da aresta
-func (p *PathFinder) getEdgeInfo(db *sql.DB, from, to string) (models.Edge, error) {
+func (p *PathFinder) getEdgeInfo(ctx context.Context, db *sql.DB, from, to string) (models.Edge, error) {
 	var label string
 	
-	err := db.QueryRow("SELECT descricao FROM ligacao WHERE id1 = ? AND id2 = ? LIMIT 1", from, to).Scan(&label)
+	query := p.dialeto.Query("SELECT descricao FROM {ligacao} WHERE id1 = ? AND id2 = ? LIMIT 1")
+	err := db.QueryRowContext(ctx, query, from, to).Scan(&label)
 	if err != nil {
 		label = "relacionamento"
 	}
@@ -165,27 +101,26 @@ func (p *PathFinder) getEdgeInfo(db *sql.DB, from, to string) (models.Edge, erro
 }
 
 // FindCommonEntities encontra entidades em comum entre duas entidades
-func (p *PathFinder) FindCommonEntities(id1, id2 string) (*models.Graph, error) {
-	db, err := sql.Open("sqlite3", p.dbPath)
-	if err != nil {
-		return nil, err
+func (p *PathFinder) FindCommonEntities(ctx context.Context, id1, id2 string) (*models.Graph, error) {
+	db := p.db
+	if db == nil {
+		return nil, fmt.Errorf("banco de dados de rede não disponível")
 	}
-	defer db.Close()
 
 	// Busca entidades em comum
-	query := `
+	query := p.dialeto.Query(`
 		SELECT DISTINCT l1.id2 as comum
-		FROM ligacao l1
-		JOIN ligacao l2 ON l1.id2 = l2.id2
+		FROM {ligacao} l1
+		JOIN {ligacao} l2 ON l1.id2 = l2.id2
 		WHERE l1.id1 = ? AND l2.id1 = ?
 		UNION
 		SELECT DISTINCT l1.id1 as comum
-		FROM ligacao l1
-		JOIN ligacao l2 ON l1.id1 = l2.id1
+		FROM {ligacao} l1
+		JOIN {ligacao} l2 ON l1.id1 = l2.id1
 		WHERE l1.id2 = ? AND l2.id2 = ?
-	`
+	`)
 
-	rows, err := db.Query(query, id1, id2, id1, id2)
+	rows, err := db.QueryContext(ctx, query, id1, id2, id1, id2)
 	if err != nil {
 		return nil, err
 	}
@@ -197,8 +132,8 @@ func (p *PathFinder) FindCommonEntities(id1, id2 string) (*models.Graph, error)
 	}
 
 	// Adiciona nós principais
-	node1, _ := p.getNodeInfo(db, id1)
-	node2, _ := p.getNodeInfo(db, id2)
+	node1, _ := p.getNodeInfo(ctx, db, id1)
+	node2, _ := p.getNodeInfo(ctx, db, id2)
 	graph.Nodes = append(graph.Nodes, node1, node2)
 
 	// Adiciona entidades em comum
@@ -208,18 +143,22 @@ func (p *PathFinder) FindCommonEntities(id1, id2 string) (*models.Graph, error)
 			continue
 		}
 
-		commonNode, err := p.getNodeInfo(db, commonID)
+		commonNode, err := p.getNodeInfo(ctx, db, commonID)
 		if err != nil {
 			continue
 		}
 		graph.Nodes = append(graph.Nodes, commonNode)
 
 		// Adiciona arestas
-		edge1, _ := p.getEdgeInfo(db, id1, commonID)
-		edge2, _ := p.getEdgeInfo(db, id2, commonID)
+		edge1, _ := p.getEdgeInfo(ctx, db, id1, commonID)
+		edge2, _ := p.getEdgeInfo(ctx, db, id2, commonID)
 		graph.Edges = append(graph.Edges, edge1, edge2)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return graph, nil
 }
 
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -8,7 +9,18 @@ import (
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
 )
 
-// ServeAnalytics retorna estatísticas do grafo
+// statusAnalise traduz os erros das análises em status HTTP: 413 para grafos
+// acima dos limites do algoritmo, 400 para os demais (algoritmo inválido)
+func statusAnalise(err error) int {
+	if errors.Is(err, analytics.ErrGrafoGrande) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// ServeAnalytics retorna estatísticas do grafo. Os parâmetros opcionais
+// centralidade e comunidades (?centralidade=pagerank&comunidades=louvain)
+// incluem a pontuação de cada nó e a partição em comunidades.
 func (h *Handler) ServeAnalytics(c *gin.Context) {
 	var graph models.Graph
 	if err := c.BindJSON(&graph); err != nil {
@@ -19,14 +31,33 @@ func (h *Handler) ServeAnalytics(c *gin.Context) {
 	analyzer := analytics.NewAnalyzer()
 	stats := analyzer.AnalyzeGraph(&graph)
 
+	if algoritmo := c.Query("centralidade"); algoritmo != "" {
+		centralidade, err := analyzer.Centralidade(&graph, algoritmo)
+		if err != nil {
+			c.JSON(statusAnalise(err), gin.H{"error": err.Error()})
+			return
+		}
+		stats.Centralidade = centralidade
+	}
+	if algoritmo := c.Query("comunidades"); algoritmo != "" {
+		comunidades, err := analyzer.Comunidades(&graph, algoritmo)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		stats.Comunidades = comunidades
+	}
+
 	c.JSON(http.StatusOK, stats)
 }
 
-// ServeNosCentrais retorna nós centrais
+// ServeNosCentrais retorna os nós de maior centralidade. O algoritmo vem no
+// corpo ou em ?algoritmo= (padrão betweenness).
 func (h *Handler) ServeNosCentrais(c *gin.Context) {
 	var req struct {
-		Graph models.Graph `json:"graph"`
-		Top   int          `json:"top"`
+		Graph     models.Graph `json:"graph"`
+		Top       int          `json:"top"`
+		Algoritmo string       `json:"algoritmo"`
 	}
 
 	if err := c.BindJSON(&req); err != nil {
@@ -37,14 +68,25 @@ func (h *Handler) ServeNosCentrais(c *gin.Context) {
 	if req.Top == 0 {
 		req.Top = 10
 	}
+	if req.Algoritmo == "" {
+		req.Algoritmo = c.DefaultQuery("algoritmo", analytics.CentralidadeBetweenness)
+	}
 
 	analyzer := analytics.NewAnalyzer()
-	central := analyzer.DetectCentralNodes(&req.Graph, req.Top)
+	resultado, err := analyzer.Centralidade(&req.Graph, req.Algoritmo)
+	if err != nil {
+		c.JSON(statusAnalise(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"centralNodes": central})
+	c.JSON(http.StatusOK, gin.H{
+		"algoritmo":    resultado.Algoritmo,
+		"centralNodes": resultado.Top(req.Top),
+	})
 }
 
-// ServeComunidades detecta comunidades
+// ServeComunidades detecta comunidades. ?algoritmo= escolhe entre louvain
+// (padrão), leiden e componentes.
 func (h *Handler) ServeComunidades(c *gin.Context) {
 	var graph models.Graph
 	if err := c.BindJSON(&graph); err != nil {
@@ -53,9 +95,25 @@ func (h *Handler) ServeComunidades(c *gin.Context) {
 	}
 
 	analyzer := analytics.NewAnalyzer()
-	communities := analyzer.DetectCommunities(&graph)
+	resultado, err := analyzer.Comunidades(&graph, c.DefaultQuery("algoritmo", analytics.ComunidadesLouvain))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// communities mantém o formato anterior: índice -> IDs
+	communities := make(map[int][]string, len(resultado.Comunidades))
+	for _, comunidade := range resultado.Comunidades {
+		communities[comunidade.ID] = comunidade.Nos
+	}
 
-	c.JSON(http.StatusOK, gin.H{"communities": communities})
+	c.JSON(http.StatusOK, gin.H{
+		"algoritmo":    resultado.Algoritmo,
+		"modularidade": resultado.Modularidade,
+		"comunidades":  resultado.Comunidades,
+		"nos":          resultado.Nos,
+		"communities":  communities,
+	})
 }
 
 // ServeCaminhoMaisCurto encontra caminho mais curto
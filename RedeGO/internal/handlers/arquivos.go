@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/arquivos"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/middleware"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/utils"
+)
+
+// tamanhoToken é o número de bytes do sufixo aleatório dos arquivos salvos
+// por usuários remotos sem chave, como na versão Python
+const tamanhoToken = 10
+
+// pastaUsuario retorna a subpasta de arquivos da chave de API da requisição,
+// a do IP para usuários remotos sem chave ou "" (a raiz) para o usuário
+// local. Responde 401 para uma chave desconhecida.
+func (h *Handler) pastaUsuario(c *gin.Context) (string, bool) {
+	chave := middleware.ChaveAPI(c)
+	if chave == "" {
+		if isLocalUser(c) {
+			return "", true
+		}
+		return arquivos.PastaAnonima(c.ClientIP()), true
+	}
+	if !utils.Contains(h.cfg.APIKeys, chave) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Chave de API inválida"})
+		return "", false
+	}
+	return arquivos.PastaChave(chave), true
+}
+
+// statusArquivo traduz os erros do repositório de arquivos em status HTTP
+func statusArquivo(err error) int {
+	switch {
+	case errors.Is(err, arquivos.ErrNomeInvalido), errors.Is(err, arquivos.ErrExtensaoNaoPermitida):
+		return http.StatusBadRequest
+	case errors.Is(err, arquivos.ErrArquivoNaoEncontrado):
+		return http.StatusNotFound
+	case errors.Is(err, arquivos.ErrArquivoGrande):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, arquivos.ErrCotaExcedida):
+		return http.StatusInsufficientStorage
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// nomeComToken indica se o nome segue o padrão nome.<token hex>.json dos
+// arquivos salvos por usuários remotos sem chave
+func nomeComToken(nome string) bool {
+	partes := strings.Split(nome, ".")
+	if len(partes) < 3 || partes[len(partes)-1] != "json" {
+		return false
+	}
+	token := partes[len(partes)-2]
+	if len(token) != 2*tamanhoToken {
+		return false
+	}
+	for _, r := range token {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// usuarioAnonimo indica um usuário remoto sem chave de API
+func usuarioAnonimo(c *gin.Context) bool {
+	return middleware.ChaveAPI(c) == "" && !isLocalUser(c)
+}
+
+// ServeArquivosListar lista os arquivos da pasta do usuário. Usuários remotos
+// sem chave de API não listam: o mesmo IP pode ser de várias pessoas.
+func (h *Handler) ServeArquivosListar(c *gin.Context) {
+	pasta, ok := h.pastaUsuario(c)
+	if !ok {
+		return
+	}
+	if usuarioAnonimo(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Listagem disponível apenas para usuário local ou com chave de API"})
+		return
+	}
+
+	lista, err := h.arquivos.Listar(pasta)
+	if err != nil {
+		c.JSON(statusArquivo(err), gin.H{"error": err.Error()})
+		return
+	}
+	var uso int64
+	for _, a := range lista {
+		uso += a.Tamanho
+	}
+	c.JSON(http.StatusOK, gin.H{"arquivos": lista, "uso": uso, "cota": h.arquivos.Cota()})
+}
+
+// ServeArquivosJSON devolve um arquivo da pasta do usuário. Arquivos que não
+// são JSON só são baixados com ArquivosDownload (opção -download), e os
+// temporários (_temporario*) são apagados após a leitura.
+func (h *Handler) ServeArquivosJSON(c *gin.Context) {
+	pasta, ok := h.pastaUsuario(c)
+	if !ok {
+		return
+	}
+	nome := arquivos.NomeSeguro(c.Param("arquivopath"))
+	ehJSON := strings.EqualFold(filepath.Ext(nome), ".json")
+	if !ehJSON && !h.cfg.ArquivosDownload {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Download de arquivos não habilitado (-download)"})
+		return
+	}
+
+	dados, err := h.arquivos.Ler(pasta, nome)
+	if err != nil {
+		c.JSON(statusArquivo(err), gin.H{"error": err.Error()})
+		return
+	}
+	if strings.HasPrefix(nome, "_temporario") {
+		if err := h.arquivos.Excluir(pasta, nome); err != nil {
+			c.JSON(statusArquivo(err), gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if ehJSON {
+		c.Data(http.StatusOK, "application/json", dados)
+		return
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(nome))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Disposition", "attachment; filename="+nome)
+	c.Data(http.StatusOK, contentType, dados)
+}
+
+// ServeArquivosExcluir apaga um arquivo da pasta do usuário. Usuários remotos
+// sem chave de API não apagam.
+func (h *Handler) ServeArquivosExcluir(c *gin.Context) {
+	pasta, ok := h.pastaUsuario(c)
+	if !ok {
+		return
+	}
+	if usuarioAnonimo(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Exclusão disponível apenas para usuário local ou com chave de API"})
+		return
+	}
+	if err := h.arquivos.Excluir(pasta, arquivos.NomeSeguro(c.Param("arquivopath"))); err != nil {
+		c.JSON(statusArquivo(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ServeArquivosJSONUpload salva o corpo JSON na pasta do usuário. Um nome
+// existente recebe sufixo numérico, exceto com ?reescreve=S. Usuários remotos
+// sem chave recebem um nome com token aleatório e só podem reescrever
+// arquivos com esse padrão.
+func (h *Handler) ServeArquivosJSONUpload(c *gin.Context) {
+	pasta, ok := h.pastaUsuario(c)
+	if !ok {
+		return
+	}
+
+	// Lê no máximo um byte além do limite: o excesso é recusado aqui, sem
+	// manter o corpo inteiro em memória, e o limite exato fica com Salvar
+	if maximo := h.arquivos.TamanhoMaximo(); maximo > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maximo+1)
+	}
+	dados, err := io.ReadAll(c.Request.Body)
+	var grande *http.MaxBytesError
+	if errors.As(err, &grande) {
+		c.JSON(http.StatusRequestEntityTooLarge, models.FileUploadResponse{
+			Mensagem: arquivos.ErrArquivoGrande.Error(),
+		})
+		return
+	}
+	if err != nil || !json.Valid(dados) {
+		c.JSON(http.StatusBadRequest, models.FileUploadResponse{
+			Mensagem: "JSON inválido",
+		})
+		return
+	}
+
+	nome := arquivos.NomeSeguro(c.Param("nomeArquivo"))
+	if nome == "" {
+		c.JSON(http.StatusBadRequest, models.FileUploadResponse{Mensagem: arquivos.ErrNomeInvalido.Error()})
+		return
+	}
+	reescrever := c.Query("reescreve") == "S"
+	anonimo := usuarioAnonimo(c)
+
+	switch {
+	case reescrever && filepath.Ext(nome) != ".json":
+		c.JSON(http.StatusBadRequest, models.FileUploadResponse{Mensagem: arquivos.ErrExtensaoNaoPermitida.Error()})
+		return
+	case reescrever && anonimo && !nomeComToken(nome):
+		c.JSON(http.StatusNotFound, models.FileUploadResponse{
+			Mensagem: "O arquivo " + nome + " não foi encontrado no servidor.",
+		})
+		return
+	case !reescrever:
+		nome = strings.TrimSuffix(nome, ".json")
+		if anonimo {
+			token, err := utils.GenerateToken(tamanhoToken)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, models.FileUploadResponse{Mensagem: err.Error()})
+				return
+			}
+			nome += "." + token
+		}
+		nome += ".json"
+	}
+
+	salvo, err := h.arquivos.Salvar(pasta, nome, dados, reescrever)
+	if err != nil {
+		mensagem := err.Error()
+		if errors.Is(err, arquivos.ErrArquivoNaoEncontrado) {
+			mensagem = "O arquivo " + nome + " não foi encontrado no servidor."
+		}
+		c.JSON(statusArquivo(err), models.FileUploadResponse{Mensagem: mensagem})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.FileUploadResponse{
+		NomeArquivoServidor: salvo,
+	})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/casos"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+)
+
+// storeCasos retorna o repositório de casos ou responde 503 se a base local
+// não estiver configurada
+func (h *Handler) storeCasos(c *gin.Context) *casos.Store {
+	if h.casos == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Base local não configurada (base_local em rede.ini)"})
+		return nil
+	}
+	return h.casos
+}
+
+// idCaso lê o parâmetro :id ou responde 400
+func idCaso(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de caso inválido"})
+		return 0, false
+	}
+	return id, true
+}
+
+// erroCaso traduz os erros do repositório em status HTTP
+func erroCaso(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, casos.ErrCasoNaoEncontrado), errors.Is(err, casos.ErrVersaoNaoEncontrada):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, casos.ErrNomeDuplicado), errors.Is(err, casos.ErrConflito):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, casos.ErrNomeVazio):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ServeCasosListar lista os casos salvos, sem os grafos
+func (h *Handler) ServeCasosListar(c *gin.Context) {
+	store := h.storeCasos(c)
+	if store == nil {
+		return
+	}
+	lista, err := store.Listar(c.Request.Context())
+	if err != nil {
+		erroCaso(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"casos": lista})
+}
+
+// ServeCasosCriar cria um caso a partir de {nome, descricao, grafo}
+func (h *Handler) ServeCasosCriar(c *gin.Context) {
+	store := h.storeCasos(c)
+	if store == nil {
+		return
+	}
+	var req struct {
+		Nome      string        `json:"nome"`
+		Descricao string        `json:"descricao"`
+		Grafo     *models.Graph `json:"grafo"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+
+	caso, err := store.Criar(c.Request.Context(), req.Nome, req.Descricao, req.Grafo)
+	if err != nil {
+		erroCaso(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, caso)
+}
+
+// ServeCasosCarregar retorna o caso com o grafo (?versao=N para versões
+// anteriores)
+func (h *Handler) ServeCasosCarregar(c *gin.Context) {
+	store := h.storeCasos(c)
+	if store == nil {
+		return
+	}
+	id, ok := idCaso(c)
+	if !ok {
+		return
+	}
+	versao, _ := strconv.Atoi(c.Query("versao"))
+
+	caso, err := store.Carregar(c.Request.Context(), id, versao)
+	if err != nil {
+		erroCaso(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, caso)
+}
+
+// ServeCasosAtualizar altera nome, descrição e/ou grafo; um grafo diferente
+// do atual gera nova versão
+func (h *Handler) ServeCasosAtualizar(c *gin.Context) {
+	store := h.storeCasos(c)
+	if store == nil {
+		return
+	}
+	id, ok := idCaso(c)
+	if !ok {
+		return
+	}
+	var req casos.AtualizacaoCaso
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+		return
+	}
+
+	caso, err := store.Atualizar(c.Request.Context(), id, req)
+	if err != nil {
+		erroCaso(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, caso)
+}
+
+// ServeCasosExcluir remove o caso e seu histórico
+func (h *Handler) ServeCasosExcluir(c *gin.Context) {
+	store := h.storeCasos(c)
+	if store == nil {
+		return
+	}
+	id, ok := idCaso(c)
+	if !ok {
+		return
+	}
+	if err := store.Excluir(c.Request.Context(), id); err != nil {
+		erroCaso(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ServeCasosVersoes lista o histórico de versões do caso
+func (h *Handler) ServeCasosVersoes(c *gin.Context) {
+	store := h.storeCasos(c)
+	if store == nil {
+		return
+	}
+	id, ok := idCaso(c)
+	if !ok {
+		return
+	}
+	versoes, err := store.Versoes(c.Request.Context(), id)
+	if err != nil {
+		erroCaso(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versoes": versoes})
+}
+
+// ServeCasosDiff compara duas versões (?de=N&para=M; por padrão a atual com
+// a anterior)
+func (h *Handler) ServeCasosDiff(c *gin.Context) {
+	store := h.storeCasos(c)
+	if store == nil {
+		return
+	}
+	id, ok := idCaso(c)
+	if !ok {
+		return
+	}
+	de, _ := strconv.Atoi(c.Query("de"))
+	para, _ := strconv.Atoi(c.Query("para"))
+
+	diff, err := store.Diff(c.Request.Context(), id, de, para)
+	if err != nil {
+		erroCaso(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
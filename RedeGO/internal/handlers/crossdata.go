@@ -1,18 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/crossdata"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/cpfcnpj"
 )
 
 // ServeCrossDataEmpresasPorCPF retorna todas as empresas de um CPF
 func (h *Handler) ServeCrossDataEmpresasPorCPF(c *gin.Context) {
 	cpf := c.Param("cpf")
 	
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.EmpresasPorCPF(cpf)
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	results, err := engine.EmpresasPorCPF(ctx, cpf)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -28,10 +35,18 @@ func (h *Handler) ServeCrossDataEmpresasPorCPF(c *gin.Context) {
 
 // ServeCrossDataSociosPorCNPJ retorna todos os sócios de um CNPJ
 func (h *Handler) ServeCrossDataSociosPorCNPJ(c *gin.Context) {
-	cnpj := c.Param("cnpj")
+	cnpj := cpfcnpj.LimparCNPJ(c.Param("cnpj"))
+
+	asOf, ok := h.parametroAsOf(c)
+	if !ok {
+		return
+	}
 	
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.SociosPorCNPJ(cnpj)
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	results, err := engine.SociosPorCNPJ(ctx, cnpj, asOf)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -40,6 +55,7 @@ func (h *Handler) ServeCrossDataSociosPorCNPJ(c *gin.Context) {
 	
 	c.JSON(http.StatusOK, gin.H{
 		"cnpj":   cnpj,
+		"as_of":  asOf,
 		"total":  len(results),
 		"socios": results,
 	})
@@ -57,8 +73,12 @@ func (h *Handler) ServeCrossDataSociosEmComum(c *gin.Context) {
 		return
 	}
 	
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.SociosEmComum(req.CNPJ1, req.CNPJ2)
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	req.CNPJ1, req.CNPJ2 = cpfcnpj.LimparCNPJ(req.CNPJ1), cpfcnpj.LimparCNPJ(req.CNPJ2)
+	results, err := engine.SociosEmComum(ctx, req.CNPJ1, req.CNPJ2)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -77,8 +97,11 @@ func (h *Handler) ServeCrossDataSociosEmComum(c *gin.Context) {
 func (h *Handler) ServeCrossDataRedeEmpresasPessoa(c *gin.Context) {
 	cpf := c.Param("cpf")
 	
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.RedeEmpresasPessoa(cpf)
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	results, err := engine.RedeEmpresasPessoa(ctx, cpf)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -92,22 +115,45 @@ func (h *Handler) ServeCrossDataRedeEmpresasPessoa(c *gin.Context) {
 	})
 }
 
-// ServeCrossDataEmpresasMesmoEndereco retorna empresas no mesmo endereço
+// ServeCrossDataEmpresasMesmoEndereco retorna empresas no mesmo endereço,
+// comparado pela forma normalizada; granularidade "sala" exige também o
+// mesmo complemento
 func (h *Handler) ServeCrossDataEmpresasMesmoEndereco(c *gin.Context) {
 	var req struct {
-		CEP        string `json:"cep"`
-		Logradouro string `json:"logradouro"`
-		Numero     string `json:"numero"`
+		CEP           string `json:"cep"`
+		Logradouro    string `json:"logradouro"`
+		Numero        string `json:"numero"`
+		Complemento   string `json:"complemento,omitempty"`
+		Granularidade string `json:"granularidade,omitempty"` // "predio" (padrão) ou "sala"
 	}
 	
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
 		return
 	}
+	granularidade, err := endereco.ValidarGranularidade(req.Granularidade)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Granularidade = granularidade
 	
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.EmpresasMesmoEndereco(req.CEP, req.Logradouro, req.Numero)
-	
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect()).
+		ComEnderecos(database.GetDBEndereco())
+	results, err := engine.EmpresasMesmoEndereco(ctx, endereco.Endereco{
+		CEP:         req.CEP,
+		Logradouro:  req.Logradouro,
+		Numero:      req.Numero,
+		Complemento: req.Complemento,
+	}, granularidade)
+	
+	if errors.Is(err, crossdata.ErrEnderecoIncompleto) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -132,8 +178,11 @@ func (h *Handler) ServeCrossDataEmpresasMesmoContato(c *gin.Context) {
 		return
 	}
 	
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.EmpresasMesmoContato(req.Email, req.Telefone)
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	results, err := engine.EmpresasMesmoContato(ctx, req.Email, req.Telefone)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -149,8 +198,11 @@ func (h *Handler) ServeCrossDataEmpresasMesmoContato(c *gin.Context) {
 
 // ServeCrossDataRepresentantesLegais retorna menores com representantes
 func (h *Handler) ServeCrossDataRepresentantesLegais(c *gin.Context) {
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.RepresentantesLegais()
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	results, err := engine.RepresentantesLegais(ctx)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -165,8 +217,11 @@ func (h *Handler) ServeCrossDataRepresentantesLegais(c *gin.Context) {
 
 // ServeCrossDataEmpresasEstrangeiras retorna empresas estrangeiras
 func (h *Handler) ServeCrossDataEmpresasEstrangeiras(c *gin.Context) {
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.EmpresasEstrangeiras()
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	results, err := engine.EmpresasEstrangeiras(ctx)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -181,8 +236,11 @@ func (h *Handler) ServeCrossDataEmpresasEstrangeiras(c *gin.Context) {
 
 // ServeCrossDataSociosEstrangeiros retorna sócios estrangeiros
 func (h *Handler) ServeCrossDataSociosEstrangeiros(c *gin.Context) {
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.SociosEstrangeiros()
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	results, err := engine.SociosEstrangeiros(ctx)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -199,8 +257,11 @@ func (h *Handler) ServeCrossDataSociosEstrangeiros(c *gin.Context) {
 func (h *Handler) ServeCrossDataTimelinePessoa(c *gin.Context) {
 	cpf := c.Param("cpf")
 	
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.TimelinePessoa(cpf)
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	results, err := engine.TimelinePessoa(ctx, cpf)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -216,8 +277,11 @@ func (h *Handler) ServeCrossDataTimelinePessoa(c *gin.Context) {
 
 // ServeCrossDataSociosEmpresasBaixadas retorna sócios com empresas baixadas
 func (h *Handler) ServeCrossDataSociosEmpresasBaixadas(c *gin.Context) {
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	results, err := engine.SociosEmpresasBaixadas()
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	results, err := engine.SociosEmpresasBaixadas(ctx)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -232,10 +296,13 @@ func (h *Handler) ServeCrossDataSociosEmpresasBaixadas(c *gin.Context) {
 
 // ServeCrossDataDadosCompletos retorna dados completos de empresa SEM CENSURA
 func (h *Handler) ServeCrossDataDadosCompletos(c *gin.Context) {
-	cnpj := c.Param("cnpj")
+	cnpj := cpfcnpj.LimparCNPJ(c.Param("cnpj"))
 	
-	engine := crossdata.NewCrossDataEngine("bases/cnpj.db", "bases/rede.db")
-	result, err := engine.DadosCompletosEmpresa(cnpj)
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	engine := crossdata.NewCrossDataEngine(database.GetDBReceita(), database.NewDialect())
+	result, err := engine.DadosCompletosEmpresa(ctx, cnpj)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -72,3 +73,41 @@ func (h *Handler) ServeExportCSV(c *gin.Context) {
 	c.Header("Content-Disposition", "attachment; filename="+filename)
 	c.Data(http.StatusOK, "text/csv", data)
 }
+
+// formatosGrafo são os formatos de grafo aceitos por /rede/dadosemarquivo,
+// indexados pela extensão do arquivo gerado
+var formatosGrafo = map[string]struct {
+	exportar    func(*models.Graph) ([]byte, error)
+	contentType string
+}{
+	"anx":     {export.NewI2Exporter().ExportGraph, "application/xml"},
+	"graphml": {export.NewGraphMLExporter().ExportGraph, "application/graphml+xml"},
+	"gexf":    {export.NewGEXFExporter().ExportGraph, "application/xml"},
+	"cyjs":    {export.NewCytoscapeExporter().ExportGraph, "application/json"},
+}
+
+// lerGrafoExportacao lê o grafo enviado para exportação: campo de formulário
+// "data" com o JSON (frontend), arquivo "data" (curl -F data=@grafo.json) ou
+// o próprio corpo JSON da requisição
+func lerGrafoExportacao(c *gin.Context) (*models.Graph, error) {
+	var dados models.ExportRequest
+
+	if texto := c.PostForm("data"); texto != "" {
+		if err := json.Unmarshal([]byte(texto), &dados); err != nil {
+			return nil, err
+		}
+	} else if arquivo, err := c.FormFile("data"); err == nil {
+		f, err := arquivo.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := json.NewDecoder(f).Decode(&dados); err != nil {
+			return nil, err
+		}
+	} else if err := c.ShouldBindJSON(&dados); err != nil {
+		return nil, err
+	}
+
+	return &models.Graph{Nodes: dados.Nodes, Edges: dados.Edges}, nil
+}
@@ -1,19 +1,63 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/forensics"
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/cpfcnpj"
 )
 
+// modeloRisco retorna o modelo do parâmetro ?modelo=<nome> (pasta de
+// modelos por caso) ou o configurado em modelo_risco. Os arquivos são lidos a
+// cada requisição, para que ajustes valham sem reiniciar o servidor.
+func (h *Handler) modeloRisco(c *gin.Context) (*forensics.ModeloRisco, error) {
+	if nome := c.Query("modelo"); nome != "" {
+		return forensics.CarregarModeloCaso(h.cfg.PastaModelosRisco, nome)
+	}
+	return forensics.CarregarModeloRisco(h.cfg.ModeloRisco)
+}
+
+// investigador cria o investigador com o modelo de risco da requisição. Se o
+// modelo for inválido, responde 400 e retorna nil.
+func (h *Handler) investigador(c *gin.Context) *forensics.Investigator {
+	modelo, err := h.modeloRisco(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil
+	}
+	return forensics.NewInvestigator(database.GetDBReceita(), database.NewDialect()).
+		ComModelo(modelo).
+		ComEnderecos(database.GetDBEndereco())
+}
+
+// ServeForensicsModeloRisco retorna o modelo de risco em uso (ou o de ?modelo=)
+func (h *Handler) ServeForensicsModeloRisco(c *gin.Context) {
+	modelo, err := h.modeloRisco(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, modelo)
+}
+
 // ServeForensicsInvestigatePerson perfil completo de suspeito
 func (h *Handler) ServeForensicsInvestigatePerson(c *gin.Context) {
 	cpf := c.Param("cpf")
-	
-	inv := forensics.NewInvestigator("bases/cnpj.db", "bases/rede.db")
-	profile, err := inv.InvestigatePerson(cpf)
+
+	inv := h.investigador(c)
+	if inv == nil {
+		return
+	}
+
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	profile, err := inv.InvestigatePerson(ctx, cpf)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -23,22 +67,71 @@ func (h *Handler) ServeForensicsInvestigatePerson(c *gin.Context) {
 	c.JSON(http.StatusOK, profile)
 }
 
-// ServeForensicsShellCompanies detecta empresas de fachada
+// ServeForensicsInvestigateCompany perfil de risco de empresa
+func (h *Handler) ServeForensicsInvestigateCompany(c *gin.Context) {
+	cnpj := cpfcnpj.LimparCNPJ(c.Param("cnpj"))
+
+	inv := h.investigador(c)
+	if inv == nil {
+		return
+	}
+
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	profile, err := inv.InvestigateCompany(ctx, cnpj)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// ServeForensicsShellCompanies detecta empresas de fachada: endereços
+// normalizados com muitas empresas, por prédio ou por sala
+// (?granularidade=sala), ordenados por densidade, baixadas e aberturas recentes
 func (h *Handler) ServeForensicsShellCompanies(c *gin.Context) {
 	minStr := c.DefaultQuery("min_empresas", "10")
 	minEmpresas, _ := strconv.Atoi(minStr)
+	limite, _ := strconv.Atoi(c.Query("limite"))
+
+	granularidade, err := endereco.ValidarGranularidade(c.Query("granularidade"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	
-	inv := forensics.NewInvestigator("bases/cnpj.db", "bases/rede.db")
-	clusters, err := inv.DetectShellCompanies(minEmpresas)
+	inv := h.investigador(c)
+	if inv == nil {
+		return
+	}
+
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	clusters, err := inv.DetectShellCompanies(ctx, endereco.ConsultaClusters{
+		Granularidade: granularidade,
+		MinEmpresas:   minEmpresas,
+		UF:            c.Query("uf"),
+		Municipio:     c.Query("municipio"),
+		Limite:        limite,
+	})
 	
+	if errors.Is(err, endereco.ErrBaseAusente) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
-		"total":    len(clusters),
-		"clusters": clusters,
+		"total":         len(clusters),
+		"granularidade": granularidade,
+		"clusters":      clusters,
 	})
 }
 
@@ -54,8 +147,15 @@ func (h *Handler) ServeForensicsFrontmen(c *gin.Context) {
 		return
 	}
 	
-	inv := forensics.NewInvestigator("bases/cnpj.db", "bases/rede.db")
-	cluster, err := inv.DetectFrontmen(req.Criterio, req.Valor)
+	inv := h.investigador(c)
+	if inv == nil {
+		return
+	}
+
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	cluster, err := inv.DetectFrontmen(ctx, req.Criterio, req.Valor)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -71,8 +171,11 @@ func (h *Handler) ServeForensicsMassRegistration(c *gin.Context) {
 	diasStr := c.DefaultQuery("dias", "30")
 	dias, _ := strconv.Atoi(diasStr)
 	
-	inv := forensics.NewInvestigator("bases/cnpj.db", "bases/rede.db")
-	results, err := inv.DetectMassRegistration(cpf, dias)
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	inv := forensics.NewInvestigator(database.GetDBReceita(), database.NewDialect())
+	results, err := inv.DetectMassRegistration(ctx, cpf, dias)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -88,12 +191,15 @@ func (h *Handler) ServeForensicsMassRegistration(c *gin.Context) {
 
 // ServeForensicsOwnershipChain rastreia cadeia de controle
 func (h *Handler) ServeForensicsOwnershipChain(c *gin.Context) {
-	cnpj := c.Param("cnpj")
+	cnpj := cpfcnpj.LimparCNPJ(c.Param("cnpj"))
 	nivelStr := c.DefaultQuery("max_nivel", "3")
 	maxNivel, _ := strconv.Atoi(nivelStr)
 	
-	inv := forensics.NewInvestigator("bases/cnpj.db", "bases/rede.db")
-	chain, err := inv.TraceOwnershipChain(cnpj, maxNivel)
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	inv := forensics.NewInvestigator(database.GetDBReceita(), database.NewDialect())
+	chain, err := inv.TraceOwnershipChain(ctx, cnpj, maxNivel)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -110,8 +216,15 @@ func (h *Handler) ServeForensicsOwnershipChain(c *gin.Context) {
 
 // ServeForensicsSuspiciousPatterns detecta padrões suspeitos
 func (h *Handler) ServeForensicsSuspiciousPatterns(c *gin.Context) {
-	inv := forensics.NewInvestigator("bases/cnpj.db", "bases/rede.db")
-	patterns, err := inv.DetectSuspiciousPatterns()
+	inv := h.investigador(c)
+	if inv == nil {
+		return
+	}
+
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	patterns, err := inv.DetectSuspiciousPatterns(ctx)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -4,30 +4,36 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/graph"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
 )
 
-// ServeCaminhos encontra caminhos entre duas entidades
+// ServeCaminhos encontra os menores caminhos entre duas entidades. As opções
+// (maxDepth, maxCaminhos, grauMaximo, excluirBaixadas, ponderado, pesos) vão
+// no mesmo JSON de from e to.
 func (h *Handler) ServeCaminhos(c *gin.Context) {
 	var req struct {
-		From     string `json:"from"`
-		To       string `json:"to"`
-		MaxDepth int    `json:"maxDepth"`
+		From string `json:"from"`
+		To   string `json:"to"`
+		graph.OpcoesCaminhos
 	}
 
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
 		return
 	}
-
-	if req.MaxDepth == 0 {
-		req.MaxDepth = 5
+	if req.From == "" || req.To == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from e to são obrigatórios"})
+		return
 	}
 
-	pathFinder := graph.NewPathFinder("bases/rede.db")
-	result, err := pathFinder.FindPaths(req.From, req.To, req.MaxDepth)
-	
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	pathFinder := graph.NewPathFinder(database.GetDBRede(), database.NewDialect()).
+		ComBaseReceita(database.GetDBReceita())
+	result, err := pathFinder.FindPaths(ctx, req.From, req.To, req.OpcoesCaminhos)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -48,8 +54,11 @@ func (h *Handler) ServeEntidadesComuns(c *gin.Context) {
 		return
 	}
 
-	pathFinder := graph.NewPathFinder("bases/rede.db")
-	result, err := pathFinder.FindCommonEntities(req.ID1, req.ID2)
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	pathFinder := graph.NewPathFinder(database.GetDBRede(), database.NewDialect())
+	result, err := pathFinder.FindCommonEntities(ctx, req.ID1, req.ID2)
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
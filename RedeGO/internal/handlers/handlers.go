@@ -1,15 +1,24 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/arquivos"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/casos"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/config"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/importer"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/middleware"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/search"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/services"
 )
 
@@ -17,14 +26,29 @@ import (
 type Handler struct {
 	cfg         *config.Config
 	redeService *services.RedeService
+	casos       *casos.Store // nil sem base_local
+	arquivos    *arquivos.Store
+	uso         *middleware.Uso // nil sem limites de requisição
+	fonte       importer.FonteDados
 }
 
+// validadeReferencias é por quanto tempo a lista de referências da fonte fica
+// guardada entre consultas a /rede/dados_publicos_disponivel
+const validadeReferencias = 30 * time.Minute
+
 // NewHandler cria uma nova instância do handler
 func NewHandler(cfg *config.Config) *Handler {
-	return &Handler{
+	h := &Handler{
 		cfg:         cfg,
 		redeService: services.NewRedeService(cfg),
+		arquivos:    arquivos.NewStore(cfg.PastaArquivos).ComCota(cfg.CotaArquivosMB << 20),
+		fonte: importer.NovaFonteCache(importer.NovaFonte(cfg.FonteDadosPublicos,
+			&http.Client{Timeout: 15 * time.Second}), validadeReferencias),
+	}
+	if db := database.GetDBLocal(); db != nil {
+		h.casos = casos.NewStore(db)
 	}
+	return h
 }
 
 // ServeRedeJSONCNPJ retorna dados da rede em formato JSON
@@ -53,7 +77,15 @@ func (h *Handler) ServeRedeJSONCNPJ(c *gin.Context) {
 		criterioCaminhos = strings.TrimPrefix(tipo, "caminhos-")
 	}
 
-	graph, err := h.redeService.CamadasRede(camada, listaIDs, "", criterioCaminhos)
+	asOf, ok := h.parametroAsOf(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	graph, err := h.redeService.CamadasRede(ctx, camada, listaIDs, criterioCaminhos, asOf)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -75,7 +107,15 @@ func (h *Handler) ServeDadosDetalhes(c *gin.Context) {
 		}
 	}
 
-	dados := h.redeService.GetDadosCNPJ(cpfcnpj)
+	asOf, ok := h.parametroAsOf(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	dados := h.redeService.GetDadosCNPJ(ctx, cpfcnpj, asOf)
 	if dados == nil {
 		c.JSON(http.StatusOK, gin.H{})
 		return
@@ -84,71 +124,34 @@ func (h *Handler) ServeDadosDetalhes(c *gin.Context) {
 	c.JSON(http.StatusOK, dados)
 }
 
-// ServeBuscaPorNome busca empresas ou sócios por nome
+// ServeBuscaPorNome busca empresas e pessoas por nome ou CNPJ, em ordem de
+// relevância. Aceita paginação (pagina, por_pagina ou limite), filtros por
+// tipo, uf, cnae e situacao (valores separados por vírgula) e facetas=S.
 func (h *Handler) ServeBuscaPorNome(c *gin.Context) {
-	nome := c.Query("q")
-	limiteStr := c.Query("limite")
-
-	limite := 10
-	if limiteStr != "" {
-		limite, _ = strconv.Atoi(limiteStr)
+	consulta := search.Consulta{
+		Texto:    c.Query("q"),
+		Tipos:    parametroLista(c, "tipo"),
+		UF:       parametroLista(c, "uf"),
+		CNAE:     parametroLista(c, "cnae"),
+		Situacao: parametroLista(c, "situacao"),
+		Facetas:  strings.EqualFold(c.Query("facetas"), "S"),
 	}
-
-	if nome == "" {
+	if consulta.Texto == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Parâmetro 'q' é obrigatório"})
 		return
 	}
+	consulta.Pagina, _ = strconv.Atoi(c.Query("pagina"))
+	consulta.PorPagina, _ = strconv.Atoi(c.DefaultQuery("por_pagina", c.Query("limite")))
 
-	results, err := h.redeService.BuscaPorNome(nome, limite)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, results)
-}
-
-// ServeArquivosJSON serve arquivos JSON salvos
-func (h *Handler) ServeArquivosJSON(c *gin.Context) {
-	arquivopath := c.Param("arquivopath")
-	
-	// Implementação simplificada - na versão completa, adicionar lógica de segurança
-	c.File(fmt.Sprintf("%s/%s", h.cfg.PastaArquivos, arquivopath))
-}
-
-// ServeArquivosJSONUpload faz upload de arquivos JSON
-func (h *Handler) ServeArquivosJSONUpload(c *gin.Context) {
-	nomeArquivo := c.Param("nomeArquivo")
-
-	var data interface{}
-	if err := c.BindJSON(&data); err != nil {
-		c.JSON(http.StatusBadRequest, models.FileUploadResponse{
-			Mensagem: "JSON inválido",
-		})
-		return
-	}
-
-	// Verifica tamanho
-	jsonBytes, _ := json.Marshal(data)
-	if len(jsonBytes) > 100000 {
-		c.JSON(http.StatusOK, models.FileUploadResponse{
-			Mensagem: "O arquivo é muito grande e não foi salvo",
-		})
-		return
-	}
-
-	// Salva arquivo (implementação simplificada)
-	c.JSON(http.StatusOK, models.FileUploadResponse{
-		NomeArquivoServidor: nomeArquivo,
-	})
+	h.responderBusca(c, consulta)
 }
 
 // ServeDadosEmArquivo exporta dados para Excel ou outros formatos
 func (h *Handler) ServeDadosEmArquivo(c *gin.Context) {
 	formato := c.Param("formato")
 
-	var dados models.ExportRequest
-	if err := c.Bind(&dados); err != nil {
+	graph, err := lerGrafoExportacao(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
 		return
 	}
@@ -157,15 +160,21 @@ func (h *Handler) ServeDadosEmArquivo(c *gin.Context) {
 	case "xlsx":
 		// Implementar exportação para Excel
 		c.JSON(http.StatusOK, gin.H{"message": "Exportação Excel não implementada ainda"})
-	case "anx":
-		// Implementar exportação para i2
-		c.JSON(http.StatusOK, gin.H{"message": "Exportação i2 não implementada ainda"})
+	case "anx", "graphml", "gexf", "cyjs":
+		formatoGrafo := formatosGrafo[formato]
+		data, err := formatoGrafo.exportar(graph)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename=rede-cnpj."+formato)
+		c.Data(http.StatusOK, formatoGrafo.contentType, data)
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato não suportado"})
 	}
 }
 
-// ServeMapa gera mapa com endereços
+// ServeMapa retorna em GeoJSON a localização das empresas do grafo
 func (h *Handler) ServeMapa(c *gin.Context) {
 	var req models.MapaRequest
 	if err := c.Bind(&req); err != nil {
@@ -173,31 +182,129 @@ func (h *Handler) ServeMapa(c *gin.Context) {
 		return
 	}
 
-	// Implementação simplificada
-	c.JSON(http.StatusOK, gin.H{"message": "Geração de mapa não implementada ainda"})
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	mapa, err := h.redeService.Mapa(ctx, req.Nodes)
+	if errors.Is(err, endereco.ErrBaseAusente) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, mapa)
 }
 
-// ServeDadosPublicosDisponivel verifica dados públicos disponíveis
+// ServeDadosPublicosDisponivel informa a referência em uso na base e as
+// disponíveis na fonte configurada (site da Receita, espelho ou diretório).
+// A lista da fonte fica guardada por validadeReferencias.
 func (h *Handler) ServeDadosPublicosDisponivel(c *gin.Context) {
-	// Implementação simplificada - na versão completa, fazer scraping do site da Receita
-	c.JSON(http.StatusOK, models.DadosPublicosResponse{
-		AnoMesSendoUsado: "2024-10",
-		AnoMesDisponivel: "2024-10",
-		URL:              "https://arquivos.receitafederal.gov.br/cnpj/dados_abertos_cnpj/",
-	})
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	resp := models.DadosPublicosResponse{
+		AnoMesSendoUsado: h.redeService.ReferenciaEmUso(ctx),
+		URL:              h.fonte.Origem(""),
+	}
+
+	refs, err := h.fonte.Referencias(ctx)
+	switch {
+	case err != nil:
+		resp.Mensagem = fmt.Sprintf("Não foi possível consultar %s: %v", resp.URL, err)
+	case len(refs) == 0:
+		resp.Mensagem = fmt.Sprintf("Nenhuma referência encontrada em %s", resp.URL)
+	default:
+		resp.AnoMesDisponivel = refs[len(refs)-1]
+		resp.AnoMesesDisponiveis = refs
+		if resp.AnoMesDisponivel > resp.AnoMesSendoUsado {
+			resp.Mensagem = fmt.Sprintf("Há dados mais recentes disponíveis (%s)", resp.AnoMesDisponivel)
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ComUso define os contadores de uso por chave exibidos em /rede/api/uso
+func (h *Handler) ComUso(uso *middleware.Uso) *Handler {
+	h.uso = uso
+	return h
 }
 
-// ServeAPIStatus retorna status da API
+// ServeAPIStatus retorna status da API e o resumo de qualidade da última
+// importação, quando disponível
 func (h *Handler) ServeAPIStatus(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"status":  "ok",
 		"version": "1.0.0",
 		"message": "RedeCNPJ API em Go",
-	})
+	}
+
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	qualidade, err := h.redeService.UltimaQualidade(ctx)
+	if err != nil {
+		resp["qualidade_erro"] = err.Error()
+	} else if qualidade != nil {
+		resp["qualidade"] = qualidade
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ServeAPIUso retorna os contadores de uso da chave de API da requisição; o
+// usuário local vê os de todas as chaves
+func (h *Handler) ServeAPIUso(c *gin.Context) {
+	if h.uso == nil {
+		c.JSON(http.StatusOK, gin.H{"uso": []middleware.ContagemUso{}})
+		return
+	}
+	resp := gin.H{"uso": h.uso.Da(middleware.ChaveAPI(c))}
+	if chave := middleware.ChaveAPI(c); chave != "" {
+		resp["chave"] = middleware.MascararChave(chave)
+	}
+	if isLocalUser(c) {
+		resp["chaves"] = h.uso.Todas()
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // Funções auxiliares
 
+// contextoConsulta retorna o contexto da requisição limitado por
+// TempoMaximoConsulta; é cancelado se o cliente desconectar
+func (h *Handler) contextoConsulta(c *gin.Context) (context.Context, context.CancelFunc) {
+	return services.ContextoConsulta(c.Request.Context(), h.cfg)
+}
+
+// parametroAsOf lê o parâmetro as_of (referência AAAA-MM). Se for inválido,
+// responde 400 e retorna ok = false.
+func (h *Handler) parametroAsOf(c *gin.Context) (string, bool) {
+	asOf := c.Query("as_of")
+	if asOf == "" {
+		return "", true
+	}
+	if err := database.ValidarReferencia(asOf); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return asOf, true
+}
+
+// parametroLista junta os valores do parâmetro, repetido ou separado por
+// vírgulas
+func parametroLista(c *gin.Context, nome string) []string {
+	var valores []string
+	for _, v := range c.QueryArray(nome) {
+		valores = append(valores, strings.Split(v, ",")...)
+	}
+	return valores
+}
+
 func isLocalUser(c *gin.Context) bool {
-	return c.ClientIP() == "127.0.0.1" || c.ClientIP() == "::1"
+	return middleware.UsuarioLocal(c)
 }
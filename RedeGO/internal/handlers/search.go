@@ -1,55 +1,67 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/search"
 )
 
-// ServeBuscaAvancada busca avançada com FTS5
+// ServeBuscaAvancada é a busca ranqueada de GET /rede/busca com a consulta
+// no corpo JSON. Os curingas (useGlob) e o sorteio (randomTest) da busca
+// antiga não existem mais: cada termo já casa como prefixo.
 func (h *Handler) ServeBuscaAvancada(c *gin.Context) {
 	var req struct {
-		Query      string `json:"query"`
-		Limit      int    `json:"limit"`
-		UseGlob    bool   `json:"useGlob"`
-		RandomTest bool   `json:"randomTest"`
+		Query     string   `json:"query"`
+		Limit     int      `json:"limit"`
+		PorPagina int      `json:"por_pagina"`
+		Pagina    int      `json:"pagina"`
+		Tipo      []string `json:"tipo"`
+		UF        []string `json:"uf"`
+		CNAE      []string `json:"cnae"`
+		Situacao  []string `json:"situacao"`
+		Facetas   bool     `json:"facetas"`
 	}
 
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
 		return
 	}
-
-	// Limite padrão
-	if req.Limit == 0 {
-		req.Limit = 10
+	if req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Campo 'query' é obrigatório"})
+		return
 	}
-	if req.Limit > 100 {
-		req.Limit = 100
+	if req.PorPagina == 0 {
+		req.PorPagina = req.Limit
 	}
 
-	// Caminho do banco de busca
-	searchDB := "bases/rede_search.db"
-
-	// Cria buscador
-	searcher := search.NewAdvancedSearch(searchDB)
-
-	// Executa busca
-	results, err := searcher.Search(search.SearchOptions{
-		Query:      req.Query,
-		Limit:      req.Limit,
-		UseGlob:    req.UseGlob,
-		RandomTest: req.RandomTest,
+	h.responderBusca(c, search.Consulta{
+		Texto:     req.Query,
+		Tipos:     req.Tipo,
+		UF:        req.UF,
+		CNAE:      req.CNAE,
+		Situacao:  req.Situacao,
+		Pagina:    req.Pagina,
+		PorPagina: req.PorPagina,
+		Facetas:   req.Facetas,
 	})
+}
 
+// responderBusca executa a consulta e responde com a página de resultados
+func (h *Handler) responderBusca(c *gin.Context, consulta search.Consulta) {
+	ctx, cancel := h.contextoConsulta(c)
+	defer cancel()
+
+	resultado, err := h.redeService.Buscar(ctx, consulta)
+	if errors.Is(err, search.ErrConsultaVazia) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"results": results,
-		"count":   len(results),
-	})
+	c.JSON(http.StatusOK, resultado)
 }
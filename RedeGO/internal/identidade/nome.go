@@ -0,0 +1,165 @@
+// Package identidade resolve a identidade de pessoas físicas sócias. A
+// Receita mascara o CPF (***123456**), então o ID PF_<cpf>-<nome> da tabela
+// ligacao junta homônimos e separa a mesma pessoa grafada de formas
+// diferentes. O pacote compara registros pelos dígitos visíveis do CPF, pela
+// semelhança do nome, pela faixa etária e pelas empresas e endereços em comum.
+package identidade
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/utils"
+)
+
+// particulas são ignoradas na comparação de nomes
+var particulas = map[string]bool{
+	"DA": true, "DE": true, "DI": true, "DO": true, "DU": true,
+	"DAS": true, "DOS": true, "E": true,
+}
+
+// NormalizarNome remove acentos, pontuação e espaços repetidos e converte
+// para maiúsculas
+func NormalizarNome(nome string) string {
+	nome = utils.RemoveAcentos(strings.ToUpper(nome))
+	return strings.Join(strings.FieldsFunc(nome, func(r rune) bool {
+		return r < 'A' || r > 'Z'
+	}), " ")
+}
+
+// tokensNome separa o nome normalizado em palavras, sem as partículas
+func tokensNome(nome string) []string {
+	var tokens []string
+	for _, t := range strings.Fields(NormalizarNome(nome)) {
+		if !particulas[t] {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// regrasFoneticas aproximam a grafia da pronúncia em português, na ordem
+// em que são aplicadas
+var regrasFoneticas = []struct {
+	padrao *regexp.Regexp
+	troca  string
+}{
+	{regexp.MustCompile(`PH`), "F"},
+	{regexp.MustCompile(`TH`), "T"},
+	{regexp.MustCompile(`[CS]H`), "X"},
+	{regexp.MustCompile(`LH`), "L"},
+	{regexp.MustCompile(`NH`), "N"},
+	{regexp.MustCompile(`Y`), "I"},
+	{regexp.MustCompile(`W`), "V"},
+	{regexp.MustCompile(`QU([EI])`), "K$1"},
+	{regexp.MustCompile(`GU([EI])`), "G$1"},
+	{regexp.MustCompile(`G([EI])`), "J$1"},
+	{regexp.MustCompile(`C([EI])`), "S$1"},
+	{regexp.MustCompile(`[CQ]`), "K"},
+	{regexp.MustCompile(`Z`), "S"},
+	{regexp.MustCompile(`H`), ""},
+	{regexp.MustCompile(`N$`), "M"},
+}
+
+// Fonetica retorna a chave fonética de uma palavra: grafias com a mesma
+// pronúncia (SOUSA e SOUZA, FELIPE e PHELIPE, THIAGO e TIAGO) têm a mesma
+// chave
+func Fonetica(palavra string) string {
+	s := strings.ReplaceAll(strings.ToUpper(palavra), "Ç", "S")
+	s = strings.ReplaceAll(NormalizarNome(s), " ", "")
+	for _, r := range regrasFoneticas {
+		s = r.padrao.ReplaceAllString(s, r.troca)
+	}
+
+	// Letras repetidas (SS, RR, LL) contam uma vez
+	var b strings.Builder
+	var anterior rune
+	for _, c := range s {
+		if c != anterior {
+			b.WriteRune(c)
+		}
+		anterior = c
+	}
+	return b.String()
+}
+
+// JaroWinkler retorna a similaridade de Jaro-Winkler entre duas strings,
+// de 0 (nada em comum) a 1 (iguais), favorecendo prefixos comuns
+func JaroWinkler(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	janela := max(len(ra), len(rb))/2 - 1
+	if janela < 0 {
+		janela = 0
+	}
+	usadosA := make([]bool, len(ra))
+	usadosB := make([]bool, len(rb))
+	coincidencias := 0
+	for i := range ra {
+		for j := max(0, i-janela); j < min(len(rb), i+janela+1); j++ {
+			if !usadosB[j] && ra[i] == rb[j] {
+				usadosA[i], usadosB[j] = true, true
+				coincidencias++
+				break
+			}
+		}
+	}
+	if coincidencias == 0 {
+		return 0
+	}
+
+	transposicoes, k := 0, 0
+	for i := range ra {
+		if !usadosA[i] {
+			continue
+		}
+		for !usadosB[k] {
+			k++
+		}
+		if ra[i] != rb[k] {
+			transposicoes++
+		}
+		k++
+	}
+
+	m := float64(coincidencias)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transposicoes)/2)/m) / 3
+
+	prefixo := 0
+	for prefixo < min(4, len(ra), len(rb)) && ra[prefixo] == rb[prefixo] {
+		prefixo++
+	}
+	return jaro + float64(prefixo)*0.1*(1-jaro)
+}
+
+// SimilaridadeNome compara dois nomes de pessoa, de 0 a 1: a média entre o
+// Jaro-Winkler dos nomes normalizados (sem partículas) e a fração de
+// palavras com a mesma chave fonética
+func SimilaridadeNome(a, b string) float64 {
+	ta, tb := tokensNome(a), tokensNome(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	jw := JaroWinkler(strings.Join(ta, " "), strings.Join(tb, " "))
+
+	chaves := make(map[string]int, len(tb))
+	for _, t := range tb {
+		chaves[Fonetica(t)]++
+	}
+	iguais := 0
+	for _, t := range ta {
+		if k := Fonetica(t); chaves[k] > 0 {
+			chaves[k]--
+			iguais++
+		}
+	}
+	fonetica := float64(iguais) / float64(max(len(ta), len(tb)))
+
+	return (jw + fonetica) / 2
+}
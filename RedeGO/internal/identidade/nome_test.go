@@ -0,0 +1,94 @@
+package identidade
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizarNome(t *testing.T) {
+	casos := map[string]string{
+		"José  da Conceição":  "JOSE DA CONCEICAO",
+		"maria d'ávila-souza": "MARIA D AVILA SOUZA",
+		"  ":                  "",
+	}
+	for entrada, esperado := range casos {
+		if obtido := NormalizarNome(entrada); obtido != esperado {
+			t.Errorf("NormalizarNome(%q) = %q, esperado %q", entrada, obtido, esperado)
+		}
+	}
+}
+
+func TestFonetica(t *testing.T) {
+	iguais := [][2]string{
+		{"SILVA", "SYLVA"},
+		{"SOUZA", "SOUSA"},
+		{"LUIZ", "LUIS"},
+		{"FELIPE", "PHELIPE"},
+		{"THIAGO", "TIAGO"},
+		{"WALTER", "VALTER"},
+		{"CAMILA", "KAMILA"},
+		{"GERALDO", "JERALDO"},
+		{"HELENA", "ELENA"},
+		{"CONCEIÇÃO", "CONSEISSAO"},
+		{"BRUNO", "BRUNNO"},
+	}
+	for _, par := range iguais {
+		if a, b := Fonetica(par[0]), Fonetica(par[1]); a != b {
+			t.Errorf("Fonetica(%s) = %s, Fonetica(%s) = %s, esperado iguais", par[0], a, par[1], b)
+		}
+	}
+
+	diferentes := [][2]string{
+		{"JOSE", "JOAO"},
+		{"GUSTAVO", "JUSTAVO"},
+		{"CARLA", "SARLA"},
+	}
+	for _, par := range diferentes {
+		if Fonetica(par[0]) == Fonetica(par[1]) {
+			t.Errorf("Fonetica(%s) = Fonetica(%s) = %s, esperado diferentes", par[0], par[1], Fonetica(par[0]))
+		}
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	casos := []struct {
+		a, b     string
+		esperado float64
+	}{
+		{"MARTHA", "MARHTA", 0.961},
+		{"DWAYNE", "DUANE", 0.840},
+		{"DIXON", "DICKSONX", 0.813},
+		{"ABC", "ABC", 1},
+		{"ABC", "XYZ", 0},
+		{"", "ABC", 0},
+	}
+	for _, c := range casos {
+		if obtido := JaroWinkler(c.a, c.b); math.Abs(obtido-c.esperado) > 0.001 {
+			t.Errorf("JaroWinkler(%s, %s) = %.3f, esperado %.3f", c.a, c.b, obtido, c.esperado)
+		}
+	}
+}
+
+func TestSimilaridadeNome(t *testing.T) {
+	parecidos := [][2]string{
+		{"JOSE DA SILVA", "JOSÉ SILVA"},
+		{"MARIA APARECIDA DE SOUZA", "MARIA APARECIDA SOUSA"},
+		{"LUIZ FELIPE THEODORO", "LUIS FILIPE TEODORO"},
+	}
+	for _, par := range parecidos {
+		if s := SimilaridadeNome(par[0], par[1]); s < nomeMinimo {
+			t.Errorf("SimilaridadeNome(%s, %s) = %.2f, esperado ao menos %.2f", par[0], par[1], s, nomeMinimo)
+		}
+	}
+
+	distantes := [][2]string{
+		{"JOSE DA SILVA", "ANTONIO PEREIRA"},
+		{"JOSE DA SILVA", "JOAO DA SILVA SANTOS"},
+		{"", "JOSE"},
+	}
+	for _, par := range distantes {
+		if s := SimilaridadeNome(par[0], par[1]); s >= nomeMinimo {
+			t.Errorf("SimilaridadeNome(%s, %s) = %.2f, esperado abaixo de %.2f", par[0], par[1], s, nomeMinimo)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package identidade
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+)
+
+// Classes de sugestão, da mais para a menos provável
+const (
+	ClasseMesmaPessoa   = "mesma_pessoa"
+	ClassePossivelMesma = "possivel_mesma_pessoa"
+)
+
+const (
+	scoreMesmaPessoa   = 0.80
+	scorePossivelMesma = 0.60
+	nomeMinimo         = 0.75 // Abaixo disso os nomes não são comparados
+	maxSugestoes       = 10
+	limiteRegistros    = 2000 // Registros de sócios lidos por resolução
+)
+
+// Pesos de cada evidência no score
+const (
+	pesoNome     = 0.45
+	pesoCPF      = 0.30
+	pesoFaixa    = 0.10
+	pesoVinculos = 0.15
+)
+
+// Evidencias detalha como o candidato foi comparado ao ID resolvido
+type Evidencias struct {
+	Nome            float64 `json:"nome"`             // Similaridade de 0 a 1
+	CPF             string  `json:"cpf"`              // "iguais" ou "desconhecido"
+	FaixaEtaria     string  `json:"faixa_etaria"`     // "igual", "adjacente" ou "desconhecida"
+	EmpresasComuns  int     `json:"empresas_comuns"`  // Empresas (CNPJ básico) em comum
+	EnderecosComuns int     `json:"enderecos_comuns"` // Endereços (CEP e número) em comum
+}
+
+// Sugestao é um ID PF que provavelmente é a mesma pessoa do ID resolvido
+type Sugestao struct {
+	ID         string     `json:"id"`
+	Nome       string     `json:"nome"`
+	CPF        string     `json:"cpf"`
+	Score      float64    `json:"score"`
+	Classe     string     `json:"classe"`
+	Evidencias Evidencias `json:"evidencias"`
+}
+
+// Resolucao é o resultado da resolução de um ID PF. Homonimos indica que o
+// próprio ID junta registros com faixas etárias incompatíveis, ou seja,
+// pessoas diferentes com o mesmo nome e os mesmos dígitos de CPF.
+// Descartados conta os candidatos de mesmo nome ou CPF cujos dígitos do CPF
+// ou faixa etária mostram que são outra pessoa.
+type Resolucao struct {
+	ID            string     `json:"id"`
+	FaixasEtarias []string   `json:"faixas_etarias"`
+	Homonimos     bool       `json:"homonimos"`
+	Sugestoes     []Sugestao `json:"sugestoes"`
+	Descartados   int        `json:"descartados"`
+}
+
+// pessoa agrupa os registros de sócio de um mesmo ID PF
+type pessoa struct {
+	cpf       string
+	nome      string
+	faixas    map[string]bool
+	empresas  map[string]bool
+	enderecos map[string]bool
+}
+
+func (p *pessoa) id() string {
+	return "PF_" + p.cpf + "-" + p.nome
+}
+
+// Resolvedor procura na tabela de sócios da Receita os IDs PF que
+// provavelmente são a mesma pessoa
+type Resolvedor struct {
+	db      *sql.DB
+	dialeto database.Dialect
+}
+
+// NewResolvedor cria um resolvedor sobre a base da Receita (SQLite ou
+// PostgreSQL)
+func NewResolvedor(db *sql.DB, dialeto database.Dialect) *Resolvedor {
+	return &Resolvedor{db: db, dialeto: dialeto}
+}
+
+// SepararID separa um ID PF_<cpf>-<nome> da tabela ligacao em CPF
+// mascarado e nome
+func SepararID(id string) (cpf, nome string, ok bool) {
+	if !strings.HasPrefix(id, "PF_") {
+		return "", "", false
+	}
+	cpf, nome, ok = strings.Cut(strings.TrimPrefix(id, "PF_"), "-")
+	return cpf, nome, ok && len(cpf) == 11 && nome != ""
+}
+
+// Resolver compara o ID PF informado com os sócios de mesmo nome ou com os
+// mesmos dígitos visíveis do CPF e retorna as sugestões de mesma pessoa,
+// da mais para a menos provável. Sócios apenas parecidos no nome e sem
+// nenhum outro vínculo não são candidatos: a comparação parte sempre do CPF
+// ou do nome exato.
+func (r *Resolvedor) Resolver(ctx context.Context, id string) (*Resolucao, error) {
+	cpf, nome, ok := SepararID(id)
+	if !ok {
+		return nil, fmt.Errorf("ID de pessoa física inválido: %s", id)
+	}
+	if r.db == nil {
+		return nil, fmt.Errorf("banco de dados da receita não disponível")
+	}
+
+	pessoas, err := r.carregar(ctx, cpf, nome)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Resolucao{ID: id, FaixasEtarias: []string{}, Sugestoes: []Sugestao{}}
+	alvo, ok := pessoas[id]
+	if !ok {
+		return res, nil
+	}
+	res.FaixasEtarias = faixasOrdenadas(alvo.faixas)
+	res.Homonimos = distanciaFaixas(alvo.faixas, alvo.faixas, true) > 1
+
+	for outroID, candidato := range pessoas {
+		if outroID == id {
+			continue
+		}
+		sug, descartado := comparar(alvo, candidato)
+		switch {
+		case descartado:
+			res.Descartados++
+		case sug != nil:
+			res.Sugestoes = append(res.Sugestoes, *sug)
+		}
+	}
+
+	sort.Slice(res.Sugestoes, func(i, j int) bool {
+		if res.Sugestoes[i].Score != res.Sugestoes[j].Score {
+			return res.Sugestoes[i].Score > res.Sugestoes[j].Score
+		}
+		return res.Sugestoes[i].ID < res.Sugestoes[j].ID
+	})
+	if len(res.Sugestoes) > maxSugestoes {
+		res.Sugestoes = res.Sugestoes[:maxSugestoes]
+	}
+	return res, nil
+}
+
+// carregar lê os sócios PF com o mesmo CPF mascarado ou o mesmo nome,
+// agrupados por ID. Com mais de limiteRegistros registros, os do próprio ID
+// vêm primeiro, seguidos dos de mesmo CPF, para que nomes comuns não deixem
+// o alvo de fora.
+func (r *Resolvedor) carregar(ctx context.Context, cpf, nome string) (map[string]*pessoa, error) {
+	where := "s.nome_socio = ?"
+	args := []interface{}{nome}
+	if digitosCPF(cpf) != "" {
+		where = "(s.cnpj_cpf_socio = ? OR s.nome_socio = ?)"
+		args = []interface{}{cpf, nome}
+	}
+	args = append(args, cpf, nome, cpf, limiteRegistros)
+
+	query := fmt.Sprintf(`
+		SELECT s.cnpj_cpf_socio, s.nome_socio, COALESCE(s.faixa_etaria, ''),
+			COALESCE(s.cnpj_basico, ''), COALESCE(est.cep, ''), COALESCE(est.numero, '')
+		FROM {socios} s
+		LEFT JOIN {estabelecimento} est ON est.cnpj = s.cnpj
+		WHERE length(s.cnpj_cpf_socio) = 11 AND %s
+		ORDER BY CASE
+			WHEN s.cnpj_cpf_socio = ? AND s.nome_socio = ? THEN 0
+			WHEN s.cnpj_cpf_socio = ? THEN 1
+			ELSE 2
+		END
+		LIMIT ?
+	`, where)
+
+	rows, err := r.db.QueryContext(ctx, r.dialeto.Query(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pessoas := make(map[string]*pessoa)
+	for rows.Next() {
+		var cpf, nome, faixa, empresa, cep, numero string
+		if err := rows.Scan(&cpf, &nome, &faixa, &empresa, &cep, &numero); err != nil {
+			return nil, err
+		}
+		p, ok := pessoas["PF_"+cpf+"-"+nome]
+		if !ok {
+			p = &pessoa{cpf: cpf, nome: nome, faixas: map[string]bool{},
+				empresas: map[string]bool{}, enderecos: map[string]bool{}}
+			pessoas[p.id()] = p
+		}
+		p.faixas[faixa] = true
+		if empresa != "" {
+			p.empresas[empresa] = true
+		}
+		if cep != "" && numero != "" {
+			p.enderecos[cep+"|"+numero] = true
+		}
+	}
+	return pessoas, rows.Err()
+}
+
+// comparar pontua o candidato em relação ao alvo. Retorna descartado quando
+// o CPF ou a faixa etária mostram que são pessoas diferentes, e nil quando
+// o score não chega a uma sugestão.
+func comparar(alvo, candidato *pessoa) (sug *Sugestao, descartado bool) {
+	ev := Evidencias{Nome: SimilaridadeNome(alvo.nome, candidato.nome)}
+	if ev.Nome < nomeMinimo {
+		return nil, false
+	}
+
+	score := pesoNome * ev.Nome
+
+	da, dc := digitosCPF(alvo.cpf), digitosCPF(candidato.cpf)
+	switch {
+	case da == "" || dc == "":
+		ev.CPF = "desconhecido"
+		score += pesoCPF / 2
+	case da == dc:
+		ev.CPF = "iguais"
+		score += pesoCPF
+	default:
+		return nil, true
+	}
+
+	// A faixa etária é a da data de referência de cada registro, então
+	// faixas vizinhas ainda são compatíveis
+	switch distanciaFaixas(alvo.faixas, candidato.faixas, false) {
+	case -1:
+		ev.FaixaEtaria = "desconhecida"
+		score += pesoFaixa / 2
+	case 0:
+		ev.FaixaEtaria = "igual"
+		score += pesoFaixa
+	case 1:
+		ev.FaixaEtaria = "adjacente"
+		score += pesoFaixa / 2
+	default:
+		return nil, true
+	}
+
+	ev.EmpresasComuns = emComum(alvo.empresas, candidato.empresas)
+	ev.EnderecosComuns = emComum(alvo.enderecos, candidato.enderecos)
+	score += pesoVinculos * min(1, 0.5*float64(ev.EmpresasComuns+ev.EnderecosComuns))
+
+	var classe string
+	switch {
+	case score >= scoreMesmaPessoa:
+		classe = ClasseMesmaPessoa
+	case score >= scorePossivelMesma:
+		classe = ClassePossivelMesma
+	default:
+		return nil, false
+	}
+
+	return &Sugestao{
+		ID:         candidato.id(),
+		Nome:       candidato.nome,
+		CPF:        candidato.cpf,
+		Score:      float64(int(score*100+0.5)) / 100,
+		Classe:     classe,
+		Evidencias: ev,
+	}, false
+}
+
+// digitosCPF retorna os seis dígitos visíveis do CPF mascarado
+// (***123456**) ou "" se não houver dígitos conhecidos
+func digitosCPF(cpf string) string {
+	if len(cpf) != 11 {
+		return ""
+	}
+	d := cpf[3:9]
+	if strings.Trim(d, "0123456789") != "" || d == "000000" {
+		return ""
+	}
+	return d
+}
+
+// distanciaFaixas retorna a distância entre as faixas etárias conhecidas
+// (1 a 9) dos dois conjuntos, ou -1 se algum deles não tiver faixa. Com
+// maior, retorna a maior distância em vez da menor.
+func distanciaFaixas(a, b map[string]bool, maior bool) int {
+	dist := -1
+	for fa := range a {
+		for fb := range b {
+			if len(fa) != 1 || len(fb) != 1 || fa < "1" || fa > "9" || fb < "1" || fb > "9" {
+				continue
+			}
+			d := int(fa[0]) - int(fb[0])
+			if d < 0 {
+				d = -d
+			}
+			if dist == -1 || (maior && d > dist) || (!maior && d < dist) {
+				dist = d
+			}
+		}
+	}
+	return dist
+}
+
+func faixasOrdenadas(faixas map[string]bool) []string {
+	lista := make([]string, 0, len(faixas))
+	for f := range faixas {
+		if f != "" {
+			lista = append(lista, f)
+		}
+	}
+	sort.Strings(lista)
+	return lista
+}
+
+func emComum(a, b map[string]bool) int {
+	n := 0
+	for k := range a {
+		if b[k] {
+			n++
+		}
+	}
+	return n
+}
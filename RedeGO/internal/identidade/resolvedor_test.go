@@ -0,0 +1,172 @@
+package identidade
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+)
+
+// baseTeste cria uma base SQLite com sócios e estabelecimentos mínimos
+func baseTeste(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cnpj.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	stmts := []string{
+		`CREATE TABLE estabelecimento (cnpj TEXT, cnpj_basico TEXT, cep TEXT, numero TEXT)`,
+		`CREATE TABLE socios (cnpj TEXT, cnpj_basico TEXT, nome_socio TEXT, cnpj_cpf_socio TEXT, faixa_etaria TEXT)`,
+		`INSERT INTO estabelecimento VALUES
+			('11111111000191', '11111111', '01000000', '10'),
+			('22222222000191', '22222222', '01000000', '10'),
+			('33333333000191', '33333333', '02000000', '20'),
+			('44444444000191', '44444444', '03000000', '30')`,
+		`INSERT INTO socios VALUES
+			('11111111000191', '11111111', 'JOSE DA SILVA', '***123456**', '4'),
+			('22222222000191', '22222222', 'JOSE SILVA', '***123456**', '4'),
+			('33333333000191', '33333333', 'JOSE DA SILVA', '***000000**', '5'),
+			('44444444000191', '44444444', 'JOSE DA SILVA', '***999999**', '4'),
+			('44444444000191', '44444444', 'JOSE DA SILVA', '', '4'),
+			('33333333000191', '33333333', 'ANTONIO PEREIRA', '***123456**', '4'),
+			('11111111000191', '11111111', 'MARIA SOUZA', '***777777**', '2'),
+			('33333333000191', '33333333', 'MARIA SOUZA', '***777777**', '7')`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestResolver(t *testing.T) {
+	r := NewResolvedor(baseTeste(t), database.Dialect{})
+	ctx := context.Background()
+
+	res, err := r.Resolver(ctx, "PF_***123456**-JOSE DA SILVA")
+	if err != nil {
+		t.Fatalf("Resolver() erro: %v", err)
+	}
+	if len(res.Sugestoes) != 2 {
+		t.Fatalf("sugestões = %+v, esperado 2", res.Sugestoes)
+	}
+
+	// Mesmo CPF, mesma faixa e mesmo endereço
+	mesma := res.Sugestoes[0]
+	if mesma.ID != "PF_***123456**-JOSE SILVA" || mesma.Classe != ClasseMesmaPessoa {
+		t.Errorf("primeira sugestão = %+v, esperado JOSE SILVA como mesma pessoa", mesma)
+	}
+	if mesma.Evidencias.CPF != "iguais" || mesma.Evidencias.EnderecosComuns != 1 {
+		t.Errorf("evidências = %+v", mesma.Evidencias)
+	}
+
+	// CPF sem dígitos conhecidos e faixa etária vizinha
+	possivel := res.Sugestoes[1]
+	if possivel.CPF != "***000000**" || possivel.Classe != ClassePossivelMesma {
+		t.Errorf("segunda sugestão = %+v, esperado possível mesma pessoa", possivel)
+	}
+	if possivel.Evidencias.FaixaEtaria != "adjacente" || possivel.Score >= mesma.Score {
+		t.Errorf("segunda sugestão = %+v", possivel)
+	}
+
+	// Outros dígitos de CPF; nome diferente com o mesmo CPF é ignorado
+	if res.Descartados != 1 || res.Homonimos {
+		t.Errorf("descartados = %d, homônimos = %v, esperado 1 e false", res.Descartados, res.Homonimos)
+	}
+
+	// Mesmo ID com faixas etárias distantes
+	res, err = r.Resolver(ctx, "PF_***777777**-MARIA SOUZA")
+	if err != nil {
+		t.Fatalf("Resolver() erro: %v", err)
+	}
+	if !res.Homonimos || len(res.FaixasEtarias) != 2 {
+		t.Errorf("MARIA SOUZA: %+v, esperado homônimos com faixas 2 e 7", res)
+	}
+
+	if _, err := r.Resolver(ctx, "PJ_11111111000191"); err == nil {
+		t.Error("Resolver(PJ) deveria retornar erro")
+	}
+}
+
+func TestResolverNomeComum(t *testing.T) {
+	db := baseTeste(t)
+
+	// Mais homônimos do que limiteRegistros, gravados antes do alvo
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= limiteRegistros; i++ {
+		if _, err := tx.Exec(`INSERT INTO socios VALUES ('99999999000191', '99999999', 'JOAO SANTOS', '***999999**', '4')`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO socios VALUES
+		('11111111000191', '11111111', 'JOAO SANTOS', '***555555**', '4'),
+		('22222222000191', '22222222', 'JOAO DOS SANTOS', '***555555**', '4')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := NewResolvedor(db, database.Dialect{}).Resolver(context.Background(), "PF_***555555**-JOAO SANTOS")
+	if err != nil {
+		t.Fatalf("Resolver() erro: %v", err)
+	}
+	if len(res.FaixasEtarias) != 1 || len(res.Sugestoes) != 1 || res.Sugestoes[0].ID != "PF_***555555**-JOAO DOS SANTOS" {
+		t.Errorf("Resolver() com nome comum = %+v, esperado o alvo e JOAO DOS SANTOS", res)
+	}
+}
+
+func TestComparar(t *testing.T) {
+	conjunto := func(v ...string) map[string]bool {
+		m := map[string]bool{}
+		for _, s := range v {
+			m[s] = true
+		}
+		return m
+	}
+	alvo := &pessoa{cpf: "***123456**", nome: "LUIZ FELIPE SOUZA", faixas: conjunto("5"),
+		empresas: conjunto("11111111", "22222222"), enderecos: conjunto()}
+
+	casos := []struct {
+		nome       string
+		candidato  *pessoa
+		classe     string
+		descartado bool
+	}{
+		{"grafia diferente e empresas em comum",
+			&pessoa{cpf: "***123456**", nome: "LUIS FILIPE SOUSA", faixas: conjunto("5"), empresas: conjunto("11111111", "22222222")},
+			ClasseMesmaPessoa, false},
+		{"CPF desconhecido e nome igual",
+			&pessoa{cpf: "***000000**", nome: "LUIZ FELIPE SOUZA", faixas: conjunto("")},
+			ClassePossivelMesma, false},
+		{"faixas etárias distantes",
+			&pessoa{cpf: "***123456**", nome: "LUIZ FELIPE SOUZA", faixas: conjunto("8")},
+			"", true},
+		{"CPF diferente",
+			&pessoa{cpf: "***654321**", nome: "LUIZ FELIPE SOUZA", faixas: conjunto("5")},
+			"", true},
+		{"nome diferente",
+			&pessoa{cpf: "***123456**", nome: "PEDRO ALVES", faixas: conjunto("5")},
+			"", false},
+	}
+	for _, c := range casos {
+		sug, descartado := comparar(alvo, c.candidato)
+		classe := ""
+		if sug != nil {
+			classe = sug.Classe
+		}
+		if classe != c.classe || descartado != c.descartado {
+			t.Errorf("%s: classe %q, descartado %v; esperado %q, %v (%+v)", c.nome, classe, descartado, c.classe, c.descartado, sug)
+		}
+	}
+}
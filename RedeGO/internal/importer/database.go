@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"strings"
 
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
@@ -25,8 +26,24 @@ type DatabaseManager struct {
 	connStr  string
 }
 
-// NewDatabaseManager cria um novo gerenciador de banco de dados
+// NewDatabaseManager cria um novo gerenciador de banco de dados.
+// No SQLite o banco existente é removido para uma importação completa.
 func NewDatabaseManager(cfg *config.Config, dbPath string) (*DatabaseManager, error) {
+	return newDatabaseManager(cfg, dbPath, true)
+}
+
+// OpenDatabaseManager abre o banco existente sem apagá-lo, para a
+// importação incremental
+func OpenDatabaseManager(cfg *config.Config, dbPath string) (*DatabaseManager, error) {
+	if cfg.PostgresURL == "" {
+		if _, err := os.Stat(dbPath); err != nil {
+			return nil, fmt.Errorf("banco atual não encontrado em %s; execute a importação completa primeiro", dbPath)
+		}
+	}
+	return newDatabaseManager(cfg, dbPath, false)
+}
+
+func newDatabaseManager(cfg *config.Config, dbPath string, recriar bool) (*DatabaseManager, error) {
 	dm := &DatabaseManager{}
 	
 	// Verifica se PostgreSQL está configurado
@@ -58,7 +75,7 @@ func NewDatabaseManager(cfg *config.Config, dbPath string) (*DatabaseManager, er
 		dm.connStr = dbPath
 		
 		// Remove banco antigo se existir
-		if _, err := os.Stat(dbPath); err == nil {
+		if _, err := os.Stat(dbPath); err == nil && recriar {
 			fmt.Printf("⚠️  Removendo banco SQLite antigo: %s\n", dbPath)
 			if err := os.Remove(dbPath); err != nil {
 				return nil, fmt.Errorf("erro ao remover banco antigo: %w", err)
@@ -115,15 +132,24 @@ func (dm *DatabaseManager) TablePrefix(table string) string {
 	// Mapear tabelas para schemas PostgreSQL
 	switch table {
 	case "empresas", "estabelecimento", "socios", "simples",
-		"cnae", "motivo", "municipio", "natureza_juridica", "pais", "qualificacao_socio":
+		"cnae", "motivo", "municipio", "natureza_juridica", "pais", "qualificacao_socio",
+		"change_log", "socios_historico", "estabelecimento_historico",
+		"qualidade_importacao":
 		return "receita." + table
-	case "ligacao":
+	case "ligacao", "busca", "endereco_normalizado", "cep_geo":
 		return "rede." + table
 	default:
 		return table
 	}
 }
 
+// StagingTable retorna o nome da tabela de staging usada na importação
+// incremental (stg_<tabela>, no schema receita para PostgreSQL)
+func (dm *DatabaseManager) StagingTable(table string) string {
+	prefixo := strings.TrimSuffix(dm.TablePrefix(table), table)
+	return prefixo + "stg_" + table
+}
+
 // AdaptPlaceholder adapta placeholders para o banco correto
 // SQLite usa ?, PostgreSQL usa $1, $2, etc
 func (dm *DatabaseManager) AdaptPlaceholder(query string) string {
@@ -1,36 +1,52 @@
 package importer
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
-
-	"golang.org/x/net/html"
 )
 
 // Downloader gerencia o download dos arquivos da Receita Federal
 type Downloader struct {
-	baseURL string
-	zipDir  string
-	client  *http.Client
+	fonte  FonteDados
+	zipDir string
+	client *http.Client
+
+	// Referência fixada (AAAA-MM); vazia usa a mais recente da fonte
+	referencia string
+
+	// Tentativas por arquivo e espera inicial entre elas (dobra a cada falha)
+	tentativas int
+	espera     time.Duration
 }
 
-// NewDownloader cria um novo downloader
-func NewDownloader(baseURL, zipDir string) *Downloader {
+// NewDownloader cria um novo downloader. A origem pode ser a URL da Receita,
+// um espelho HTTP ou um diretório local com o mesmo layout.
+func NewDownloader(origem, zipDir string) *Downloader {
+	client := &http.Client{
+		Timeout: 30 * time.Minute,
+	}
 	return &Downloader{
-		baseURL: baseURL,
-		zipDir:  zipDir,
-		client: &http.Client{
-			Timeout: 30 * time.Minute,
-		},
+		fonte:      NovaFonte(origem, client),
+		zipDir:     zipDir,
+		client:     client,
+		tentativas: 5,
+		espera:     2 * time.Second,
 	}
 }
 
+// SetReferencia fixa a referência (AAAA-MM) a ser baixada
+func (d *Downloader) SetReferencia(referencia string) {
+	d.referencia = referencia
+}
+
 // Download baixa todos os arquivos ZIP
 func (d *Downloader) Download() error {
 	// Cria diretório se não existir
@@ -38,106 +54,99 @@ func (d *Downloader) Download() error {
 		return fmt.Errorf("erro ao criar diretório %s: %w", d.zipDir, err)
 	}
 
-	// Busca última referência (pasta mais recente)
-	latestRef, err := d.getLatestReference()
+	ref, err := d.escolherReferencia()
 	if err != nil {
-		return fmt.Errorf("erro ao buscar última referência: %w", err)
+		return err
 	}
 
-	fmt.Printf("📅 Última referência encontrada: %s\n", latestRef)
+	fmt.Printf("📅 Referência: %s\n", ref)
 	
 	// Lista arquivos ZIP disponíveis
-	url := d.baseURL + latestRef
-	files, err := d.listZipFiles(url)
+	files, err := d.fonte.Arquivos(ref)
 	if err != nil {
 		return fmt.Errorf("erro ao listar arquivos: %w", err)
 	}
+	if len(files) == 0 {
+		return fmt.Errorf("nenhum arquivo ZIP em %s", d.fonte.Origem(ref))
+	}
 
 	fmt.Printf("📋 Encontrados %d arquivos ZIP\n\n", len(files))
 
 	// Baixa arquivos em paralelo (máximo 5 simultâneos)
-	return d.downloadParallel(files, 5)
-}
-
-// getLatestReference busca a pasta mais recente no site da Receita
-func (d *Downloader) getLatestReference() (string, error) {
-	resp, err := d.client.Get(d.baseURL)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	doc, err := html.Parse(resp.Body)
+	arquivos, err := d.downloadParallel(ref, files, 5)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	var folders []string
-	var f func(*html.Node)
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			for _, attr := range n.Attr {
-				if attr.Key == "href" && strings.HasPrefix(attr.Val, "20") && strings.HasSuffix(attr.Val, "/") {
-					folders = append(folders, attr.Val)
-				}
-			}
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
-		}
+	manifesto := &Manifesto{
+		Referencia: ref,
+		Origem:     d.fonte.Origem(ref),
+		GeradoEm:   time.Now(),
+		Arquivos:   arquivos,
 	}
-	f(doc)
-
-	if len(folders) == 0 {
-		return "", fmt.Errorf("nenhuma pasta encontrada em %s", d.baseURL)
+	if err := manifesto.Salvar(d.zipDir); err != nil {
+		return fmt.Errorf("erro ao gravar manifesto: %w", err)
 	}
 
-	// Retorna a última (mais recente)
-	latest := folders[len(folders)-1]
-	return latest, nil
+	fmt.Printf("📝 Manifesto gravado em %s\n", filepath.Join(d.zipDir, ManifestoArquivo))
+	return nil
 }
 
-// listZipFiles lista todos os arquivos .zip na URL
-func (d *Downloader) listZipFiles(url string) ([]string, error) {
-	resp, err := d.client.Get(url)
+// escolherReferencia retorna a referência fixada, se existir na fonte, ou a
+// mais recente
+func (d *Downloader) escolherReferencia() (string, error) {
+	refs, err := d.fonte.Referencias(context.Background())
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("erro ao listar referências: %w", err)
+	}
+	if len(refs) == 0 {
+		return "", fmt.Errorf("nenhuma referência encontrada em %s", d.fonte.Origem(""))
 	}
-	defer resp.Body.Close()
 
-	doc, err := html.Parse(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var files []string
-	var f func(*html.Node)
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			for _, attr := range n.Attr {
-				if attr.Key == "href" && strings.HasSuffix(attr.Val, ".zip") {
-					fileURL := attr.Val
-					if !strings.HasPrefix(fileURL, "http") {
-						fileURL = url + fileURL
-					}
-					files = append(files, fileURL)
-				}
-			}
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
+	if d.referencia == "" {
+		return refs[len(refs)-1], nil
+	}
+	for _, ref := range refs {
+		if ref == d.referencia {
+			return ref, nil
 		}
 	}
-	f(doc)
-
-	return files, nil
+	return "", fmt.Errorf("referência %s não encontrada em %s (disponíveis: %s)", d.referencia, d.fonte.Origem(""), strings.Join(refs, ", "))
 }
 
-// downloadParallel baixa arquivos em paralelo
-func (d *Downloader) downloadParallel(urls []string, maxConcurrent int) error {
+// downloadParallel baixa arquivos em paralelo e retorna as entradas do
+// manifesto, na ordem das URLs
+func (d *Downloader) downloadParallel(ref string, urls []string, maxConcurrent int) ([]ArquivoManifesto, error) {
 	sem := make(chan struct{}, maxConcurrent)
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(urls))
+	arquivos := make([]ArquivoManifesto, len(urls))
+
+	anterior, err := LerManifesto(d.zipDir)
+	if err != nil {
+		fmt.Printf("⚠️  %v; os arquivos existentes serão verificados novamente\n", err)
+		anterior = nil
+	}
+	// Os nomes dos ZIPs se repetem a cada mês: arquivos de um manifesto de
+	// outra referência são de outra publicação e precisam ser baixados de novo
+	if anterior != nil && anterior.Referencia != ref {
+		fmt.Printf("⚠️  Arquivos existentes são da referência %s; baixando %s\n", anterior.Referencia, ref)
+		for _, url := range urls {
+			destPath := filepath.Join(d.zipDir, filepath.Base(url))
+			os.Remove(destPath)
+			os.Remove(destPath + ".part")
+		}
+		anterior = nil
+	}
+	// O manifesto registra a referência antes dos downloads: uma execução
+	// interrompida retoma os arquivos e .part desta referência em vez de
+	// descartá-los como sendo da anterior
+	if anterior == nil {
+		parcial := &Manifesto{Referencia: ref, Origem: d.fonte.Origem(ref), GeradoEm: time.Now()}
+		if err := parcial.Salvar(d.zipDir); err != nil {
+			return nil, fmt.Errorf("erro ao gravar manifesto: %w", err)
+		}
+	}
 
 	for i, url := range urls {
 		wg.Add(1)
@@ -149,18 +158,21 @@ func (d *Downloader) downloadParallel(urls []string, maxConcurrent int) error {
 			filename := filepath.Base(fileURL)
 			destPath := filepath.Join(d.zipDir, filename)
 
-			// Verifica se já existe
-			if _, err := os.Stat(destPath); err == nil {
+			// Arquivo já existente só é aproveitado se estiver íntegro
+			if entrada, ok := d.arquivoExistente(destPath, fileURL, anterior.Arquivo(filename)); ok {
 				fmt.Printf("[%d/%d] ⏭️  %s (já existe)\n", idx+1, len(urls), filename)
+				arquivos[idx] = entrada
 				return
 			}
 
 			fmt.Printf("[%d/%d] ⬇️  Baixando %s...\n", idx+1, len(urls), filename)
 			
-			if err := d.downloadFile(fileURL, destPath); err != nil {
+			entrada, err := d.downloadFile(fileURL, destPath)
+			if err != nil {
 				errChan <- fmt.Errorf("erro ao baixar %s: %w", filename, err)
 				return
 			}
+			arquivos[idx] = entrada
 
 			fmt.Printf("[%d/%d] ✅ %s concluído\n", idx+1, len(urls), filename)
 		}(i, url)
@@ -171,30 +183,216 @@ func (d *Downloader) downloadParallel(urls []string, maxConcurrent int) error {
 
 	// Verifica se houve erros
 	if len(errChan) > 0 {
-		return <-errChan
+		return nil, <-errChan
 	}
 
-	return nil
+	return arquivos, nil
+}
+
+// arquivoExistente verifica um ZIP já presente na pasta. Com entrada no
+// manifesto anterior, o tamanho precisa conferir; sem ela, o hash é
+// recalculado. Em ambos os casos o ZIP precisa passar em VerificarZip.
+func (d *Downloader) arquivoExistente(destPath, fileURL string, anterior *ArquivoManifesto) (ArquivoManifesto, bool) {
+	info, err := os.Stat(destPath)
+	if err != nil {
+		return ArquivoManifesto{}, false
+	}
+
+	if anterior != nil && anterior.Tamanho != info.Size() {
+		fmt.Printf("⚠️  %s difere do manifesto, baixando novamente\n", filepath.Base(destPath))
+		return ArquivoManifesto{}, false
+	}
+
+	if err := VerificarZip(destPath); err != nil {
+		fmt.Printf("⚠️  %v, baixando novamente\n", err)
+		os.Remove(destPath)
+		return ArquivoManifesto{}, false
+	}
+
+	if anterior != nil {
+		return *anterior, true
+	}
+
+	hash, tamanho, err := hashArquivo(destPath)
+	if err != nil {
+		return ArquivoManifesto{}, false
+	}
+	return ArquivoManifesto{
+		Nome:    filepath.Base(destPath),
+		URL:     fileURL,
+		Tamanho: tamanho,
+		SHA256:  hash,
+	}, true
+}
+
+// downloadFile baixa um arquivo com novas tentativas e espera crescente.
+// O conteúdo é gravado em <destino>.part, retomado via Range após falhas e
+// renomeado para o destino apenas depois de conferir tamanho e integridade.
+func (d *Downloader) downloadFile(url, destPath string) (ArquivoManifesto, error) {
+	partPath := destPath + ".part"
+	espera := d.espera
+
+	var err error
+	for tentativa := 1; tentativa <= d.tentativas; tentativa++ {
+		if tentativa > 1 {
+			fmt.Printf("    🔁 %s: tentativa %d/%d em %s (%v)\n", filepath.Base(destPath), tentativa, d.tentativas, espera, err)
+			time.Sleep(espera)
+			espera *= 2
+		}
+
+		if fonteRemota(url) {
+			err = d.baixarParte(url, partPath)
+		} else {
+			err = copiarParte(url, partPath)
+		}
+		if err != nil {
+			continue
+		}
+
+		if err = VerificarZip(partPath); err != nil {
+			// Conteúdo corrompido: recomeça do zero
+			os.Remove(partPath)
+			continue
+		}
+
+		if err = os.Rename(partPath, destPath); err != nil {
+			return ArquivoManifesto{}, err
+		}
+
+		hash, tamanho, err := hashArquivo(destPath)
+		if err != nil {
+			return ArquivoManifesto{}, err
+		}
+		return ArquivoManifesto{
+			Nome:    filepath.Base(destPath),
+			URL:     url,
+			Tamanho: tamanho,
+			SHA256:  hash,
+		}, nil
+	}
+
+	return ArquivoManifesto{}, fmt.Errorf("%d tentativas sem sucesso: %w", d.tentativas, err)
 }
 
-// downloadFile baixa um arquivo individual
-func (d *Downloader) downloadFile(url, destPath string) error {
-	resp, err := d.client.Get(url)
+// baixarParte continua o download em partPath a partir do tamanho atual e
+// confere o total recebido com o tamanho informado pelo servidor
+func (d *Downloader) baixarParte(url, partPath string) error {
+	var inicio int64
+	if info, err := os.Stat(partPath); err == nil {
+		inicio = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if inicio > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", inicio))
+	}
+
+	resp, err := d.client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	var total int64 = -1
+	flags := os.O_CREATE | os.O_WRONLY
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+		total = totalContentRange(resp.Header.Get("Content-Range"))
+		if total < 0 && resp.ContentLength >= 0 {
+			total = inicio + resp.ContentLength
+		}
+	case http.StatusOK:
+		// Servidor ignorou o Range: recomeça do zero
+		flags |= os.O_TRUNC
+		inicio = 0
+		total = resp.ContentLength
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Parte local maior que o arquivo remoto: descarta
+		os.Remove(partPath)
+		return fmt.Errorf("intervalo inválido para %s, recomeçando", filepath.Base(partPath))
+	default:
 		return fmt.Errorf("status code: %d", resp.StatusCode)
 	}
 
-	out, err := os.Create(destPath)
+	out, err := os.OpenFile(partPath, flags, 0644)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
-	return err
+	n, copyErr := io.Copy(out, resp.Body)
+	if err := out.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	if copyErr != nil {
+		return copyErr
+	}
+
+	if total >= 0 && inicio+n != total {
+		return fmt.Errorf("download incompleto: %d de %d bytes", inicio+n, total)
+	}
+	return nil
+}
+
+// copiarParte continua a cópia de um ZIP de diretório local (espelho
+// offline) em partPath, com as mesmas garantias de baixarParte
+func copiarParte(origem, partPath string) error {
+	src, err := os.Open(origem)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	var inicio int64
+	if part, err := os.Stat(partPath); err == nil {
+		inicio = part.Size()
+	}
+	if inicio > info.Size() {
+		os.Remove(partPath)
+		return fmt.Errorf("parte local maior que %s, recomeçando", filepath.Base(origem))
+	}
+	if _, err := src.Seek(inicio, io.SeekStart); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	n, copyErr := io.Copy(out, src)
+	if err := out.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	if copyErr != nil {
+		return copyErr
+	}
+
+	if inicio+n != info.Size() {
+		return fmt.Errorf("cópia incompleta: %d de %d bytes", inicio+n, info.Size())
+	}
+	return nil
+}
+
+// totalContentRange extrai o tamanho total de "bytes inicio-fim/total";
+// retorna -1 se não for informado
+func totalContentRange(contentRange string) int64 {
+	idx := strings.LastIndex(contentRange, "/")
+	if idx < 0 {
+		return -1
+	}
+	total, err := strconv.ParseInt(contentRange[idx+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return total
 }
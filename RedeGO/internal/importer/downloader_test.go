@@ -0,0 +1,453 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/config"
+)
+
+// zipTeste gera um ZIP com um CSV de conteúdo pouco compressível
+func zipTeste(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("K3241.K03200Y0.D41012.EMPRECSV")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(f, "\"%08d\";\"EMPRESA %x\";\"2062\";\"49\";\"%d,00\";\"01\";\"\"\n", i, sha256.Sum256([]byte{byte(i), byte(i >> 8)}), i)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// servidorReceita simula a listagem da Receita com uma referência e um ZIP.
+// falhas indica quantas requisições ao ZIP devem falhar antes de servir o
+// arquivo; corte faz a primeira resposta ser interrompida após esse número
+// de bytes.
+type servidorReceita struct {
+	conteudo []byte
+	falhas   int
+	corte    int
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (s *servidorReceita) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		fmt.Fprint(w, `<html><body><a href="2024-09/">2024-09/</a><a href="2024-10/">2024-10/</a></body></html>`)
+	case "/2024-09/", "/2024-10/":
+		fmt.Fprint(w, `<html><body><a href="Empresas0.zip">Empresas0.zip</a></body></html>`)
+	case "/2024-09/Empresas0.zip", "/2024-10/Empresas0.zip":
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		falhar := s.falhas > 0
+		if falhar {
+			s.falhas--
+		}
+		corte := s.corte
+		s.corte = 0
+		s.mu.Unlock()
+
+		if falhar {
+			http.Error(w, "indisponível", http.StatusServiceUnavailable)
+			return
+		}
+		if corte > 0 {
+			// Anuncia o tamanho completo mas encerra a conexão no meio
+			w.Header().Set("Content-Length", fmt.Sprint(len(s.conteudo)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(s.conteudo[:corte])
+			if hj, ok := w.(http.Hijacker); ok {
+				conn, _, _ := hj.Hijack()
+				conn.Close()
+			}
+			return
+		}
+		http.ServeContent(w, r, "Empresas0.zip", time.Time{}, bytes.NewReader(s.conteudo))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func novoDownloaderTeste(url, dir string) *Downloader {
+	d := NewDownloader(url+"/", dir)
+	d.espera = time.Millisecond
+	return d
+}
+
+func hashBytes(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func conferirDownload(t *testing.T, dir string, conteudo []byte) {
+	t.Helper()
+	conferirReferencia(t, dir, conteudo, "2024-10")
+}
+
+func conferirReferencia(t *testing.T, dir string, conteudo []byte, referencia string) {
+	t.Helper()
+
+	baixado, err := os.ReadFile(filepath.Join(dir, "Empresas0.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(baixado, conteudo) {
+		t.Fatalf("conteúdo baixado difere: %d bytes, esperado %d", len(baixado), len(conteudo))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Empresas0.zip.part")); !os.IsNotExist(err) {
+		t.Errorf("arquivo .part não foi removido")
+	}
+
+	m, err := LerManifesto(dir)
+	if err != nil || m == nil {
+		t.Fatalf("LerManifesto() = %v, %v", m, err)
+	}
+	if m.Referencia != referencia {
+		t.Errorf("Referencia = %q, esperado %q", m.Referencia, referencia)
+	}
+	entrada := m.Arquivo("Empresas0.zip")
+	if entrada == nil {
+		t.Fatal("Empresas0.zip ausente do manifesto")
+	}
+	if entrada.SHA256 != hashBytes(conteudo) || entrada.Tamanho != int64(len(conteudo)) {
+		t.Errorf("manifesto = %+v, esperado sha256 %s e %d bytes", entrada, hashBytes(conteudo), len(conteudo))
+	}
+}
+
+func TestDownloaderCompleto(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	if err := novoDownloaderTeste(ts.URL, dir).Download(); err != nil {
+		t.Fatalf("Download() erro: %v", err)
+	}
+	conferirDownload(t, dir, srv.conteudo)
+}
+
+func TestDownloaderRetomaParte(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	metade := len(srv.conteudo) / 2
+	if err := os.WriteFile(filepath.Join(dir, "Empresas0.zip.part"), srv.conteudo[:metade], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := novoDownloaderTeste(ts.URL, dir).Download(); err != nil {
+		t.Fatalf("Download() erro: %v", err)
+	}
+	conferirDownload(t, dir, srv.conteudo)
+
+	esperado := fmt.Sprintf("bytes=%d-", metade)
+	if len(srv.ranges) != 1 || srv.ranges[0] != esperado {
+		t.Errorf("Range = %q, esperado [%q]", srv.ranges, esperado)
+	}
+}
+
+func TestDownloaderConexaoInterrompida(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t)}
+	srv.corte = len(srv.conteudo) / 3
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	if err := novoDownloaderTeste(ts.URL, dir).Download(); err != nil {
+		t.Fatalf("Download() erro: %v", err)
+	}
+	conferirDownload(t, dir, srv.conteudo)
+
+	if len(srv.ranges) != 2 || !strings.HasPrefix(srv.ranges[1], "bytes=") {
+		t.Errorf("Range = %q, esperado retomada na segunda requisição", srv.ranges)
+	}
+}
+
+func TestDownloaderTentativas(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t), falhas: 2}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	if err := novoDownloaderTeste(ts.URL, dir).Download(); err != nil {
+		t.Fatalf("Download() erro: %v", err)
+	}
+	conferirDownload(t, dir, srv.conteudo)
+
+	if len(srv.ranges) != 3 {
+		t.Errorf("requisições = %d, esperado 3", len(srv.ranges))
+	}
+}
+
+func TestDownloaderDesisteAposTentativas(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t), falhas: 100}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	d := novoDownloaderTeste(ts.URL, t.TempDir())
+	d.tentativas = 3
+	if err := d.Download(); err == nil {
+		t.Fatal("Download() sem erro, esperado falha após 3 tentativas")
+	}
+	if len(srv.ranges) != 3 {
+		t.Errorf("requisições = %d, esperado 3", len(srv.ranges))
+	}
+}
+
+func TestDownloaderSubstituiArquivoTruncado(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	// Arquivo truncado de uma execução interrompida (versão antiga, sem .part)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Empresas0.zip"), srv.conteudo[:100], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := novoDownloaderTeste(ts.URL, dir).Download(); err != nil {
+		t.Fatalf("Download() erro: %v", err)
+	}
+	conferirDownload(t, dir, srv.conteudo)
+}
+
+func TestDownloaderAproveitaArquivoIntegro(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Empresas0.zip"), srv.conteudo, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := novoDownloaderTeste(ts.URL, dir).Download(); err != nil {
+		t.Fatalf("Download() erro: %v", err)
+	}
+	conferirDownload(t, dir, srv.conteudo)
+
+	if len(srv.ranges) != 0 {
+		t.Errorf("requisições = %d, esperado 0 (arquivo já íntegro)", len(srv.ranges))
+	}
+}
+
+func TestDownloaderReferenciaFixada(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	d := novoDownloaderTeste(ts.URL, dir)
+	d.SetReferencia("2024-09")
+	if err := d.Download(); err != nil {
+		t.Fatalf("Download() erro: %v", err)
+	}
+	conferirReferencia(t, dir, srv.conteudo, "2024-09")
+
+	m, _ := LerManifesto(dir)
+	if esperado := ts.URL + "/2024-09/"; m.Origem != esperado {
+		t.Errorf("Origem = %q, esperado %q", m.Origem, esperado)
+	}
+}
+
+func TestDownloaderNovaReferenciaBaixaNovamente(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	d := novoDownloaderTeste(ts.URL, dir)
+	d.SetReferencia("2024-09")
+	if err := d.Download(); err != nil {
+		t.Fatalf("Download(2024-09) erro: %v", err)
+	}
+
+	// Mesmo nome e tamanho, mas o ZIP do mês anterior não pode ser
+	// aproveitado para a nova referência
+	d.SetReferencia("2024-10")
+	if err := d.Download(); err != nil {
+		t.Fatalf("Download(2024-10) erro: %v", err)
+	}
+	conferirReferencia(t, dir, srv.conteudo, "2024-10")
+
+	if len(srv.ranges) != 2 {
+		t.Errorf("requisições = %d, esperado 2 (uma por referência)", len(srv.ranges))
+	}
+
+	// Na mesma referência o arquivo do manifesto é aproveitado
+	if err := d.Download(); err != nil {
+		t.Fatalf("Download(2024-10) repetido erro: %v", err)
+	}
+	if len(srv.ranges) != 2 {
+		t.Errorf("requisições = %d, esperado 2 (arquivo já baixado)", len(srv.ranges))
+	}
+}
+
+func TestDownloaderNovaReferenciaInterrompida(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	d := novoDownloaderTeste(ts.URL, dir)
+	d.SetReferencia("2024-09")
+	if err := d.Download(); err != nil {
+		t.Fatalf("Download(2024-09) erro: %v", err)
+	}
+
+	// O download da nova referência cai no meio e não há nova tentativa
+	terco := len(srv.conteudo) / 3
+	srv.corte = terco
+	d.SetReferencia("2024-10")
+	d.tentativas = 1
+	if err := d.Download(); err == nil {
+		t.Fatal("Download(2024-10) interrompido não retornou erro")
+	}
+	if m, err := LerManifesto(dir); err != nil || m == nil || m.Referencia != "2024-10" {
+		t.Fatalf("manifesto após interrupção = %+v, %v, esperado referência 2024-10", m, err)
+	}
+
+	// A nova execução retoma o .part da mesma referência
+	d.tentativas = 5
+	if err := d.Download(); err != nil {
+		t.Fatalf("Download(2024-10) retomado erro: %v", err)
+	}
+	conferirReferencia(t, dir, srv.conteudo, "2024-10")
+	if esperado := fmt.Sprintf("bytes=%d-", terco); srv.ranges[len(srv.ranges)-1] != esperado {
+		t.Errorf("Range = %q, esperado retomada com %q", srv.ranges, esperado)
+	}
+}
+
+func TestDownloaderReferenciaInexistente(t *testing.T) {
+	srv := &servidorReceita{conteudo: zipTeste(t)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	d := novoDownloaderTeste(ts.URL, t.TempDir())
+	d.SetReferencia("2023-01")
+	err := d.Download()
+	if err == nil || !strings.Contains(err.Error(), "2024-09, 2024-10") {
+		t.Fatalf("Download() erro = %v, esperado referência não encontrada listando as disponíveis", err)
+	}
+	if len(srv.ranges) != 0 {
+		t.Errorf("requisições = %d, esperado 0", len(srv.ranges))
+	}
+}
+
+func TestDownloaderFonteLocal(t *testing.T) {
+	conteudo := zipTeste(t)
+	espelho := t.TempDir()
+	for _, ref := range []string{"2024-09", "2024-10", "temp"} {
+		if err := os.MkdirAll(filepath.Join(espelho, ref), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(espelho, "2024-10", "Empresas0.zip"), conteudo, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ultima, err := UltimaReferencia(context.Background(), NovaFonte(espelho, nil))
+	if err != nil || ultima != "2024-10" {
+		t.Fatalf("UltimaReferencia() = %q, %v, esperado %q", ultima, err, "2024-10")
+	}
+
+	dir := t.TempDir()
+	if err := novoDownloaderTeste(espelho, dir).Download(); err != nil {
+		t.Fatalf("Download() erro: %v", err)
+	}
+	conferirDownload(t, dir, conteudo)
+
+	m, _ := LerManifesto(dir)
+	if esperado := filepath.Join(espelho, "2024-10"); m.Origem != esperado {
+		t.Errorf("Origem = %q, esperado %q", m.Origem, esperado)
+	}
+}
+
+func TestMesReferencia(t *testing.T) {
+	dir := t.TempDir()
+	manifesto := &Manifesto{Referencia: "2024-10"}
+	if err := manifesto.Salvar(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	imp := NewImporter(&config.Config{ReferenciaBD: "2024-08"})
+	imp.zipDir = dir
+
+	if ref, err := imp.mesReferencia(); err != nil || ref != "2024-10" {
+		t.Errorf("mesReferencia() = %q, %v, esperado %q do manifesto", ref, err, "2024-10")
+	}
+
+	if err := imp.SetReferencia("2024/09"); err == nil {
+		t.Error("SetReferencia(\"2024/09\") sem erro, esperado formato inválido")
+	}
+	if err := imp.SetReferencia("2024-09"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := imp.mesReferencia(); err == nil {
+		t.Error("mesReferencia() sem erro, esperado conflito com o manifesto")
+	}
+
+	imp.zipDir = t.TempDir()
+	if ref, err := imp.mesReferencia(); err != nil || ref != "2024-09" {
+		t.Errorf("mesReferencia() = %q, %v, esperado %q", ref, err, "2024-09")
+	}
+}
+
+func TestFonteCache(t *testing.T) {
+	espelho := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(espelho, "2024-09"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	guardada := NovaFonteCache(NovaFonte(espelho, nil), time.Hour)
+	semCache := NovaFonteCache(NovaFonte(espelho, nil), 0)
+	for _, fonte := range []FonteDados{guardada, semCache} {
+		if refs, err := fonte.Referencias(context.Background()); err != nil || len(refs) != 1 {
+			t.Fatalf("Referencias() = %v, %v", refs, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(espelho, "2024-10"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if refs, _ := guardada.Referencias(context.Background()); len(refs) != 1 {
+		t.Errorf("Referencias() dentro da validade = %v, esperado a lista guardada", refs)
+	}
+	if refs, _ := semCache.Referencias(context.Background()); len(refs) != 2 {
+		t.Errorf("Referencias() vencida = %v, esperado 2024-09 e 2024-10", refs)
+	}
+
+	// A requisição cancelada não chega ao site
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("requisição inesperada: %s", r.URL)
+	}))
+	defer servidor.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NovaFonte(servidor.URL, servidor.Client()).Referencias(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Referencias() com contexto cancelado = %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+package importer
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
+)
+
+const loteEnderecos = 50000 // Linhas por transação
+
+// colunasEndereco são as colunas de endereco_normalizado, na ordem de
+// linhaEndereco
+var colunasEndereco = []string{
+	"cnpj", "cnpj_basico", "tipo_logradouro", "logradouro", "numero", "complemento", "bairro",
+	"cep", "cep_valido", "municipio", "uf", "chave", "chave_complemento",
+	"situacao_cadastral", "data_inicio_atividades",
+}
+
+// Enderecos gera a tabela endereco_normalizado a partir dos estabelecimentos
+// e importa a tabela cep_geo (CEP, latitude e longitude) do geocodificador.
+// A situação cadastral e a data de abertura são copiadas para que os
+// clusters de endereço não dependam da base da Receita no SQLite.
+type Enderecos struct {
+	origem  *sql.DB // Base da Receita (estabelecimento)
+	destino *sql.DB // Base de endereços; no PostgreSQL, a mesma conexão
+	dialeto database.Dialect
+}
+
+// NewEnderecos cria o gerador de endereços sobre as conexões informadas
+func NewEnderecos(origem, destino *sql.DB, dialeto database.Dialect) *Enderecos {
+	return &Enderecos{origem: origem, destino: destino, dialeto: dialeto}
+}
+
+// Normalizar recria endereco_normalizado com os endereços normalizados de
+// todos os estabelecimentos e retorna o número de linhas gravadas
+func (e *Enderecos) Normalizar() (int, error) {
+	ddl := []string{
+		`DROP TABLE IF EXISTS {endereco_normalizado}`,
+		`CREATE TABLE {endereco_normalizado} (
+			cnpj TEXT PRIMARY KEY,
+			cnpj_basico TEXT,
+			tipo_logradouro TEXT,
+			logradouro TEXT,
+			numero TEXT,
+			complemento TEXT,
+			bairro TEXT,
+			cep TEXT,
+			cep_valido INTEGER,
+			municipio TEXT,
+			uf TEXT,
+			chave TEXT,
+			chave_complemento TEXT,
+			situacao_cadastral TEXT,
+			data_inicio_atividades TEXT
+		)`,
+	}
+	for _, stmt := range ddl {
+		if _, err := e.destino.Exec(e.dialeto.Query(stmt)); err != nil {
+			return 0, fmt.Errorf("erro ao criar endereco_normalizado: %w", err)
+		}
+	}
+
+	rows, err := e.origem.Query(e.dialeto.Query(fmt.Sprintf(`
+		SELECT cnpj, COALESCE(cnpj_basico, ''), COALESCE(tipo_logradouro, ''), COALESCE(logradouro, ''),
+			COALESCE(numero, ''), COALESCE(complemento, ''), COALESCE(bairro, ''), COALESCE(cep, ''),
+			COALESCE(municipio, ''), COALESCE(uf, ''), COALESCE(situacao_cadastral, ''), COALESCE(%s, '')
+		FROM {estabelecimento}
+	`, e.dialeto.DateText("data_inicio_atividades"))))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	total := 0
+	lote := make([][]interface{}, 0, loteEnderecos)
+	for rows.Next() {
+		var cnpj, cnpjBasico, situacao, abertura string
+		var end endereco.Endereco
+		if err := rows.Scan(&cnpj, &cnpjBasico, &end.TipoLogradouro, &end.Logradouro, &end.Numero, &end.Complemento,
+			&end.Bairro, &end.CEP, &end.Municipio, &end.UF, &situacao, &abertura); err != nil {
+			return total, err
+		}
+		lote = append(lote, linhaEndereco(cnpj, cnpjBasico, endereco.Normalizar(end), situacao, abertura))
+
+		if len(lote) == loteEnderecos {
+			if err := e.gravarLote(lote); err != nil {
+				return total, err
+			}
+			total += len(lote)
+			lote = lote[:0]
+			if total%1000000 == 0 {
+				fmt.Printf("  %d endereços normalizados...\n", total)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return total, err
+	}
+	if err := e.gravarLote(lote); err != nil {
+		return total, err
+	}
+	total += len(lote)
+
+	indices := []string{
+		`CREATE INDEX idx_endereco_chave ON {endereco_normalizado}(chave)`,
+		`CREATE INDEX idx_endereco_chave_complemento ON {endereco_normalizado}(chave_complemento)`,
+		`CREATE INDEX idx_endereco_cep ON {endereco_normalizado}(cep)`,
+	}
+	for _, stmt := range indices {
+		if _, err := e.destino.Exec(e.dialeto.Query(stmt)); err != nil {
+			return total, fmt.Errorf("erro ao criar índice: %w", err)
+		}
+	}
+	return total, nil
+}
+
+// linhaEndereco monta a linha de endereco_normalizado na ordem de
+// colunasEndereco
+func linhaEndereco(cnpj, cnpjBasico string, n endereco.Normalizado, situacao, abertura string) []interface{} {
+	cepValido := 0
+	if n.CEPValido {
+		cepValido = 1
+	}
+	return []interface{}{
+		cnpj, cnpjBasico, n.TipoLogradouro, n.Logradouro, n.Numero, n.Complemento, n.Bairro,
+		n.CEP, cepValido, n.Municipio, n.UF, n.Chave, n.ChaveComplemento, situacao, abertura,
+	}
+}
+
+// gravarLote grava as linhas em uma transação: COPY no PostgreSQL e INSERT
+// preparado no SQLite
+func (e *Enderecos) gravarLote(linhas [][]interface{}) error {
+	if len(linhas) == 0 {
+		return nil
+	}
+	tx, err := e.destino.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := e.dialeto.Query(fmt.Sprintf("INSERT INTO {endereco_normalizado} VALUES (%s)",
+		strings.TrimSuffix(strings.Repeat("?, ", len(colunasEndereco)), ", ")))
+	if e.dialeto.Postgres {
+		query = pq.CopyInSchema("rede", "endereco_normalizado", colunasEndereco...)
+	}
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, linha := range linhas {
+		if _, err := stmt.Exec(linha...); err != nil {
+			return err
+		}
+	}
+	if e.dialeto.Postgres {
+		if _, err := stmt.Exec(); err != nil {
+			return err
+		}
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ImportarCEPs grava na tabela cep_geo os CEPs de um CSV com cabeçalho e as
+// colunas cep, latitude (ou lat) e longitude (ou lon, lng), separadas por
+// vírgula ou ponto e vírgula; outras colunas são ignoradas. CEPs já
+// importados são atualizados. Retorna os CEPs gravados e as linhas
+// ignoradas por CEP ou coordenada inválidos.
+func (e *Enderecos) ImportarCEPs(r io.Reader) (importados, ignorados int, err error) {
+	leitor := bufio.NewReader(r)
+	primeira, _ := leitor.Peek(4096)
+	csvReader := csv.NewReader(leitor)
+	if linha, _, _ := strings.Cut(string(primeira), "\n"); strings.Contains(linha, ";") {
+		csvReader.Comma = ';'
+	}
+	csvReader.FieldsPerRecord = -1
+
+	cabecalho, err := csvReader.Read()
+	if err != nil {
+		return 0, 0, fmt.Errorf("erro ao ler cabeçalho: %w", err)
+	}
+	colCEP, colLat, colLon := -1, -1, -1
+	for i, nome := range cabecalho {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(nome, "\ufeff"))) {
+		case "cep":
+			colCEP = i
+		case "latitude", "lat":
+			colLat = i
+		case "longitude", "lon", "lng":
+			colLon = i
+		}
+	}
+	if colCEP < 0 || colLat < 0 || colLon < 0 {
+		return 0, 0, fmt.Errorf("o cabeçalho deve ter as colunas cep, latitude e longitude")
+	}
+
+	if _, err := e.destino.Exec(e.dialeto.Query(`CREATE TABLE IF NOT EXISTS {cep_geo} (
+		cep TEXT PRIMARY KEY,
+		latitude DOUBLE PRECISION,
+		longitude DOUBLE PRECISION
+	)`)); err != nil {
+		return 0, 0, fmt.Errorf("erro ao criar cep_geo: %w", err)
+	}
+
+	upsert := e.dialeto.Query(`
+		INSERT INTO {cep_geo} (cep, latitude, longitude) VALUES (?, ?, ?)
+		ON CONFLICT (cep) DO UPDATE SET latitude = excluded.latitude, longitude = excluded.longitude
+	`)
+	gravar := func(linhas [][]interface{}) error {
+		tx, err := e.destino.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		stmt, err := tx.Prepare(upsert)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, linha := range linhas {
+			if _, err := stmt.Exec(linha...); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	}
+
+	lote := make([][]interface{}, 0, loteEnderecos)
+	for {
+		registro, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return importados, ignorados, err
+		}
+		if len(registro) <= max(colCEP, colLat, colLon) {
+			ignorados++
+			continue
+		}
+		cep := endereco.LimparCEP(registro[colCEP])
+		lat, errLat := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(registro[colLat]), ",", "."), 64)
+		lon, errLon := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(registro[colLon]), ",", "."), 64)
+		if len(cep) != 8 || errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			ignorados++
+			continue
+		}
+
+		lote = append(lote, []interface{}{cep, lat, lon})
+		if len(lote) == loteEnderecos {
+			if err := gravar(lote); err != nil {
+				return importados, ignorados, err
+			}
+			importados += len(lote)
+			lote = lote[:0]
+		}
+	}
+	if len(lote) > 0 {
+		if err := gravar(lote); err != nil {
+			return importados, ignorados, err
+		}
+		importados += len(lote)
+	}
+	return importados, ignorados, nil
+}
+
+// CreateAddressTable normaliza os endereços dos estabelecimentos na tabela
+// endereco_normalizado (base_endereco_normalizado no SQLite, schema rede no
+// PostgreSQL)
+func (i *Importer) CreateAddressTable() error {
+	fmt.Println("🏠 Normalizando endereços (endereco_normalizado)...")
+	enderecos, fechar, err := i.abrirBasesEndereco()
+	if err != nil {
+		return err
+	}
+	defer fechar()
+
+	start := time.Now()
+	total, err := enderecos.Normalizar()
+	if err != nil {
+		return fmt.Errorf("erro ao normalizar endereços: %w", err)
+	}
+	fmt.Printf("  ✅ %d endereços normalizados em %v\n", total, time.Since(start))
+	return nil
+}
+
+// ImportCEPs importa o CSV de CEPs geocodificados usado pelo mapa
+func (i *Importer) ImportCEPs(arquivo string) error {
+	fmt.Printf("📍 Importando CEPs geocodificados de %s...\n", arquivo)
+	f, err := os.Open(arquivo)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enderecos, fechar, err := i.abrirBasesEndereco()
+	if err != nil {
+		return err
+	}
+	defer fechar()
+
+	start := time.Now()
+	importados, ignorados, err := enderecos.ImportarCEPs(f)
+	if err != nil {
+		return fmt.Errorf("erro ao importar CEPs: %w", err)
+	}
+	fmt.Printf("  ✅ %d CEPs importados em %v (%d linhas ignoradas)\n", importados, time.Since(start), ignorados)
+	return nil
+}
+
+// abrirBasesEndereco abre a base da Receita (somente leitura) e a base de
+// endereços no SQLite, ou a conexão única do PostgreSQL
+func (i *Importer) abrirBasesEndereco() (*Enderecos, func(), error) {
+	if i.cfg != nil && i.cfg.PostgresURL != "" {
+		dm, err := OpenDatabaseManager(i.cfg, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := dm.CreateSchemas(); err != nil {
+			dm.Close()
+			return nil, nil, err
+		}
+		return NewEnderecos(dm.GetDB(), dm.GetDB(), database.Dialect{Postgres: true}), func() { dm.Close() }, nil
+	}
+
+	receita := filepath.Join(i.dbDir, "cnpj.db")
+	base := filepath.Join(i.dbDir, "endereco_normalizado.db")
+	if i.cfg != nil && i.cfg.BaseReceita != "" {
+		receita = i.cfg.BaseReceita
+	}
+	if i.cfg != nil && i.cfg.BaseEnderecoNormalizado != "" {
+		base = i.cfg.BaseEnderecoNormalizado
+	}
+
+	if _, err := os.Stat(receita); err != nil {
+		return nil, nil, fmt.Errorf("base da receita não encontrada em %s", receita)
+	}
+	origem, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", receita))
+	if err != nil {
+		return nil, nil, err
+	}
+	destino, err := sql.Open("sqlite3", base)
+	if err != nil {
+		origem.Close()
+		return nil, nil, err
+	}
+	if _, err := destino.Exec("PRAGMA journal_mode = WAL; PRAGMA synchronous = NORMAL;"); err != nil {
+		origem.Close()
+		destino.Close()
+		return nil, nil, err
+	}
+	fechar := func() {
+		origem.Close()
+		destino.Close()
+	}
+	return NewEnderecos(origem, destino, database.Dialect{}), fechar, nil
+}
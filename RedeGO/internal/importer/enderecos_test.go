@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
+)
+
+func TestEnderecosNormalizarEGeocodificar(t *testing.T) {
+	dir := t.TempDir()
+	origem, err := sql.Open("sqlite3", filepath.Join(dir, "cnpj.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer origem.Close()
+	destino, err := sql.Open("sqlite3", filepath.Join(dir, "endereco_normalizado.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destino.Close()
+
+	stmts := []string{
+		`CREATE TABLE estabelecimento (cnpj TEXT, cnpj_basico TEXT, tipo_logradouro TEXT, logradouro TEXT, numero TEXT,
+			complemento TEXT, bairro TEXT, cep TEXT, municipio TEXT, uf TEXT, situacao_cadastral TEXT, data_inicio_atividades TEXT)`,
+		`INSERT INTO estabelecimento VALUES
+			('11111111000191', '11111111', 'R', 'DR CESARIO MOTA', '10', 'SL 1201', 'CENTRO', '01221020', '7107', 'SP', '02', '2020-01-10'),
+			('22222222000191', '22222222', 'RUA', 'DOUTOR CESARIO MOTA', '10', 'SALA 1201', NULL, '01221020', '7107', 'SP', '08', '2021-05-03'),
+			('33333333000191', '33333333', 'AV', 'PAULISTA', 'S/N', NULL, NULL, '01310100', '7107', 'SP', '02', '2019-02-01'),
+			('44444444000191', '44444444', NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, '02', NULL)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := origem.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := NewEnderecos(origem, destino, database.Dialect{})
+	total, err := e.Normalizar()
+	if err != nil {
+		t.Fatalf("Normalizar() erro: %v", err)
+	}
+	if total != 4 {
+		t.Errorf("Normalizar() = %d linhas, esperado 4", total)
+	}
+
+	var predios int
+	if err := destino.QueryRow(`SELECT COUNT(DISTINCT chave_complemento) FROM endereco_normalizado
+		WHERE cnpj IN ('11111111000191', '22222222000191')`).Scan(&predios); err != nil {
+		t.Fatal(err)
+	}
+	if predios != 1 {
+		t.Errorf("grafias da mesma sala geraram %d chaves, esperado 1", predios)
+	}
+
+	csv := "cep;latitude;longitude;cidade\n" +
+		"01221-020;-23,5440;-46,6490;SAO PAULO\n" +
+		"01310200;-23.5600;-46.6550;SAO PAULO\n" +
+		"01310400;-23.5620;-46.6570;SAO PAULO\n" +
+		"123;-23.0;-46.0;INVALIDO\n" +
+		"01000000;abc;-46.0;INVALIDO\n"
+	importados, ignorados, err := e.ImportarCEPs(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("ImportarCEPs() erro: %v", err)
+	}
+	if importados != 3 || ignorados != 2 {
+		t.Errorf("ImportarCEPs() = %d importados e %d ignorados, esperado 3 e 2", importados, ignorados)
+	}
+
+	// Reimportar atualiza a coordenada
+	if _, _, err := e.ImportarCEPs(strings.NewReader("lng,lat,cep\n-46.6500,-23.5450,01221020\n")); err != nil {
+		t.Fatalf("ImportarCEPs() erro na reimportação: %v", err)
+	}
+
+	locais, err := endereco.NewGeocodificador(destino, database.Dialect{}).Localizar(context.Background(),
+		[]string{"11111111000191", "33333333000191", "44444444000191", "99999999000191"})
+	if err != nil {
+		t.Fatalf("Localizar() erro: %v", err)
+	}
+	if len(locais) != 2 {
+		t.Fatalf("Localizar() = %+v, esperado 2 localizações", locais)
+	}
+	if l := locais["11111111000191"]; l.Precisao != endereco.PrecisaoCEP || l.Latitude != -23.545 || l.Longitude != -46.65 {
+		t.Errorf("localização pelo CEP = %+v", l)
+	}
+	if l := locais["33333333000191"]; l.Precisao != endereco.PrecisaoSetor || l.Latitude != -23.561 || l.Endereco.Numero != endereco.SemNumero {
+		t.Errorf("localização pelo setor = %+v", l)
+	}
+}
+
+func TestImportarCEPsCabecalhoInvalido(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "endereco_normalizado.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, _, err := NewEnderecos(db, db, database.Dialect{}).ImportarCEPs(strings.NewReader("codigo,x,y\n01000000,1,2\n")); err == nil {
+		t.Error("ImportarCEPs() sem colunas cep/latitude/longitude deveria falhar")
+	}
+}
@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"golang.org/x/net/html"
+)
+
+// FonteReceita é o endereço oficial dos dados abertos do CNPJ
+const FonteReceita = "https://arquivos.receitafederal.gov.br/dados/cnpj/dados_abertos_cnpj/"
+
+// FonteDados é a origem dos arquivos: o site da Receita, um espelho HTTP ou
+// um diretório local com o mesmo layout (uma pasta AAAA-MM por referência,
+// com os ZIPs dentro)
+type FonteDados interface {
+	// Referencias lista as referências disponíveis em ordem crescente
+	Referencias(ctx context.Context) ([]string, error)
+	// Arquivos lista as URLs (ou caminhos locais) dos ZIPs da referência
+	Arquivos(referencia string) ([]string, error)
+	// Origem retorna o endereço da pasta da referência
+	Origem(referencia string) string
+}
+
+// NovaFonte cria a fonte adequada à origem: URL http(s) ou diretório local.
+// Origem vazia usa o site da Receita.
+func NovaFonte(origem string, client *http.Client) FonteDados {
+	if origem == "" {
+		origem = FonteReceita
+	}
+	if fonteRemota(origem) {
+		if !strings.HasSuffix(origem, "/") {
+			origem += "/"
+		}
+		return &fonteHTTP{baseURL: origem, client: client}
+	}
+	return &fonteLocal{dir: origem}
+}
+
+// UltimaReferencia retorna a referência mais recente da fonte
+func UltimaReferencia(ctx context.Context, fonte FonteDados) (string, error) {
+	refs, err := fonte.Referencias(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(refs) == 0 {
+		return "", fmt.Errorf("nenhuma referência encontrada em %s", fonte.Origem(""))
+	}
+	return refs[len(refs)-1], nil
+}
+
+func fonteRemota(origem string) bool {
+	return strings.HasPrefix(origem, "http://") || strings.HasPrefix(origem, "https://")
+}
+
+// fonteHTTP lê as listagens HTML do site da Receita ou de um espelho
+type fonteHTTP struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (f *fonteHTTP) Origem(referencia string) string {
+	if referencia == "" {
+		return f.baseURL
+	}
+	return f.baseURL + referencia + "/"
+}
+
+func (f *fonteHTTP) Referencias(ctx context.Context) ([]string, error) {
+	links, err := f.links(ctx, f.baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, link := range links {
+		ref := strings.TrimSuffix(link, "/")
+		if strings.HasSuffix(link, "/") && database.ValidarReferencia(ref) == nil {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+func (f *fonteHTTP) Arquivos(referencia string) ([]string, error) {
+	url := f.Origem(referencia)
+	links, err := f.links(context.Background(), url)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, link := range links {
+		if !strings.HasSuffix(link, ".zip") {
+			continue
+		}
+		if !strings.HasPrefix(link, "http") {
+			link = url + link
+		}
+		files = append(files, link)
+	}
+	return files, nil
+}
+
+// links retorna os href de todos os <a> da página
+func (f *fonteHTTP) links(ctx context.Context, url string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status code %d", url, resp.StatusCode)
+	}
+
+	doc, err := html.Parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []string
+	var visitar func(*html.Node)
+	visitar = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, attr := range n.Attr {
+				if attr.Key == "href" {
+					links = append(links, attr.Val)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visitar(c)
+		}
+	}
+	visitar(doc)
+
+	return links, nil
+}
+
+// fonteLocal lê as referências de um diretório (espelho offline)
+type fonteLocal struct {
+	dir string
+}
+
+func (f *fonteLocal) Origem(referencia string) string {
+	return filepath.Join(f.dir, referencia)
+}
+
+func (f *fonteLocal) Referencias(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, entry := range entries {
+		if entry.IsDir() && database.ValidarReferencia(entry.Name()) == nil {
+			refs = append(refs, entry.Name())
+		}
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+func (f *fonteLocal) Arquivos(referencia string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(f.dir, referencia, "*.zip"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// fonteCache guarda a lista de referências de outra fonte por um tempo, para
+// que consultas frequentes (como /rede/dados_publicos_disponivel) não
+// acessem o site da Receita a cada requisição. Falhas não são guardadas.
+type fonteCache struct {
+	FonteDados
+	validade time.Duration
+
+	mu       sync.Mutex
+	refs     []string
+	obtidaEm time.Time
+}
+
+// NovaFonteCache envolve a fonte guardando a lista de referências por validade
+func NovaFonteCache(fonte FonteDados, validade time.Duration) FonteDados {
+	return &fonteCache{FonteDados: fonte, validade: validade}
+}
+
+func (f *fonteCache) Referencias(ctx context.Context) ([]string, error) {
+	f.mu.Lock()
+	if f.refs != nil && time.Since(f.obtidaEm) < f.validade {
+		refs := f.refs
+		f.mu.Unlock()
+		return refs, nil
+	}
+	f.mu.Unlock()
+
+	refs, err := f.FonteDados.Referencias(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.refs, f.obtidaEm = refs, time.Now()
+	f.mu.Unlock()
+	return refs, nil
+}
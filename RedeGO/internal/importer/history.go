@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// tabelasHistorico são versionadas por referência mensal: cada linha tem
+// valido_de (primeira referência em que apareceu) e valido_ate (referência em
+// que deixou de valer; NULL para a versão atual)
+var tabelasHistorico = []string{"estabelecimento", "socios"}
+
+// createHistoryTables cria socios_historico e estabelecimento_historico
+func (p *Processor) createHistoryTables() error {
+	db := p.dbMgr.GetDB()
+
+	for _, table := range tabelasHistorico {
+		hist := p.dbMgr.TablePrefix(table + "_historico")
+
+		var stmts []string
+		if p.dbMgr.IsPostgreSQL() {
+			stmts = []string{
+				fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s)", hist, p.dbMgr.TablePrefix(table)),
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS valido_de VARCHAR(7)", hist),
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS valido_ate VARCHAR(7)", hist),
+			}
+		} else {
+			colunas := make([]string, 0, len(colunasTabelas[table])+2)
+			for _, coluna := range colunasTabelas[table] {
+				colunas = append(colunas, coluna+" TEXT")
+			}
+			colunas = append(colunas, "valido_de TEXT", "valido_ate TEXT")
+			stmts = []string{
+				fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", hist, strings.Join(colunas, ", ")),
+			}
+		}
+
+		stmts = append(stmts,
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_historico_cnpj ON %s(cnpj)", table, hist),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_historico_cnpj_basico ON %s(cnpj_basico)", table, hist),
+			// MAX(valido_de) dá a referência em uso (/rede/dados_publicos_disponivel)
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_historico_valido_de ON %s(valido_de)", table, hist),
+		)
+		if table == "socios" {
+			stmts = append(stmts,
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_socios_historico_cnpj_cpf_socio ON %s(cnpj_cpf_socio)", hist),
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_socios_historico_nome_socio ON %s(nome_socio)", hist),
+			)
+		}
+
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("erro ao criar histórico de %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// seedHistory abre, na importação completa, a primeira versão de cada linha
+// quando o histórico ainda está vazio
+func (p *Processor) seedHistory(referencia string) error {
+	db := p.dbMgr.GetDB()
+
+	for _, table := range tabelasHistorico {
+		hist := p.dbMgr.TablePrefix(table + "_historico")
+		colunas := strings.Join(colunasTabelas[table], ", ")
+
+		query := p.dbMgr.AdaptPlaceholder(fmt.Sprintf(`
+			INSERT INTO %s (%s, valido_de)
+			SELECT %s, ? FROM %s
+			WHERE NOT EXISTS (SELECT 1 FROM %s WHERE valido_ate IS NULL)
+		`, hist, colunas, colunas, p.dbMgr.TablePrefix(table), hist))
+
+		fmt.Printf("  Versionando %s (referência %s)...\n", table, referencia)
+		if _, err := db.Exec(query, referencia); err != nil {
+			return fmt.Errorf("erro ao versionar %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// applyHistory versiona as alterações da referência antes que o staging seja
+// aplicado: fecha (valido_ate) as versões alteradas ou removidas e abre
+// (valido_de) as novas. Depende do change_log já preenchido por detectChanges,
+// que registra sócios e estabelecimentos ausentes da referência.
+func (p *Processor) applyHistory(tx *sql.Tx, referencia string) error {
+	estab := p.dbMgr.TablePrefix("estabelecimento")
+	stgEstab := p.dbMgr.StagingTable("estabelecimento")
+	histEstab := p.dbMgr.TablePrefix("estabelecimento_historico")
+	colunasEstab := strings.Join(colunasTabelas["estabelecimento"], ", ")
+
+	stgSocios := p.dbMgr.StagingTable("socios")
+	histSocios := p.dbMgr.TablePrefix("socios_historico")
+	colunasSocios := strings.Join(colunasTabelas["socios"], ", ")
+
+	// Referência à tabela em UPDATE sem alias (SQLite e PostgreSQL aceitam o
+	// nome sem schema)
+	nomeHistSocios := "socios_historico"
+
+	mesmoSocio := func(a, b string) string {
+		partes := make([]string, 0, 4)
+		for _, coluna := range strings.Split(chaveSocio, ", ") {
+			partes = append(partes, fmt.Sprintf("COALESCE(%s.%s, '') = COALESCE(%s.%s, '')", a, coluna, b, coluna))
+		}
+		return strings.Join(partes, " AND ")
+	}
+
+	stmts := []struct {
+		descricao string
+		query     string
+		args      int
+	}{
+		{"fechando versões de estabelecimento", fmt.Sprintf(`
+			UPDATE %s SET valido_ate = ?
+			WHERE valido_ate IS NULL AND cnpj IN (
+				SELECT cnpj FROM (SELECT %s FROM %s EXCEPT SELECT %s FROM %s) d
+			)
+		`, histEstab, colunasEstab, stgEstab, colunasEstab, estab), 1},
+		{"fechando estabelecimentos excluídos", fmt.Sprintf(`
+			UPDATE %s SET valido_ate = ?
+			WHERE valido_ate IS NULL AND cnpj IN (
+				SELECT cnpj FROM %s WHERE referencia = ? AND tipo = '%s'
+			)
+		`, histEstab, p.dbMgr.TablePrefix("change_log"), AlteracaoExclusao), 2},
+		{"abrindo versões de estabelecimento", fmt.Sprintf(`
+			INSERT INTO %s (%s, valido_de)
+			SELECT %s, ? FROM %s n
+			WHERE NOT EXISTS (SELECT 1 FROM %s h WHERE h.cnpj = n.cnpj AND h.valido_ate IS NULL)
+		`, histEstab, colunasEstab, colunasEstab, stgEstab, histEstab), 1},
+		{"fechando versões de sócios", fmt.Sprintf(`
+			UPDATE %s SET valido_ate = ?
+			WHERE valido_ate IS NULL
+			AND cnpj_basico IN (
+				SELECT cnpj_basico FROM %s WHERE referencia = ? AND tipo = '%s'
+			)
+			AND NOT EXISTS (SELECT 1 FROM %s n WHERE %s)
+		`, histSocios, p.dbMgr.TablePrefix("change_log"), AlteracaoSocioSaida, stgSocios, mesmoSocio("n", nomeHistSocios)), 2},
+		{"abrindo versões de sócios", fmt.Sprintf(`
+			INSERT INTO %s (%s, valido_de)
+			SELECT %s, ? FROM %s n
+			WHERE NOT EXISTS (SELECT 1 FROM %s h WHERE h.valido_ate IS NULL AND %s)
+		`, histSocios, colunasSocios, colunasSocios, stgSocios, histSocios, mesmoSocio("n", "h")), 1},
+	}
+
+	for _, stmt := range stmts {
+		fmt.Printf("  Histórico: %s...\n", stmt.descricao)
+		args := make([]interface{}, stmt.args)
+		for i := range args {
+			args[i] = referencia
+		}
+		if _, err := tx.Exec(p.dbMgr.AdaptPlaceholder(stmt.query), args...); err != nil {
+			return fmt.Errorf("erro no histórico (%s): %w", stmt.descricao, err)
+		}
+	}
+	return nil
+}
@@ -1,7 +1,11 @@
 package importer
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/config"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
 )
 
 // Importer gerencia a importação de dados da Receita Federal
@@ -13,41 +17,148 @@ type Importer struct {
 	csvDir    string
 	dbDir     string
 	
-	// URLs
-	baseURL   string
+	// Origem dos arquivos: URL da Receita, espelho HTTP ou diretório local
+	fonte     string
+
+	// Referência mensal (AAAA-MM) fixada com SetReferencia
+	referencia string
+
+	// Leitores de CSV do pipeline; 0 usa a configuração ou o número de CPUs
+	workers int
 }
 
 // NewImporter cria um novo importador
 func NewImporter(cfg *config.Config) *Importer {
+	fonte := FonteReceita
+	if cfg != nil && cfg.FonteDadosPublicos != "" {
+		fonte = cfg.FonteDadosPublicos
+	}
+
 	return &Importer{
-		cfg:     cfg,
-		zipDir:  "dados-publicos-zip",
-		csvDir:  "dados-publicos",
-		dbDir:   "bases",
-		baseURL: "https://arquivos.receitafederal.gov.br/dados/cnpj/dados_abertos_cnpj/",
+		cfg:    cfg,
+		zipDir: "dados-publicos-zip",
+		csvDir: "dados-publicos",
+		dbDir:  "bases",
+		fonte:  fonte,
 	}
 }
 
+// SetReferencia fixa a referência (AAAA-MM) baixada e registrada no
+// histórico e no change_log
+func (i *Importer) SetReferencia(referencia string) error {
+	if err := database.ValidarReferencia(referencia); err != nil {
+		return err
+	}
+	i.referencia = referencia
+	return nil
+}
+
+// SetFonte define a origem dos arquivos (URL ou diretório local)
+func (i *Importer) SetFonte(fonte string) {
+	i.fonte = fonte
+}
+
+// SetWorkers define o número de leitores de CSV usados no processamento
+func (i *Importer) SetWorkers(workers int) {
+	i.workers = workers
+}
+
+// mesReferencia resolve a referência dos arquivos: a fixada em SetReferencia,
+// a do manifesto do download, referencia_bd (se no formato AAAA-MM) ou o mês
+// atual. Uma referência fixada diferente da do manifesto é erro, pois os ZIPs
+// da pasta seriam registrados com o mês errado.
+func (i *Importer) mesReferencia() (string, error) {
+	manifesto, err := LerManifesto(i.zipDir)
+	if err != nil {
+		return "", err
+	}
+
+	if i.referencia != "" {
+		if manifesto != nil && manifesto.Referencia != "" && manifesto.Referencia != i.referencia {
+			return "", fmt.Errorf("os arquivos em %s são da referência %s, não de %s; baixe novamente com -ref %s", i.zipDir, manifesto.Referencia, i.referencia, i.referencia)
+		}
+		return i.referencia, nil
+	}
+	if manifesto != nil && manifesto.Referencia != "" {
+		return manifesto.Referencia, nil
+	}
+	if i.cfg != nil && database.ValidarReferencia(i.cfg.ReferenciaBD) == nil {
+		return i.cfg.ReferenciaBD, nil
+	}
+	return time.Now().Format("2006-01"), nil
+}
+
 // DownloadFiles baixa os arquivos ZIP da Receita Federal
 func (i *Importer) DownloadFiles() error {
-	downloader := NewDownloader(i.baseURL, i.zipDir)
+	downloader := NewDownloader(i.fonte, i.zipDir)
+	downloader.SetReferencia(i.referencia)
 	return downloader.Download()
 }
 
 // ProcessFiles processa os arquivos ZIP e cria o banco cnpj.db
 func (i *Importer) ProcessFiles() error {
 	processor := NewProcessorWithConfig(i.zipDir, i.csvDir, i.dbDir, i.cfg)
+	referencia, err := i.mesReferencia()
+	if err != nil {
+		return err
+	}
+	processor.referencia = referencia
+	processor.workers = i.workers
 	return processor.Process()
 }
 
+// ProcessIncremental aplica os arquivos ZIP sobre a base existente,
+// registrando as alterações da referência em change_log
+func (i *Importer) ProcessIncremental() error {
+	processor := NewProcessorWithConfig(i.zipDir, i.csvDir, i.dbDir, i.cfg)
+	referencia, err := i.mesReferencia()
+	if err != nil {
+		return err
+	}
+	processor.referencia = referencia
+	processor.workers = i.workers
+	return processor.ProcessIncremental()
+}
+
 // CreateLinkTables cria as tabelas de ligação (rede.db)
 func (i *Importer) CreateLinkTables() error {
 	linker := NewLinker(i.dbDir)
 	return linker.CreateLinks()
 }
 
-// CreateSearchIndexes cria os índices de busca (rede_search.db)
+// CreateSearchIndexes cria os índices de busca: rede_search.db no SQLite ou
+// a visão materializada rede.busca no PostgreSQL
 func (i *Importer) CreateSearchIndexes() error {
+	if i.cfg != nil && i.cfg.PostgresURL != "" {
+		return i.createSearchPostgreSQL()
+	}
 	indexer := NewIndexer(i.dbDir)
+	if i.cfg != nil {
+		indexer.SetBases(i.cfg.BaseReceita, i.cfg.BaseRede, i.cfg.BaseRedeSearch)
+	}
 	return indexer.CreateIndexes()
 }
+
+// createSearchPostgreSQL recria a visão materializada de busca
+func (i *Importer) createSearchPostgreSQL() error {
+	fmt.Println("🔍 Criando índice de busca (rede.busca)...")
+	dm, err := OpenDatabaseManager(i.cfg, "")
+	if err != nil {
+		return err
+	}
+	defer dm.Close()
+
+	start := time.Now()
+	for _, stmt := range GetBuscaPostgreSQL() {
+		if _, err := dm.GetDB().Exec(stmt); err != nil {
+			return fmt.Errorf("erro ao criar índice de busca: %w", err)
+		}
+	}
+
+	var count int
+	if err := dm.GetDB().QueryRow("SELECT COUNT(*) FROM rede.busca").Scan(&count); err != nil {
+		return err
+	}
+	fmt.Printf("  ✅ %d entradas indexadas em %v\n", count, time.Since(start))
+	return nil
+}
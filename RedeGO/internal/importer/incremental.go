@@ -0,0 +1,465 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/config"
+)
+
+// Tipos de alteração registrados em change_log
+const (
+	AlteracaoInclusao     = "inclusao"      // Novo estabelecimento
+	AlteracaoExclusao     = "exclusao"      // Estabelecimento ausente da referência
+	AlteracaoSituacao     = "situacao"      // Situação cadastral alterada
+	AlteracaoEndereco     = "endereco"      // Endereço alterado
+	AlteracaoCapital      = "capital"       // Capital social alterado
+	AlteracaoSocioEntrada = "socio_entrada" // Sócio incluído no QSA
+	AlteracaoSocioSaida   = "socio_saida"   // Sócio removido do QSA
+)
+
+// tabelasLookup são substituídas integralmente quando presentes na referência
+var tabelasLookup = []string{"cnae", "motivo", "municipio", "natureza_juridica", "pais", "qualificacao_socio"}
+
+// colunasTabelas lista as colunas comparadas e copiadas no merge incremental
+var colunasTabelas = map[string][]string{
+	"empresas": {
+		"cnpj_basico", "razao_social", "natureza_juridica", "qualificacao_responsavel",
+		"capital_social", "porte_empresa", "ente_federativo_responsavel",
+	},
+	"estabelecimento": {
+		"cnpj", "cnpj_basico", "cnpj_ordem", "cnpj_dv", "matriz_filial", "nome_fantasia",
+		"situacao_cadastral", "data_situacao_cadastral", "motivo_situacao_cadastral",
+		"nome_cidade_exterior", "pais", "data_inicio_atividades", "cnae_fiscal",
+		"cnae_fiscal_secundaria", "tipo_logradouro", "logradouro", "numero", "complemento",
+		"bairro", "cep", "uf", "municipio", "ddd1", "telefone1", "ddd2", "telefone2",
+		"ddd_fax", "fax", "correio_eletronico", "situacao_especial", "data_situacao_especial",
+	},
+	"socios": {
+		"cnpj", "cnpj_basico", "identificador_de_socio", "nome_socio", "cnpj_cpf_socio",
+		"qualificacao_socio", "data_entrada_sociedade", "pais", "representante_legal",
+		"nome_representante", "qualificacao_representante_legal", "faixa_etaria",
+	},
+	"simples": {
+		"cnpj_basico", "opcao_simples", "data_opcao_simples", "data_exclusao_simples",
+		"opcao_mei", "data_opcao_mei", "data_exclusao_mei",
+	},
+}
+
+// chavesTabelas identifica cada linha nas tabelas com upsert
+var chavesTabelas = map[string]string{
+	"empresas":        "cnpj_basico",
+	"estabelecimento": "cnpj",
+	"simples":         "cnpj_basico",
+}
+
+// chaveSocio identifica um sócio no QSA para detectar entradas e saídas
+const chaveSocio = "cnpj_basico, cnpj_cpf_socio, nome_socio, qualificacao_socio"
+
+// ProcessIncremental carrega uma nova referência mensal em tabelas de
+// staging, registra em change_log as diferenças em relação à base atual e
+// aplica as alterações sem recriar o banco
+func (p *Processor) ProcessIncremental() error {
+	referencia := p.mesReferencia()
+	fmt.Printf("📦 Iniciando importação incremental (referência %s)...\n", referencia)
+
+	if err := os.MkdirAll(p.dbDir, 0755); err != nil {
+		return err
+	}
+
+	cfg := p.cfg
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+
+	var err error
+	p.dbMgr, err = OpenDatabaseManager(cfg, filepath.Join(p.dbDir, "cnpj.db"))
+	if err != nil {
+		return fmt.Errorf("erro ao abrir banco: %w", err)
+	}
+	defer p.dbMgr.Close()
+
+	if err := p.dbMgr.CreateSchemas(); err != nil {
+		return fmt.Errorf("erro ao criar schemas: %w", err)
+	}
+
+	if err := p.migrateTables(); err != nil {
+		return err
+	}
+
+	if err := p.createChangeLog(); err != nil {
+		return err
+	}
+
+	if err := p.createHistoryTables(); err != nil {
+		return err
+	}
+
+	if err := p.createStagingTables(); err != nil {
+		return err
+	}
+	defer p.dropStagingTables()
+
+	// Carrega a nova referência nas tabelas de staging
+	p.staging = true
+	defer func() { p.staging = false }()
+
+	zipFiles, err := filepath.Glob(filepath.Join(p.zipDir, "*.zip"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📋 Encontrados %d arquivos ZIP para processar\n\n", len(zipFiles))
+
+	if err := conferirZips(p.zipDir, zipFiles); err != nil {
+		return err
+	}
+
+	p.qualidade = NovoRelatorioQualidade(p.mesReferencia())
+	if err := p.importarZips(zipFiles); err != nil {
+		return err
+	}
+
+	fmt.Println("\n🔍 Preparando staging...")
+	if err := p.prepareStaging(); err != nil {
+		return err
+	}
+
+	// Detecção e aplicação na mesma transação: uma falha não deixa
+	// change_log sem as alterações correspondentes (ou vice-versa)
+	tx, err := p.dbMgr.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	fmt.Println("\n🔎 Detectando alterações...")
+	if err := p.detectChanges(tx, referencia); err != nil {
+		return err
+	}
+
+	fmt.Println("\n💾 Aplicando alterações...")
+	if err := p.applyHistory(tx, referencia); err != nil {
+		return err
+	}
+	if err := p.applyChanges(tx, referencia); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if err := p.printChangeSummary(referencia); err != nil {
+		return err
+	}
+
+	if err := p.printStats(); err != nil {
+		return err
+	}
+
+	if err := p.salvarQualidade("incremental"); err != nil {
+		return err
+	}
+
+	fmt.Println("\n✅ Importação incremental concluída!")
+	fmt.Println("ℹ️  Recrie as tabelas de ligação (-links) e os índices de busca (-search)")
+	return nil
+}
+
+// createChangeLog cria a tabela change_log se ainda não existir
+func (p *Processor) createChangeLog() error {
+	db := p.dbMgr.GetDB()
+	tabela := p.dbMgr.TablePrefix("change_log")
+
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			referencia TEXT NOT NULL,
+			cnpj_basico TEXT NOT NULL,
+			cnpj TEXT,
+			tipo TEXT NOT NULL,
+			valor_anterior TEXT,
+			valor_novo TEXT
+		)`, tabela),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_change_log_referencia ON %s(referencia)", tabela),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_change_log_cnpj ON %s(cnpj)", tabela),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_change_log_cnpj_basico ON %s(cnpj_basico)", tabela),
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("erro ao criar change_log: %w", err)
+		}
+	}
+	return nil
+}
+
+// stagingTables lista as tabelas carregadas em staging
+func stagingTables() []string {
+	tables := append([]string{}, tabelasLookup...)
+	return append(tables, "empresas", "estabelecimento", "socios", "simples")
+}
+
+// createStagingTables cria tabelas stg_* vazias com a estrutura das definitivas
+func (p *Processor) createStagingTables() error {
+	db := p.dbMgr.GetDB()
+
+	for _, table := range stagingTables() {
+		stg := p.dbMgr.StagingTable(table)
+		atual := p.dbMgr.TablePrefix(table)
+
+		var create string
+		if p.dbMgr.IsPostgreSQL() {
+			create = fmt.Sprintf("CREATE UNLOGGED TABLE %s (LIKE %s)", stg, atual)
+		} else {
+			create = fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s WHERE 0", stg, atual)
+		}
+
+		if _, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", stg)); err != nil {
+			return fmt.Errorf("erro ao remover staging %s: %w", stg, err)
+		}
+		if _, err := db.Exec(create); err != nil {
+			return fmt.Errorf("erro ao criar staging %s: %w", stg, err)
+		}
+	}
+	return nil
+}
+
+// dropStagingTables remove as tabelas de staging
+func (p *Processor) dropStagingTables() {
+	db := p.dbMgr.GetDB()
+	for _, table := range stagingTables() {
+		db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", p.dbMgr.StagingTable(table)))
+	}
+}
+
+// prepareStaging indexa as chaves do staging e preenche o CNPJ da matriz
+// nos sócios
+func (p *Processor) prepareStaging() error {
+	db := p.dbMgr.GetDB()
+
+	stmts := []string{
+		fmt.Sprintf("CREATE INDEX idx_stg_empresas_cnpj_basico ON %s(cnpj_basico)", p.dbMgr.StagingTable("empresas")),
+		fmt.Sprintf("CREATE INDEX idx_stg_estabelecimento_cnpj ON %s(cnpj)", p.dbMgr.StagingTable("estabelecimento")),
+		fmt.Sprintf("CREATE INDEX idx_stg_estabelecimento_cnpj_basico ON %s(cnpj_basico)", p.dbMgr.StagingTable("estabelecimento")),
+		fmt.Sprintf("CREATE INDEX idx_stg_socios_cnpj_basico ON %s(cnpj_basico)", p.dbMgr.StagingTable("socios")),
+		fmt.Sprintf("CREATE INDEX idx_stg_simples_cnpj_basico ON %s(cnpj_basico)", p.dbMgr.StagingTable("simples")),
+	}
+	stmts = append(stmts, p.preencherCNPJSocios(p.dbMgr.StagingTable("socios"), p.dbMgr.StagingTable("estabelecimento")))
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("erro ao preparar staging: %w", err)
+		}
+	}
+	return nil
+}
+
+// enderecoExpr monta o endereço completo de um estabelecimento para comparação
+func enderecoExpr(alias string) string {
+	campos := []string{"tipo_logradouro", "logradouro", "numero", "complemento", "bairro", "cep", "municipio", "uf"}
+	partes := make([]string, len(campos))
+	for i, campo := range campos {
+		partes[i] = fmt.Sprintf("COALESCE(%s.%s, '')", alias, campo)
+	}
+	return strings.Join(partes, " || '|' || ")
+}
+
+// detectChanges compara staging e base atual e grava as diferenças por CNPJ
+// em change_log. Reaplicar uma referência já aplicada não gera registros.
+func (p *Processor) detectChanges(tx *sql.Tx, referencia string) error {
+	changeLog := p.dbMgr.TablePrefix("change_log")
+	empresas := p.dbMgr.TablePrefix("empresas")
+	estab := p.dbMgr.TablePrefix("estabelecimento")
+	socios := p.dbMgr.TablePrefix("socios")
+	stgEmpresas := p.dbMgr.StagingTable("empresas")
+	stgEstab := p.dbMgr.StagingTable("estabelecimento")
+	stgSocios := p.dbMgr.StagingTable("socios")
+
+	insert := fmt.Sprintf("INSERT INTO %s (referencia, cnpj_basico, cnpj, tipo, valor_anterior, valor_novo) ", changeLog)
+
+	// CNPJ da matriz, usado nas alterações registradas no nível da empresa
+	matriz := func(alias string) string {
+		return fmt.Sprintf("(SELECT m.cnpj FROM %s m WHERE m.cnpj_basico = %s.cnpj_basico AND m.matriz_filial = '1' LIMIT 1)", stgEstab, alias)
+	}
+
+	descricaoSocio := "d.nome_socio || ' (' || COALESCE(d.cnpj_cpf_socio, '') || ') - ' || COALESCE(d.qualificacao_socio, '')"
+
+	consultas := []struct {
+		tipo  string
+		query string
+	}{
+		{AlteracaoInclusao, fmt.Sprintf(`
+			SELECT ?, n.cnpj_basico, n.cnpj, '%s', NULL, n.situacao_cadastral
+			FROM %s n
+			WHERE NOT EXISTS (SELECT 1 FROM %s a WHERE a.cnpj = n.cnpj)
+		`, AlteracaoInclusao, stgEstab, estab)},
+		// Só com estabelecimentos no staging: uma referência sem esse arquivo
+		// não remove a base inteira
+		{AlteracaoExclusao, fmt.Sprintf(`
+			SELECT ?, a.cnpj_basico, a.cnpj, '%s', a.situacao_cadastral, NULL
+			FROM %s a
+			WHERE NOT EXISTS (SELECT 1 FROM %s n WHERE n.cnpj = a.cnpj)
+			AND EXISTS (SELECT 1 FROM %s)
+		`, AlteracaoExclusao, estab, stgEstab, stgEstab)},
+		{AlteracaoSituacao, fmt.Sprintf(`
+			SELECT ?, n.cnpj_basico, n.cnpj, '%s', a.situacao_cadastral, n.situacao_cadastral
+			FROM %s n JOIN %s a ON a.cnpj = n.cnpj
+			WHERE COALESCE(a.situacao_cadastral, '') <> COALESCE(n.situacao_cadastral, '')
+		`, AlteracaoSituacao, stgEstab, estab)},
+		{AlteracaoEndereco, fmt.Sprintf(`
+			SELECT ?, n.cnpj_basico, n.cnpj, '%s', %s, %s
+			FROM %s n JOIN %s a ON a.cnpj = n.cnpj
+			WHERE %s <> %s
+		`, AlteracaoEndereco, enderecoExpr("a"), enderecoExpr("n"), stgEstab, estab, enderecoExpr("a"), enderecoExpr("n"))},
+		{AlteracaoCapital, fmt.Sprintf(`
+			SELECT ?, n.cnpj_basico, %s, '%s', CAST(a.capital_social AS TEXT), CAST(n.capital_social AS TEXT)
+			FROM %s n JOIN %s a ON a.cnpj_basico = n.cnpj_basico
+			WHERE COALESCE(a.capital_social, 0) <> COALESCE(n.capital_social, 0)
+		`, matriz("n"), AlteracaoCapital, stgEmpresas, empresas)},
+		{AlteracaoSocioEntrada, fmt.Sprintf(`
+			SELECT ?, d.cnpj_basico, %s, '%s', NULL, %s
+			FROM (SELECT %s FROM %s EXCEPT SELECT %s FROM %s) d
+			WHERE EXISTS (SELECT 1 FROM %s e WHERE e.cnpj_basico = d.cnpj_basico)
+		`, matriz("d"), AlteracaoSocioEntrada, descricaoSocio, chaveSocio, stgSocios, chaveSocio, socios, empresas)},
+		// Só com sócios no staging: uma referência sem os arquivos de sócios
+		// não esvazia o QSA de todas as empresas
+		{AlteracaoSocioSaida, fmt.Sprintf(`
+			SELECT ?, d.cnpj_basico, %s, '%s', %s, NULL
+			FROM (SELECT %s FROM %s EXCEPT SELECT %s FROM %s) d
+			WHERE EXISTS (SELECT 1 FROM %s e WHERE e.cnpj_basico = d.cnpj_basico)
+			AND EXISTS (SELECT 1 FROM %s)
+		`, matriz("d"), AlteracaoSocioSaida, descricaoSocio, chaveSocio, socios, chaveSocio, stgSocios, stgEmpresas, stgSocios)},
+	}
+
+	for _, c := range consultas {
+		fmt.Printf("  Comparando %s...\n", c.tipo)
+		if _, err := tx.Exec(p.dbMgr.AdaptPlaceholder(insert+c.query), referencia); err != nil {
+			return fmt.Errorf("erro ao detectar alterações (%s): %w", c.tipo, err)
+		}
+	}
+
+	return nil
+}
+
+// applyChanges aplica o staging sobre as tabelas definitivas. Linhas novas
+// ou alteradas são substituídas pela chave (remoção + inserção, o que cobre
+// também a mudança de UF da chave particionada do PostgreSQL); os
+// estabelecimentos excluídos na referência são removidos e o QSA é
+// substituído por empresa quando houve entrada ou saída de sócios.
+func (p *Processor) applyChanges(tx *sql.Tx, referencia string) error {
+	// Tabelas de lookup: substituição integral quando vieram na referência
+	for _, table := range tabelasLookup {
+		atual := p.dbMgr.TablePrefix(table)
+		stg := p.dbMgr.StagingTable(table)
+		stmts := []string{
+			fmt.Sprintf("DELETE FROM %s WHERE EXISTS (SELECT 1 FROM %s)", atual, stg),
+			fmt.Sprintf("INSERT INTO %s (codigo, descricao) SELECT codigo, descricao FROM %s", atual, stg),
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("erro ao atualizar %s: %w", table, err)
+			}
+		}
+	}
+
+	// Upsert das tabelas com chave
+	for _, table := range []string{"empresas", "estabelecimento", "simples"} {
+		atual := p.dbMgr.TablePrefix(table)
+		stg := p.dbMgr.StagingTable(table)
+		chave := chavesTabelas[table]
+		colunas := strings.Join(colunasTabelas[table], ", ")
+
+		remover := fmt.Sprintf(`
+			DELETE FROM %s WHERE %s IN (
+				SELECT %s FROM (SELECT %s FROM %s EXCEPT SELECT %s FROM %s) d
+			)
+		`, atual, chave, chave, colunas, stg, colunas, atual)
+		inserir := fmt.Sprintf(`
+			INSERT INTO %s (%s)
+			SELECT %s FROM %s n
+			WHERE NOT EXISTS (SELECT 1 FROM %s a WHERE a.%s = n.%s)
+		`, atual, colunas, colunas, stg, atual, chave, chave)
+
+		fmt.Printf("  Atualizando %s...\n", table)
+		res, err := tx.Exec(remover)
+		if err != nil {
+			return fmt.Errorf("erro ao remover versões antigas de %s: %w", table, err)
+		}
+		alteradas, _ := res.RowsAffected()
+		res, err = tx.Exec(inserir)
+		if err != nil {
+			return fmt.Errorf("erro ao inserir %s: %w", table, err)
+		}
+		inseridas, _ := res.RowsAffected()
+		fmt.Printf("      ✅ %d alteradas, %d novas\n", alteradas, inseridas-alteradas)
+	}
+
+	fmt.Println("  Removendo estabelecimentos excluídos...")
+	excluir := p.dbMgr.AdaptPlaceholder(fmt.Sprintf(`
+		DELETE FROM %s WHERE cnpj IN (
+			SELECT cnpj FROM %s WHERE referencia = ? AND tipo = '%s'
+		)
+	`, p.dbMgr.TablePrefix("estabelecimento"), p.dbMgr.TablePrefix("change_log"), AlteracaoExclusao))
+	if _, err := tx.Exec(excluir, referencia); err != nil {
+		return fmt.Errorf("erro ao remover estabelecimentos excluídos: %w", err)
+	}
+
+	// QSA: substitui os sócios das empresas com entrada/saída e inclui os das
+	// empresas que ainda não tinham sócios. Sem sócios no staging o QSA atual
+	// é mantido.
+	socios := p.dbMgr.TablePrefix("socios")
+	stgSocios := p.dbMgr.StagingTable("socios")
+	colunas := strings.Join(colunasTabelas["socios"], ", ")
+
+	fmt.Println("  Atualizando socios...")
+	remover := p.dbMgr.AdaptPlaceholder(fmt.Sprintf(`
+		DELETE FROM %s WHERE cnpj_basico IN (
+			SELECT cnpj_basico FROM %s WHERE referencia = ? AND tipo IN ('%s', '%s')
+		)
+		AND EXISTS (SELECT 1 FROM %s)
+	`, socios, p.dbMgr.TablePrefix("change_log"), AlteracaoSocioEntrada, AlteracaoSocioSaida, stgSocios))
+	if _, err := tx.Exec(remover, referencia); err != nil {
+		return fmt.Errorf("erro ao remover QSA alterado: %w", err)
+	}
+
+	inserir := fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		SELECT %s FROM %s n
+		WHERE NOT EXISTS (SELECT 1 FROM %s a WHERE a.cnpj_basico = n.cnpj_basico)
+	`, socios, colunas, colunas, stgSocios, socios)
+	if _, err := tx.Exec(inserir); err != nil {
+		return fmt.Errorf("erro ao inserir QSA: %w", err)
+	}
+
+	return nil
+}
+
+// printChangeSummary imprime o total de alterações por tipo na referência
+func (p *Processor) printChangeSummary(referencia string) error {
+	db := p.dbMgr.GetDB()
+
+	query := p.dbMgr.AdaptPlaceholder(fmt.Sprintf(
+		"SELECT tipo, COUNT(*) FROM %s WHERE referencia = ? GROUP BY tipo ORDER BY tipo",
+		p.dbMgr.TablePrefix("change_log"),
+	))
+	rows, err := db.Query(query, referencia)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fmt.Printf("\n📝 Alterações na referência %s:\n", referencia)
+	for rows.Next() {
+		var tipo string
+		var total int
+		if err := rows.Scan(&tipo, &total); err != nil {
+			return err
+		}
+		fmt.Printf("  %s: %d\n", tipo, total)
+	}
+	return rows.Err()
+}
@@ -11,35 +11,50 @@ import (
 
 // Indexer cria os índices de busca (rede_search.db)
 type Indexer struct {
-	dbDir string
+	cnpjDB   string
+	redeDB   string
+	searchDB string
 }
 
-// NewIndexer cria um novo indexer
+// NewIndexer cria um novo indexer para as bases do diretório informado
 func NewIndexer(dbDir string) *Indexer {
-	return &Indexer{dbDir: dbDir}
+	return &Indexer{
+		cnpjDB:   filepath.Join(dbDir, "cnpj.db"),
+		redeDB:   filepath.Join(dbDir, "rede.db"),
+		searchDB: filepath.Join(dbDir, "rede_search.db"),
+	}
+}
+
+// SetBases define os caminhos das bases; valores vazios mantêm os padrões
+func (i *Indexer) SetBases(receita, rede, search string) {
+	if receita != "" {
+		i.cnpjDB = receita
+	}
+	if rede != "" {
+		i.redeDB = rede
+	}
+	if search != "" {
+		i.searchDB = search
+	}
 }
 
 // CreateIndexes cria os índices de busca full-text
 func (i *Indexer) CreateIndexes() error {
 	fmt.Println("🔍 Criando índices de busca...")
 	
-	cnpjDB := filepath.Join(i.dbDir, "cnpj.db")
-	redeDB := filepath.Join(i.dbDir, "rede.db")
-	searchDB := filepath.Join(i.dbDir, "rede_search.db")
-
 	// Abre conexão
-	db, err := sql.Open("sqlite3", searchDB)
+	db, err := sql.Open("sqlite3", i.searchDB)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
 	// Anexa bancos
-	_, err = db.Exec(fmt.Sprintf("ATTACH DATABASE '%s' as cnpj", cnpjDB))
+	_, err = db.Exec(fmt.Sprintf("ATTACH DATABASE '%s' as cnpj", i.cnpjDB))
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec(fmt.Sprintf("ATTACH DATABASE '%s' as rede", redeDB))
+	_, err = db.Exec(fmt.Sprintf("ATTACH DATABASE '%s' as rede", i.redeDB))
 	if err != nil {
 		return err
 	}
@@ -49,38 +64,57 @@ func (i *Indexer) CreateIndexes() error {
 -- Remove tabela antiga
 DROP TABLE IF EXISTS id_search;
 
--- Cria tabela FTS5 (Full-Text Search)
-CREATE VIRTUAL TABLE id_search USING fts5(id_descricao);
+-- Cria tabela FTS5 (Full-Text Search). id_descricao mantém o formato
+-- PJ_<cnpj>-<nome>; nome é a coluna ranqueada e as demais, sem índice,
+-- tipam o resultado e servem de filtro. Acentos são ignorados na busca.
+CREATE VIRTUAL TABLE id_search USING fts5(
+    id_descricao,
+    nome,
+    id UNINDEXED,
+    tipo UNINDEXED,
+    cnpj UNINDEXED,
+    uf UNINDEXED,
+    municipio UNINDEXED,
+    situacao UNINDEXED,
+    cnae UNINDEXED,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
 
 -- Insere dados para busca
 INSERT INTO id_search
-SELECT id_descricao
+SELECT id_descricao, nome, id, tipo, cnpj, uf, municipio, situacao, cnae
 FROM (
     -- PJ com razão social
-    SELECT 'PJ_' || te.cnpj || '-' || t.razao_social as id_descricao
+    SELECT 'PJ_' || te.cnpj || '-' || t.razao_social as id_descricao,
+           t.razao_social as nome, 'PJ_' || te.cnpj as id, 'PJ' as tipo, te.cnpj,
+           te.uf, m.descricao as municipio, te.situacao_cadastral as situacao, te.cnae_fiscal as cnae
     FROM cnpj.estabelecimento te 
     LEFT JOIN cnpj.empresas t ON t.cnpj_basico=te.cnpj_basico
+    LEFT JOIN cnpj.municipio m ON m.codigo=te.municipio
     WHERE te.matriz_filial='1'
     
     UNION ALL
     
     -- PJ com nome fantasia
-    SELECT 'PJ_' || te.cnpj || '-' || te.nome_fantasia as id_descricao 
+    SELECT 'PJ_' || te.cnpj || '-' || te.nome_fantasia as id_descricao,
+           te.nome_fantasia as nome, 'PJ_' || te.cnpj as id, 'PJ' as tipo, te.cnpj,
+           te.uf, m.descricao as municipio, te.situacao_cadastral as situacao, te.cnae_fiscal as cnae
     FROM cnpj.estabelecimento te 
+    LEFT JOIN cnpj.municipio m ON m.codigo=te.municipio
     WHERE te.nome_fantasia IS NOT NULL AND te.nome_fantasia <> ''
     
     UNION ALL
     
-    -- PF e PE da tabela de ligação
-    SELECT id1 as id_descricao
-    FROM rede.ligacao
-    WHERE substr(id1,1,3)<>'PJ_'
-    
-    UNION ALL
-    
-    SELECT id2 as id_descricao
-    FROM rede.ligacao
-    WHERE substr(id2,1,3)<>'PJ_'
+    -- PF (PF_<cpf>-<nome>) e PE (PE_<nome>) da tabela de ligação
+    SELECT id as id_descricao,
+           CASE WHEN substr(id,1,3)='PF_' THEN substr(id, instr(id,'-')+1) ELSE substr(id,4) END as nome,
+           id, substr(id,1,2) as tipo, NULL, NULL, NULL, NULL, NULL
+    FROM (
+        SELECT id1 as id FROM rede.ligacao
+        UNION
+        SELECT id2 as id FROM rede.ligacao
+    )
+    WHERE substr(id,1,3)<>'PJ_'
 ) as tunion
 GROUP BY id_descricao;
 `
@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestoArquivo é o nome do manifesto gravado na pasta dos ZIPs
+const ManifestoArquivo = "manifest.json"
+
+// Manifesto registra a referência baixada e o hash de cada arquivo
+type Manifesto struct {
+	Referencia string             `json:"referencia"`
+	Origem     string             `json:"origem"`
+	GeradoEm   time.Time          `json:"gerado_em"`
+	Arquivos   []ArquivoManifesto `json:"arquivos"`
+}
+
+// ArquivoManifesto descreve um ZIP baixado
+type ArquivoManifesto struct {
+	Nome    string `json:"nome"`
+	URL     string `json:"url"`
+	Tamanho int64  `json:"tamanho"`
+	SHA256  string `json:"sha256"`
+}
+
+// LerManifesto lê o manifesto da pasta. Retorna nil, sem erro, se não existir.
+func LerManifesto(dir string) (*Manifesto, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestoArquivo))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifesto
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("manifesto inválido: %w", err)
+	}
+	return &m, nil
+}
+
+// Salvar grava o manifesto na pasta (arquivo temporário + rename)
+func (m *Manifesto) Salvar(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	destino := filepath.Join(dir, ManifestoArquivo)
+	if err := os.WriteFile(destino+".part", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(destino+".part", destino)
+}
+
+// Arquivo retorna a entrada do manifesto com o nome informado
+func (m *Manifesto) Arquivo(nome string) *ArquivoManifesto {
+	if m == nil {
+		return nil
+	}
+	for i := range m.Arquivos {
+		if m.Arquivos[i].Nome == nome {
+			return &m.Arquivos[i]
+		}
+	}
+	return nil
+}
+
+// VerificarZip lê todas as entradas do ZIP, o que valida o diretório central
+// e o CRC32 de cada arquivo
+func VerificarZip(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("zip inválido %s: %w", filepath.Base(path), err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("zip inválido %s (%s): %w", filepath.Base(path), f.Name, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("zip corrompido %s (%s): %w", filepath.Base(path), f.Name, err)
+		}
+	}
+	return nil
+}
+
+// hashArquivo calcula o SHA-256 e o tamanho do arquivo
+func hashArquivo(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// conferirZips verifica, antes do processamento, se os ZIPs conferem com o
+// manifesto (quando existe) e se o diretório central de cada um é legível
+func conferirZips(dir string, zipFiles []string) error {
+	manifesto, err := LerManifesto(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range zipFiles {
+		nome := filepath.Base(path)
+
+		if entrada := manifesto.Arquivo(nome); entrada != nil {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if info.Size() != entrada.Tamanho {
+				return fmt.Errorf("%s tem %d bytes, manifesto indica %d; baixe novamente", nome, info.Size(), entrada.Tamanho)
+			}
+		}
+
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("zip inválido %s: %w; baixe novamente", nome, err)
+		}
+		r.Close()
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/normalizer"
+)
+
+// Parâmetros do pipeline de carga
+const (
+	tamanhoBloco            = 1000             // linhas enviadas de uma vez pelos leitores ao agrupador
+	tamanhoLote             = 50000            // linhas por lote gravado
+	maxGravadoresPostgreSQL = 8                // conexões de escrita simultâneas no PostgreSQL
+	intervaloProgresso      = 10 * time.Second // intervalo entre relatórios de progresso
+)
+
+// lote é um conjunto de linhas normalizadas de uma tabela, pronto para gravação
+type lote struct {
+	tabela string
+	linhas [][]interface{}
+}
+
+// tarefaCSV é um CSV dentro de um ZIP, lido inteiro por um único leitor
+type tarefaCSV struct {
+	arquivo *zip.File
+	tabela  string
+}
+
+// numWorkers retorna o número de leitores: o definido no processor, o da
+// configuração (importacao_workers) ou o número de CPUs
+func (p *Processor) numWorkers() int {
+	if p.workers > 0 {
+		return p.workers
+	}
+	if p.cfg != nil && p.cfg.ImportacaoWorkers > 0 {
+		return p.cfg.ImportacaoWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// carregarSchema carrega o schema de normalização: o padrão, estendido pelo
+// arquivo de schema_normalizacao, se configurado
+func (p *Processor) carregarSchema() error {
+	if p.schema != nil {
+		return nil
+	}
+	path := ""
+	if p.cfg != nil {
+		path = p.cfg.SchemaNormalizacao
+	}
+	schema, err := normalizer.CarregarSchema(path)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		fmt.Printf("📐 Schema de normalização: %s\n", path)
+	}
+	p.schema = schema
+	return nil
+}
+
+// importarZips carrega os CSVs dos ZIPs em um pipeline:
+//
+//	leitores (workers) → agrupador por tabela → fila limitada → gravadores
+//
+// Os leitores descompactam e normalizam CSVs concorrentemente; cada tabela
+// tem um agrupador que junta as linhas em lotes de tamanhoLote; os gravadores
+// consomem uma fila com capacidade igual ao seu número, de modo que, se o
+// banco for mais lento que a leitura, leitores e agrupadores ficam bloqueados
+// em vez de acumular memória. O SQLite tem um único gravador; o PostgreSQL
+// usa COPY FROM STDIN em até maxGravadoresPostgreSQL conexões.
+func (p *Processor) importarZips(zipFiles []string) error {
+	if err := p.carregarSchema(); err != nil {
+		return err
+	}
+	prog := novoProgresso()
+
+	var tarefas []tarefaCSV
+	for _, zipFile := range zipFiles {
+		r, err := zip.OpenReader(zipFile)
+		if err != nil {
+			return fmt.Errorf("erro ao abrir %s: %w", zipFile, err)
+		}
+		defer r.Close()
+
+		for _, f := range r.File {
+			tabela := p.getTableName(f.Name)
+			if tabela == "" {
+				continue // Ignora arquivos desconhecidos
+			}
+			tarefas = append(tarefas, tarefaCSV{arquivo: f, tabela: tabela})
+			prog.prever(tabela, int64(f.UncompressedSize64))
+		}
+	}
+
+	// Maiores primeiro, para que o último CSV não prenda o pipeline sozinho
+	sort.SliceStable(tarefas, func(i, j int) bool {
+		return tarefas[i].arquivo.UncompressedSize64 > tarefas[j].arquivo.UncompressedSize64
+	})
+
+	workers := p.numWorkers()
+	gravadores := 1
+	if p.dbMgr.IsPostgreSQL() {
+		gravadores = min(workers, maxGravadoresPostgreSQL)
+	}
+	fmt.Printf("⚙️  %d CSVs | %d leitores | %d gravador(es) | lotes de %d linhas\n\n", len(tarefas), workers, gravadores, tamanhoLote)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var erroOnce sync.Once
+	var erro error
+	falhar := func(err error) {
+		erroOnce.Do(func() {
+			erro = err
+			cancel()
+		})
+	}
+
+	// Gravadores
+	fila := make(chan *lote, gravadores)
+	var wgGravadores sync.WaitGroup
+	for i := 0; i < gravadores; i++ {
+		wgGravadores.Add(1)
+		go func() {
+			defer wgGravadores.Done()
+			for l := range fila {
+				if ctx.Err() != nil {
+					continue // Drena a fila após uma falha
+				}
+				n, err := p.gravarLote(l)
+				if err != nil {
+					falhar(fmt.Errorf("erro ao gravar lote de %s: %w", l.tabela, err))
+					continue
+				}
+				prog.gravados(l.tabela, n, len(l.linhas)-n)
+				p.qualidade.RegistrarN(l.tabela, CampoRegistro, MotivoRejeitadoBanco, int64(len(l.linhas)-n), "")
+			}
+		}()
+	}
+
+	// Agrupadores por tabela
+	blocos := make(map[string]chan [][]interface{})
+	for _, t := range tarefas {
+		if _, ok := blocos[t.tabela]; !ok {
+			blocos[t.tabela] = make(chan [][]interface{}, workers)
+		}
+	}
+	var wgAgrupadores sync.WaitGroup
+	for tabela, ch := range blocos {
+		wgAgrupadores.Add(1)
+		go func(tabela string, ch <-chan [][]interface{}) {
+			defer wgAgrupadores.Done()
+			atual := make([][]interface{}, 0, tamanhoLote)
+			enviar := func() {
+				select {
+				case fila <- &lote{tabela: tabela, linhas: atual}:
+				case <-ctx.Done():
+				}
+				atual = make([][]interface{}, 0, tamanhoLote)
+			}
+			for bloco := range ch {
+				atual = append(atual, bloco...)
+				if len(atual) >= tamanhoLote {
+					enviar()
+				}
+			}
+			if len(atual) > 0 {
+				enviar()
+			}
+		}(tabela, ch)
+	}
+
+	// Leitores
+	pendentes := make(chan tarefaCSV, len(tarefas))
+	for _, t := range tarefas {
+		pendentes <- t
+	}
+	close(pendentes)
+
+	var wgLeitores sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wgLeitores.Add(1)
+		go func() {
+			defer wgLeitores.Done()
+			for t := range pendentes {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := p.lerCSV(ctx, t, blocos[t.tabela], prog); err != nil {
+					falhar(fmt.Errorf("erro ao ler %s: %w", t.arquivo.Name, err))
+				}
+			}
+		}()
+	}
+
+	parar := make(chan struct{})
+	go prog.relatar(parar)
+
+	wgLeitores.Wait()
+	for _, ch := range blocos {
+		close(ch)
+	}
+	wgAgrupadores.Wait()
+	close(fila)
+	wgGravadores.Wait()
+	close(parar)
+
+	if erro != nil {
+		return erro
+	}
+	prog.resumo()
+
+	for nome, t := range prog.tabelas {
+		p.qualidade.DefinirCarga(nome, CargaTabela{
+			Gravados:    t.gravados.Load(),
+			Descartados: t.descartados.Load(),
+		})
+	}
+	return nil
+}
+
+// lerCSV lê e normaliza um CSV do ZIP, enviando as linhas em blocos ao
+// agrupador da tabela
+func (p *Processor) lerCSV(ctx context.Context, t tarefaCSV, saida chan<- [][]interface{}, prog *progresso) error {
+	rc, err := t.arquivo.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	fmt.Printf("    📄 Lendo %s (%s)...\n", t.arquivo.Name, t.tabela)
+
+	reader := csv.NewReader(prog.leitor(t.tabela, rc))
+	reader.Comma = ';'
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	// Rejeições acumuladas localmente e mescladas ao fim do CSV, para não
+	// disputar o relatório da importação a cada valor
+	qualidade := NovoRelatorioQualidade("")
+	defer p.qualidade.Mesclar(qualidade)
+
+	norm := p.schema.Normalizador(t.tabela).ComRelatorio(t.tabela, qualidade)
+	bloco := make([][]interface{}, 0, tamanhoBloco)
+	descartados := 0
+
+	enviar := func() bool {
+		select {
+		case saida <- bloco:
+			bloco = make([][]interface{}, 0, tamanhoBloco)
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			descartados++ // Ignora linhas com erro
+			qualidade.Registrar(t.tabela, CampoRegistro, MotivoCSVInvalido, err.Error())
+			continue
+		}
+
+		linha := linhaRegistro(norm, t.tabela, record)
+		if linha == nil {
+			descartados++
+			qualidade.Registrar(t.tabela, CampoRegistro, MotivoRegistroInvalido, strings.Join(record, ";"))
+			continue
+		}
+
+		bloco = append(bloco, linha)
+		if len(bloco) == tamanhoBloco && !enviar() {
+			return nil
+		}
+	}
+	if len(bloco) > 0 && !enviar() {
+		return nil
+	}
+
+	prog.gravados(t.tabela, 0, descartados)
+	return nil
+}
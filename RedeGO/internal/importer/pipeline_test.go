@@ -0,0 +1,289 @@
+package importer
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gravarZipCSV cria um ZIP com um CSV no formato da Receita (";" e aspas)
+func gravarZipCSV(t *testing.T, path, nome string, linhas [][]string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	csv, err := w.Create(nome)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, linha := range linhas {
+		campos := make([]string, len(linha))
+		for i, campo := range linha {
+			campos[i] = `"` + campo + `"`
+		}
+		fmt.Fprintln(csv, strings.Join(campos, ";"))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dvCNPJ calcula os dígitos verificadores do CNPJ (módulo 11)
+func dvCNPJ(base string) string {
+	digito := func(s string) byte {
+		soma, peso := 0, 2
+		for i := len(s) - 1; i >= 0; i-- {
+			soma += int(s[i]-'0') * peso
+			if peso++; peso > 9 {
+				peso = 2
+			}
+		}
+		if resto := soma % 11; resto >= 2 {
+			return byte('0' + 11 - resto)
+		}
+		return '0'
+	}
+	d1 := digito(base)
+	d2 := digito(base + string(d1))
+	return string([]byte{d1, d2})
+}
+
+func estabelecimentoTeste(basico, ordem, matriz, uf string) []string {
+	linha := make([]string, 30)
+	linha[0], linha[1], linha[2], linha[3] = basico, ordem, dvCNPJ(basico+ordem), matriz
+	linha[4] = "FANTASIA " + basico
+	linha[5] = "02"
+	linha[6] = "20200101"
+	linha[19] = uf
+	return linha
+}
+
+// fixturePipeline grava ZIPs com 120 empresas, estabelecimentos (matrizes e
+// uma filial), sócios e CNAEs, além de uma empresa duplicada e uma incompleta
+func fixturePipeline(t *testing.T, zipDir string) (empresas, estabelecimentos, socios [][]string) {
+	t.Helper()
+
+	for i := 1; len(empresas) < 120; i++ {
+		basico := fmt.Sprintf("%08d", i)
+		if dvCNPJ(basico+"0001") == "00" {
+			continue // O normalizador trata código só com zeros como vazio
+		}
+		empresas = append(empresas, []string{basico, "EMPRESA " + basico, "2062", "49", "1000,00", "01", ""})
+		estabelecimentos = append(estabelecimentos, estabelecimentoTeste(basico, "0001", "1", "SP"))
+		socios = append(socios, []string{basico, "2", "SOCIO " + basico, "***123456**", "49", "20200101", "", "", "", "00", "4"})
+	}
+	estabelecimentos = append(estabelecimentos, estabelecimentoTeste(empresas[0][0], "0002", "2", "RJ"))
+	// Valores sujos: e-mail e CEP inválidos viram NULL e entram no relatório de qualidade
+	estabelecimentos[1][27] = "contato.sem.arroba"
+	estabelecimentos[2][18] = "123"
+
+	gravarZipCSV(t, filepath.Join(zipDir, "Empresas0.zip"), "K3241.K03200Y0.D41012.EMPRECSV", append(empresas, empresas[0], []string{"00000999"}))
+	gravarZipCSV(t, filepath.Join(zipDir, "Estabelecimentos0.zip"), "K3241.K03200Y0.D41012.ESTABELE", estabelecimentos)
+	gravarZipCSV(t, filepath.Join(zipDir, "Socios0.zip"), "K3241.K03200Y0.D41012.SOCIOCSV", socios)
+	gravarZipCSV(t, filepath.Join(zipDir, "Cnaes.zip"), "F.K03200$Z.D41012.CNAECSV", [][]string{
+		{"6201501", "Desenvolvimento de programas de computador sob encomenda"},
+		{"4711302", "Comércio varejista de mercadorias em geral"},
+	})
+	return empresas, estabelecimentos, socios
+}
+
+func contarLinhas(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestProcessorPipeline(t *testing.T) {
+	dir := t.TempDir()
+	zipDir := filepath.Join(dir, "zip")
+	if err := os.MkdirAll(zipDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	fixturePipeline(t, zipDir)
+
+	p := NewProcessor(zipDir, filepath.Join(dir, "csv"), filepath.Join(dir, "bases"))
+	p.referencia = "2024-10"
+	p.workers = 3
+	if err := p.Process(); err != nil {
+		t.Fatalf("Process() erro: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "bases", "cnpj.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	contagens := []struct {
+		query    string
+		esperado int
+	}{
+		{"SELECT COUNT(*) FROM empresas", 121}, // SQLite sem chave: a duplicata é mantida
+		{"SELECT COUNT(*) FROM estabelecimento", 121},
+		{"SELECT COUNT(*) FROM socios", 120},
+		{"SELECT COUNT(*) FROM cnae", 2},
+		{"SELECT COUNT(*) FROM socios WHERE cnpj IS NULL", 0},
+		{"SELECT COUNT(*) FROM socios s JOIN estabelecimento e ON e.cnpj = s.cnpj WHERE e.matriz_filial = '1'", 120},
+		{"SELECT COUNT(*) FROM estabelecimento_historico WHERE valido_de = '2024-10'", 121},
+		{"SELECT COUNT(*) FROM estabelecimento WHERE correio_eletronico IS NOT NULL OR cep IS NOT NULL", 0},
+		{"SELECT COUNT(*) FROM qualidade_importacao WHERE referencia = '2024-10' AND modo = 'completa'", 1},
+	}
+	for _, c := range contagens {
+		if n := contarLinhas(t, db, c.query); n != c.esperado {
+			t.Errorf("%s = %d, esperado %d", c.query, n, c.esperado)
+		}
+	}
+
+	var relatorio string
+	if err := db.QueryRow("SELECT relatorio FROM qualidade_importacao").Scan(&relatorio); err != nil {
+		t.Fatal(err)
+	}
+	var r RelatorioQualidade
+	if err := json.Unmarshal([]byte(relatorio), &r); err != nil {
+		t.Fatal(err)
+	}
+	resumo := r.Resumo(20)
+	esperadas := map[string]int64{
+		"estabelecimento.correio_eletronico." + MotivoEmailInvalido: 1,
+		"estabelecimento.cep." + MotivoTamanhoInvalido:              1,
+		"empresas." + CampoRegistro + "." + MotivoRegistroInvalido:  1,
+	}
+	for _, rej := range resumo.Principais {
+		delete(esperadas, fmt.Sprintf("%s.%s.%s", rej.Tabela, rej.Campo, rej.Motivo))
+	}
+	if len(esperadas) > 0 {
+		t.Errorf("rejeições ausentes do relatório: %v (relatório: %s)", esperadas, relatorio)
+	}
+	if resumo.RegistrosGravados == 0 {
+		t.Error("RegistrosGravados = 0 no relatório")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bases", "qualidade-2024-10.md")); err != nil {
+		t.Errorf("relatório Markdown não gravado: %v", err)
+	}
+}
+
+func TestProcessorPipelineIncremental(t *testing.T) {
+	dir := t.TempDir()
+	zipDir := filepath.Join(dir, "zip")
+	if err := os.MkdirAll(zipDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	empresas, estabelecimentos, socios := fixturePipeline(t, zipDir)
+
+	p := NewProcessor(zipDir, filepath.Join(dir, "csv"), filepath.Join(dir, "bases"))
+	p.referencia = "2024-10"
+	p.workers = 2
+	if err := p.Process(); err != nil {
+		t.Fatalf("Process() erro: %v", err)
+	}
+
+	// Nova referência: capital alterado em uma empresa, situação e endereço
+	// alterados em outras duas, um sócio a menos, um sócio novo e a filial
+	// (último estabelecimento) fora da publicação
+	empresas[5][4] = "5000,00"
+	estabelecimentos[10][5] = "08"
+	estabelecimentos[20][14] = "RUA NOVA"
+	socios = append(socios[1:], []string{empresas[7][0], "2", "SOCIO NOVO", "***654321**", "49", "20241101", "", "", "", "00", "3"})
+	gravarZipCSV(t, filepath.Join(zipDir, "Empresas0.zip"), "K3241.K03200Y0.D41012.EMPRECSV", empresas)
+	gravarZipCSV(t, filepath.Join(zipDir, "Estabelecimentos0.zip"), "K3241.K03200Y0.D41012.ESTABELE", estabelecimentos[:len(estabelecimentos)-1])
+	gravarZipCSV(t, filepath.Join(zipDir, "Socios0.zip"), "K3241.K03200Y0.D41012.SOCIOCSV", socios)
+
+	p.referencia = "2024-11"
+	if err := p.ProcessIncremental(); err != nil {
+		t.Fatalf("ProcessIncremental() erro: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "bases", "cnpj.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	contagens := []struct {
+		query    string
+		esperado int
+	}{
+		{"SELECT COUNT(*) FROM change_log WHERE referencia = '2024-11' AND tipo = 'capital'", 1},
+		{"SELECT COUNT(*) FROM change_log WHERE referencia = '2024-11' AND tipo = 'socio_saida'", 1},
+		{"SELECT COUNT(*) FROM change_log WHERE referencia = '2024-11' AND tipo = 'exclusao'", 1},
+		{"SELECT COUNT(*) FROM change_log WHERE referencia = '2024-11' AND tipo = 'situacao' AND valor_anterior = '02' AND valor_novo = '08'", 1},
+		{"SELECT COUNT(*) FROM change_log WHERE referencia = '2024-11' AND tipo = 'endereco' AND valor_novo LIKE '%RUA NOVA%'", 1},
+		{"SELECT COUNT(*) FROM change_log WHERE referencia = '2024-11' AND tipo = 'socio_entrada' AND valor_novo LIKE 'SOCIO NOVO%'", 1},
+		{"SELECT COUNT(*) FROM change_log WHERE referencia = '2024-11'", 6},
+		{"SELECT COUNT(*) FROM estabelecimento", 120},
+		{"SELECT COUNT(*) FROM estabelecimento WHERE matriz_filial = '2'", 0},
+		{"SELECT COUNT(*) FROM estabelecimento WHERE situacao_cadastral = '08'", 1},
+		{"SELECT COUNT(*) FROM estabelecimento WHERE logradouro = 'RUA NOVA'", 1},
+		{"SELECT COUNT(*) FROM estabelecimento_historico WHERE valido_ate = '2024-11' AND matriz_filial = '2'", 1},
+		{"SELECT COUNT(*) FROM estabelecimento_historico WHERE valido_ate IS NULL", 120},
+		{"SELECT COUNT(*) FROM socios", 120},
+		{"SELECT COUNT(*) FROM socios WHERE cnpj IS NULL", 0},
+		{"SELECT COUNT(*) FROM socios_historico WHERE valido_ate IS NULL", 120},
+	}
+	for _, c := range contagens {
+		if n := contarLinhas(t, db, c.query); n != c.esperado {
+			t.Errorf("%s = %d, esperado %d", c.query, n, c.esperado)
+		}
+	}
+
+	// Referência publicada sem os arquivos de sócios: o QSA e o histórico de
+	// sócios ficam como estavam
+	if err := os.Remove(filepath.Join(zipDir, "Socios0.zip")); err != nil {
+		t.Fatal(err)
+	}
+	p.referencia = "2024-12"
+	if err := p.ProcessIncremental(); err != nil {
+		t.Fatalf("ProcessIncremental() sem sócios erro: %v", err)
+	}
+	contagens = []struct {
+		query    string
+		esperado int
+	}{
+		{"SELECT COUNT(*) FROM change_log WHERE referencia = '2024-12'", 0},
+		{"SELECT COUNT(*) FROM socios", 120},
+		{"SELECT COUNT(*) FROM socios_historico WHERE valido_ate IS NULL", 120},
+	}
+	for _, c := range contagens {
+		if n := contarLinhas(t, db, c.query); n != c.esperado {
+			t.Errorf("sem sócios: %s = %d, esperado %d", c.query, n, c.esperado)
+		}
+	}
+}
+
+func TestProgressoLinha(t *testing.T) {
+	pr := novoProgresso()
+	pr.prever("socios", 1000)
+
+	tab := pr.tabelas["socios"]
+	if linha := tab.linha("socios", pr.inicio); linha != "" {
+		t.Errorf("linha() antes da leitura = %q, esperado vazia", linha)
+	}
+
+	inicio := pr.inicio
+	tab.inicio.Store(inicio.UnixNano())
+	tab.lidos.Store(250)
+	pr.gravados("socios", 500, 3)
+
+	linha := tab.linha("socios", inicio.Add(10e9))
+	for _, parte := range []string{"500 registros", "50 reg/s", "25.0%", "ETA 30s"} {
+		if !strings.Contains(linha, parte) {
+			t.Errorf("linha() = %q, esperado conter %q", linha, parte)
+		}
+	}
+	if tab.descartados.Load() != 3 {
+		t.Errorf("descartados = %d, esperado 3", tab.descartados.Load())
+	}
+}
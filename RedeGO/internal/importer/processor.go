@@ -1,15 +1,14 @@
 package importer
 
 import (
-	"archive/zip"
-	"encoding/csv"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/config"
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/normalizer"
 )
 
 // Processor processa os arquivos ZIP e importa para o banco de dados
@@ -19,6 +18,22 @@ type Processor struct {
 	dbDir  string
 	dbMgr  *DatabaseManager
 	cfg    *config.Config
+
+	// referencia é o mês (AAAA-MM) dos arquivos, usado no histórico e no change_log
+	referencia string
+
+	// staging direciona as inserções para as tabelas stg_* (modo incremental)
+	staging bool
+
+	// workers é o número de leitores de CSV do pipeline; 0 usa a configuração
+	// ou o número de CPUs
+	workers int
+
+	// qualidade acumula os valores rejeitados pelos normalizadores
+	qualidade *RelatorioQualidade
+
+	// schema define a normalização dos campos por tabela
+	schema *normalizer.Schema
 }
 
 // NewProcessor cria um novo processor
@@ -85,12 +100,13 @@ func (p *Processor) Process() error {
 
 	fmt.Printf("📋 Encontrados %d arquivos ZIP para processar\n\n", len(zipFiles))
 
-	for i, zipFile := range zipFiles {
-		fmt.Printf("[%d/%d] 📦 Processando %s...\n", i+1, len(zipFiles), filepath.Base(zipFile))
-		
-		if err := p.processZipFile(zipFile); err != nil {
-			return fmt.Errorf("erro ao processar %s: %w", zipFile, err)
-		}
+	if err := conferirZips(p.zipDir, zipFiles); err != nil {
+		return err
+	}
+
+	p.qualidade = NovoRelatorioQualidade(p.mesReferencia())
+	if err := p.importarZips(zipFiles); err != nil {
+		return err
 	}
 
 	// Cria índices
@@ -99,15 +115,45 @@ func (p *Processor) Process() error {
 		return err
 	}
 
+	// Histórico por referência
+	fmt.Println("\n🕓 Criando histórico...")
+	if err := p.createHistoryTables(); err != nil {
+		return err
+	}
+	if err := p.seedHistory(p.mesReferencia()); err != nil {
+		return err
+	}
+
 	// Estatísticas
 	if err := p.printStats(); err != nil {
 		return err
 	}
 
+	if err := p.salvarQualidade("completa"); err != nil {
+		return err
+	}
+
 	fmt.Println("\n✅ Processamento concluído!")
 	return nil
 }
 
+// mesReferencia retorna a referência dos arquivos ou, se não informada, o mês atual
+func (p *Processor) mesReferencia() string {
+	if p.referencia != "" {
+		return p.referencia
+	}
+	return time.Now().Format("2006-01")
+}
+
+// tabelaDestino retorna a tabela que recebe as inserções: a definitiva ou,
+// no modo incremental, a de staging
+func (p *Processor) tabelaDestino(table string) string {
+	if p.staging {
+		return p.dbMgr.StagingTable(table)
+	}
+	return p.dbMgr.TablePrefix(table)
+}
+
 // createTables cria as tabelas no banco
 func (p *Processor) createTables() error {
 	db := p.dbMgr.GetDB()
@@ -126,90 +172,45 @@ func (p *Processor) createTables() error {
 		}
 	}
 	
-	return nil
+	return p.migrateTables()
 }
 
-// processZipFile processa um arquivo ZIP
-func (p *Processor) processZipFile(zipPath string) error {
-	r, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return err
+// migrateTables ajusta tabelas criadas por versões anteriores (PostgreSQL)
+func (p *Processor) migrateTables() error {
+	if !p.dbMgr.IsPostgreSQL() {
+		return nil
 	}
-	defer r.Close()
-
-	for _, f := range r.File {
-		if err := p.processCSVFromZip(f); err != nil {
-			return err
+	for _, stmt := range GetMigracoesPostgreSQL() {
+		if _, err := p.dbMgr.GetDB().Exec(stmt); err != nil {
+			return fmt.Errorf("erro ao migrar tabelas: %w", err)
 		}
 	}
-
 	return nil
 }
 
-// processCSVFromZip processa um CSV dentro do ZIP
-func (p *Processor) processCSVFromZip(f *zip.File) error {
-	db := p.dbMgr.GetDB()
-	rc, err := f.Open()
-	if err != nil {
-		return err
-	}
-	defer rc.Close()
-
-	// Determina tipo de arquivo
-	tableName := p.getTableName(f.Name)
-	if tableName == "" {
-		return nil // Ignora arquivos desconhecidos
-	}
-
-	fmt.Printf("    Importando %s para tabela %s...\n", f.Name, tableName)
-
-	// Lê CSV
-	reader := csv.NewReader(rc)
-	reader.Comma = ';'
-	reader.LazyQuotes = true
-	reader.FieldsPerRecord = -1
-
-	// Inicia transação
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// Prepara statement com normalizador
-	stmt, normalizer, err := p.prepareInsertStatement(tx, tableName)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	// Importa linhas
-	count := 0
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			continue // Ignora linhas com erro
-		}
-
-		if err := p.insertRecordWithNormalization(stmt, normalizer, tableName, record); err != nil {
-			continue // Ignora registros com erro
-		}
-
-		count++
-		if count%100000 == 0 {
-			fmt.Printf("      %d registros...\n", count)
-		}
-	}
-
-	if err := tx.Commit(); err != nil {
-		return err
+// preencherCNPJSocios grava nos sócios o CNPJ da matriz da empresa; as
+// linhas são carregadas sem ele porque os estabelecimentos são lidos em
+// paralelo
+func (p *Processor) preencherCNPJSocios(socios, estabelecimento string) string {
+	if p.dbMgr.IsPostgreSQL() {
+		return fmt.Sprintf(`
+			UPDATE %s s
+			SET cnpj = e.cnpj
+			FROM %s e
+			WHERE e.cnpj_basico = s.cnpj_basico
+			AND e.matriz_filial = '1'
+			AND s.cnpj IS NULL
+		`, socios, estabelecimento)
 	}
-
-	fmt.Printf("      ✅ %d registros importados\n", count)
-	return nil
+	return fmt.Sprintf(`
+		UPDATE %[1]s
+		SET cnpj = (
+			SELECT cnpj FROM %[2]s e
+			WHERE e.cnpj_basico = %[1]s.cnpj_basico
+			AND e.matriz_filial = '1'
+			LIMIT 1
+		)
+	`, socios, estabelecimento)
 }
 
 // getTableName determina o nome da tabela baseado no nome do arquivo
@@ -259,22 +260,10 @@ func (p *Processor) createIndexes() error {
 		}
 	}
 	
-	// Atualiza CNPJ dos sócios (apenas se não for PostgreSQL, pois lá já é feito durante import)
-	if !p.dbMgr.IsPostgreSQL() {
-		fmt.Println("  Atualizando CNPJ dos sócios...")
-		_, err := db.Exec(`
-			UPDATE socios
-			SET cnpj = (
-				SELECT cnpj FROM estabelecimento 
-				WHERE estabelecimento.cnpj_basico = socios.cnpj_basico 
-				AND estabelecimento.matriz_filial = '1'
-				LIMIT 1
-			)
-		`)
-		return err
-	}
-	
-	return nil
+	// Atualiza CNPJ dos sócios
+	fmt.Println("  Atualizando CNPJ dos sócios...")
+	_, err := db.Exec(p.preencherCNPJSocios(p.dbMgr.TablePrefix("socios"), p.dbMgr.TablePrefix("estabelecimento")))
+	return err
 }
 
 // printStats imprime estatísticas
@@ -0,0 +1,148 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// progressoTabela acompanha a carga de uma tabela. O percentual e o ETA são
+// calculados sobre os bytes descompactados lidos dos CSVs.
+type progressoTabela struct {
+	previsto    int64 // bytes descompactados dos CSVs da tabela
+	lidos       atomic.Int64
+	gravados    atomic.Int64
+	descartados atomic.Int64
+	inicio      atomic.Int64 // UnixNano do primeiro byte lido
+	fim         atomic.Int64 // UnixNano do último lote gravado
+}
+
+// progresso agrega o andamento de todas as tabelas do pipeline. O mapa é
+// preenchido antes de os leitores começarem e só é lido depois disso.
+type progresso struct {
+	inicio  time.Time
+	tabelas map[string]*progressoTabela
+}
+
+func novoProgresso() *progresso {
+	return &progresso{
+		inicio:  time.Now(),
+		tabelas: make(map[string]*progressoTabela),
+	}
+}
+
+// prever soma ao total esperado da tabela o tamanho de um CSV
+func (pr *progresso) prever(tabela string, bytes int64) {
+	t, ok := pr.tabelas[tabela]
+	if !ok {
+		t = &progressoTabela{}
+		pr.tabelas[tabela] = t
+	}
+	t.previsto += bytes
+}
+
+// gravados contabiliza linhas aceitas e descartadas da tabela
+func (pr *progresso) gravados(tabela string, aceitas, descartadas int) {
+	t := pr.tabelas[tabela]
+	t.gravados.Add(int64(aceitas))
+	t.descartados.Add(int64(descartadas))
+	if aceitas > 0 {
+		t.fim.Store(time.Now().UnixNano())
+	}
+}
+
+// leitor envolve o CSV descompactado contando os bytes lidos
+func (pr *progresso) leitor(tabela string, r io.Reader) io.Reader {
+	return &leitorContado{r: r, t: pr.tabelas[tabela]}
+}
+
+type leitorContado struct {
+	r io.Reader
+	t *progressoTabela
+}
+
+func (l *leitorContado) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	if n > 0 {
+		l.t.inicio.CompareAndSwap(0, time.Now().UnixNano())
+		l.t.lidos.Add(int64(n))
+	}
+	return n, err
+}
+
+// nomes retorna as tabelas em ordem alfabética
+func (pr *progresso) nomes() []string {
+	nomes := make([]string, 0, len(pr.tabelas))
+	for nome := range pr.tabelas {
+		nomes = append(nomes, nome)
+	}
+	sort.Strings(nomes)
+	return nomes
+}
+
+// relatar imprime o andamento das tabelas em carga a cada intervaloProgresso
+// até que parar seja fechado
+func (pr *progresso) relatar(parar <-chan struct{}) {
+	ticker := time.NewTicker(intervaloProgresso)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-parar:
+			return
+		case <-ticker.C:
+			fmt.Printf("  ⏱️  %s decorridos\n", time.Since(pr.inicio).Round(time.Second))
+			for _, nome := range pr.nomes() {
+				if linha := pr.tabelas[nome].linha(nome, time.Now()); linha != "" {
+					fmt.Println(linha)
+				}
+			}
+		}
+	}
+}
+
+// linha formata o andamento da tabela; vazia se a leitura não começou
+func (t *progressoTabela) linha(nome string, agora time.Time) string {
+	inicio := t.inicio.Load()
+	if inicio == 0 {
+		return ""
+	}
+
+	decorrido := agora.Sub(time.Unix(0, inicio))
+	gravados := t.gravados.Load()
+	lidos := t.lidos.Load()
+
+	var vazao float64
+	if decorrido > 0 {
+		vazao = float64(gravados) / decorrido.Seconds()
+	}
+
+	percentual, eta := 100.0, "-"
+	if t.previsto > 0 && lidos < t.previsto {
+		fracao := float64(lidos) / float64(t.previsto)
+		percentual = fracao * 100
+		if fracao > 0 {
+			restante := time.Duration(float64(decorrido) * (1 - fracao) / fracao)
+			eta = restante.Round(time.Second).String()
+		}
+	}
+
+	return fmt.Sprintf("    📈 %-18s %12d registros  %9.0f reg/s  %5.1f%%  ETA %s", nome, gravados, vazao, percentual, eta)
+}
+
+// resumo imprime o total gravado, descartado e a vazão média por tabela
+func (pr *progresso) resumo() {
+	fmt.Printf("\n📊 Carga concluída em %s\n", time.Since(pr.inicio).Round(time.Second))
+	for _, nome := range pr.nomes() {
+		t := pr.tabelas[nome]
+		gravados := t.gravados.Load()
+
+		var vazao float64
+		if inicio, fim := t.inicio.Load(), t.fim.Load(); inicio > 0 && fim > inicio {
+			vazao = float64(gravados) / time.Duration(fim-inicio).Seconds()
+		}
+		fmt.Printf("  %-18s %12d registros  %9.0f reg/s  (%d descartados)\n", nome, gravados, vazao, t.descartados.Load())
+	}
+}
@@ -0,0 +1,326 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/normalizer"
+)
+
+// Motivos de rejeição registrados pelo normalizador e pelo pipeline
+const (
+	MotivoObrigatorioVazio  = normalizer.MotivoObrigatorioVazio
+	MotivoValorZerado       = normalizer.MotivoValorZerado
+	MotivoDataInvalida      = normalizer.MotivoDataInvalida
+	MotivoDataForaIntervalo = normalizer.MotivoDataForaIntervalo
+	MotivoTamanhoInvalido   = normalizer.MotivoTamanhoInvalido
+	MotivoEmailInvalido     = normalizer.MotivoEmailInvalido
+	MotivoUFInvalida        = normalizer.MotivoUFInvalida
+	MotivoPadraoInvalido    = normalizer.MotivoPadraoInvalido
+	MotivoValorInvalido     = normalizer.MotivoValorInvalido
+	MotivoTruncado          = normalizer.MotivoTruncado
+	MotivoNegativo          = normalizer.MotivoNegativo
+
+	// Rejeições de registro inteiro (campo CampoRegistro)
+	MotivoCSVInvalido      = "csv_invalido"
+	MotivoRegistroInvalido = "registro_invalido"
+	MotivoRejeitadoBanco   = "rejeitado_banco"
+)
+
+// CampoRegistro identifica rejeições do registro inteiro, não de um campo
+const CampoRegistro = "_registro"
+
+// Limites das amostras guardadas por rejeição
+const (
+	maxAmostras       = 5
+	maxTamanhoAmostra = 120
+)
+
+// Rejeicao agrega os valores de um campo descartados (ou truncados) por um motivo
+type Rejeicao struct {
+	Tabela     string   `json:"tabela"`
+	Campo      string   `json:"campo"`
+	Motivo     string   `json:"motivo"`
+	Quantidade int64    `json:"quantidade"`
+	Amostras   []string `json:"amostras,omitempty"`
+}
+
+// CargaTabela resume a carga de uma tabela
+type CargaTabela struct {
+	Gravados    int64 `json:"gravados"`
+	Descartados int64 `json:"descartados"`
+}
+
+// RelatorioQualidade reúne as rejeições de uma importação. É seguro para uso
+// concorrente; cada leitor do pipeline acumula em um relatório próprio e o
+// mescla ao da importação ao terminar o CSV.
+type RelatorioQualidade struct {
+	Referencia string                 `json:"referencia"`
+	GeradoEm   time.Time              `json:"gerado_em"`
+	Tabelas    map[string]CargaTabela `json:"tabelas"`
+	Rejeicoes  []*Rejeicao            `json:"rejeicoes"`
+
+	mu     sync.Mutex
+	indice map[string]*Rejeicao
+}
+
+// NovoRelatorioQualidade cria um relatório vazio para a referência
+func NovoRelatorioQualidade(referencia string) *RelatorioQualidade {
+	return &RelatorioQualidade{
+		Referencia: referencia,
+		Tabelas:    make(map[string]CargaTabela),
+		indice:     make(map[string]*Rejeicao),
+	}
+}
+
+// Registrar contabiliza uma rejeição e guarda o valor bruto como amostra
+func (r *RelatorioQualidade) Registrar(tabela, campo, motivo, valor string) {
+	r.RegistrarN(tabela, campo, motivo, 1, valor)
+}
+
+// RegistrarN contabiliza n rejeições de uma vez; amostra vazia não é guardada
+func (r *RelatorioQualidade) RegistrarN(tabela, campo, motivo string, n int64, amostra string) {
+	if r == nil || n <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registrar(tabela, campo, motivo, n, []string{amostra})
+}
+
+func (r *RelatorioQualidade) registrar(tabela, campo, motivo string, n int64, amostras []string) {
+	if r.indice == nil {
+		r.indice = make(map[string]*Rejeicao)
+	}
+
+	chave := tabela + "\x00" + campo + "\x00" + motivo
+	rej, ok := r.indice[chave]
+	if !ok {
+		rej = &Rejeicao{Tabela: tabela, Campo: campo, Motivo: motivo}
+		r.indice[chave] = rej
+		r.Rejeicoes = append(r.Rejeicoes, rej)
+	}
+	rej.Quantidade += n
+
+	for _, amostra := range amostras {
+		if len(rej.Amostras) >= maxAmostras {
+			break
+		}
+		amostra = amostraValor(amostra)
+		if amostra == "" || contem(rej.Amostras, amostra) {
+			continue
+		}
+		rej.Amostras = append(rej.Amostras, amostra)
+	}
+}
+
+// Mesclar soma ao relatório as rejeições de outro
+func (r *RelatorioQualidade) Mesclar(outro *RelatorioQualidade) {
+	if r == nil || outro == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rej := range outro.Rejeicoes {
+		r.registrar(rej.Tabela, rej.Campo, rej.Motivo, rej.Quantidade, rej.Amostras)
+	}
+}
+
+// DefinirCarga registra o total gravado e descartado de uma tabela
+func (r *RelatorioQualidade) DefinirCarga(tabela string, carga CargaTabela) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Tabelas == nil {
+		r.Tabelas = make(map[string]CargaTabela)
+	}
+	r.Tabelas[tabela] = carga
+}
+
+// ordenar coloca as rejeições por tabela e, dentro dela, por quantidade
+func (r *RelatorioQualidade) ordenar() {
+	sort.SliceStable(r.Rejeicoes, func(i, j int) bool {
+		a, b := r.Rejeicoes[i], r.Rejeicoes[j]
+		if a.Tabela != b.Tabela {
+			return a.Tabela < b.Tabela
+		}
+		if a.Quantidade != b.Quantidade {
+			return a.Quantidade > b.Quantidade
+		}
+		return a.Campo+a.Motivo < b.Campo+b.Motivo
+	})
+}
+
+// JSON serializa o relatório
+func (r *RelatorioQualidade) JSON() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ordenar()
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Markdown formata o relatório em tabelas Markdown
+func (r *RelatorioQualidade) Markdown() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ordenar()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Relatório de qualidade — referência %s\n\n", r.Referencia)
+	fmt.Fprintf(&b, "Gerado em %s\n\n", r.GeradoEm.Format(time.RFC3339))
+
+	b.WriteString("## Carga por tabela\n\n")
+	b.WriteString("| Tabela | Gravados | Descartados |\n|---|---:|---:|\n")
+	tabelas := make([]string, 0, len(r.Tabelas))
+	for tabela := range r.Tabelas {
+		tabelas = append(tabelas, tabela)
+	}
+	sort.Strings(tabelas)
+	for _, tabela := range tabelas {
+		carga := r.Tabelas[tabela]
+		fmt.Fprintf(&b, "| %s | %d | %d |\n", tabela, carga.Gravados, carga.Descartados)
+	}
+
+	b.WriteString("\n## Rejeições\n\n")
+	if len(r.Rejeicoes) == 0 {
+		b.WriteString("Nenhum valor rejeitado.\n")
+		return b.String()
+	}
+	b.WriteString("| Tabela | Campo | Motivo | Quantidade | Amostras |\n|---|---|---|---:|---|\n")
+	for _, rej := range r.Rejeicoes {
+		amostras := make([]string, len(rej.Amostras))
+		for i, amostra := range rej.Amostras {
+			amostras[i] = "`" + strings.ReplaceAll(amostra, "|", "\\|") + "`"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %d | %s |\n", rej.Tabela, rej.Campo, rej.Motivo, rej.Quantidade, strings.Join(amostras, ", "))
+	}
+	return b.String()
+}
+
+// Salvar grava qualidade-<referencia>.json e .md na pasta
+func (r *RelatorioQualidade) Salvar(dir string) (string, error) {
+	data, err := r.JSON()
+	if err != nil {
+		return "", err
+	}
+
+	base := filepath.Join(dir, "qualidade-"+r.Referencia)
+	if err := os.WriteFile(base+".json", data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(base+".md", []byte(r.Markdown()), 0644); err != nil {
+		return "", err
+	}
+	return base, nil
+}
+
+// salvarQualidade grava o relatório da carga em <dbDir>/qualidade-<ref>.json
+// e .md e acrescenta uma linha à tabela qualidade_importacao, lida por
+// /rede/api/status
+func (p *Processor) salvarQualidade(modo string) error {
+	r := p.qualidade
+	if r == nil {
+		return nil
+	}
+	r.Referencia = p.mesReferencia()
+	r.GeradoEm = time.Now().UTC()
+
+	base, err := r.Salvar(p.dbDir)
+	if err != nil {
+		return fmt.Errorf("erro ao gravar relatório de qualidade: %w", err)
+	}
+
+	data, err := r.JSON()
+	if err != nil {
+		return err
+	}
+
+	db := p.dbMgr.GetDB()
+	tabela := p.dbMgr.TablePrefix("qualidade_importacao")
+	if _, err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		referencia TEXT NOT NULL,
+		gerado_em TEXT NOT NULL,
+		modo TEXT NOT NULL,
+		relatorio TEXT NOT NULL,
+		markdown TEXT NOT NULL
+	)`, tabela)); err != nil {
+		return fmt.Errorf("erro ao criar qualidade_importacao: %w", err)
+	}
+	query := p.dbMgr.AdaptPlaceholder(fmt.Sprintf(
+		"INSERT INTO %s (referencia, gerado_em, modo, relatorio, markdown) VALUES (?, ?, ?, ?, ?)", tabela))
+	if _, err := db.Exec(query, r.Referencia, r.GeradoEm.Format(time.RFC3339), modo, string(data), r.Markdown()); err != nil {
+		return fmt.Errorf("erro ao gravar relatório de qualidade: %w", err)
+	}
+
+	resumo := r.Resumo(5)
+	fmt.Printf("\n🧪 Qualidade: %d valores rejeitados ou truncados; relatório em %s.md\n", resumo.Rejeicoes, base)
+	for _, rej := range resumo.Principais {
+		fmt.Printf("  %s.%s (%s): %d\n", rej.Tabela, rej.Campo, rej.Motivo, rej.Quantidade)
+	}
+	return nil
+}
+
+// ResumoQualidade é a visão compacta do relatório exposta em /rede/api/status
+type ResumoQualidade struct {
+	Referencia           string           `json:"referencia"`
+	GeradoEm             time.Time        `json:"gerado_em"`
+	RegistrosGravados    int64            `json:"registros_gravados"`
+	RegistrosDescartados int64            `json:"registros_descartados"`
+	Rejeicoes            int64            `json:"rejeicoes"`
+	RejeicoesPorTabela   map[string]int64 `json:"rejeicoes_por_tabela"`
+	Principais           []*Rejeicao      `json:"principais"`
+}
+
+// Resumo totaliza o relatório e lista as n rejeições mais frequentes
+func (r *RelatorioQualidade) Resumo(n int) *ResumoQualidade {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	resumo := &ResumoQualidade{
+		Referencia:         r.Referencia,
+		GeradoEm:           r.GeradoEm,
+		RejeicoesPorTabela: make(map[string]int64),
+	}
+	for _, carga := range r.Tabelas {
+		resumo.RegistrosGravados += carga.Gravados
+		resumo.RegistrosDescartados += carga.Descartados
+	}
+
+	principais := make([]*Rejeicao, len(r.Rejeicoes))
+	copy(principais, r.Rejeicoes)
+	for _, rej := range principais {
+		resumo.Rejeicoes += rej.Quantidade
+		resumo.RejeicoesPorTabela[rej.Tabela] += rej.Quantidade
+	}
+	sort.SliceStable(principais, func(i, j int) bool {
+		return principais[i].Quantidade > principais[j].Quantidade
+	})
+	if len(principais) > n {
+		principais = principais[:n]
+	}
+	resumo.Principais = principais
+	return resumo
+}
+
+// amostraValor limpa e limita o valor bruto guardado como amostra
+func amostraValor(valor string) string {
+	valor = normalizer.SanitizeString(valor)
+	if len(valor) > maxTamanhoAmostra {
+		valor = strings.ToValidUTF8(valor[:maxTamanhoAmostra], "") + "…"
+	}
+	return valor
+}
+
+func contem(lista []string, valor string) bool {
+	for _, v := range lista {
+		if v == valor {
+			return true
+		}
+	}
+	return false
+}
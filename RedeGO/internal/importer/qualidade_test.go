@@ -0,0 +1,90 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/normalizer"
+)
+
+func TestNormalizerRegistraRejeicoes(t *testing.T) {
+	r := NovoRelatorioQualidade("2024-10")
+	n := normalizer.GetEstabelecimentoNormalizer().ComRelatorio("estabelecimento", r)
+
+	casos := []struct {
+		campo  string
+		valor  string
+		valido bool
+	}{
+		{"uf", "SP", true},
+		{"uf", "XX", false},
+		{"uf", "ZZ", false},
+		{"cep", "01310-100", true},
+		{"cep", "0131", false},
+		{"data_inicio_atividades", "20201301", false},
+		{"data_inicio_atividades", "2020AB01", false},
+		{"correio_eletronico", "", false}, // vazio não é rejeição
+		{"correio_eletronico", "fulano@", false},
+	}
+	for _, c := range casos {
+		if got := n.NormalizeString(c.campo, c.valor); got.Valid != c.valido {
+			t.Errorf("NormalizeString(%q, %q).Valid = %v, esperado %v", c.campo, c.valor, got.Valid, c.valido)
+		}
+	}
+
+	esperado := map[string]int64{
+		"uf/" + MotivoUFInvalida:                            2,
+		"cep/" + MotivoTamanhoInvalido:                      1,
+		"data_inicio_atividades/" + MotivoDataForaIntervalo: 1,
+		"data_inicio_atividades/" + MotivoDataInvalida:      1,
+		"correio_eletronico/" + MotivoEmailInvalido:         1,
+	}
+	if len(r.Rejeicoes) != len(esperado) {
+		t.Errorf("rejeições = %d, esperado %d", len(r.Rejeicoes), len(esperado))
+	}
+	for _, rej := range r.Rejeicoes {
+		chave := rej.Campo + "/" + rej.Motivo
+		if rej.Quantidade != esperado[chave] {
+			t.Errorf("%s = %d, esperado %d", chave, rej.Quantidade, esperado[chave])
+		}
+		if rej.Tabela != "estabelecimento" {
+			t.Errorf("%s: tabela = %q, esperado %q", chave, rej.Tabela, "estabelecimento")
+		}
+	}
+
+	uf := r.indice["estabelecimento\x00uf\x00"+MotivoUFInvalida]
+	if uf == nil || strings.Join(uf.Amostras, ",") != "XX,ZZ" {
+		t.Errorf("amostras de uf = %v, esperado [XX ZZ]", uf)
+	}
+}
+
+func TestRelatorioQualidadeMesclar(t *testing.T) {
+	total := NovoRelatorioQualidade("2024-10")
+	for i := 0; i < 3; i++ {
+		parcial := NovoRelatorioQualidade("")
+		for j := 0; j < 4; j++ {
+			parcial.Registrar("socios", "faixa_etaria", MotivoPadraoInvalido, string(rune('A'+i*4+j)))
+		}
+		total.Mesclar(parcial)
+	}
+	total.RegistrarN("socios", CampoRegistro, MotivoRejeitadoBanco, 7, "")
+	total.DefinirCarga("socios", CargaTabela{Gravados: 100, Descartados: 7})
+
+	resumo := total.Resumo(1)
+	if resumo.Rejeicoes != 19 || resumo.RejeicoesPorTabela["socios"] != 19 {
+		t.Errorf("Resumo() = %+v, esperado 19 rejeições em socios", resumo)
+	}
+	if len(resumo.Principais) != 1 || resumo.Principais[0].Quantidade != 12 {
+		t.Errorf("Principais = %+v, esperado faixa_etaria com 12", resumo.Principais)
+	}
+	if n := len(resumo.Principais[0].Amostras); n != maxAmostras {
+		t.Errorf("amostras = %d, esperado %d", n, maxAmostras)
+	}
+
+	md := total.Markdown()
+	for _, parte := range []string{"referência 2024-10", "| socios | 100 | 7 |", "| socios | faixa_etaria | padrao_invalido | 12 |"} {
+		if !strings.Contains(md, parte) {
+			t.Errorf("Markdown() sem %q:\n%s", parte, md)
+		}
+	}
+}
@@ -160,7 +160,7 @@ func GetTableSchemasPostgreSQL() map[string]string {
 		)`,
 		
 		"receita.socios": `CREATE TABLE IF NOT EXISTS receita.socios (
-			cnpj VARCHAR(14) NOT NULL,
+			cnpj VARCHAR(14),
 			cnpj_basico VARCHAR(8) NOT NULL,
 			identificador_de_socio VARCHAR(1) NOT NULL,
 			nome_socio TEXT NOT NULL,
@@ -186,6 +186,16 @@ func GetTableSchemasPostgreSQL() map[string]string {
 	}
 }
 
+// GetMigracoesPostgreSQL ajusta bancos criados por versões anteriores.
+// socios.cnpj deixou de ser obrigatório: é preenchido após a carga, e sócios
+// de empresas sem matriz ficam com o CNPJ nulo, como no SQLite.
+func GetMigracoesPostgreSQL() []string {
+	return []string{
+		"ALTER TABLE IF EXISTS receita.socios ALTER COLUMN cnpj DROP NOT NULL",
+		"ALTER TABLE IF EXISTS receita.socios_historico ALTER COLUMN cnpj DROP NOT NULL",
+	}
+}
+
 // GetIndexesSQLite retorna os índices para SQLite
 func GetIndexesSQLite() []string {
 	return []string{
@@ -216,3 +226,43 @@ func GetIndexesPostgreSQL() []string {
 		"CREATE INDEX IF NOT EXISTS idx_socios_nome_socio ON receita.socios(nome_socio)",
 	}
 }
+
+// GetBuscaPostgreSQL retorna os comandos que criam o índice de busca do
+// PostgreSQL: a visão materializada rede.busca, com tsvector sem acentos e
+// trigramas (pg_trgm) do nome. Equivale à tabela FTS5 id_search do SQLite.
+func GetBuscaPostgreSQL() []string {
+	return []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE EXTENSION IF NOT EXISTS unaccent",
+		"DROP MATERIALIZED VIEW IF EXISTS rede.busca",
+		`CREATE MATERIALIZED VIEW rede.busca AS
+		SELECT id, tipo, nome, cnpj, uf, municipio, situacao, cnae,
+			upper(unaccent(nome)) AS nome_busca,
+			to_tsvector('simple', unaccent(nome)) AS documento
+		FROM (
+			SELECT 'PJ_' || est.cnpj AS id, 'PJ' AS tipo, e.razao_social AS nome, est.cnpj,
+				est.uf, m.descricao AS municipio, est.situacao_cadastral AS situacao, est.cnae_fiscal AS cnae
+			FROM receita.estabelecimento est
+			JOIN receita.empresas e ON e.cnpj_basico = est.cnpj_basico
+			LEFT JOIN receita.municipio m ON m.codigo = est.municipio
+			WHERE est.matriz_filial = '1'
+			UNION
+			SELECT 'PJ_' || est.cnpj, 'PJ', est.nome_fantasia, est.cnpj,
+				est.uf, m.descricao, est.situacao_cadastral, est.cnae_fiscal
+			FROM receita.estabelecimento est
+			LEFT JOIN receita.municipio m ON m.codigo = est.municipio
+			WHERE est.nome_fantasia IS NOT NULL AND est.nome_fantasia <> ''
+			UNION
+			SELECT id, substr(id, 1, 2),
+				CASE WHEN substr(id, 1, 3) = 'PF_' THEN substr(id, strpos(id, '-') + 1) ELSE substr(id, 4) END,
+				NULL, NULL, NULL, NULL, NULL
+			FROM (SELECT id1 AS id FROM rede.ligacao UNION SELECT id2 FROM rede.ligacao) AS l
+			WHERE substr(id, 1, 3) <> 'PJ_'
+		) AS t
+		WHERE nome IS NOT NULL AND nome <> ''`,
+		"CREATE INDEX idx_busca_documento ON rede.busca USING GIN (documento)",
+		"CREATE INDEX idx_busca_nome_trgm ON rede.busca USING GIN (nome_busca gin_trgm_ops)",
+		"CREATE INDEX idx_busca_cnpj ON rede.busca (cnpj)",
+		"ANALYZE rede.busca",
+	}
+}
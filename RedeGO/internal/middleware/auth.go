@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chaveContexto guarda no gin.Context a chave de API autenticada
+const chaveContexto = "rede.chaveAPI"
+
+// UsuarioLocal indica se a requisição veio da própria máquina. Usa o endereço
+// da conexão, não X-Forwarded-For, para que o cabeçalho não forje a isenção.
+func UsuarioLocal(c *gin.Context) bool {
+	ip := c.RemoteIP()
+	return ip == "127.0.0.1" || ip == "::1"
+}
+
+// lerChave lê a chave do cabeçalho X-API-Key ou do parâmetro api_key
+func lerChave(c *gin.Context) string {
+	if chave := c.GetHeader("X-API-Key"); chave != "" {
+		return chave
+	}
+	return c.Query("api_key")
+}
+
+// ChaveAPI retorna a chave de API autenticada pela requisição. Sem o
+// middleware de autenticação, retorna a chave informada, sem validação.
+func ChaveAPI(c *gin.Context) string {
+	if chave, ok := c.Get(chaveContexto); ok {
+		return chave.(string)
+	}
+	return lerChave(c)
+}
+
+// identidade é a chave de API ou, sem ela, o IP da conexão. X-Forwarded-For
+// não é usado: um valor novo por requisição daria um limite novo a cada vez.
+func identidade(c *gin.Context) string {
+	if chave := ChaveAPI(c); chave != "" {
+		return "chave:" + chave
+	}
+	return "ip:" + c.RemoteIP()
+}
+
+// Autenticacao valida a chave de API (cabeçalho X-API-Key ou ?api_key=).
+// Sem chaves configuradas a API é aberta; o usuário local dispensa a chave.
+func Autenticacao(chaves []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chave := lerChave(c)
+		if chave != "" && chaveValida(chaves, chave) {
+			c.Set(chaveContexto, chave)
+			c.Next()
+			return
+		}
+		c.Set(chaveContexto, "")
+		switch {
+		case len(chaves) == 0, chave == "" && UsuarioLocal(c):
+			c.Next()
+		case chave == "":
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Chave de API obrigatória (cabeçalho X-API-Key ou parâmetro api_key)"})
+		default:
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Chave de API inválida"})
+		}
+	}
+}
+
+// chaveValida compara a chave com as configuradas em tempo constante
+func chaveValida(chaves []string, chave string) bool {
+	valida := false
+	for _, k := range chaves {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(chave)) == 1 {
+			valida = true
+		}
+	}
+	return valida
+}
+
+// ContagemUso acumula as requisições de uma chave em um grupo de rotas
+type ContagemUso struct {
+	Grupo        string    `json:"grupo"`
+	Requisicoes  int64     `json:"requisicoes"`
+	Bloqueadas   int64     `json:"bloqueadas"`
+	UltimoAcesso time.Time `json:"ultimoAcesso"`
+}
+
+// Uso guarda os contadores de uso por chave de API e grupo de rotas.
+// Requisições sem chave não são contadas.
+type Uso struct {
+	mu        sync.Mutex
+	contagens map[string]map[string]*ContagemUso
+}
+
+// NewUso cria os contadores de uso
+func NewUso() *Uso {
+	return &Uso{contagens: make(map[string]map[string]*ContagemUso)}
+}
+
+// registrar contabiliza uma requisição, aceita ou bloqueada
+func (u *Uso) registrar(chave, grupo string, bloqueada bool, agora time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	grupos, ok := u.contagens[chave]
+	if !ok {
+		grupos = make(map[string]*ContagemUso)
+		u.contagens[chave] = grupos
+	}
+	contagem, ok := grupos[grupo]
+	if !ok {
+		contagem = &ContagemUso{Grupo: grupo}
+		grupos[grupo] = contagem
+	}
+	contagem.Requisicoes++
+	if bloqueada {
+		contagem.Bloqueadas++
+	}
+	contagem.UltimoAcesso = agora
+}
+
+// Da retorna os contadores da chave informada, por grupo
+func (u *Uso) Da(chave string) []ContagemUso {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.contagensDe(chave)
+}
+
+func (u *Uso) contagensDe(chave string) []ContagemUso {
+	lista := []ContagemUso{}
+	for _, contagem := range u.contagens[chave] {
+		lista = append(lista, *contagem)
+	}
+	sort.Slice(lista, func(i, j int) bool { return lista[i].Grupo < lista[j].Grupo })
+	return lista
+}
+
+// Todas retorna os contadores de todas as chaves, indexados pela chave
+// mascarada
+func (u *Uso) Todas() map[string][]ContagemUso {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	todas := make(map[string][]ContagemUso, len(u.contagens))
+	for chave := range u.contagens {
+		todas[MascararChave(chave)] = u.contagensDe(chave)
+	}
+	return todas
+}
+
+// MascararChave identifica a chave sem expô-la: o início da chave e um
+// resumo SHA-256, que distingue chaves com o mesmo prefixo
+func MascararChave(chave string) string {
+	soma := sha256.Sum256([]byte(chave))
+	prefixo := ""
+	if len(chave) > 8 {
+		prefixo = chave[:4]
+	}
+	return prefixo + "****" + hex.EncodeToString(soma[:3])
+}
+
+// Limitar aplica o limitador às rotas do grupo, respondendo 429 com
+// Retry-After quando a identidade esgota a quota. O usuário local é isento.
+func Limitar(l *Limitador, uso *Uso) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if UsuarioLocal(c) {
+			c.Next()
+			return
+		}
+		agora := time.Now()
+		ok, espera := l.Permitir(identidade(c), agora)
+		if chave := ChaveAPI(c); uso != nil && chave != "" {
+			uso.registrar(chave, l.Grupo, !ok, agora)
+		}
+		if ok {
+			c.Next()
+			return
+		}
+		segundos := int(math.Ceil(espera.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(segundos))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error":      fmt.Sprintf("Limite de requisições excedido para %s (%s)", l.Grupo, limitesTexto(l.limites)),
+			"retryAfter": segundos,
+		})
+	}
+}
+
+func limitesTexto(limites []Limite) string {
+	textos := make([]string, len(limites))
+	for i, l := range limites {
+		textos[i] = l.Texto
+	}
+	return strings.Join(textos, "; ")
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func routerTeste(chaves []string, limite string, uso *Uso) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	limites, err := ParseLimites(limite)
+	if err != nil {
+		panic(err)
+	}
+	r := gin.New()
+	g := r.Group("/rede", Autenticacao(chaves), Limitar(NewLimitador("dados", limites), uso))
+	g.GET("/teste", func(c *gin.Context) {
+		c.String(http.StatusOK, ChaveAPI(c))
+	})
+	return r
+}
+
+func requisicao(r *gin.Engine, origem, url, chave string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.RemoteAddr = origem + ":40000"
+	if chave != "" {
+		req.Header.Set("X-API-Key", chave)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAutenticacao(t *testing.T) {
+	r := routerTeste([]string{"chave-1", "chave-2"}, "100/minute", nil)
+	casos := []struct {
+		nome   string
+		origem string
+		url    string
+		chave  string
+		status int
+		corpo  string
+	}{
+		{"cabeçalho", "10.0.0.1", "/rede/teste", "chave-1", http.StatusOK, "chave-1"},
+		{"parâmetro", "10.0.0.1", "/rede/teste?api_key=chave-2", "", http.StatusOK, "chave-2"},
+		{"sem chave", "10.0.0.1", "/rede/teste", "", http.StatusUnauthorized, ""},
+		{"chave inválida", "10.0.0.1", "/rede/teste", "outra", http.StatusUnauthorized, ""},
+		{"local sem chave", "127.0.0.1", "/rede/teste", "", http.StatusOK, ""},
+		{"local com chave inválida", "127.0.0.1", "/rede/teste", "outra", http.StatusUnauthorized, ""},
+	}
+	for _, c := range casos {
+		w := requisicao(r, c.origem, c.url, c.chave)
+		if w.Code != c.status || (c.status == http.StatusOK && w.Body.String() != c.corpo) {
+			t.Errorf("%s: status %d corpo %q, esperado %d %q", c.nome, w.Code, w.Body.String(), c.status, c.corpo)
+		}
+	}
+
+	// X-Forwarded-For não forja o usuário local
+	req := httptest.NewRequest(http.MethodGet, "/rede/teste", nil)
+	req.RemoteAddr = "10.0.0.1:40000"
+	req.Header.Set("X-Forwarded-For", "127.0.0.1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("X-Forwarded-For 127.0.0.1: status %d, esperado 401", w.Code)
+	}
+
+	aberta := routerTeste(nil, "100/minute", nil)
+	if w := requisicao(aberta, "10.0.0.1", "/rede/teste", "qualquer"); w.Code != http.StatusOK || w.Body.String() != "" {
+		t.Errorf("API sem chaves: status %d corpo %q, esperado 200 sem chave", w.Code, w.Body.String())
+	}
+}
+
+func TestLimitarPorChave(t *testing.T) {
+	uso := NewUso()
+	r := routerTeste([]string{"chave-1", "chave-2"}, "2/minute", uso)
+
+	for i := 0; i < 2; i++ {
+		if w := requisicao(r, "10.0.0.1", "/rede/teste", "chave-1"); w.Code != http.StatusOK {
+			t.Fatalf("requisição %d: status %d", i+1, w.Code)
+		}
+	}
+	w := requisicao(r, "10.0.0.1", "/rede/teste", "chave-1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("3ª requisição: status %d Retry-After %q, esperado 429 e 30", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := requisicao(r, "10.0.0.1", "/rede/teste", "chave-2"); w.Code != http.StatusOK {
+		t.Errorf("outra chave do mesmo IP: status %d, esperado 200", w.Code)
+	}
+	for i := 0; i < 3; i++ {
+		if w := requisicao(r, "127.0.0.1", "/rede/teste", ""); w.Code != http.StatusOK {
+			t.Errorf("usuário local: status %d, esperado 200", w.Code)
+		}
+	}
+
+	// Sem chaves, o limite é do IP da conexão: X-Forwarded-For forjado a cada
+	// requisição não abre um limite novo
+	aberta := routerTeste(nil, "2/minute", nil)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/rede/teste", nil)
+		req.RemoteAddr = "10.0.0.2:40000"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("192.0.2.%d", i+1))
+		w := httptest.NewRecorder()
+		aberta.ServeHTTP(w, req)
+		if esperado := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}[i]; w.Code != esperado {
+			t.Errorf("X-Forwarded-For forjado, requisição %d: status %d, esperado %d", i+1, w.Code, esperado)
+		}
+	}
+
+	contagens := uso.Da("chave-1")
+	if len(contagens) != 1 || contagens[0].Grupo != "dados" || contagens[0].Requisicoes != 3 || contagens[0].Bloqueadas != 1 {
+		t.Errorf("Uso.Da(chave-1) = %+v", contagens)
+	}
+	if time.Since(contagens[0].UltimoAcesso) > time.Minute {
+		t.Errorf("UltimoAcesso = %v", contagens[0].UltimoAcesso)
+	}
+	todas := uso.Todas()
+	if len(todas) != 2 || len(todas[MascararChave("chave-1")]) != 1 {
+		t.Errorf("Uso.Todas() = %+v", todas)
+	}
+}
@@ -0,0 +1,172 @@
+// Package middleware reúne os middlewares Gin do servidor de APIs:
+// autenticação por chave de API e limite de requisições por chave.
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limite é uma quota de requisições por período, como "20/minute" ou
+// "100 per hour"
+type Limite struct {
+	Quantidade int
+	Periodo    time.Duration
+	Texto      string
+}
+
+var reLimite = regexp.MustCompile(`^(\d+)\s*(?:/|per)\s*(\d+)?\s*(second|minute|hour|day|month|year)s?$`)
+
+var unidadesLimite = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// ParseLimite interpreta um limite na sintaxe do Flask-Limiter, usada no
+// rede.ini da versão Python: "100 per hour", "20/minute", "10 per 2 minutes"
+func ParseLimite(texto string) (Limite, error) {
+	texto = strings.TrimSpace(texto)
+	m := reLimite.FindStringSubmatch(strings.ToLower(texto))
+	if m == nil {
+		return Limite{}, fmt.Errorf("limite inválido %q (use \"20/minute\" ou \"100 per hour\")", texto)
+	}
+	quantidade, err := strconv.Atoi(m[1])
+	if err != nil || quantidade <= 0 {
+		return Limite{}, fmt.Errorf("limite inválido %q: quantidade deve ser positiva", texto)
+	}
+	multiplo := 1
+	if m[2] != "" {
+		if multiplo, err = strconv.Atoi(m[2]); err != nil || multiplo <= 0 {
+			return Limite{}, fmt.Errorf("limite inválido %q: período deve ser positivo", texto)
+		}
+	}
+	return Limite{
+		Quantidade: quantidade,
+		Periodo:    time.Duration(multiplo) * unidadesLimite[m[3]],
+		Texto:      texto,
+	}, nil
+}
+
+// ParseLimites interpreta um ou mais limites separados por ";" ou ",", como
+// "20/minute; 500 per day"
+func ParseLimites(texto string) ([]Limite, error) {
+	var limites []Limite
+	for _, parte := range strings.FieldsFunc(texto, func(r rune) bool { return r == ';' || r == ',' }) {
+		if strings.TrimSpace(parte) == "" {
+			continue
+		}
+		limite, err := ParseLimite(parte)
+		if err != nil {
+			return nil, err
+		}
+		limites = append(limites, limite)
+	}
+	if len(limites) == 0 {
+		return nil, fmt.Errorf("nenhum limite em %q", texto)
+	}
+	return limites, nil
+}
+
+func (l Limite) String() string {
+	return l.Texto
+}
+
+// balde é um token bucket: começa cheio com Quantidade fichas e recupera
+// Quantidade fichas a cada Periodo
+type balde struct {
+	fichas       float64
+	atualizadoEm time.Time
+}
+
+// maxBaldes é o número de identidades a partir do qual os baldes já cheios
+// são descartados
+const maxBaldes = 10000
+
+// Limitador aplica limites a um grupo de rotas, com um balde por identidade
+// (chave de API ou IP) e por limite
+type Limitador struct {
+	Grupo   string
+	limites []Limite
+	mu      sync.Mutex
+	baldes  map[string][]balde
+}
+
+// NewLimitador cria o limitador de um grupo de rotas
+func NewLimitador(grupo string, limites []Limite) *Limitador {
+	return &Limitador{Grupo: grupo, limites: limites, baldes: make(map[string][]balde)}
+}
+
+// Permitir consome uma ficha de cada limite da identidade. Se algum balde
+// estiver vazio nada é consumido e retorna a espera até a próxima ficha.
+func (l *Limitador) Permitir(identidade string, agora time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	baldes, ok := l.baldes[identidade]
+	if !ok {
+		if len(l.baldes) >= maxBaldes {
+			l.descartarCheios(agora)
+		}
+		baldes = make([]balde, len(l.limites))
+		for i, limite := range l.limites {
+			baldes[i] = balde{fichas: float64(limite.Quantidade), atualizadoEm: agora}
+		}
+		l.baldes[identidade] = baldes
+	}
+
+	var espera time.Duration
+	for i, limite := range l.limites {
+		b := &baldes[i]
+		b.fichas = l.recarregar(limite, *b, agora)
+		b.atualizadoEm = agora
+		if b.fichas < 1 {
+			falta := (1 - b.fichas) * float64(limite.Periodo) / float64(limite.Quantidade)
+			if d := time.Duration(math.Ceil(falta)); d > espera {
+				espera = d
+			}
+		}
+	}
+	if espera > 0 {
+		return false, espera
+	}
+	for i := range baldes {
+		baldes[i].fichas--
+	}
+	return true, 0
+}
+
+// recarregar calcula as fichas do balde no instante informado
+func (l *Limitador) recarregar(limite Limite, b balde, agora time.Time) float64 {
+	decorrido := agora.Sub(b.atualizadoEm)
+	if decorrido <= 0 {
+		return b.fichas
+	}
+	fichas := b.fichas + float64(limite.Quantidade)*float64(decorrido)/float64(limite.Periodo)
+	return math.Min(fichas, float64(limite.Quantidade))
+}
+
+// descartarCheios remove as identidades cujos baldes já se recompletaram,
+// equivalentes a um balde novo
+func (l *Limitador) descartarCheios(agora time.Time) {
+	for identidade, baldes := range l.baldes {
+		cheio := true
+		for i, limite := range l.limites {
+			if l.recarregar(limite, baldes[i], agora) < float64(limite.Quantidade) {
+				cheio = false
+				break
+			}
+		}
+		if cheio {
+			delete(l.baldes, identidade)
+		}
+	}
+}
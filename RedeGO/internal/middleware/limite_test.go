@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimite(t *testing.T) {
+	casos := []struct {
+		entrada    string
+		quantidade int
+		periodo    time.Duration
+	}{
+		{"20/minute", 20, time.Minute},
+		{"100 per hour", 100, time.Hour},
+		{"10 per 2 minutes", 10, 2 * time.Minute},
+		{" 5 / second ", 5, time.Second},
+		{"1000 PER DAY", 1000, 24 * time.Hour},
+		{"3/30 seconds", 3, 30 * time.Second},
+	}
+	for _, c := range casos {
+		limite, err := ParseLimite(c.entrada)
+		if err != nil {
+			t.Errorf("ParseLimite(%q) erro: %v", c.entrada, err)
+			continue
+		}
+		if limite.Quantidade != c.quantidade || limite.Periodo != c.periodo {
+			t.Errorf("ParseLimite(%q) = %d/%v, esperado %d/%v", c.entrada, limite.Quantidade, limite.Periodo, c.quantidade, c.periodo)
+		}
+	}
+
+	for _, invalido := range []string{"", "vinte/minute", "20 per fortnight", "0/minute", "20"} {
+		if _, err := ParseLimite(invalido); err == nil {
+			t.Errorf("ParseLimite(%q) deveria falhar", invalido)
+		}
+	}
+}
+
+func TestParseLimites(t *testing.T) {
+	limites, err := ParseLimites("20/minute; 500 per day")
+	if err != nil {
+		t.Fatalf("ParseLimites() erro: %v", err)
+	}
+	if len(limites) != 2 || limites[1].Quantidade != 500 {
+		t.Errorf("ParseLimites() = %+v", limites)
+	}
+	if _, err := ParseLimites(" ; "); err == nil {
+		t.Error("ParseLimites() sem limites deveria falhar")
+	}
+}
+
+func TestLimitador(t *testing.T) {
+	l := NewLimitador("dados", []Limite{{Quantidade: 2, Periodo: time.Minute}, {Quantidade: 3, Periodo: time.Hour}})
+	inicio := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Permitir("a", inicio); !ok {
+			t.Fatalf("requisição %d bloqueada dentro do limite", i+1)
+		}
+	}
+	ok, espera := l.Permitir("a", inicio)
+	if ok || espera != 30*time.Second {
+		t.Errorf("3ª requisição = %v, espera %v; esperado bloqueio de 30s", ok, espera)
+	}
+	if ok, _ := l.Permitir("b", inicio); !ok {
+		t.Error("outra identidade não deveria ser afetada")
+	}
+
+	// Após 30s o limite por minuto libera uma ficha; o por hora ainda tem uma
+	if ok, _ := l.Permitir("a", inicio.Add(30*time.Second)); !ok {
+		t.Error("requisição após a espera deveria passar")
+	}
+	// Agora o limite por hora está esgotado: 1/4 de ficha recuperada em 5
+	// minutos, faltam 15 (20 minutos por ficha)
+	ok, espera = l.Permitir("a", inicio.Add(5*time.Minute))
+	if ok || espera < 15*time.Minute-time.Second || espera > 15*time.Minute+time.Second {
+		t.Errorf("4ª requisição = %v, espera %v; esperado bloqueio de 15m", ok, espera)
+	}
+}
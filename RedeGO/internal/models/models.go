@@ -29,8 +29,10 @@ type Edge struct {
 
 // Graph representa o grafo completo
 type Graph struct {
-	Nodes []Node `json:"no"`
-	Edges []Edge `json:"ligacao"`
+	Nodes    []Node `json:"no"`
+	Edges    []Edge `json:"ligacao"`
+	Truncado bool   `json:"truncado,omitempty"` // Expansão interrompida por limite de registros ou tempo
+	Mensagem string `json:"mensagem,omitempty"`
 }
 
 // CNPJData representa dados de uma empresa
@@ -77,10 +79,15 @@ type SocioData struct {
 
 // SearchResult representa resultado de busca
 type SearchResult struct {
-	ID    string `json:"id"`
-	Label string `json:"label"`
-	Type  string `json:"tipo"`
-	Score float64 `json:"score,omitempty"`
+	ID        string  `json:"id"` // ID do nó: PJ_<cnpj>, PF_<cpf>-<nome> ou PE_<nome>
+	Label     string  `json:"label"`
+	Type      string  `json:"tipo"`
+	CNPJ      string  `json:"cnpj,omitempty"`
+	UF        string  `json:"uf,omitempty"`
+	Municipio string  `json:"municipio,omitempty"`
+	Situacao  string  `json:"situacao,omitempty"` // código da situação cadastral
+	CNAE      string  `json:"cnae,omitempty"`
+	Score     float64 `json:"score,omitempty"`
 }
 
 // CamadaRequest representa requisição para buscar camadas
@@ -104,6 +111,28 @@ type MapaRequest struct {
 	Nodes []Node `json:"no"`
 }
 
+// GeoJSON representa o mapa dos nós como FeatureCollection (RFC 7946)
+type GeoJSON struct {
+	Type           string           `json:"type"` // Sempre "FeatureCollection"
+	Features       []GeoJSONFeature `json:"features"`
+	NaoLocalizados []string         `json:"nao_localizados,omitempty"` // IDs PJ sem endereço ou coordenada
+	Truncado       bool             `json:"truncado,omitempty"`        // Mais empresas que geocode_max
+	Mensagem       string           `json:"mensagem,omitempty"`
+}
+
+// GeoJSONFeature representa um estabelecimento no mapa
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"` // Sempre "Feature"
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONGeometry representa um ponto; Coordinates é [longitude, latitude]
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
 // ExportRequest representa requisição para exportar dados
 type ExportRequest struct {
 	Nodes []Node `json:"no"`
@@ -125,8 +154,9 @@ type FileUploadResponse struct {
 
 // DadosPublicosResponse representa resposta sobre dados públicos disponíveis
 type DadosPublicosResponse struct {
-	AnoMesSendoUsado   string `json:"ano_mes_sendo_usado"`
-	AnoMesDisponivel   string `json:"ano_mes_disponivel"`
-	URL                string `json:"url"`
-	Mensagem           string `json:"mensagem,omitempty"`
+	AnoMesSendoUsado    string   `json:"ano_mes_sendo_usado"`
+	AnoMesDisponivel    string   `json:"ano_mes_disponivel"`
+	AnoMesesDisponiveis []string `json:"anos_meses_disponiveis,omitempty"`
+	URL                 string   `json:"url"`
+	Mensagem            string   `json:"mensagem,omitempty"`
 }
@@ -0,0 +1,334 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/utils"
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/cpfcnpj"
+)
+
+// ErrConsultaVazia indica uma consulta sem nenhum termo pesquisável
+var ErrConsultaVazia = errors.New("consulta vazia: informe um nome ou CNPJ")
+
+const (
+	porPaginaPadrao = 10
+	porPaginaMaximo = 100
+	facetasMaximo   = 20
+)
+
+// facetas são as colunas do índice que aceitam filtro e têm contagem
+var facetas = []string{"uf", "cnae", "situacao"}
+
+// Consulta é uma busca por nome ou CNPJ, com filtros e paginação
+type Consulta struct {
+	Texto     string
+	Tipos     []string // PJ, PF ou PE; vazio busca todos
+	UF        []string
+	CNAE      []string
+	Situacao  []string // código da situação cadastral (02 = ativa)
+	Pagina    int      // a partir de 1
+	PorPagina int
+	Facetas   bool // conta os resultados por UF, CNAE e situação
+}
+
+// Faceta é o número de resultados com um valor de filtro
+type Faceta struct {
+	Valor      string `json:"valor"`
+	Descricao  string `json:"descricao,omitempty"`
+	Quantidade int    `json:"quantidade"`
+}
+
+// Resultado é uma página da busca, em ordem de relevância
+type Resultado struct {
+	Resultados []models.SearchResult `json:"resultados"`
+	Total      int                   `json:"total"`
+	Pagina     int                   `json:"pagina"`
+	PorPagina  int                   `json:"porPagina"`
+	Facetas    map[string][]Faceta   `json:"facetas,omitempty"`
+}
+
+// Buscador faz a busca ranqueada no índice criado pelo importador: a tabela
+// FTS5 id_search no SQLite ou a visão materializada {busca} (tsvector e
+// pg_trgm) no PostgreSQL
+type Buscador struct {
+	db      *sql.DB
+	dialeto database.Dialect
+}
+
+// NewBuscador cria o buscador sobre a conexão da base de busca
+func NewBuscador(db *sql.DB, dialeto database.Dialect) *Buscador {
+	return &Buscador{db: db, dialeto: dialeto}
+}
+
+// Buscar retorna a página de resultados da consulta. Acentos são ignorados
+// e cada termo casa como prefixo; um CNPJ válido busca o estabelecimento.
+func (b *Buscador) Buscar(ctx context.Context, consulta Consulta) (*Resultado, error) {
+	if b.db == nil {
+		return nil, fmt.Errorf("banco de dados de busca não disponível")
+	}
+	consulta = normalizarConsulta(consulta)
+
+	condicao, args, err := b.condicaoTexto(consulta.Texto)
+	if err != nil {
+		return nil, err
+	}
+	filtro, argsFiltro := filtros(consulta)
+	where := condicao + filtro
+	args = append(args, argsFiltro...)
+
+	resultado := &Resultado{
+		Resultados: []models.SearchResult{},
+		Pagina:     consulta.Pagina,
+		PorPagina:  consulta.PorPagina,
+	}
+
+	contagem := b.dialeto.Query(fmt.Sprintf("SELECT COUNT(DISTINCT id) FROM %s WHERE %s", b.tabela(), where))
+	if err := b.db.QueryRowContext(ctx, contagem, args...).Scan(&resultado.Total); err != nil {
+		return nil, erroIndice(err)
+	}
+	if resultado.Total == 0 {
+		return resultado, nil
+	}
+
+	rows, err := b.db.QueryContext(ctx, b.queryPagina(consulta.Texto, where), b.argsPagina(consulta, args)...)
+	if err != nil {
+		return nil, erroIndice(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.SearchResult
+		var cnpj, uf, municipio, situacao, cnae sql.NullString
+		if err := rows.Scan(&r.ID, &r.Type, &r.Label, &cnpj, &uf, &municipio, &situacao, &cnae, &r.Score); err != nil {
+			return nil, err
+		}
+		r.CNPJ, r.UF, r.Municipio, r.Situacao, r.CNAE = cnpj.String, uf.String, municipio.String, situacao.String, cnae.String
+		resultado.Resultados = append(resultado.Resultados, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if consulta.Facetas {
+		resultado.Facetas = make(map[string][]Faceta, len(facetas))
+		for _, coluna := range facetas {
+			contagens, err := b.contarFaceta(ctx, coluna, where, args)
+			if err != nil {
+				return nil, erroIndice(err)
+			}
+			resultado.Facetas[coluna] = contagens
+		}
+	}
+	return resultado, nil
+}
+
+// tabela é o índice de busca do backend
+func (b *Buscador) tabela() string {
+	if b.dialeto.Postgres {
+		return "{busca}"
+	}
+	return "id_search"
+}
+
+// condicaoTexto monta a condição que seleciona as entradas da consulta
+func (b *Buscador) condicaoTexto(texto string) (string, []interface{}, error) {
+	if pareceCNPJValido(texto) {
+		cnpj := cpfcnpj.LimparCNPJ(texto)
+		if b.dialeto.Postgres {
+			return "cnpj = ?", []interface{}{cnpj}, nil
+		}
+		// O CNPJ é um termo de id_descricao (PJ_<cnpj>-<nome>)
+		return "id_search MATCH ? AND cnpj = ?", []interface{}{`id_descricao : "` + cnpj + `"`, cnpj}, nil
+	}
+
+	termos := termosBusca(texto)
+	if len(termos) == 0 {
+		return "", nil, ErrConsultaVazia
+	}
+	if b.dialeto.Postgres {
+		return "(documento @@ to_tsquery('simple', ?) OR nome_busca % ?)",
+			[]interface{}{expressaoTSQuery(termos), strings.Join(termos, " ")}, nil
+	}
+	return "id_search MATCH ?", []interface{}{expressaoFTS(termos)}, nil
+}
+
+// queryPagina seleciona uma linha por entidade (razão social e nome fantasia
+// da mesma empresa são entradas distintas do índice), pela maior relevância
+func (b *Buscador) queryPagina(texto string, where string) string {
+	if b.dialeto.Postgres {
+		score := "1.0"
+		if !pareceCNPJValido(texto) {
+			score = "ts_rank(documento, to_tsquery('simple', ?)) + similarity(nome_busca, ?)"
+		}
+		return b.dialeto.Query(fmt.Sprintf(`
+			SELECT id, tipo, nome, cnpj, uf, municipio, situacao, cnae, score FROM (
+				SELECT DISTINCT ON (id) id, tipo, nome, cnpj, uf, municipio, situacao, cnae, %s AS score
+				FROM {busca}
+				WHERE %s
+				ORDER BY id, score DESC
+			) AS t
+			ORDER BY score DESC, nome
+			LIMIT ? OFFSET ?
+		`, score, where))
+	}
+	// Pesos do bm25 por coluna: apenas nome conta na relevância. O bm25 só
+	// vale na consulta do MATCH, por isso a CTE materializada. Com MAX(), o
+	// SQLite devolve as demais colunas da linha de maior relevância.
+	return fmt.Sprintf(`
+		WITH encontrados AS MATERIALIZED (
+			SELECT id, tipo, nome, cnpj, uf, municipio, situacao, cnae, -bm25(id_search, 0.0, 1.0) AS score
+			FROM id_search
+			WHERE %s
+		)
+		SELECT id, tipo, nome, cnpj, uf, municipio, situacao, cnae, MAX(score) AS score
+		FROM encontrados
+		GROUP BY id
+		ORDER BY score DESC, nome
+		LIMIT ? OFFSET ?
+	`, where)
+}
+
+// argsPagina completa os argumentos de queryPagina: os do score (PostgreSQL),
+// os da condição e a paginação
+func (b *Buscador) argsPagina(consulta Consulta, args []interface{}) []interface{} {
+	var todos []interface{}
+	if b.dialeto.Postgres && !pareceCNPJValido(consulta.Texto) {
+		termos := termosBusca(consulta.Texto)
+		todos = append(todos, expressaoTSQuery(termos), strings.Join(termos, " "))
+	}
+	todos = append(todos, args...)
+	return append(todos, consulta.PorPagina, (consulta.Pagina-1)*consulta.PorPagina)
+}
+
+// contarFaceta conta os resultados por valor da coluna, dos mais frequentes
+// aos menos
+func (b *Buscador) contarFaceta(ctx context.Context, coluna, where string, args []interface{}) ([]Faceta, error) {
+	query := b.dialeto.Query(fmt.Sprintf(`
+		SELECT %[1]s, COUNT(DISTINCT id) AS quantidade
+		FROM %[2]s
+		WHERE %[3]s AND %[1]s IS NOT NULL AND %[1]s <> ''
+		GROUP BY %[1]s
+		ORDER BY quantidade DESC, %[1]s
+		LIMIT %[4]d
+	`, coluna, b.tabela(), where, facetasMaximo))
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contagens := []Faceta{}
+	for rows.Next() {
+		var f Faceta
+		if err := rows.Scan(&f.Valor, &f.Quantidade); err != nil {
+			return nil, err
+		}
+		contagens = append(contagens, f)
+	}
+	return contagens, rows.Err()
+}
+
+// normalizarConsulta aplica os padrões de paginação e padroniza os filtros
+func normalizarConsulta(c Consulta) Consulta {
+	if c.Pagina < 1 {
+		c.Pagina = 1
+	}
+	if c.PorPagina <= 0 {
+		c.PorPagina = porPaginaPadrao
+	}
+	if c.PorPagina > porPaginaMaximo {
+		c.PorPagina = porPaginaMaximo
+	}
+	c.Tipos = valoresFiltro(c.Tipos)
+	c.UF = valoresFiltro(c.UF)
+	c.CNAE = valoresFiltro(c.CNAE)
+	c.Situacao = valoresFiltro(c.Situacao)
+	return c
+}
+
+// valoresFiltro remove espaços e valores vazios e converte para maiúsculas
+func valoresFiltro(valores []string) []string {
+	var limpos []string
+	for _, v := range valores {
+		if v = strings.ToUpper(strings.TrimSpace(v)); v != "" {
+			limpos = append(limpos, v)
+		}
+	}
+	return limpos
+}
+
+// filtros monta as condições de tipo e das facetas; valores de um mesmo
+// filtro se combinam com OU, filtros diferentes com E
+func filtros(c Consulta) (string, []interface{}) {
+	var sb strings.Builder
+	var args []interface{}
+	for _, f := range []struct {
+		coluna  string
+		valores []string
+	}{
+		{"tipo", c.Tipos},
+		{"uf", c.UF},
+		{"cnae", c.CNAE},
+		{"situacao", c.Situacao},
+	} {
+		if len(f.valores) == 0 {
+			continue
+		}
+		sb.WriteString(" AND " + f.coluna + " IN (?" + strings.Repeat(", ?", len(f.valores)-1) + ")")
+		for _, v := range f.valores {
+			args = append(args, v)
+		}
+	}
+	return sb.String(), args
+}
+
+// termosBusca separa a consulta em termos sem acentos, em maiúsculas, só
+// com letras e dígitos
+func termosBusca(texto string) []string {
+	texto = strings.ToUpper(utils.RemoveAcentos(texto))
+	return strings.FieldsFunc(texto, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+}
+
+// expressaoFTS monta a expressão MATCH do FTS5: todos os termos, como
+// prefixo, na coluna nome
+func expressaoFTS(termos []string) string {
+	partes := make([]string, len(termos))
+	for i, t := range termos {
+		partes[i] = `"` + t + `"*`
+	}
+	return "nome : (" + strings.Join(partes, " AND ") + ")"
+}
+
+// expressaoTSQuery monta a tsquery do PostgreSQL: todos os termos, como
+// prefixo
+func expressaoTSQuery(termos []string) string {
+	partes := make([]string, len(termos))
+	for i, t := range termos {
+		partes[i] = t + ":*"
+	}
+	return strings.Join(partes, " & ")
+}
+
+// pareceCNPJValido indica se a consulta é um CNPJ, com ou sem máscara
+func pareceCNPJValido(texto string) bool {
+	cnpj := cpfcnpj.LimparCNPJ(texto)
+	return cpfcnpj.PareceCNPJ(cnpj) && cpfcnpj.ValidarCNPJ(cnpj) != ""
+}
+
+// erroIndice explica o erro de um índice de busca ausente ou criado por uma
+// versão anterior do importador
+func erroIndice(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "no such column") || strings.Contains(msg, "no such table") ||
+		strings.Contains(msg, "does not exist") || strings.Contains(msg, "no such module") {
+		return fmt.Errorf("índice de busca ausente ou desatualizado; recrie com rede-cnpj-importer -search: %w", err)
+	}
+	return err
+}
@@ -0,0 +1,174 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/importer"
+)
+
+func TestTermosBusca(t *testing.T) {
+	casos := []struct {
+		entrada string
+		termos  []string
+	}{
+		{"José da Silva", []string{"JOSE", "DA", "SILVA"}},
+		{"  CONSTRUÇÕES   ÁGUA-BRANCA ltda.", []string{"CONSTRUCOES", "AGUA", "BRANCA", "LTDA"}},
+		{"\"nome\" OR *", []string{"NOME", "OR"}},
+		{"*?-", []string{}},
+	}
+	for _, c := range casos {
+		if termos := termosBusca(c.entrada); !reflect.DeepEqual(termos, c.termos) {
+			t.Errorf("termosBusca(%q) = %v, esperado %v", c.entrada, termos, c.termos)
+		}
+	}
+
+	if got := expressaoFTS([]string{"JOSE", "SILVA"}); got != `nome : ("JOSE"* AND "SILVA"*)` {
+		t.Errorf("expressaoFTS() = %s", got)
+	}
+	if got := expressaoTSQuery([]string{"JOSE", "SILVA"}); got != "JOSE:* & SILVA:*" {
+		t.Errorf("expressaoTSQuery() = %s", got)
+	}
+}
+
+func TestFiltros(t *testing.T) {
+	c := normalizarConsulta(Consulta{Tipos: []string{"pj"}, UF: []string{" sp", "", "RJ"}, Situacao: []string{"02"}})
+	where, args := filtros(c)
+	if where != " AND tipo IN (?) AND uf IN (?, ?) AND situacao IN (?)" {
+		t.Errorf("filtros() = %q", where)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"PJ", "SP", "RJ", "02"}) {
+		t.Errorf("filtros() args = %v", args)
+	}
+	if c.Pagina != 1 || c.PorPagina != porPaginaPadrao {
+		t.Errorf("paginação padrão = %d/%d", c.Pagina, c.PorPagina)
+	}
+	if c := normalizarConsulta(Consulta{PorPagina: 1000}); c.PorPagina != porPaginaMaximo {
+		t.Errorf("por página = %d, esperado o máximo %d", c.PorPagina, porPaginaMaximo)
+	}
+}
+
+// indiceTeste cria cnpj.db e rede.db mínimos e o índice id_search com o
+// indexador do importador
+func indiceTeste(t *testing.T) *sql.DB {
+	t.Helper()
+	dir := t.TempDir()
+	stmts := map[string][]string{
+		"cnpj.db": {
+			`CREATE TABLE empresas (cnpj_basico TEXT, razao_social TEXT)`,
+			`CREATE TABLE estabelecimento (cnpj TEXT, cnpj_basico TEXT, matriz_filial TEXT, nome_fantasia TEXT,
+				situacao_cadastral TEXT, cnae_fiscal TEXT, uf TEXT, municipio TEXT)`,
+			`CREATE TABLE municipio (codigo TEXT, descricao TEXT)`,
+			`INSERT INTO municipio VALUES ('7107', 'SAO PAULO'), ('6001', 'RIO DE JANEIRO')`,
+			`INSERT INTO empresas VALUES ('11222333', 'CONSTRUÇÕES ÁGUA BRANCA LTDA'), ('11444777', 'AGUA BRANCA COMERCIO LTDA'),
+				('12ABC345', 'PADARIA BRANCA DE NEVE LTDA')`,
+			`INSERT INTO estabelecimento VALUES
+				('11222333000181', '11222333', '1', 'ÁGUA BRANCA', '02', '4120400', 'SP', '7107'),
+				('11444777000161', '11444777', '1', '', '08', '4711302', 'RJ', '6001'),
+				('12ABC34501DE35', '12ABC345', '1', '', '02', '1091102', 'SP', '7107')`,
+		},
+		"rede.db": {
+			`CREATE TABLE ligacao (id1 TEXT, id2 TEXT, descricao TEXT, comentario TEXT)`,
+			`INSERT INTO ligacao VALUES ('PF_***123456**-JOSÉ ÁGUA BRANCA', 'PJ_11222333000181', 'Sócio', 'socios'),
+				('PE_WHITE WATER INC', 'PJ_11444777000161', 'Sócio', 'socios')`,
+		},
+	}
+	for arquivo, comandos := range stmts {
+		db, err := sql.Open("sqlite3", filepath.Join(dir, arquivo))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, stmt := range comandos {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		db.Close()
+	}
+
+	if err := importer.NewIndexer(dir).CreateIndexes(); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			t.Skip("SQLite sem FTS5; execute os testes com -tags sqlite_fts5")
+		}
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "rede_search.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestBuscarSQLite(t *testing.T) {
+	b := NewBuscador(indiceTeste(t), database.Dialect{})
+	ctx := context.Background()
+
+	// Sem acentos, por prefixo, uma linha por entidade
+	r, err := b.Buscar(ctx, Consulta{Texto: "agua bran", Facetas: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 3 || len(r.Resultados) != 3 {
+		t.Fatalf("agua bran: total %d, resultados %+v", r.Total, r.Resultados)
+	}
+	tipos := map[string]string{}
+	for i, res := range r.Resultados {
+		tipos[res.ID] = res.Type
+		if res.Score <= 0 || (i > 0 && res.Score > r.Resultados[i-1].Score) {
+			t.Errorf("score fora de ordem: %+v", r.Resultados)
+		}
+	}
+	if tipos["PJ_11222333000181"] != "PJ" || tipos["PJ_11444777000161"] != "PJ" || tipos["PF_***123456**-JOSÉ ÁGUA BRANCA"] != "PF" {
+		t.Errorf("tipos = %v", tipos)
+	}
+	if uf := r.Facetas["uf"]; len(uf) != 2 || uf[0].Quantidade != 1 {
+		t.Errorf("faceta uf = %+v", uf)
+	}
+
+	// Filtros
+	r, err = b.Buscar(ctx, Consulta{Texto: "branca", UF: []string{"sp"}, Situacao: []string{"02"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 2 {
+		t.Errorf("branca em SP ativas: total %d, resultados %+v", r.Total, r.Resultados)
+	}
+	for _, res := range r.Resultados {
+		if res.UF != "SP" || res.Municipio != "SAO PAULO" || res.Situacao != "02" {
+			t.Errorf("resultado fora do filtro: %+v", res)
+		}
+	}
+
+	// Paginação
+	r, err = b.Buscar(ctx, Consulta{Texto: "branca", PorPagina: 2, Pagina: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 4 || len(r.Resultados) != 2 {
+		t.Errorf("página 2: total %d, %d resultados", r.Total, len(r.Resultados))
+	}
+
+	// CNPJ com máscara, numérico ou alfanumérico
+	for _, cnpj := range []string{"11.222.333/0001-81", "12.abc.345/01de-35"} {
+		r, err = b.Buscar(ctx, Consulta{Texto: cnpj})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Total != 1 || r.Resultados[0].CNPJ != strings.ToUpper(strings.NewReplacer(".", "", "/", "", "-", "").Replace(cnpj)) {
+			t.Errorf("CNPJ %s: %+v", cnpj, r.Resultados)
+		}
+	}
+
+	if _, err := b.Buscar(ctx, Consulta{Texto: "*?-"}); !errors.Is(err, ErrConsultaVazia) {
+		t.Errorf("consulta sem termos: erro %v, esperado ErrConsultaVazia", err)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"time"
 
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/export"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
 	"github.com/tealeg/xlsx/v3"
 )
@@ -130,73 +130,16 @@ func (s *ExportService) ExportToCSV(graph *models.Graph) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-// ExportToI2 exporta dados para formato i2 Chart Reader (.anx)
+// ExportToI2 exporta dados para formato i2 Analyst's Notebook / Chart Reader (.anx)
 func (s *ExportService) ExportToI2(graph *models.Graph) (*bytes.Buffer, error) {
-	// Estrutura simplificada do formato i2
-	// Na versão completa, implementar XML completo do formato ANX
-	
-	i2Data := map[string]interface{}{
-		"chart": map[string]interface{}{
-			"version": "1.0",
-			"created": time.Now().Format(time.RFC3339),
-			"entities": s.convertNodesToI2Entities(graph.Nodes),
-			"links":    s.convertEdgesToI2Links(graph.Edges),
-		},
-	}
-
-	data, err := json.MarshalIndent(i2Data, "", "  ")
+	data, err := export.NewI2Exporter().ExportGraph(graph)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao serializar i2: %w", err)
+		return nil, err
 	}
 
 	return bytes.NewBuffer(data), nil
 }
 
-// convertNodesToI2Entities converte nós para formato i2
-func (s *ExportService) convertNodesToI2Entities(nodes []models.Node) []map[string]interface{} {
-	entities := make([]map[string]interface{}, len(nodes))
-	
-	for i, node := range nodes {
-		entityType := "Person"
-		if node.Type == "PJ" {
-			entityType = "Organization"
-		}
-
-		entities[i] = map[string]interface{}{
-			"id":    node.ID,
-			"label": node.Label,
-			"type":  entityType,
-			"properties": map[string]interface{}{
-				"icon":  node.Icon,
-				"color": node.Color,
-				"note":  node.Note,
-			},
-		}
-	}
-
-	return entities
-}
-
-// convertEdgesToI2Links converte arestas para formato i2
-func (s *ExportService) convertEdgesToI2Links(edges []models.Edge) []map[string]interface{} {
-	links := make([]map[string]interface{}, len(edges))
-	
-	for i, edge := range edges {
-		links[i] = map[string]interface{}{
-			"from":  edge.From,
-			"to":    edge.To,
-			"label": edge.Label,
-			"type":  edge.Type,
-			"properties": map[string]interface{}{
-				"qualificacao": edge.Qualificacao,
-				"valor":        edge.Value,
-			},
-		}
-	}
-
-	return links
-}
-
 // escapeCsv escapa valores para CSV
 func escapeCsv(s string) string {
 	// Se contém vírgula, aspas ou quebra de linha, envolve em aspas
@@ -1,14 +1,22 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/config"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/database"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/endereco"
+	grafo "github.com/peder1981/rede-cnpj/RedeGO/internal/graph"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/identidade"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/importer"
 	"github.com/peder1981/rede-cnpj/RedeGO/internal/models"
+	"github.com/peder1981/rede-cnpj/RedeGO/internal/search"
 	"github.com/peder1981/rede-cnpj/RedeGO/pkg/cpfcnpj"
 )
 
@@ -26,20 +34,43 @@ func NewRedeService(cfg *config.Config) *RedeService {
 	}
 }
 
-// CamadasRede busca camadas de relacionamentos a partir de uma lista de IDs
-func (s *RedeService) CamadasRede(camada int, listaIDs []string, grupo string, criterioCaminhos string) (*models.Graph, error) {
-	database.Lock()
-	defer database.Unlock()
-
-	startTime := time.Now()
-	maxDuration := time.Duration(s.cfg.TempoMaximoConsulta) * time.Second
+// ContextoConsulta deriva do contexto informado um contexto com o prazo
+// TempoMaximoConsulta. Se o contexto pai já tiver um prazo menor, ele é mantido.
+func ContextoConsulta(parent context.Context, cfg *config.Config) (context.Context, context.CancelFunc) {
+	if cfg.TempoMaximoConsulta <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, time.Duration(cfg.TempoMaximoConsulta*float64(time.Second)))
+}
 
+// CamadasRede busca camadas de relacionamentos a partir de uma lista de IDs.
+// A expansão é feita em largura sobre a tabela ligacao: a camada 1 traz os
+// vizinhos diretos dos IDs informados, a camada 2 os vizinhos destes e assim
+// por diante. Se o limite de registros por camada for atingido ou o prazo do
+// contexto expirar, o grafo parcial é retornado com Truncado = true. Se o
+// contexto for cancelado (cliente desconectou), retorna o erro do contexto.
+// Com asOf (AAAA-MM), as ligações são reconstruídas a partir do histórico de
+// sócios e estabelecimentos vigente naquela referência.
+// Com criterioCaminhos ("caminhos", "direto" ou "comum"), em vez da expansão
+// são retornados os caminhos entre cada par de IDs informados.
+// Nos dois casos, os nós PF recebem as sugestões de mesma pessoa da
+// resolução de identidades (ver marcarIdentidades).
+func (s *RedeService) CamadasRede(ctx context.Context, camada int, listaIDs []string, criterioCaminhos string, asOf string) (*models.Graph, error) {
 	graph := &models.Graph{
 		Nodes: make([]models.Node, 0),
 		Edges: make([]models.Edge, 0),
 	}
 
-	if len(listaIDs) == 0 && grupo == "" {
+	if len(listaIDs) == 0 {
+		return graph, nil
+	}
+
+	if criterioCaminhos != "" {
+		graph, err := s.caminhosRede(ctx, camada, listaIDs, criterioCaminhos, asOf)
+		if err != nil {
+			return nil, err
+		}
+		s.marcarIdentidades(ctx, graph, asOf)
 		return graph, nil
 	}
 
@@ -47,195 +78,545 @@ func (s *RedeService) CamadasRede(camada int, listaIDs []string, grupo string, c
 	nodeMap := make(map[string]bool)
 	edgeMap := make(map[string]bool)
 
-	// Processa cada ID da lista
-	for _, id := range listaIDs {
-		if time.Since(startTime) > maxDuration {
+	// Camada 0: IDs informados
+	fronteira := make([]string, 0, len(listaIDs))
+	for _, informado := range listaIDs {
+		ids, err := s.idsLigacao(ctx, informado)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if nodeMap[id] {
+				continue
+			}
+			graph.Nodes = append(graph.Nodes, s.createNodeFromLigacaoID(ctx, id, asOf))
+			nodeMap[id] = true
+			fronteira = append(fronteira, id)
+		}
+	}
+
+	// Camadas seguintes: expansão em largura
+	for nivel := 1; nivel <= camada && len(fronteira) > 0; nivel++ {
+		proxima, err := s.expandirCamada(ctx, fronteira, nivel, asOf, graph, nodeMap, edgeMap)
+		if err != nil {
+			return nil, err
+		}
+		if graph.Truncado {
 			break
 		}
+		fronteira = proxima
+	}
 
-		id = strings.TrimSpace(id)
-		if id == "" {
+	s.marcarIdentidades(ctx, graph, asOf)
+	return graph, nil
+}
+
+// rotulosIdentidade rótulos das arestas entre nós PF da mesma pessoa
+var rotulosIdentidade = map[string]string{
+	identidade.ClasseMesmaPessoa:   "mesma pessoa",
+	identidade.ClassePossivelMesma: "possível mesma pessoa",
+}
+
+// marcarIdentidades compara os primeiros IdentidadesCamada nós PF do grafo
+// com os outros sócios de mesmo nome ou CPF mascarado. As sugestões ficam em
+// Data["identidades"], IDs que juntam pessoas de faixas etárias distantes
+// recebem a flag "possiveis_homonimos" e os pares de nós do grafo que são
+// (possivelmente) a mesma pessoa ganham uma aresta do tipo da classe. A
+// resolução usa o QSA atual, então não se aplica a grafos históricos (asOf).
+// Se o prazo expirar, os nós restantes ficam sem sugestões; as falhas da
+// resolução são informadas em Mensagem.
+func (s *RedeService) marcarIdentidades(ctx context.Context, graph *models.Graph, asOf string) {
+	db := database.GetDBReceita()
+	if s.cfg.IdentidadesCamada <= 0 || asOf != "" || db == nil {
+		return
+	}
+	resolvedor := identidade.NewResolvedor(db, database.NewDialect())
+
+	noGrafo := make(map[string]bool, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		noGrafo[node.ID] = true
+	}
+	ligados := make(map[string]bool)
+	resolvidos, falhas := 0, 0
+	var primeiraFalha error
+
+	for i := range graph.Nodes {
+		node := &graph.Nodes[i]
+		if node.Type != "PF" {
+			continue
+		}
+		if resolvidos >= s.cfg.IdentidadesCamada || ctx.Err() != nil {
+			break
+		}
+		resolvidos++
+
+		res, err := resolvedor.Resolver(ctx, node.ID)
+		if err != nil {
+			if ctx.Err() == nil {
+				falhas++
+				if primeiraFalha == nil {
+					primeiraFalha = err
+				}
+			}
 			continue
 		}
+		if len(res.Sugestoes) == 0 && !res.Homonimos {
+			continue
+		}
+		if res.Homonimos {
+			node.Flags = append(node.Flags, "possiveis_homonimos")
+		}
+		if node.Data == nil {
+			node.Data = make(map[string]interface{})
+		}
+		node.Data["identidades"] = res
 
-		// Valida e normaliza CPF/CNPJ
-		if validCNPJ := cpfcnpj.ValidarCNPJ(id); validCNPJ != "" {
-			id = validCNPJ
-		} else if validCPF := cpfcnpj.ValidarCPF(id); validCPF != "" {
-			id = validCPF
+		for _, sug := range res.Sugestoes {
+			par := node.ID + "|" + sug.ID
+			if sug.ID < node.ID {
+				par = sug.ID + "|" + node.ID
+			}
+			if !noGrafo[sug.ID] || ligados[par] {
+				continue
+			}
+			ligados[par] = true
+			graph.Edges = append(graph.Edges, models.Edge{
+				From:  node.ID,
+				To:    sug.ID,
+				Label: fmt.Sprintf("%s (%.2f)", rotulosIdentidade[sug.Classe], sug.Score),
+				Type:  sug.Classe,
+				Value: sug.Score,
+			})
 		}
+	}
+	if falhas > 0 {
+		aviso := fmt.Sprintf("Resolução de identidade falhou em %d nós PF: %v", falhas, primeiraFalha)
+		if graph.Mensagem != "" {
+			aviso = graph.Mensagem + "; " + aviso
+		}
+		graph.Mensagem = aviso
+	}
+}
 
-		// Adiciona nó inicial se não existir
+// caminhosRede liga os IDs informados, dois a dois, pelos menores caminhos
+// com até 2*camada ligações (o alcance da expansão a partir de ambos os
+// lados). "caminhos" traz os k menores caminhos, "direto" apenas o menor e
+// "comum" as entidades ligadas diretamente aos dois IDs.
+func (s *RedeService) caminhosRede(ctx context.Context, camada int, listaIDs []string, criterio string, asOf string) (*models.Graph, error) {
+	if asOf != "" {
+		return nil, fmt.Errorf("busca de caminhos não disponível com referência histórica")
+	}
+
+	opcoes := grafo.OpcoesCaminhos{MaxProfundidade: 2 * max(camada, 1)}
+	switch criterio {
+	case "caminhos":
+	case "direto":
+		opcoes.MaxCaminhos = 1
+	case "comum":
+		opcoes.MaxProfundidade = 2
+		opcoes.MaxCaminhos = s.cfg.LimiteRegistrosCamada
+	default:
+		return nil, fmt.Errorf("critério de caminhos não previsto: %s", criterio)
+	}
+
+	ids := make([]string, 0, len(listaIDs))
+	vistos := make(map[string]bool)
+	for _, informado := range listaIDs {
+		resolvidos, err := s.idsLigacao(ctx, informado)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range resolvidos {
+			if !vistos[id] {
+				vistos[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	graph := &models.Graph{
+		Nodes: make([]models.Node, 0),
+		Edges: make([]models.Edge, 0),
+	}
+	nodeMap := make(map[string]bool)
+	edgeMap := make(map[string]bool)
+	adicionarNo := func(id string, nivel int) {
 		if !nodeMap[id] {
-			node := s.createNodeFromID(id)
+			node := s.createNodeFromLigacaoID(ctx, id, asOf)
+			node.Camada = nivel
 			graph.Nodes = append(graph.Nodes, node)
 			nodeMap[id] = true
 		}
+	}
+	for _, id := range ids {
+		adicionarNo(id, 0)
+	}
 
-		// Busca relacionamentos
-		if err := s.buscarRelacionamentos(id, camada, graph, nodeMap, edgeMap, startTime, maxDuration); err != nil {
-			return nil, err
+	pathFinder := grafo.NewPathFinder(s.db, database.NewDialect())
+	for i := 0; i < len(ids); i++ {
+		for j := i + 1; j < len(ids); j++ {
+			resultado, err := pathFinder.FindPaths(ctx, ids[i], ids[j], opcoes)
+			if err != nil {
+				return nil, err
+			}
+			for _, caminho := range resultado.Caminhos {
+				for k, id := range caminho.Nos {
+					adicionarNo(id, min(k, len(caminho.Nos)-1-k))
+				}
+			}
+			for _, edge := range resultado.Edges {
+				edgeKey := edge.From + "->" + edge.To
+				if !edgeMap[edgeKey] {
+					graph.Edges = append(graph.Edges, edge)
+					edgeMap[edgeKey] = true
+				}
+			}
+			if resultado.Truncado {
+				graph.Truncado = true
+				graph.Mensagem = resultado.Mensagem
+				return graph, nil
+			}
 		}
 	}
 
 	return graph, nil
 }
 
-// buscarRelacionamentos busca recursivamente os relacionamentos de um ID
-func (s *RedeService) buscarRelacionamentos(id string, camada int, graph *models.Graph, nodeMap map[string]bool, edgeMap map[string]bool, startTime time.Time, maxDuration time.Duration) error {
-	if camada <= 0 || time.Since(startTime) > maxDuration {
-		return nil
-	}
+// expandirCamada busca os vizinhos de todos os nós da fronteira e retorna os
+// nós descobertos, que formam a fronteira da camada seguinte
+func (s *RedeService) expandirCamada(ctx context.Context, fronteira []string, nivel int, asOf string, graph *models.Graph, nodeMap map[string]bool, edgeMap map[string]bool) ([]string, error) {
+	proxima := make([]string, 0)
+	registros := 0
 
-	// Busca sócios se for CNPJ
-	if len(id) == 14 {
-		if err := s.buscarSocios(id, graph, nodeMap, edgeMap); err != nil {
-			return err
+	for _, id := range fronteira {
+		if err := ctx.Err(); err != nil {
+			return proxima, s.prazoEsgotado(err, graph, nivel)
 		}
-	}
 
-	// Busca empresas se for CPF ou nome de sócio
-	if len(id) == 11 || len(id) > 14 {
-		if err := s.buscarEmpresas(id, graph, nodeMap, edgeMap); err != nil {
-			return err
+		restante := s.cfg.LimiteRegistrosCamada - registros
+
+		// Busca um registro a mais para saber se o limite foi ultrapassado
+		var ligacoes []ligacao
+		var err error
+		if asOf != "" {
+			ligacoes, err = s.buscarLigacoesHistoricas(ctx, id, restante+1, asOf)
+		} else {
+			ligacoes, err = s.buscarLigacoes(ctx, id, restante+1)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return proxima, s.prazoEsgotado(ctx.Err(), graph, nivel)
+			}
+			return nil, err
+		}
+		if len(ligacoes) > restante {
+			ligacoes = ligacoes[:restante]
+			graph.Truncado = true
+			graph.Mensagem = fmt.Sprintf("Limite de %d registros atingido na camada %d", s.cfg.LimiteRegistrosCamada, nivel)
+		}
+		registros += len(ligacoes)
+
+		for _, lig := range ligacoes {
+			vizinho := lig.id1
+			if vizinho == id {
+				vizinho = lig.id2
+			}
+
+			if !nodeMap[vizinho] {
+				node := s.createNodeFromLigacaoID(ctx, vizinho, asOf)
+				node.Camada = nivel
+				graph.Nodes = append(graph.Nodes, node)
+				nodeMap[vizinho] = true
+				proxima = append(proxima, vizinho)
+			}
+
+			// Aresta mantém a direção da ligação (sócio -> empresa)
+			edgeKey := lig.id1 + "->" + lig.id2
+			if !edgeMap[edgeKey] {
+				graph.Edges = append(graph.Edges, models.Edge{
+					From:         lig.id1,
+					To:           lig.id2,
+					Label:        lig.descricao,
+					Type:         grafo.TipoLigacao(lig.descricao),
+					Qualificacao: lig.descricao,
+				})
+				edgeMap[edgeKey] = true
+			}
+		}
+
+		if graph.Truncado {
+			return proxima, nil
 		}
 	}
 
+	return proxima, nil
+}
+
+// prazoEsgotado marca o grafo como truncado quando o prazo da consulta expira.
+// Cancelamentos (cliente desconectado) são devolvidos como erro.
+func (s *RedeService) prazoEsgotado(err error, graph *models.Graph, nivel int) error {
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	graph.Truncado = true
+	graph.Mensagem = fmt.Sprintf("Tempo máximo de consulta atingido na camada %d", nivel)
 	return nil
 }
 
-// buscarSocios busca os sócios de uma empresa
-func (s *RedeService) buscarSocios(cnpj string, graph *models.Graph, nodeMap map[string]bool, edgeMap map[string]bool) error {
+// ligacao representa um registro da tabela ligacao
+type ligacao struct {
+	id1       string
+	id2       string
+	descricao string
+}
+
+// buscarLigacoes busca as ligações em que o ID aparece como origem ou destino
+func (s *RedeService) buscarLigacoes(ctx context.Context, id string, limite int) ([]ligacao, error) {
 	db := database.GetDBRede()
 	if db == nil {
-		return fmt.Errorf("banco de dados de rede não disponível")
+		return nil, fmt.Errorf("banco de dados de rede não disponível")
 	}
 
-	// Monta ID no formato PJ_CNPJ
-	cnpjID := "PJ_" + cnpj
-
-	query := `
-		SELECT id1, id2, descricao
-		FROM ligacao
-		WHERE id2 = ?
+	tabela := database.TablePrefix("ligacao")
+	query := database.AdaptQuery(fmt.Sprintf(`
+		SELECT id1, id2, descricao FROM %s WHERE id1 = ?
+		UNION ALL
+		SELECT id1, id2, descricao FROM %s WHERE id2 = ?
 		LIMIT ?
-	`
+	`, tabela, tabela))
 
-	rows, err := db.Query(query, cnpjID, s.cfg.LimiteRegistrosCamada)
+	rows, err := db.QueryContext(ctx, query, id, id, limite)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer rows.Close()
 
+	ligacoes := make([]ligacao, 0)
 	for rows.Next() {
-		var id1, id2, descricao string
+		var lig ligacao
+		var descricao sql.NullString
 
-		if err := rows.Scan(&id1, &id2, &descricao); err != nil {
+		if err := rows.Scan(&lig.id1, &lig.id2, &descricao); err != nil {
 			continue
 		}
+		lig.descricao = descricao.String
+		ligacoes = append(ligacoes, lig)
+	}
 
-		// Remove prefixo PE_ ou PF_ do ID do sócio
-		socioID := id1
-		socioLabel := strings.TrimPrefix(id1, "PE_")
-		socioLabel = strings.TrimPrefix(socioLabel, "PF_")
+	return ligacoes, rows.Err()
+}
 
-		if !nodeMap[socioID] {
-			node := models.Node{
-				ID:    socioID,
-				Label: socioLabel,
-				Type:  "PF",
-				Icon:  "pessoa",
-			}
-			graph.Nodes = append(graph.Nodes, node)
-			nodeMap[socioID] = true
-		}
-
-		// Cria aresta (invertida: sócio -> empresa)
-		edgeKey := socioID + "->" + cnpjID
-		if !edgeMap[edgeKey] {
-			edge := models.Edge{
-				From:         socioID,
-				To:           cnpjID,
-				Label:        descricao,
-				Type:         "socio",
-				Qualificacao: descricao,
-			}
-			graph.Edges = append(graph.Edges, edge)
-			edgeMap[edgeKey] = true
+// idSocioHistorico monta o ID de ligação do sócio com as mesmas regras do
+// importador (PJ_<cnpj>, PF_<cpf>-<nome>, PE_<nome>)
+const idSocioHistorico = `CASE
+		WHEN length(s.cnpj_cpf_socio) = 14 THEN 'PJ_' || s.cnpj_cpf_socio
+		WHEN length(s.cnpj_cpf_socio) = 11 THEN 'PF_' || s.cnpj_cpf_socio || '-' || s.nome_socio
+		ELSE 'PE_' || s.nome_socio
+	END`
+
+// buscarLigacoesHistoricas reconstrói as ligações de um ID na referência asOf
+// a partir de socios_historico e estabelecimento_historico. Cobre os vínculos
+// de sócio (PJ, PF e PE) e de filial; representantes legais não são versionados.
+func (s *RedeService) buscarLigacoesHistoricas(ctx context.Context, id string, limite int, asOf string) ([]ligacao, error) {
+	db := database.GetDBReceita()
+	if db == nil {
+		return nil, fmt.Errorf("banco de dados da receita não disponível")
+	}
+
+	dialeto := database.NewDialect()
+	selectSocio := fmt.Sprintf(`
+		SELECT %%s, 'PJ_' || s.cnpj, COALESCE(q.descricao, '')
+		FROM {socios_historico} s
+		LEFT JOIN {qualificacao_socio} q ON q.codigo = s.qualificacao_socio
+		WHERE %%s AND %s
+	`, dialeto.Vigente("s"))
+
+	var partes []string
+	var args []interface{}
+
+	switch {
+	case strings.HasPrefix(id, "PJ_"):
+		cnpj := strings.TrimPrefix(id, "PJ_")
+
+		// Sócios da empresa e empresas em que ela é sócia
+		partes = append(partes,
+			fmt.Sprintf(selectSocio, idSocioHistorico, "s.cnpj = ?"),
+			fmt.Sprintf(selectSocio, "'PJ_' || s.cnpj_cpf_socio", "s.cnpj_cpf_socio = ?"),
+		)
+		args = append(args, cnpj, asOf, asOf, cnpj, asOf, asOf)
+
+		// Filial -> matriz
+		partes = append(partes, fmt.Sprintf(`
+			SELECT 'PJ_' || f.cnpj, 'PJ_' || m.cnpj, 'filial'
+			FROM {estabelecimento_historico} f
+			JOIN {estabelecimento_historico} m ON m.cnpj_basico = f.cnpj_basico
+			WHERE f.matriz_filial = '2' AND m.matriz_filial = '1'
+			AND (f.cnpj = ? OR m.cnpj = ?)
+			AND %s AND %s
+		`, dialeto.Vigente("f"), dialeto.Vigente("m")))
+		args = append(args, cnpj, cnpj, asOf, asOf, asOf, asOf)
+
+	case strings.HasPrefix(id, "PF_"):
+		cpf, nome, _ := strings.Cut(strings.TrimPrefix(id, "PF_"), "-")
+		partes = append(partes, fmt.Sprintf(selectSocio, idSocioHistorico, "s.cnpj_cpf_socio = ? AND s.nome_socio = ?"))
+		args = append(args, cpf, nome, asOf, asOf)
+
+	case strings.HasPrefix(id, "PE_"):
+		partes = append(partes, fmt.Sprintf(selectSocio, idSocioHistorico, "s.nome_socio = ? AND COALESCE(s.cnpj_cpf_socio, '') = ''"))
+		args = append(args, strings.TrimPrefix(id, "PE_"), asOf, asOf)
+
+	default:
+		return []ligacao{}, nil
+	}
+
+	query := dialeto.Query(strings.Join(partes, " UNION ALL ") + " LIMIT ?")
+	args = append(args, limite)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ligacoes := make([]ligacao, 0)
+	for rows.Next() {
+		var lig ligacao
+		if err := rows.Scan(&lig.id1, &lig.id2, &lig.descricao); err != nil {
+			continue
 		}
+		ligacoes = append(ligacoes, lig)
 	}
 
-	return nil
+	return ligacoes, rows.Err()
+}
+
+// normalizarIDLigacao converte um ID informado pelo usuário para o formato
+// da tabela ligacao (PJ_<cnpj>, PF_<cpf>-<nome>, PE_<nome>). Um CPF, completo
+// ou mascarado, volta sem prefixo: idsLigacao o resolve para os nós PF.
+func normalizarIDLigacao(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return ""
+	}
+
+	if strings.HasPrefix(id, "PJ_") || strings.HasPrefix(id, "PF_") || strings.HasPrefix(id, "PE_") {
+		return id
+	}
+	if cpfMascarado(id) != "" {
+		return id
+	}
+
+	// Valida e normaliza CPF/CNPJ
+	if validCNPJ := cpfcnpj.ValidarCNPJ(id); validCNPJ != "" {
+		return "PJ_" + validCNPJ
+	} else if validCPF := cpfcnpj.ValidarCPF(id); validCPF != "" {
+		return validCPF
+	}
+
+	return id
 }
 
-// buscarEmpresas busca as empresas de um sócio
-func (s *RedeService) buscarEmpresas(socioID string, graph *models.Graph, nodeMap map[string]bool, edgeMap map[string]bool) error {
+// cpfMascarado retorna o CPF no formato mascarado do QSA (***456789**) a
+// partir do CPF completo ou já mascarado, ou vazio se o valor não for CPF
+func cpfMascarado(cpf string) string {
+	switch {
+	case len(cpf) == 11 && strings.Trim(cpf, "0123456789") == "":
+		return "***" + cpf[3:9] + "**"
+	case len(cpf) == 11 && strings.HasPrefix(cpf, "***") && strings.HasSuffix(cpf, "**") &&
+		strings.Trim(cpf[3:9], "0123456789") == "":
+		return cpf
+	}
+	return ""
+}
+
+// idsLigacao converte um ID informado pelo usuário nos IDs da tabela ligacao.
+// Como o nó de pessoa física inclui o nome (PF_<cpf mascarado>-<nome>), um
+// CPF resolve para todos os nós PF com aquele CPF mascarado, buscados por
+// faixa de prefixo em id1 e id2 (até LimiteRegistrosCamada nós).
+func (s *RedeService) idsLigacao(ctx context.Context, informado string) ([]string, error) {
+	id := normalizarIDLigacao(informado)
+	cpf := cpfMascarado(id)
+	if cpf == "" {
+		if id == "" {
+			return nil, nil
+		}
+		return []string{id}, nil
+	}
+
 	db := database.GetDBRede()
 	if db == nil {
-		return fmt.Errorf("banco de dados de rede não disponível")
+		return nil, fmt.Errorf("banco de dados de rede não disponível")
 	}
 
-	query := `
-		SELECT id1, id2, descricao
-		FROM ligacao
-		WHERE id1 = ?
+	// '.' é o caractere seguinte a '-': a faixa cobre exatamente o prefixo
+	inicio, fim := "PF_"+cpf+"-", "PF_"+cpf+"."
+	tabela := database.TablePrefix("ligacao")
+	query := database.AdaptQuery(fmt.Sprintf(`
+		SELECT id FROM (
+			SELECT id1 AS id FROM %s WHERE id1 >= ? AND id1 < ?
+			UNION
+			SELECT id2 AS id FROM %s WHERE id2 >= ? AND id2 < ?
+		) pf
+		ORDER BY id
 		LIMIT ?
-	`
+	`, tabela, tabela))
 
-	rows, err := db.Query(query, socioID, s.cfg.LimiteRegistrosCamada)
+	rows, err := db.QueryContext(ctx, query, inicio, fim, inicio, fim, s.cfg.LimiteRegistrosCamada)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer rows.Close()
 
+	var ids []string
 	for rows.Next() {
-		var id1, id2, descricao string
-
-		if err := rows.Scan(&id1, &id2, &descricao); err != nil {
-			continue
+		var pf string
+		if err := rows.Scan(&pf); err != nil {
+			return nil, err
 		}
+		ids = append(ids, pf)
+	}
+	return ids, rows.Err()
+}
 
-		// id2 é o CNPJ no formato PJ_CNPJ
-		cnpjID := id2
-		cnpj := strings.TrimPrefix(id2, "PJ_")
-
-		// Cria nó da empresa se não existir
-		if !nodeMap[cnpjID] {
-			node := s.createNodeFromID(cnpj)
-			node.ID = cnpjID // Usa ID com prefixo
-			graph.Nodes = append(graph.Nodes, node)
-			nodeMap[cnpjID] = true
-		}
-
-		// Cria aresta (sócio -> empresa)
-		edgeKey := socioID + "->" + cnpjID
-		if !edgeMap[edgeKey] {
-			edge := models.Edge{
-				From:         socioID,
-				To:           cnpjID,
-				Label:        descricao,
-				Type:         "socio",
-				Qualificacao: descricao,
-			}
-			graph.Edges = append(graph.Edges, edge)
-			edgeMap[edgeKey] = true
+// createNodeFromLigacaoID cria um nó a partir de um ID da tabela ligacao
+func (s *RedeService) createNodeFromLigacaoID(ctx context.Context, id string, asOf string) models.Node {
+	switch {
+	case strings.HasPrefix(id, "PJ_"):
+		node := s.createNodeFromID(ctx, strings.TrimPrefix(id, "PJ_"), asOf)
+		node.ID = id // Usa ID com prefixo
+		node.Type = "PJ"
+		node.Icon = "empresa"
+		return node
+	case strings.HasPrefix(id, "PE_"):
+		return models.Node{
+			ID:    id,
+			Label: strings.TrimPrefix(id, "PE_"),
+			Type:  "PE",
+			Icon:  "pessoa",
+		}
+	case strings.HasPrefix(id, "PF_"):
+		return models.Node{
+			ID:    id,
+			Label: strings.TrimPrefix(id, "PF_"),
+			Type:  "PF",
+			Icon:  "pessoa",
 		}
+	default:
+		return s.createNodeFromID(ctx, id, asOf)
 	}
-
-	return nil
 }
 
 // createNodeFromID cria um nó a partir de um ID
-func (s *RedeService) createNodeFromID(id string) models.Node {
+func (s *RedeService) createNodeFromID(ctx context.Context, id string, asOf string) models.Node {
 	node := models.Node{
 		ID:   id,
 		Type: "PJ",
 		Icon: "empresa",
 	}
 
-	// Busca dados da empresa se for CNPJ
-	if len(id) == 14 {
-		if dados := s.GetDadosCNPJ(id); dados != nil {
+	// Busca dados da empresa se for CNPJ (numérico ou alfanumérico)
+	if cpfcnpj.PareceCNPJ(id) {
+		if dados := s.GetDadosCNPJ(ctx, id, asOf); dados != nil {
 			node.Label = dados.RazaoSocial
 			if dados.SituacaoCadastral == "02" {
 				node.Color = "green"
@@ -254,54 +635,69 @@ func (s *RedeService) createNodeFromID(id string) models.Node {
 	return node
 }
 
-// GetDadosCNPJ busca dados detalhados de um CNPJ
-func (s *RedeService) GetDadosCNPJ(cnpj string) *models.CNPJData {
+// GetDadosCNPJ busca dados detalhados de um CNPJ. Com asOf (AAAA-MM), os
+// dados do estabelecimento vêm da versão vigente naquela referência.
+func (s *RedeService) GetDadosCNPJ(ctx context.Context, cnpj string, asOf string) *models.CNPJData {
 	db := database.GetDBReceita()
 	if db == nil {
 		return nil
 	}
 
+	// Aceita CNPJ com máscara e alfanumérico em minúsculas
+	cnpj = cpfcnpj.LimparCNPJ(cnpj)
+	if !cpfcnpj.PareceCNPJ(cnpj) {
+		return nil
+	}
+
 	// Extrai partes do CNPJ
 	cnpjBasico := cnpj[:8]
 	cnpjOrdem := cnpj[8:12]
 	cnpjDV := cnpj[12:14]
 
+	tabela, filtro := "{estabelecimento}", ""
+	args := []interface{}{cnpjBasico, cnpjOrdem, cnpjDV}
+	dialeto := database.NewDialect()
+	if asOf != "" {
+		tabela, filtro = "{estabelecimento_historico}", " AND "+dialeto.Vigente("e")
+		args = append(args, asOf, asOf)
+	}
+
 	// Query corrigida para estrutura real do banco
-	query := `
+	query := dialeto.Query(fmt.Sprintf(`
 		SELECT 
 			e.cnpj_basico || e.cnpj_ordem || e.cnpj_dv as cnpj,
 			emp.razao_social,
 			e.nome_fantasia,
-			e.situacao_cadastral,
-			e.data_situacao_cadastral,
+			COALESCE(e.situacao_cadastral, ''),
+			COALESCE(%[3]s, ''),
 			e.motivo_situacao_cadastral,
 			emp.natureza_juridica,
 			e.cnae_fiscal,
-			emp.capital_social,
-			emp.porte_empresa,
+			COALESCE(emp.capital_social, 0),
+			COALESCE(emp.porte_empresa, ''),
 			e.data_inicio_atividades,
-			e.tipo_logradouro || ' ' || e.logradouro as logradouro,
+			TRIM(COALESCE(e.tipo_logradouro, '') || ' ' || COALESCE(e.logradouro, '')) as logradouro,
 			e.numero,
 			e.complemento,
 			e.bairro,
-			m.descricao as municipio,
-			e.uf,
+			COALESCE(m.descricao, e.municipio, '') as municipio,
+			COALESCE(e.uf, ''),
 			e.cep,
 			e.ddd1 || e.telefone1 as telefone1,
 			e.ddd2 || e.telefone2 as telefone2,
 			e.correio_eletronico as email
-		FROM estabelecimento e
-		JOIN empresas emp ON e.cnpj_basico = emp.cnpj_basico
-		LEFT JOIN municipio m ON e.municipio = m.codigo
-		WHERE e.cnpj_basico = ? AND e.cnpj_ordem = ? AND e.cnpj_dv = ?
-	`
+		FROM %[1]s e
+		JOIN {empresas} emp ON e.cnpj_basico = emp.cnpj_basico
+		LEFT JOIN {municipio} m ON e.municipio = m.codigo
+		WHERE e.cnpj_basico = ? AND e.cnpj_ordem = ? AND e.cnpj_dv = ?%[2]s
+	`, tabela, filtro, dialeto.DateText("e.data_situacao_cadastral")))
 
 	var dados models.CNPJData
 	var nomeFantasia, dataAbertura, email, telefone1, telefone2 sql.NullString
 	var complemento, motivoSituacao, naturezaJuridica, cnae sql.NullString
 	var numero, bairro, cep sql.NullString
 
-	err := db.QueryRow(query, cnpjBasico, cnpjOrdem, cnpjDV).Scan(
+	err := db.QueryRowContext(ctx, query, args...).Scan(
 		&dados.CNPJ, &dados.RazaoSocial, &nomeFantasia, &dados.SituacaoCadastral,
 		&dados.DataSituacao, &motivoSituacao, &naturezaJuridica,
 		&cnae, &dados.CapitalSocial, &dados.Porte, &dataAbertura,
@@ -371,66 +767,144 @@ func (s *RedeService) GetDadosCNPJ(cnpj string) *models.CNPJData {
 	return &dados
 }
 
-// BuscaPorNome busca empresas ou sócios por nome
-func (s *RedeService) BuscaPorNome(nome string, limite int) ([]models.SearchResult, error) {
-	db := database.GetDBSearch()
-	if db == nil {
-		db = database.GetDBReceita()
+// ReferenciaEmUso retorna a referência (AAAA-MM) mais recente importada na
+// base, lida do histórico de estabelecimentos. Sem histórico, usa
+// referencia_bd da configuração.
+func (s *RedeService) ReferenciaEmUso(ctx context.Context) string {
+	db := database.GetDBReceita()
+	if db != nil {
+		var referencia sql.NullString
+		query := database.NewDialect().Query("SELECT MAX(valido_de) FROM {estabelecimento_historico}")
+		if err := db.QueryRowContext(ctx, query).Scan(&referencia); err == nil && referencia.String != "" {
+			return referencia.String
+		}
 	}
+	return s.cfg.ReferenciaBD
+}
+
+// UltimaQualidade retorna o resumo do relatório de qualidade da última
+// importação, gravado pelo importador em qualidade_importacao. Retorna nil se
+// a base ainda não tiver relatório.
+func (s *RedeService) UltimaQualidade(ctx context.Context) (*importer.ResumoQualidade, error) {
+	db := database.GetDBReceita()
 	if db == nil {
-		return nil, fmt.Errorf("banco de dados não disponível")
+		return nil, nil
 	}
 
-	results := make([]models.SearchResult, 0)
-	nome = strings.ToUpper(strings.TrimSpace(nome))
+	var relatorio string
+	query := database.NewDialect().Query("SELECT relatorio FROM {qualidade_importacao} ORDER BY gerado_em DESC LIMIT 1")
+	if err := db.QueryRowContext(ctx, query).Scan(&relatorio); err != nil {
+		// Sem linhas ou sem a tabela (base importada por versão anterior)
+		msg := err.Error()
+		if errors.Is(err, sql.ErrNoRows) || strings.Contains(msg, "no such table") || strings.Contains(msg, "does not exist") {
+			return nil, nil
+		}
+		return nil, err
+	}
 
-	// Busca em empresas
-	query := `
-		SELECT cnpj, razao_social
-		FROM empresas
-		WHERE razao_social LIKE ? OR nome_fantasia LIKE ?
-		LIMIT ?
-	`
-
-	rows, err := db.Query(query, "%"+nome+"%", "%"+nome+"%", limite)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var cnpj, razao string
-			if err := rows.Scan(&cnpj, &razao); err == nil {
-				results = append(results, models.SearchResult{
-					ID:    cnpj,
-					Label: razao,
-					Type:  "PJ",
-				})
-			}
+	var r importer.RelatorioQualidade
+	if err := json.Unmarshal([]byte(relatorio), &r); err != nil {
+		return nil, fmt.Errorf("relatório de qualidade inválido: %w", err)
+	}
+	return r.Resumo(10), nil
+}
+
+// Buscar faz a busca ranqueada por nome ou CNPJ no índice de busca, com
+// filtros, paginação e facetas. As facetas de situação e CNAE trazem a
+// descrição dos códigos.
+func (s *RedeService) Buscar(ctx context.Context, consulta search.Consulta) (*search.Resultado, error) {
+	resultado, err := search.NewBuscador(database.GetDBSearch(), database.NewDialect()).Buscar(ctx, consulta)
+	if err != nil {
+		return nil, err
+	}
+
+	// Estabelecimentos fora do índice (filiais sem nome fantasia) ainda são
+	// encontrados pelo CNPJ, se a consulta não tiver filtros
+	cnpj := cpfcnpj.LimparCNPJ(consulta.Texto)
+	semFiltros := len(consulta.Tipos)+len(consulta.UF)+len(consulta.CNAE)+len(consulta.Situacao) == 0
+	if resultado.Total == 0 && semFiltros && cpfcnpj.PareceCNPJ(cnpj) && cpfcnpj.ValidarCNPJ(cnpj) != "" {
+		if dados := s.GetDadosCNPJ(ctx, cnpj, ""); dados != nil {
+			resultado.Total = 1
+			resultado.Resultados = append(resultado.Resultados, models.SearchResult{
+				ID:        "PJ_" + cnpj,
+				Label:     dados.RazaoSocial,
+				Type:      "PJ",
+				CNPJ:      cnpj,
+				UF:        dados.UF,
+				Municipio: dados.Municipio,
+				Score:     1,
+			})
 		}
 	}
 
-	// Busca em sócios se ainda tiver espaço
-	if len(results) < limite {
-		query = `
-			SELECT DISTINCT identificador_socio, nome_socio
-			FROM socios
-			WHERE nome_socio LIKE ?
-			LIMIT ?
-		`
-
-		rows, err = db.Query(query, "%"+nome+"%", limite-len(results))
-		if err == nil {
-			defer rows.Close()
-			for rows.Next() {
-				var id, nomeSocio string
-				if err := rows.Scan(&id, &nomeSocio); err == nil {
-					results = append(results, models.SearchResult{
-						ID:    id,
-						Label: nomeSocio,
-						Type:  "PF",
-					})
+	if len(resultado.Facetas) > 0 {
+		if dic := database.GetDicionarios(); dic != nil {
+			for coluna, descricoes := range map[string]map[string]string{"situacao": dic.SituacaoCadastral, "cnae": dic.CNAE} {
+				for i, f := range resultado.Facetas[coluna] {
+					resultado.Facetas[coluna][i].Descricao = descricoes[f.Valor]
 				}
 			}
 		}
 	}
+	return resultado, nil
+}
+
+// Mapa localiza as empresas (nós PJ) do grafo pelo endereço normalizado e
+// retorna os pontos em GeoJSON. Até GeocodeMax empresas são localizadas; as
+// demais são marcadas com Truncado. Retorna endereco.ErrBaseAusente se a
+// base de endereços não estiver configurada.
+func (s *RedeService) Mapa(ctx context.Context, nodes []models.Node) (*models.GeoJSON, error) {
+	mapa := &models.GeoJSON{Type: "FeatureCollection", Features: make([]models.GeoJSONFeature, 0)}
+
+	var empresas []models.Node
+	vistos := make(map[string]bool)
+	for _, no := range nodes {
+		if !strings.HasPrefix(no.ID, "PJ_") || vistos[no.ID] {
+			continue
+		}
+		vistos[no.ID] = true
+		empresas = append(empresas, no)
+	}
+	if limite := max(s.cfg.GeocodeMax, 0); len(empresas) > limite {
+		mapa.Truncado = true
+		mapa.Mensagem = fmt.Sprintf("Apenas %d de %d empresas localizadas (geocode_max)", limite, len(empresas))
+		empresas = empresas[:limite]
+	}
+	if len(empresas) == 0 {
+		return mapa, nil
+	}
 
-	return results, nil
+	cnpjs := make([]string, len(empresas))
+	for i, no := range empresas {
+		cnpjs[i] = strings.TrimPrefix(no.ID, "PJ_")
+	}
+	localizacoes, err := endereco.NewGeocodificador(database.GetDBEndereco(), database.NewDialect()).Localizar(ctx, cnpjs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, no := range empresas {
+		l, ok := localizacoes[cnpjs[i]]
+		if !ok {
+			mapa.NaoLocalizados = append(mapa.NaoLocalizados, no.ID)
+			continue
+		}
+		label := no.Label
+		if label == "" {
+			label = cnpjs[i]
+		}
+		mapa.Features = append(mapa.Features, models.GeoJSONFeature{
+			Type:     "Feature",
+			Geometry: models.GeoJSONGeometry{Type: "Point", Coordinates: []float64{l.Longitude, l.Latitude}},
+			Properties: map[string]interface{}{
+				"id":       no.ID,
+				"label":    label,
+				"cnpj":     cnpjs[i],
+				"endereco": l.Endereco.Texto(),
+				"cep":      l.Endereco.CEP,
+				"precisao": l.Precisao,
+			},
+		})
+	}
+	return mapa, nil
 }
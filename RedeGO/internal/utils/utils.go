@@ -178,7 +178,8 @@ func IsNumeric(s string) bool {
 	return len(s) > 0
 }
 
-// ExtractDigits extrai apenas dígitos de uma string
+// ExtractDigits extrai apenas dígitos de uma string. Para CNPJ, que pode ser
+// alfanumérico, use cpfcnpj.LimparCNPJ
 func ExtractDigits(s string) string {
 	var result strings.Builder
 	for _, c := range s {
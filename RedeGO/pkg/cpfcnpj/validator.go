@@ -6,7 +6,10 @@ import (
 	"strings"
 )
 
-var digitRegex = regexp.MustCompile(`\d`)
+var (
+	digitRegex     = regexp.MustCompile(`\d`)
+	cnpjSemMascara = regexp.MustCompile(`^[0-9A-Z]{12}[0-9]{2}$`)
+)
 
 // ValidarCPF valida CPFs, retornando apenas a string de números válida
 func ValidarCPF(cpf string) string {
@@ -66,15 +69,19 @@ func ValidarCPF(cpf string) string {
 	return cpf
 }
 
-// ValidarCNPJ valida CNPJs, retornando apenas a string de números válida
+// ValidarCNPJ valida CNPJs numéricos ou alfanuméricos (a partir de julho de
+// 2026), retornando o CNPJ sem máscara e com letras maiúsculas. Um radical de
+// 8 posições recebe a ordem 0001 e os dígitos verificadores da matriz. Como
+// letras fazem parte do CNPJ, texto junto ao número não é descartado:
+// "CNPJ 11.222.333/0001-81" é inválido.
 func ValidarCNPJ(cnpj string) string {
-	// Extrai apenas dígitos
-	digits := digitRegex.FindAllString(cnpj, -1)
-	cnpj = strings.Join(digits, "")
-
+	cnpj = LimparCNPJ(cnpj)
 	if cnpj == "" {
 		return ""
 	}
+	if CNPJAlfanumerico(cnpj) {
+		return validarCNPJAlfanumerico(cnpj)
+	}
 
 	cnpjOriginal := cnpj
 
@@ -102,59 +109,92 @@ func ValidarCNPJ(cnpj string) string {
 		cnpj = strings.Repeat("0", 14-len(cnpj)) + cnpj
 	}
 
-	// Converte para slice de inteiros
-	inteiros := make([]int, 14)
-	for i, c := range cnpj {
-		inteiros[i], _ = strconv.Atoi(string(c))
+	dv := DigitosVerificadoresCNPJ(cnpj[:12])
+	if cnpj[12:] == dv {
+		return cnpj
 	}
 
-	// Gera os 2 dígitos verificadores
-	novo := make([]int, 12)
-	copy(novo, inteiros[:12])
+	// Se tinha 8 dígitos da matriz, retorna com dígitos verificadores corretos
+	if len(cnpjOriginal) == 8 {
+		return cnpj[:12] + dv
+	}
 
-	prod := []int{5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	return ""
+}
 
-	for len(novo) < 14 {
-		r := 0
-		for i, v := range novo {
-			r += v * prod[i]
+// validarCNPJAlfanumerico valida um CNPJ com letras, que não admite zeros à
+// esquerda implícitos: aceita o radical (8) ou o CNPJ completo (14). O
+// radical precisa de ao menos um dígito, para que palavras de 8 letras
+// ("CONTADOR") não virem CNPJ.
+func validarCNPJAlfanumerico(cnpj string) string {
+	switch len(cnpj) {
+	case 8:
+		if !digitRegex.MatchString(cnpj) {
+			return ""
 		}
-		r = r % 11
-
-		var f int
-		if r > 1 {
-			f = 11 - r
-		} else {
-			f = 0
+		base := cnpj + "0001"
+		return base + DigitosVerificadoresCNPJ(base)
+	case 14:
+		if PareceCNPJ(cnpj) && cnpj[12:] == DigitosVerificadoresCNPJ(cnpj[:12]) {
+			return cnpj
 		}
-
-		novo = append(novo, f)
-		prod = append([]int{6}, prod...)
 	}
+	return ""
+}
 
-	// Verifica se o número gerado coincide com o original
-	valid := true
-	for i := range inteiros {
-		if novo[i] != inteiros[i] {
-			valid = false
-			break
+// DigitosVerificadoresCNPJ calcula os 2 dígitos verificadores da base de 12
+// posições. Cada caractere vale seu código ASCII menos 48 (0-9 valem 0-9 e
+// A-Z valem 17-42), com os pesos e o módulo 11 do CNPJ numérico.
+func DigitosVerificadoresCNPJ(base string) string {
+	valores := make([]int, 0, 14)
+	for _, c := range base {
+		valores = append(valores, int(c)-48)
+	}
+
+	pesos := []int{5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	dv := ""
+	for len(valores) < 14 {
+		soma := 0
+		for i, v := range valores {
+			soma += v * pesos[i]
+		}
+		d := 0
+		if r := soma % 11; r > 1 {
+			d = 11 - r
 		}
+		valores = append(valores, d)
+		dv += strconv.Itoa(d)
+		pesos = append([]int{6}, pesos...)
 	}
+	return dv
+}
 
-	if valid {
-		return cnpj
+// LimparCNPJ remove a máscara (pontos, barra, hífen, espaços) e converte as
+// letras para maiúsculas, mantendo apenas 0-9 e A-Z
+func LimparCNPJ(cnpj string) string {
+	var b strings.Builder
+	for _, c := range strings.ToUpper(cnpj) {
+		if (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') {
+			b.WriteRune(c)
+		}
 	}
+	return b.String()
+}
 
-	// Se tinha 8 dígitos da matriz, retorna com dígitos verificadores corretos
-	if len(cnpjOriginal) == 8 {
-		result := cnpj[:12] + strconv.Itoa(novo[12]) + strconv.Itoa(novo[13])
-		return result
-	}
+// CNPJAlfanumerico indica se o CNPJ (sem máscara) contém letras
+func CNPJAlfanumerico(cnpj string) bool {
+	return strings.IndexFunc(cnpj, func(c rune) bool { return c >= 'A' && c <= 'Z' }) >= 0
+}
 
-	return ""
+// PareceCNPJ indica se o valor tem o formato de um CNPJ sem máscara: 12
+// posições alfanuméricas seguidas de 2 dígitos. Não confere os dígitos
+// verificadores.
+func PareceCNPJ(cnpj string) bool {
+	return cnpjSemMascara.MatchString(cnpj)
 }
 
-// CNPJFormatado formata um CNPJ no padrão XX.XXX.XXX/XXXX-XX
+// CNPJFormatado formata um CNPJ no padrão XX.XXX.XXX/XXXX-XX, numérico ou
+// alfanumérico
 func CNPJFormatado(cnpj string) string {
 	if len(cnpj) != 14 {
 		return cnpj
@@ -38,6 +38,15 @@ func TestValidarCNPJ(t *testing.T) {
 		{"CNPJ vazio", "", ""},
 		{"CNPJ com 8 dígitos (radical)", "11222333", "11222333000181"},
 		{"CNPJ curto", "123", ""},
+		{"CNPJ alfanumérico", "12ABC34501DE35", "12ABC34501DE35"},
+		{"CNPJ alfanumérico com máscara", "12.ABC.345/01DE-35", "12ABC34501DE35"},
+		{"CNPJ alfanumérico minúsculo", "12.abc.345/01de-35", "12ABC34501DE35"},
+		{"CNPJ alfanumérico com DV errado", "12ABC34501DE36", ""},
+		{"CNPJ alfanumérico com letra no DV", "12ABC34501DE3A", ""},
+		{"CNPJ alfanumérico curto", "ABC34501DE35", ""},
+		{"CNPJ alfanumérico com 8 posições (radical)", "12ABC345", "12ABC345000188"},
+		{"palavra de 8 letras", "CONTADOR", ""},
+		{"CNPJ com texto junto", "CNPJ 11.222.333/0001-81", ""},
 	}
 
 	for _, tt := range tests {
@@ -57,6 +66,7 @@ func TestCNPJFormatado(t *testing.T) {
 		expected string
 	}{
 		{"CNPJ válido", "11222333000181", "11.222.333/0001-81"},
+		{"CNPJ alfanumérico", "12ABC34501DE35", "12.ABC.345/01DE-35"},
 		{"CNPJ inválido", "123", "123"},
 	}
 
@@ -70,6 +80,55 @@ func TestCNPJFormatado(t *testing.T) {
 	}
 }
 
+func TestDigitosVerificadoresCNPJ(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		// Exemplo da especificação da Receita para o CNPJ alfanumérico
+		{"base alfanumérica", "12ABC34501DE", "35"},
+		{"base numérica", "112223330001", "81"},
+		{"base só com letras", "ABCDEFGHIJKL", "80"},
+		{"base zerada", "000000000000", "00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DigitosVerificadoresCNPJ(tt.input)
+			if result != tt.expected {
+				t.Errorf("DigitosVerificadoresCNPJ(%q) = %q, esperado %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLimparCNPJ(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		parece   bool
+	}{
+		{"numérico com máscara", "11.222.333/0001-81", "11222333000181", true},
+		{"alfanumérico com máscara", " 12.abc.345/01de-35 ", "12ABC34501DE35", true},
+		{"letra no DV", "12ABC34501DE3A", "12ABC34501DE3A", false},
+		{"CPF", "123.456.789-09", "12345678909", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := LimparCNPJ(tt.input)
+			if result != tt.expected {
+				t.Errorf("LimparCNPJ(%q) = %q, esperado %q", tt.input, result, tt.expected)
+			}
+			if parece := PareceCNPJ(result); parece != tt.parece {
+				t.Errorf("PareceCNPJ(%q) = %v, esperado %v", result, parece, tt.parece)
+			}
+		})
+	}
+}
+
 func TestRemoveCPFFinal(t *testing.T) {
 	tests := []struct {
 		name     string
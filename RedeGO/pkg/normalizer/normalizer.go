@@ -0,0 +1,424 @@
+// Package normalizer normaliza os campos dos dados públicos do CNPJ a partir
+// de metadados por tabela. É usado pelo importador (CSV → banco) e pela
+// migração SQLite → PostgreSQL; os metadados vêm de um schema declarativo
+// (schema.yaml embutido, que pode ser estendido por um arquivo YAML/JSON).
+package normalizer
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/peder1981/rede-cnpj/RedeGO/pkg/cpfcnpj"
+)
+
+// Motivos de rejeição de campo, informados ao Relator
+const (
+	MotivoObrigatorioVazio  = "obrigatorio_vazio"
+	MotivoValorZerado       = "valor_zerado"
+	MotivoDataInvalida      = "data_invalida"
+	MotivoDataForaIntervalo = "data_fora_intervalo"
+	MotivoTamanhoInvalido   = "tamanho_invalido"
+	MotivoEmailInvalido     = "email_invalido"
+	MotivoUFInvalida        = "uf_invalida"
+	MotivoPadraoInvalido    = "padrao_invalido"
+	MotivoValorInvalido     = "valor_invalido"
+	MotivoTruncado          = "truncado"
+	MotivoNegativo          = "negativo"
+)
+
+var (
+	naoDigitos = regexp.MustCompile(`[^0-9]`)
+	emailRegex = regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}$`)
+)
+
+// FieldType representa o tipo de um campo no banco de dados
+type FieldType string
+
+const (
+	FieldTypeDate    FieldType = "DATE"
+	FieldTypeVarchar FieldType = "VARCHAR"
+	FieldTypeText    FieldType = "TEXT"
+	FieldTypeNumeric FieldType = "NUMERIC"
+	FieldTypeInteger FieldType = "INTEGER"
+	FieldTypeCNPJ    FieldType = "CNPJ"
+	FieldTypeCPF     FieldType = "CPF"
+	FieldTypeCEP     FieldType = "CEP"
+	FieldTypeEmail   FieldType = "EMAIL"
+	FieldTypeUF      FieldType = "UF"
+	FieldTypeCode    FieldType = "CODE"
+	FieldTypePhone   FieldType = "PHONE"
+)
+
+// FieldMetadata contém metadados sobre um campo
+type FieldMetadata struct {
+	Name        string
+	Type        FieldType
+	MaxLength   int
+	Required    bool
+	Pattern     *regexp.Regexp
+	ValidValues []string
+	Rules       []Regra // aplicadas em ordem ao valor, antes da normalização do tipo
+}
+
+// Relator recebe os valores não vazios convertidos em NULL ou truncados
+type Relator interface {
+	Registrar(tabela, campo, motivo, valor string)
+}
+
+// Normalizer é responsável por normalizar dados baseado em metadados
+type Normalizer struct {
+	metadata map[string]FieldMetadata
+
+	// Relatório que recebe os valores rejeitados, identificados pela tabela
+	relatorio Relator
+	tabela    string
+}
+
+// NewNormalizer cria um novo normalizador
+func NewNormalizer() *Normalizer {
+	return &Normalizer{
+		metadata: make(map[string]FieldMetadata),
+	}
+}
+
+// ComRelatorio faz o normalizador registrar em r, sob o nome da tabela, cada
+// valor não vazio convertido em NULL ou truncado
+func (n *Normalizer) ComRelatorio(tabela string, r Relator) *Normalizer {
+	n.tabela = tabela
+	n.relatorio = r
+	return n
+}
+
+// rejeitar registra o valor bruto no relatório, se houver
+func (n *Normalizer) rejeitar(campo, motivo, valor string) {
+	if n.relatorio != nil && motivo != "" {
+		n.relatorio.Registrar(n.tabela, campo, motivo, valor)
+	}
+}
+
+// RegisterField registra metadados de um campo
+func (n *Normalizer) RegisterField(field FieldMetadata) {
+	n.metadata[field.Name] = field
+}
+
+// NormalizeString normaliza uma string baseado nos metadados do campo
+func (n *Normalizer) NormalizeString(fieldName string, value string) sql.NullString {
+	meta, exists := n.metadata[fieldName]
+	if !exists {
+		// Se não tem metadados, apenas sanitiza UTF-8
+		return sql.NullString{
+			String: SanitizeString(value),
+			Valid:  value != "",
+		}
+	}
+
+	// Remove espaços em branco
+	trimmed := strings.TrimSpace(value)
+
+	// Regras do schema
+	for _, regra := range meta.Rules {
+		var motivo string
+		if trimmed, motivo = regra(trimmed); motivo != "" {
+			n.rejeitar(fieldName, motivo, value)
+			return sql.NullString{Valid: false}
+		}
+	}
+
+	// Se campo obrigatório e vazio, retorna inválido
+	if meta.Required && trimmed == "" {
+		n.rejeitar(fieldName, MotivoObrigatorioVazio, value)
+		return sql.NullString{Valid: false}
+	}
+
+	// Se campo opcional e vazio, retorna NULL
+	if !meta.Required && trimmed == "" {
+		return sql.NullString{Valid: false}
+	}
+
+	// Normaliza baseado no tipo
+	var result sql.NullString
+	var motivo string
+	switch meta.Type {
+	case FieldTypeDate:
+		result, motivo = n.normalizeDate(trimmed)
+	case FieldTypeCNPJ:
+		result, motivo = n.normalizeCNPJ(trimmed)
+	case FieldTypeCPF:
+		result, motivo = n.normalizeCPF(trimmed)
+	case FieldTypeCEP:
+		result, motivo = n.normalizeCEP(trimmed)
+	case FieldTypeEmail:
+		result, motivo = n.normalizeEmail(trimmed)
+	case FieldTypeUF:
+		result, motivo = n.normalizeUF(trimmed)
+	case FieldTypeCode:
+		result, motivo = n.normalizeCode(trimmed, meta)
+	case FieldTypePhone:
+		result, motivo = n.normalizePhone(trimmed)
+	case FieldTypeVarchar, FieldTypeText:
+		result, motivo = n.normalizeText(trimmed, meta)
+	default:
+		return sql.NullString{
+			String: SanitizeString(trimmed),
+			Valid:  true,
+		}
+	}
+
+	// Valores permitidos (enums)
+	if result.Valid && len(meta.ValidValues) > 0 && !contem(meta.ValidValues, result.String) {
+		n.rejeitar(fieldName, MotivoValorInvalido, value)
+		return sql.NullString{Valid: false}
+	}
+
+	n.rejeitar(fieldName, motivo, value)
+	return result
+}
+
+func contem(lista []string, valor string) bool {
+	for _, v := range lista {
+		if v == valor {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeNullString normaliza um sql.NullString
+func (n *Normalizer) NormalizeNullString(fieldName string, ns sql.NullString) sql.NullString {
+	if !ns.Valid {
+		return sql.NullString{Valid: false}
+	}
+	return n.NormalizeString(fieldName, ns.String)
+}
+
+// normalizeDate normaliza campos de data
+func (n *Normalizer) normalizeDate(value string) (sql.NullString, string) {
+	// Valores inválidos
+	invalidValues := []string{"", "0", "00", "000", "0000", "00000000"}
+	for _, invalid := range invalidValues {
+		if value == invalid {
+			return sql.NullString{Valid: false}, MotivoValorZerado
+		}
+	}
+
+	// Formato YYYYMMDD (8 dígitos)
+	if len(value) == 8 {
+		// Verifica se todos são dígitos
+		for _, c := range value {
+			if c < '0' || c > '9' {
+				return sql.NullString{Valid: false}, MotivoDataInvalida
+			}
+		}
+
+		year := value[0:4]
+		month := value[4:6]
+		day := value[6:8]
+
+		// Valida componentes
+		if year < "1900" || year > "2100" {
+			return sql.NullString{Valid: false}, MotivoDataForaIntervalo
+		}
+		if month < "01" || month > "12" {
+			return sql.NullString{Valid: false}, MotivoDataForaIntervalo
+		}
+		if day < "01" || day > "31" {
+			return sql.NullString{Valid: false}, MotivoDataForaIntervalo
+		}
+
+		// Retorna formatado como YYYY-MM-DD
+		return sql.NullString{
+			String: year + "-" + month + "-" + day,
+			Valid:  true,
+		}, ""
+	}
+
+	// Formato YYYY-MM-DD (já correto)
+	if len(value) == 10 && value[4] == '-' && value[7] == '-' {
+		return sql.NullString{String: value, Valid: true}, ""
+	}
+
+	// Formato inválido
+	return sql.NullString{Valid: false}, MotivoDataInvalida
+}
+
+// normalizeCNPJ normaliza CNPJ numérico ou alfanumérico (remove máscara,
+// converte letras para maiúsculas, valida tamanho e formato)
+func (n *Normalizer) normalizeCNPJ(value string) (sql.NullString, string) {
+	cnpj := cpfcnpj.LimparCNPJ(value)
+
+	// CNPJ deve ter 14 posições
+	if len(cnpj) != 14 {
+		return sql.NullString{Valid: false}, MotivoTamanhoInvalido
+	}
+
+	// 12 posições alfanuméricas e 2 dígitos verificadores
+	if !cpfcnpj.PareceCNPJ(cnpj) {
+		return sql.NullString{Valid: false}, MotivoPadraoInvalido
+	}
+
+	// CNPJ não pode ser todo zeros
+	if cnpj == "00000000000000" {
+		return sql.NullString{Valid: false}, MotivoValorZerado
+	}
+
+	return sql.NullString{String: cnpj, Valid: true}, ""
+}
+
+// normalizeCPF normaliza CPF (remove máscara, valida tamanho)
+func (n *Normalizer) normalizeCPF(value string) (sql.NullString, string) {
+	// Remove caracteres não numéricos
+	cpf := naoDigitos.ReplaceAllString(value, "")
+
+	// CPF deve ter 11 dígitos
+	if len(cpf) != 11 {
+		return sql.NullString{Valid: false}, MotivoTamanhoInvalido
+	}
+
+	// CPF não pode ser todo zeros
+	if cpf == "00000000000" {
+		return sql.NullString{Valid: false}, MotivoValorZerado
+	}
+
+	return sql.NullString{String: cpf, Valid: true}, ""
+}
+
+// normalizeCEP normaliza CEP (remove máscara, valida tamanho)
+func (n *Normalizer) normalizeCEP(value string) (sql.NullString, string) {
+	// Remove caracteres não numéricos
+	cep := naoDigitos.ReplaceAllString(value, "")
+
+	// CEP deve ter 8 dígitos
+	if len(cep) != 8 {
+		return sql.NullString{Valid: false}, MotivoTamanhoInvalido
+	}
+
+	// CEP não pode ser todo zeros
+	if cep == "00000000" {
+		return sql.NullString{Valid: false}, MotivoValorZerado
+	}
+
+	return sql.NullString{String: cep, Valid: true}, ""
+}
+
+// normalizeEmail normaliza email
+func (n *Normalizer) normalizeEmail(value string) (sql.NullString, string) {
+	// Email vazio é válido (campo opcional)
+	if value == "" {
+		return sql.NullString{Valid: false}, ""
+	}
+
+	// Converte para minúsculas
+	email := strings.ToLower(value)
+
+	// Validação básica de email
+	if !emailRegex.MatchString(email) {
+		return sql.NullString{Valid: false}, MotivoEmailInvalido
+	}
+
+	return sql.NullString{String: SanitizeString(email), Valid: true}, ""
+}
+
+// normalizeUF normaliza UF (sigla de estado)
+func (n *Normalizer) normalizeUF(value string) (sql.NullString, string) {
+	uf := strings.ToUpper(strings.TrimSpace(value))
+
+	// Lista de UFs válidas
+	validUFs := map[string]bool{
+		"AC": true, "AL": true, "AP": true, "AM": true, "BA": true,
+		"CE": true, "DF": true, "ES": true, "GO": true, "MA": true,
+		"MT": true, "MS": true, "MG": true, "PA": true, "PB": true,
+		"PR": true, "PE": true, "PI": true, "RJ": true, "RN": true,
+		"RS": true, "RO": true, "RR": true, "SC": true, "SP": true,
+		"SE": true, "TO": true, "EX": true, // EX = Exterior
+	}
+
+	if !validUFs[uf] {
+		return sql.NullString{Valid: false}, MotivoUFInvalida
+	}
+
+	return sql.NullString{String: uf, Valid: true}, ""
+}
+
+// normalizeCode normaliza códigos (CNAE, natureza jurídica, etc)
+func (n *Normalizer) normalizeCode(value string, meta FieldMetadata) (sql.NullString, string) {
+	// Remove espaços
+	code := strings.TrimSpace(value)
+
+	// Código vazio ou "0" é inválido
+	if code == "" || code == "0" || code == "00" {
+		return sql.NullString{Valid: false}, MotivoValorZerado
+	}
+
+	// Valida tamanho máximo
+	motivo := ""
+	if meta.MaxLength > 0 && len(code) > meta.MaxLength {
+		code = code[:meta.MaxLength]
+		motivo = MotivoTruncado
+	}
+
+	// Valida padrão se especificado
+	if meta.Pattern != nil && !meta.Pattern.MatchString(code) {
+		return sql.NullString{Valid: false}, MotivoPadraoInvalido
+	}
+
+	return sql.NullString{String: code, Valid: true}, motivo
+}
+
+// normalizePhone normaliza telefone (remove caracteres não numéricos)
+func (n *Normalizer) normalizePhone(value string) (sql.NullString, string) {
+	// Remove caracteres não numéricos
+	phone := naoDigitos.ReplaceAllString(value, "")
+
+	// Telefone vazio é válido (campo opcional)
+	if phone == "" || phone == "0" {
+		return sql.NullString{Valid: false}, MotivoValorZerado
+	}
+
+	// Telefone deve ter entre 8 e 11 dígitos
+	if len(phone) < 8 || len(phone) > 11 {
+		return sql.NullString{Valid: false}, MotivoTamanhoInvalido
+	}
+
+	return sql.NullString{String: phone, Valid: true}, ""
+}
+
+// normalizeText normaliza campos de texto
+func (n *Normalizer) normalizeText(value string, meta FieldMetadata) (sql.NullString, string) {
+	// Sanitiza UTF-8
+	text := SanitizeString(value)
+
+	// Valida tamanho máximo
+	motivo := ""
+	if meta.MaxLength > 0 && len(text) > meta.MaxLength {
+		text = text[:meta.MaxLength]
+		motivo = MotivoTruncado
+	}
+
+	// Valida padrão se especificado
+	if meta.Pattern != nil && !meta.Pattern.MatchString(text) {
+		return sql.NullString{Valid: false}, MotivoPadraoInvalido
+	}
+
+	return sql.NullString{String: text, Valid: true}, motivo
+}
+
+// NormalizeFloat64 normaliza valores numéricos
+func (n *Normalizer) NormalizeFloat64(fieldName string, value sql.NullFloat64) sql.NullFloat64 {
+	if !value.Valid {
+		return sql.NullFloat64{Valid: false}
+	}
+
+	// Valores negativos em campos que não permitem
+	meta, exists := n.metadata[fieldName]
+	if exists && meta.Type == FieldTypeNumeric {
+		// Capital social não pode ser negativo
+		if value.Float64 < 0 {
+			n.rejeitar(fieldName, MotivoNegativo, fmt.Sprint(value.Float64))
+			return sql.NullFloat64{Valid: false}
+		}
+	}
+
+	return value
+}
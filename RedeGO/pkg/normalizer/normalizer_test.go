@@ -1,4 +1,4 @@
-package main
+package normalizer
 
 import (
 	"database/sql"
@@ -82,6 +82,16 @@ func TestNormalizerCNPJ(t *testing.T) {
 			input:    "00000000000000",
 			expected: sql.NullString{Valid: false},
 		},
+		{
+			name:     "CNPJ alfanumérico com máscara",
+			input:    "12.abc.345/01DE-35",
+			expected: sql.NullString{String: "12ABC34501DE35", Valid: true},
+		},
+		{
+			name:     "CNPJ alfanumérico com letra no DV",
+			input:    "12ABC34501DE3A",
+			expected: sql.NullString{Valid: false},
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,140 @@
+package normalizer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Regra transforma o valor de um campo antes da normalização do tipo. Um
+// motivo não vazio rejeita o valor (vira NULL e é informado ao Relator).
+type Regra func(valor string) (resultado string, motivo string)
+
+// RegraConfig é a declaração de uma regra no schema
+//
+//	regras:
+//	  - regra: substituir
+//	    padrao: '[^0-9]'
+//	    por: ''
+//	  - regra: mapear
+//	    valores: {"S": "1", "N": "0"}
+type RegraConfig struct {
+	Regra   string            `yaml:"regra"`
+	Padrao  string            `yaml:"padrao,omitempty"`
+	Por     string            `yaml:"por,omitempty"`
+	Valores map[string]string `yaml:"valores,omitempty"`
+	Tamanho int               `yaml:"tamanho,omitempty"`
+	Motivo  string            `yaml:"motivo,omitempty"`
+}
+
+// FabricaRegra cria uma Regra a partir da sua declaração no schema
+type FabricaRegra func(cfg RegraConfig) (Regra, error)
+
+var (
+	regrasMu sync.RWMutex
+	regras   = map[string]FabricaRegra{
+		"maiusculas":      func(RegraConfig) (Regra, error) { return regraFunc(strings.ToUpper), nil },
+		"minusculas":      func(RegraConfig) (Regra, error) { return regraFunc(strings.ToLower), nil },
+		"substituir":      regraSubstituir,
+		"rejeitar":        regraRejeitar,
+		"mapear":          regraMapear,
+		"completar_zeros": regraCompletarZeros,
+	}
+)
+
+// RegistrarRegra disponibiliza uma nova regra para os schemas, sob o nome
+// usado no campo "regra". Substitui uma regra existente de mesmo nome.
+func RegistrarRegra(nome string, fabrica FabricaRegra) {
+	regrasMu.Lock()
+	defer regrasMu.Unlock()
+	regras[nome] = fabrica
+}
+
+// RegrasDisponiveis lista os nomes das regras registradas
+func RegrasDisponiveis() []string {
+	regrasMu.RLock()
+	defer regrasMu.RUnlock()
+	nomes := make([]string, 0, len(regras))
+	for nome := range regras {
+		nomes = append(nomes, nome)
+	}
+	sort.Strings(nomes)
+	return nomes
+}
+
+// NovaRegra cria a Regra declarada em cfg
+func NovaRegra(cfg RegraConfig) (Regra, error) {
+	regrasMu.RLock()
+	fabrica, ok := regras[cfg.Regra]
+	regrasMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("regra desconhecida %q (disponíveis: %s)", cfg.Regra, strings.Join(RegrasDisponiveis(), ", "))
+	}
+	return fabrica(cfg)
+}
+
+func regraFunc(f func(string) string) Regra {
+	return func(valor string) (string, string) {
+		return f(valor), ""
+	}
+}
+
+// regraSubstituir troca as ocorrências de padrao por "por" (sintaxe de
+// regexp.ReplaceAllString, aceita $1)
+func regraSubstituir(cfg RegraConfig) (Regra, error) {
+	re, err := regexp.Compile(cfg.Padrao)
+	if err != nil {
+		return nil, fmt.Errorf("substituir: padrão inválido: %w", err)
+	}
+	return func(valor string) (string, string) {
+		return re.ReplaceAllString(valor, cfg.Por), ""
+	}, nil
+}
+
+// regraRejeitar descarta valores que casam com padrao, com o motivo
+// declarado ou padrao_invalido
+func regraRejeitar(cfg RegraConfig) (Regra, error) {
+	re, err := regexp.Compile(cfg.Padrao)
+	if err != nil {
+		return nil, fmt.Errorf("rejeitar: padrão inválido: %w", err)
+	}
+	motivo := cfg.Motivo
+	if motivo == "" {
+		motivo = MotivoPadraoInvalido
+	}
+	return func(valor string) (string, string) {
+		if re.MatchString(valor) {
+			return valor, motivo
+		}
+		return valor, ""
+	}, nil
+}
+
+// regraMapear substitui valores exatos; os demais passam inalterados
+func regraMapear(cfg RegraConfig) (Regra, error) {
+	if len(cfg.Valores) == 0 {
+		return nil, fmt.Errorf("mapear: nenhum valor declarado")
+	}
+	return func(valor string) (string, string) {
+		if novo, ok := cfg.Valores[valor]; ok {
+			return novo, ""
+		}
+		return valor, ""
+	}, nil
+}
+
+// regraCompletarZeros completa com zeros à esquerda valores numéricos menores
+// que tamanho (ex.: cnpj_basico "1234" → "00001234")
+func regraCompletarZeros(cfg RegraConfig) (Regra, error) {
+	if cfg.Tamanho <= 0 {
+		return nil, fmt.Errorf("completar_zeros: tamanho deve ser positivo")
+	}
+	return func(valor string) (string, string) {
+		if valor == "" || len(valor) >= cfg.Tamanho || naoDigitos.MatchString(valor) {
+			return valor, ""
+		}
+		return strings.Repeat("0", cfg.Tamanho-len(valor)) + valor, ""
+	}, nil
+}
@@ -1,4 +1,4 @@
-package importer
+package normalizer
 
 import (
 	"strings"
@@ -8,8 +8,8 @@ import (
 	"golang.org/x/text/transform"
 )
 
-// sanitizeString corrige problemas de encoding em strings
-func sanitizeString(s string) string {
+// SanitizeString corrige problemas de encoding em strings
+func SanitizeString(s string) string {
 	// Se já é UTF-8 válido, retorna como está
 	if utf8.ValidString(s) {
 		return s
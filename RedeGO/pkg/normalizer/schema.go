@@ -0,0 +1,192 @@
+package normalizer
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaPadraoYAML são os metadados das tabelas da Receita, espelhando as
+// colunas criadas pelo importador
+//
+//go:embed schema.yaml
+var schemaPadraoYAML []byte
+
+// CampoSchema é a declaração de um campo no schema
+type CampoSchema struct {
+	Tipo           FieldType     `yaml:"tipo"`
+	TamanhoMaximo  int           `yaml:"tamanho_maximo,omitempty"`
+	Obrigatorio    bool          `yaml:"obrigatorio,omitempty"`
+	Padrao         string        `yaml:"padrao,omitempty"`
+	ValoresValidos []string      `yaml:"valores_validos,omitempty"`
+	Regras         []RegraConfig `yaml:"regras,omitempty"`
+}
+
+// Schema reúne os campos declarados por tabela. O arquivo é YAML (ou JSON,
+// que o parser YAML também aceita):
+//
+//	tabelas:
+//	  estabelecimento:
+//	    cep:
+//	      tipo: CEP
+//	      tamanho_maximo: 8
+//	      regras:
+//	        - regra: rejeitar
+//	          padrao: '^99999'
+type Schema struct {
+	Tabelas map[string]map[string]CampoSchema `yaml:"tabelas"`
+
+	campos map[string]map[string]FieldMetadata
+}
+
+var (
+	schemaPadraoOnce sync.Once
+	schemaPadrao     *Schema
+)
+
+// SchemaPadrao retorna o schema embutido (schema.yaml)
+func SchemaPadrao() *Schema {
+	schemaPadraoOnce.Do(func() {
+		s, err := ParseSchema(schemaPadraoYAML)
+		if err != nil {
+			panic(fmt.Sprintf("schema.yaml embutido inválido: %v", err))
+		}
+		schemaPadrao = s
+	})
+	return schemaPadrao
+}
+
+// ParseSchema lê e valida um schema YAML ou JSON
+func ParseSchema(data []byte) (*Schema, error) {
+	s := &Schema{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("schema inválido: %w", err)
+	}
+	if err := s.compilar(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CarregarSchema retorna o schema padrão estendido pelo arquivo em path: os
+// campos declarados no arquivo substituem os do padrão e tabelas novas são
+// acrescentadas. Com path vazio retorna o schema padrão.
+func CarregarSchema(path string) (*Schema, error) {
+	if path == "" {
+		return SchemaPadrao(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler schema de normalização: %w", err)
+	}
+	s, err := ParseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return SchemaPadrao().Mesclar(s), nil
+}
+
+// Mesclar retorna um novo schema com os campos de s sobrepostos pelos de outro
+func (s *Schema) Mesclar(outro *Schema) *Schema {
+	m := &Schema{
+		Tabelas: make(map[string]map[string]CampoSchema),
+		campos:  make(map[string]map[string]FieldMetadata),
+	}
+	for _, origem := range []*Schema{s, outro} {
+		for tabela, campos := range origem.Tabelas {
+			if m.Tabelas[tabela] == nil {
+				m.Tabelas[tabela] = make(map[string]CampoSchema)
+				m.campos[tabela] = make(map[string]FieldMetadata)
+			}
+			for nome, campo := range campos {
+				m.Tabelas[tabela][nome] = campo
+				m.campos[tabela][nome] = origem.campos[tabela][nome]
+			}
+		}
+	}
+	return m
+}
+
+// Normalizador cria um normalizador com os campos da tabela; tabelas não
+// declaradas (ex.: as de código/descrição) recebem um normalizador vazio
+func (s *Schema) Normalizador(tabela string) *Normalizer {
+	n := NewNormalizer()
+	for _, meta := range s.campos[tabela] {
+		n.RegisterField(meta)
+	}
+	return n
+}
+
+// compilar valida as declarações e monta os metadados de cada campo
+func (s *Schema) compilar() error {
+	s.campos = make(map[string]map[string]FieldMetadata, len(s.Tabelas))
+	for tabela, campos := range s.Tabelas {
+		s.campos[tabela] = make(map[string]FieldMetadata, len(campos))
+		for nome, campo := range campos {
+			meta, err := campo.metadados(nome)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", tabela, nome, err)
+			}
+			s.campos[tabela][nome] = meta
+		}
+	}
+	return nil
+}
+
+func (c CampoSchema) metadados(nome string) (FieldMetadata, error) {
+	meta := FieldMetadata{
+		Name:        nome,
+		Type:        c.Tipo,
+		MaxLength:   c.TamanhoMaximo,
+		Required:    c.Obrigatorio,
+		ValidValues: c.ValoresValidos,
+	}
+
+	switch c.Tipo {
+	case FieldTypeDate, FieldTypeVarchar, FieldTypeText, FieldTypeNumeric, FieldTypeInteger,
+		FieldTypeCNPJ, FieldTypeCPF, FieldTypeCEP, FieldTypeEmail, FieldTypeUF, FieldTypeCode, FieldTypePhone:
+	default:
+		return meta, fmt.Errorf("tipo desconhecido %q", c.Tipo)
+	}
+
+	if c.Padrao != "" {
+		re, err := regexp.Compile(c.Padrao)
+		if err != nil {
+			return meta, fmt.Errorf("padrão inválido: %w", err)
+		}
+		meta.Pattern = re
+	}
+
+	for _, cfg := range c.Regras {
+		regra, err := NovaRegra(cfg)
+		if err != nil {
+			return meta, err
+		}
+		meta.Rules = append(meta.Rules, regra)
+	}
+	return meta, nil
+}
+
+// GetEmpresasNormalizer retorna o normalizador para a tabela empresas
+func GetEmpresasNormalizer() *Normalizer {
+	return SchemaPadrao().Normalizador("empresas")
+}
+
+// GetEstabelecimentoNormalizer retorna o normalizador para a tabela estabelecimento
+func GetEstabelecimentoNormalizer() *Normalizer {
+	return SchemaPadrao().Normalizador("estabelecimento")
+}
+
+// GetSociosNormalizer retorna o normalizador para a tabela socios
+func GetSociosNormalizer() *Normalizer {
+	return SchemaPadrao().Normalizador("socios")
+}
+
+// GetSimplesNormalizer retorna o normalizador para a tabela simples
+func GetSimplesNormalizer() *Normalizer {
+	return SchemaPadrao().Normalizador("simples")
+}
@@ -0,0 +1,185 @@
+package normalizer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// relatorTeste guarda as rejeições como "tabela.campo.motivo"
+type relatorTeste []string
+
+func (r *relatorTeste) Registrar(tabela, campo, motivo, valor string) {
+	*r = append(*r, tabela+"."+campo+"."+motivo)
+}
+
+func TestSchemaPadrao(t *testing.T) {
+	s := SchemaPadrao()
+
+	campos := map[string]int{"empresas": 7, "estabelecimento": 31, "socios": 12, "simples": 7}
+	for tabela, esperado := range campos {
+		if n := len(s.Tabelas[tabela]); n != esperado {
+			t.Errorf("SchemaPadrao().Tabelas[%q] tem %d campos, esperado %d", tabela, n, esperado)
+		}
+	}
+
+	n := s.Normalizador("empresas")
+	if got := n.NormalizeString("cnpj_basico", "1234567-"); got.Valid {
+		t.Errorf("cnpj_basico fora do padrão aceito: %v", got)
+	}
+	if got := n.NormalizeString("cnpj_basico", "12ABC345"); got.String != "12ABC345" {
+		t.Errorf("cnpj_basico alfanumérico = %v, esperado 12ABC345", got)
+	}
+
+	// Tabelas de código/descrição não têm metadados: só sanitiza
+	if got := s.Normalizador("cnae").NormalizeString("descricao", " Texto "); got.String != " Texto " {
+		t.Errorf("Normalizador(cnae).NormalizeString() = %q, esperado %q", got.String, " Texto ")
+	}
+}
+
+func TestValoresValidos(t *testing.T) {
+	var r relatorTeste
+	n := GetSociosNormalizer().ComRelatorio("socios", &r)
+
+	if got := n.NormalizeString("identificador_de_socio", "2"); !got.Valid || got.String != "2" {
+		t.Errorf("identificador_de_socio 2 = %v, esperado válido", got)
+	}
+	if got := n.NormalizeString("identificador_de_socio", "7"); got.Valid {
+		t.Errorf("identificador_de_socio 7 = %v, esperado NULL", got)
+	}
+	if len(r) != 1 || r[0] != "socios.identificador_de_socio."+MotivoValorInvalido {
+		t.Errorf("rejeições = %v, esperado [socios.identificador_de_socio.%s]", r, MotivoValorInvalido)
+	}
+}
+
+func TestCarregarSchemaPersonalizado(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "regras.yaml")
+	yaml := `
+tabelas:
+  estabelecimento:
+    cep:
+      tipo: CEP
+      tamanho_maximo: 8
+      regras:
+        - regra: rejeitar
+          padrao: '^99999'
+          motivo: cep_ficticio
+    nome_fantasia:
+      tipo: TEXT
+      regras:
+        - regra: maiusculas
+        - regra: substituir
+          padrao: '\s+'
+          por: ' '
+  empresas:
+    cnpj_basico:
+      tipo: CODE
+      tamanho_maximo: 8
+      obrigatorio: true
+      regras:
+        - regra: completar_zeros
+          tamanho: 8
+  nova_tabela:
+    situacao:
+      tipo: CODE
+      regras:
+        - regra: mapear
+          valores: {"ATIVA": "02", "BAIXADA": "08"}
+`
+	if err := os.WriteFile(yamlPath, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := CarregarSchema(yamlPath)
+	if err != nil {
+		t.Fatalf("CarregarSchema() erro: %v", err)
+	}
+
+	var r relatorTeste
+	estab := s.Normalizador("estabelecimento").ComRelatorio("estabelecimento", &r)
+	empresas := s.Normalizador("empresas")
+	nova := s.Normalizador("nova_tabela")
+
+	tests := []struct {
+		n      *Normalizer
+		campo  string
+		valor  string
+		valido bool
+		saida  string
+	}{
+		{estab, "cep", "99999-000", false, ""},
+		{estab, "cep", "01310-100", true, "01310100"},
+		{estab, "nome_fantasia", "padaria   do  joão", true, "PADARIA DO JOÃO"},
+		{estab, "uf", "rj", true, "RJ"}, // Campo não declarado: mantém o padrão
+		{empresas, "cnpj_basico", "1234", true, "00001234"},
+		{nova, "situacao", "BAIXADA", true, "08"},
+		{nova, "situacao", "SUSPENSA", true, "SUSPENSA"},
+	}
+	for _, tt := range tests {
+		got := tt.n.NormalizeString(tt.campo, tt.valor)
+		if got.Valid != tt.valido || got.String != tt.saida {
+			t.Errorf("NormalizeString(%q, %q) = %v, esperado {%q %v}", tt.campo, tt.valor, got, tt.saida, tt.valido)
+		}
+	}
+	if len(r) != 1 || r[0] != "estabelecimento.cep.cep_ficticio" {
+		t.Errorf("rejeições = %v, esperado [estabelecimento.cep.cep_ficticio]", r)
+	}
+
+	// O schema padrão não é alterado pela extensão
+	if got := GetEmpresasNormalizer().NormalizeString("cnpj_basico", "1234"); got.Valid {
+		t.Errorf("schema padrão alterado: cnpj_basico 1234 = %v", got)
+	}
+}
+
+func TestCarregarSchemaJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "regras.json")
+	json := `{"tabelas": {"socios": {"nome_socio": {"tipo": "TEXT", "obrigatorio": true, "regras": [{"regra": "minusculas"}]}}}}`
+	if err := os.WriteFile(path, []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := CarregarSchema(path)
+	if err != nil {
+		t.Fatalf("CarregarSchema() erro: %v", err)
+	}
+	if got := s.Normalizador("socios").NormalizeString("nome_socio", "FULANO"); got.String != "fulano" {
+		t.Errorf("nome_socio = %q, esperado %q", got.String, "fulano")
+	}
+}
+
+func TestParseSchemaInvalido(t *testing.T) {
+	tests := []struct {
+		nome   string
+		yaml   string
+		trecho string
+	}{
+		{"tipo desconhecido", "tabelas: {t: {c: {tipo: BOOL}}}", `tipo desconhecido "BOOL"`},
+		{"padrão inválido", "tabelas: {t: {c: {tipo: TEXT, padrao: '('}}}", "padrão inválido"},
+		{"regra desconhecida", "tabelas: {t: {c: {tipo: TEXT, regras: [{regra: inverter}]}}}", `regra desconhecida "inverter"`},
+		{"regra sem parâmetro", "tabelas: {t: {c: {tipo: CODE, regras: [{regra: completar_zeros}]}}}", "tamanho deve ser positivo"},
+	}
+	for _, tt := range tests {
+		_, err := ParseSchema([]byte(tt.yaml))
+		if err == nil || !strings.Contains(err.Error(), tt.trecho) {
+			t.Errorf("%s: ParseSchema() erro = %v, esperado conter %q", tt.nome, err, tt.trecho)
+		}
+	}
+}
+
+func TestRegistrarRegra(t *testing.T) {
+	RegistrarRegra("sem_pontuacao", func(RegraConfig) (Regra, error) {
+		return func(valor string) (string, string) {
+			return strings.Trim(valor, ".,;"), ""
+		}, nil
+	})
+
+	s, err := ParseSchema([]byte("tabelas: {t: {c: {tipo: TEXT, regras: [{regra: sem_pontuacao}]}}}"))
+	if err != nil {
+		t.Fatalf("ParseSchema() erro: %v", err)
+	}
+	if got := s.Normalizador("t").NormalizeString("c", "...LTDA."); got.String != "LTDA" {
+		t.Errorf("NormalizeString() = %q, esperado %q", got.String, "LTDA")
+	}
+}